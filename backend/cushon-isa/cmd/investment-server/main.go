@@ -10,12 +10,30 @@ import (
 
 	"net/http"
 
+	"github.com/stcol316/cushon-isa/internal/account"
+	"github.com/stcol316/cushon-isa/internal/aml"
+	"github.com/stcol316/cushon-isa/internal/charges"
 	"github.com/stcol316/cushon-isa/internal/config"
 	"github.com/stcol316/cushon-isa/internal/customer"
 	"github.com/stcol316/cushon-isa/internal/database"
+	"github.com/stcol316/cushon-isa/internal/employee"
+	"github.com/stcol316/cushon-isa/internal/employer"
+	"github.com/stcol316/cushon-isa/internal/encryption"
 	"github.com/stcol316/cushon-isa/internal/fund"
 	"github.com/stcol316/cushon-isa/internal/investment"
+	"github.com/stcol316/cushon-isa/internal/kyc"
+	"github.com/stcol316/cushon-isa/internal/ledger"
+	"github.com/stcol316/cushon-isa/internal/lifetimeisa"
+	"github.com/stcol316/cushon-isa/internal/performance"
+	"github.com/stcol316/cushon-isa/internal/reconciliation"
+	"github.com/stcol316/cushon-isa/internal/riskprofile"
 	"github.com/stcol316/cushon-isa/internal/server"
+	"github.com/stcol316/cushon-isa/internal/statement"
+	"github.com/stcol316/cushon-isa/internal/transfer"
+	"github.com/stcol316/cushon-isa/internal/valuation"
+
+	// Note: Embedded time zone database so the settlement cut-off works in minimal containers
+	_ "time/tzdata"
 )
 
 func main() {
@@ -34,6 +52,45 @@ func main() {
 	if dberr != nil {
 		log.Fatal(dberr)
 	}
+	niCipher, cipherErr := encryption.NewCipher(cfg.NIEncryptionKey)
+	if cipherErr != nil {
+		log.Fatalf("Failed to create NI number cipher: %v", cipherErr)
+	}
+
+	//Note: Swappable KYC provider
+	var kycProvider kyc.KYCProvider
+	switch cfg.KYCProvider {
+	case "stub":
+		kycProvider = kyc.NewStubProvider()
+	default:
+		log.Fatalf("Unknown KYC provider: %s", cfg.KYCProvider)
+	}
+
+	//Note: Swappable email provider. The log provider sends nothing so is only allowed in development
+	var notifier customer.Notifier
+	switch cfg.EmailProvider {
+	case "smtp":
+		smtpNotifier, err := customer.NewSMTPNotifier(customer.SMTPConfig{
+			Host:            cfg.SMTPHost,
+			Port:            cfg.SMTPPort,
+			Username:        cfg.SMTPUsername,
+			Password:        cfg.SMTPPassword,
+			From:            cfg.EmailFrom,
+			VerificationURL: cfg.EmailVerificationURL,
+		})
+		if err != nil {
+			log.Fatalf("Failed to create SMTP notifier: %v", err)
+		}
+		notifier = smtpNotifier
+	case "log":
+		if cfg.Environment != "development" {
+			log.Fatalf("Email provider %q sends nothing and is only allowed in development", cfg.EmailProvider)
+		}
+		notifier = customer.NewLogNotifier()
+	default:
+		log.Fatalf("Unknown email provider: %s", cfg.EmailProvider)
+	}
+
 	fmt.Println("Starting Healthcheck go routine")
 	db_service.StartHealthCheck(1 * time.Minute)
 
@@ -42,20 +99,121 @@ func main() {
 	customerRepo := customer.NewRepository(db_service.DB())
 	fundRepo := fund.NewRepository(db_service.DB())
 	investmentRepo := investment.NewRepository(db_service.DB())
+	kycRepo := kyc.NewRepository(db_service.DB())
+	amlRepo := aml.NewRepository(db_service.DB())
+	riskProfileRepo := riskprofile.NewRepository(db_service.DB())
+	statementRepo := statement.NewRepository(db_service.DB())
+	performanceRepo := performance.NewRepository(db_service.DB())
+	valuationRepo := valuation.NewRepository(db_service.DB())
+	chargesRepo := charges.NewRepository(db_service.DB())
+	ledgerRepo := ledger.NewRepository(db_service.DB())
+	reconciliationRepo := reconciliation.NewRepository(db_service.DB())
+	transferRepo := transfer.NewRepository(db_service.DB())
+	lifetimeISARepo := lifetimeisa.NewRepository(db_service.DB())
+	accountRepo := account.NewRepository(db_service.DB())
+	employerRepo := employer.NewRepository(db_service.DB())
+	employeeRepo := employee.NewRepository(db_service.DB())
+
+	// Note: AML rules engine used to screen investments
+	amlEngine := aml.NewEngine(aml.Config{
+		SingleDepositHoldAbove:   cfg.AMLSingleDepositHoldAbove,
+		SingleDepositRejectAbove: cfg.AMLSingleDepositRejectAbove,
+		VelocityWindowDays:       cfg.AMLVelocityWindowDays,
+		VelocityMaxCount:         cfg.AMLVelocityMaxCount,
+		VelocityMaxAmount:        cfg.AMLVelocityMaxAmount,
+		RapidWithdrawalDays:      cfg.AMLRapidWithdrawalDays,
+	})
 
 	// Note: Service layer to handle business logic between DB and handlers
 	fmt.Println("Creating Service Layer")
-	customerService := customer.NewService(customerRepo)
+	customerService := customer.NewService(customerRepo, niCipher, notifier)
 	fundService := fund.NewService(fundRepo)
-	investmentService := investment.NewService(investmentRepo)
+	riskProfileService := riskprofile.NewService(riskProfileRepo)
+	investmentService := investment.NewService(investmentRepo, amlEngine, fundService, riskProfileService, investment.Allowances{
+		Annual:   cfg.ISAAnnualAllowance,
+		Junior:   cfg.JuniorISAAnnualAllowance,
+		Lifetime: cfg.LifetimeISAAnnualAllowance,
+	})
+	kycService := kyc.NewService(kycRepo, kycProvider, niCipher)
+	amlService := aml.NewService(amlRepo)
+	statementService := statement.NewService(statementRepo)
+	performanceService := performance.NewService(performanceRepo)
+	valuationService := valuation.NewService(valuationRepo)
+	chargesService := charges.NewService(chargesRepo)
+	ledgerService := ledger.NewService(ledgerRepo)
+	reconciliationService := reconciliation.NewService(reconciliationRepo)
+	transferService := transfer.NewService(transferRepo, investmentService, fundService, customerService)
+	lifetimeISAService := lifetimeisa.NewService(lifetimeISARepo, investmentService, fundService)
+	accountService := account.NewService(accountRepo)
+	employerService := employer.NewService(employerRepo, fundService)
+	employeeService := employee.NewService(employeeRepo)
+
+	// Note: Daily settlement batch runs at the dealing cut-off
+	settlementScheduler, settlementErr := investment.NewSettlementScheduler(investmentService, cfg.SettlementCutoff, cfg.SettlementTimezone)
+	if settlementErr != nil {
+		log.Fatalf("Failed to create settlement scheduler: %v", settlementErr)
+	}
+	schedulerCtx, stopSchedulers := context.WithCancel(context.Background())
+	defer stopSchedulers()
+	fmt.Println("Starting Settlement go routine")
+	settlementScheduler.Start(schedulerCtx)
+
+	// Note: Nightly valuation snapshots used by the value and performance endpoints
+	valuationScheduler, valuationErr := valuation.NewScheduler(valuationService, cfg.ValuationTime, cfg.ValuationTimezone)
+	if valuationErr != nil {
+		log.Fatalf("Failed to create valuation scheduler: %v", valuationErr)
+	}
+	fmt.Println("Starting Valuation go routine")
+	valuationScheduler.Start(schedulerCtx)
+
+	// Note: Nightly charge accrual after valuations, with monthly collection
+	chargesScheduler, chargesErr := charges.NewScheduler(chargesService, cfg.ChargesTime, cfg.ChargesTimezone)
+	if chargesErr != nil {
+		log.Fatalf("Failed to create charges scheduler: %v", chargesErr)
+	}
+	fmt.Println("Starting Charges go routine")
+	chargesScheduler.Start(schedulerCtx)
+
+	// Note: Nightly conversion of Junior ISAs whose holders have turned 18
+	conversionScheduler, conversionErr := customer.NewConversionScheduler(customerService, cfg.JuniorISAConversionTime, cfg.JuniorISAConversionTimezone)
+	if conversionErr != nil {
+		log.Fatalf("Failed to create junior ISA conversion scheduler: %v", conversionErr)
+	}
+	fmt.Println("Starting Junior ISA conversion go routine")
+	conversionScheduler.Start(schedulerCtx)
+
+	// Note: Daily Lifetime ISA bonus claim, picks up each month once it has ended
+	bonusScheduler, bonusErr := lifetimeisa.NewBonusScheduler(lifetimeISAService, cfg.LifetimeISABonusTime, cfg.LifetimeISABonusTimezone)
+	if bonusErr != nil {
+		log.Fatalf("Failed to create lifetime ISA bonus scheduler: %v", bonusErr)
+	}
+	fmt.Println("Starting Lifetime ISA bonus go routine")
+	bonusScheduler.Start(schedulerCtx)
 
 	// Note: Presentation layer to handle APIs
 	fmt.Println("Creating Presentation Layer")
-	customerHandler := customer.NewHandler(customerService)
+	customerHandler := customer.NewHandler(customerService, conversionScheduler)
 	fundHandler := fund.NewHandler(fundService)
-	investmentHandler := investment.NewHandler(investmentService)
+	investmentHandler := investment.NewHandler(investmentService, settlementScheduler)
+	kycHandler := kyc.NewHandler(kycService, cfg.KYCCallbackSecret)
+	amlHandler := aml.NewHandler(amlService)
+	riskProfileHandler := riskprofile.NewHandler(riskProfileService)
+	statementHandler := statement.NewHandler(statementService)
+	performanceHandler := performance.NewHandler(performanceService)
+	valuationHandler := valuation.NewHandler(valuationScheduler)
+	chargesHandler := charges.NewHandler(chargesService, chargesScheduler)
+	ledgerHandler := ledger.NewHandler(ledgerService)
+	reconciliationHandler := reconciliation.NewHandler(reconciliationService)
+	transferHandler := transfer.NewHandler(transferService)
+	lifetimeISAHandler := lifetimeisa.NewHandler(lifetimeISAService, bonusScheduler)
+	accountHandler := account.NewHandler(accountService)
+	employerHandler := employer.NewHandler(employerService)
+	employeeHandler := employee.NewHandler(employeeService)
 
-	server := server.NewServer(cfg, customerHandler, fundHandler, investmentHandler)
+	server, err := server.NewServer(cfg, customerHandler, fundHandler, investmentHandler, kycHandler, amlHandler, riskProfileHandler, statementHandler, performanceHandler, valuationHandler, chargesHandler, ledgerHandler, reconciliationHandler, transferHandler, lifetimeISAHandler, accountHandler, employerHandler, employeeHandler)
+	if err != nil {
+		log.Fatalf("Failed to create server: %v", err)
+	}
 	fmt.Println("Running...")
 
 	// Create a done channel to signal when the shutdown is complete
@@ -64,7 +222,7 @@ func main() {
 	// Run graceful shutdown in a separate goroutine
 	go gracefulShutdown(server, db_service, done)
 
-	err := server.ListenAndServe()
+	err = server.ListenAndServe()
 	if err != nil && err != http.ErrServerClosed {
 		panic(fmt.Sprintf("http server error: %s", err))
 	}
@@ -0,0 +1,70 @@
+// Batch command that imports a client money bank statement and reconciles it against pending deposits, e.g.
+//
+//	go run cmd/reconcile/main.go -file statement.xml
+//
+// The format is detected from the content unless -format csv or -format camt053 is given
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/stcol316/cushon-isa/internal/config"
+	"github.com/stcol316/cushon-isa/internal/database"
+	"github.com/stcol316/cushon-isa/internal/reconciliation"
+)
+
+func main() {
+	file := flag.String("file", "", "bank statement file to import")
+	format := flag.String("format", "", "statement format, csv or camt053")
+	verbose := flag.Bool("v", false, "print every statement line as JSON")
+	flag.Parse()
+
+	if *file == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	data, err := os.ReadFile(*file)
+	if err != nil {
+		log.Fatalf("Failed to read statement: %v", err)
+	}
+
+	cfg, cfgerr := config.Load()
+	if cfgerr != nil {
+		log.Fatalf("Failed to load config: %v", cfgerr)
+	}
+
+	db_service, dberr := database.NewPostgresDB(cfg)
+	if dberr != nil {
+		log.Fatal(dberr)
+	}
+	defer db_service.Close()
+
+	// Note: Ctrl+C stops the import between lines, lines already matched stay matched
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	service := reconciliation.NewService(reconciliation.NewRepository(db_service.DB()))
+	result, err := service.Import(ctx, *format, data)
+	if err != nil {
+		log.Fatalf("Failed to import statement: %v", err)
+	}
+
+	if *verbose {
+		out, _ := json.MarshalIndent(result.Items, "", "  ")
+		fmt.Println(string(out))
+	}
+
+	fmt.Printf("Imported %s statement %s: %d lines, %d matched, %d partial, %d unmatched, %d duplicate, %d ignored\n",
+		result.Format, result.StatementID, result.Lines, result.Matched, result.Partial, result.Unmatched, result.Duplicate, result.Ignored)
+	if result.Partial+result.Unmatched > 0 {
+		os.Exit(1)
+	}
+}
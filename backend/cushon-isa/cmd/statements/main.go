@@ -0,0 +1,66 @@
+// Batch command that generates PDF statements for every customer for a period, e.g.
+//
+//	go run cmd/statements/main.go -period 2025-Q1 -out ./statements
+//
+// Statements are written to <out>/<period>/<customer id>.pdf
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/stcol316/cushon-isa/internal/config"
+	"github.com/stcol316/cushon-isa/internal/database"
+	"github.com/stcol316/cushon-isa/internal/models"
+	"github.com/stcol316/cushon-isa/internal/statement"
+)
+
+func main() {
+	period := flag.String("period", "", "statement period, a year (2025) or a quarter (2025-Q1)")
+	out := flag.String("out", "statements", "directory to write statements to")
+	flag.Parse()
+
+	if *period == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, cfgerr := config.Load()
+	if cfgerr != nil {
+		log.Fatalf("Failed to load config: %v", cfgerr)
+	}
+
+	db_service, dberr := database.NewPostgresDB(cfg)
+	if dberr != nil {
+		log.Fatal(dberr)
+	}
+	defer db_service.Close()
+
+	dir := filepath.Join(*out, *period)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		log.Fatalf("Failed to create output directory: %v", err)
+	}
+
+	// Note: Ctrl+C stops the batch between customers
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	service := statement.NewService(statement.NewRepository(db_service.DB()))
+	run, err := service.GenerateBatch(ctx, *period, func(s *models.Statement, pdf []byte) error {
+		return os.WriteFile(filepath.Join(dir, s.CustomerID+".pdf"), pdf, 0o640)
+	})
+	if err != nil {
+		log.Fatalf("Failed to generate statements: %v", err)
+	}
+
+	fmt.Printf("Generated %d statements for %s in %s (%d errors)\n", run.Generated, run.Period, dir, run.Errors)
+	if run.Errors > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,49 @@
+package account
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	helper "github.com/stcol316/cushon-isa/pkg/helpers"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+func (h *Handler) ListCustomerAccountsHandler(w http.ResponseWriter, r *http.Request) {
+	customerID := chi.URLParam(r, "customerId")
+	if _, err := uuid.Parse(customerID); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "invalid customer ID format")
+		return
+	}
+
+	accounts, err := h.service.listCustomerAccounts(r.Context(), customerID)
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, accounts)
+}
+
+func (h *Handler) GetAccountHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "invalid account ID format")
+		return
+	}
+
+	account, err := h.service.getAccount(r.Context(), id)
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, account)
+}
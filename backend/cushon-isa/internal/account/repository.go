@@ -0,0 +1,108 @@
+package account
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/models"
+)
+
+// TODO: Use interfaces at service level instead of "repo *Repository"
+type AccountRepository interface {
+	ListCustomerAccounts(ctx context.Context, customerID string) ([]models.Account, error)
+	GetAccount(ctx context.Context, id string) (*models.Account, error)
+	CustomerExists(ctx context.Context, customerID string) (bool, error)
+}
+
+type Repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+const accountColumns = `
+	id, COALESCE(customer_id::text, ''), COALESCE(employee_id::text, ''), product, status, opened_at, closed_at`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAccount(row rowScanner) (*models.Account, error) {
+	var account models.Account
+	var closed sql.NullTime
+	if err := row.Scan(
+		&account.ID,
+		&account.CustomerID,
+		&account.EmployeeID,
+		&account.Product,
+		&account.Status,
+		&account.OpenedAt,
+		&closed,
+	); err != nil {
+		return nil, err
+	}
+	if closed.Valid {
+		account.ClosedAt = &closed.Time
+	}
+	return &account, nil
+}
+
+// Note: Open accounts first, then the most recently opened
+func (r *Repository) listCustomerAccounts(ctx context.Context, customerID string) ([]models.Account, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT`+accountColumns+`
+        FROM accounts
+        WHERE customer_id = $1
+        ORDER BY status = 'closed', opened_at DESC, id
+    `, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list accounts: %w", err)
+	}
+	defer rows.Close()
+
+	accounts := []models.Account{}
+	for rows.Next() {
+		account, err := scanAccount(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan account: %w", err)
+		}
+		accounts = append(accounts, *account)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list accounts: %w", err)
+	}
+
+	return accounts, nil
+}
+
+func (r *Repository) getAccount(ctx context.Context, id string) (*models.Account, error) {
+	account, err := scanAccount(r.db.QueryRowContext(ctx, `
+        SELECT`+accountColumns+`
+        FROM accounts
+        WHERE id = $1
+    `, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, isaerrors.ErrAccountNotFound
+		}
+		return nil, fmt.Errorf("failed to get account: %w", err)
+	}
+	return account, nil
+}
+
+func (r *Repository) customerExists(ctx context.Context, customerID string) (bool, error) {
+	var exists bool
+	err := r.db.QueryRowContext(ctx, `
+        SELECT EXISTS (SELECT 1 FROM retail_customers WHERE id = $1)
+    `, customerID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check customer: %w", err)
+	}
+	return exists, nil
+}
@@ -0,0 +1,75 @@
+package account
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var accountRowColumns = []string{"id", "customer_id", "employee_id", "product", "status", "opened_at", "closed_at"}
+
+func TestRepository_ListCustomerAccounts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	opened := time.Date(2025, 1, 2, 9, 0, 0, 0, time.UTC)
+	closed := time.Date(2025, 6, 1, 9, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery("SELECT (.+) FROM accounts WHERE customer_id = \\$1 ORDER BY status = 'closed'").
+		WithArgs("customer1").
+		WillReturnRows(sqlmock.NewRows(accountRowColumns).
+			AddRow("account2", "customer1", "", models.ISAProductLifetime, models.AccountStatusOpen, opened, nil).
+			AddRow("account1", "customer1", "", models.ISAProductStandard, models.AccountStatusClosed, opened, closed))
+
+	accounts, err := repo.listCustomerAccounts(context.Background(), "customer1")
+	require.NoError(t, err)
+	require.Len(t, accounts, 2)
+	assert.Nil(t, accounts[0].ClosedAt)
+	assert.Equal(t, models.ISAProductStandard, accounts[1].Product)
+	assert.Equal(t, &closed, accounts[1].ClosedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestService_ListCustomerAccounts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	service := NewService(NewRepository(db))
+
+	mock.ExpectQuery("SELECT (.+) FROM accounts").
+		WithArgs("missing").
+		WillReturnRows(sqlmock.NewRows(accountRowColumns))
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM retail_customers").
+		WithArgs("missing").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	_, err = service.listCustomerAccounts(context.Background(), "missing")
+	assert.ErrorIs(t, err, isaerrors.ErrCustomerNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_GetAccount(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+
+	mock.ExpectQuery("SELECT (.+) FROM accounts WHERE id = \\$1").
+		WithArgs("missing").
+		WillReturnError(sql.ErrNoRows)
+
+	_, err = repo.getAccount(context.Background(), "missing")
+	assert.ErrorIs(t, err, isaerrors.ErrAccountNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
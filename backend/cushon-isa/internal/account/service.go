@@ -0,0 +1,38 @@
+package account
+
+import (
+	"context"
+
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/models"
+)
+
+type Service struct {
+	repo *Repository
+}
+
+func NewService(repo *Repository) *Service {
+	return &Service{repo: repo}
+}
+
+// Note: Every customer has an account from sign up, so no accounts means no customer
+func (s *Service) listCustomerAccounts(ctx context.Context, customerID string) ([]models.Account, error) {
+	accounts, err := s.repo.listCustomerAccounts(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	if len(accounts) == 0 {
+		exists, err := s.repo.customerExists(ctx, customerID)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, isaerrors.ErrCustomerNotFound
+		}
+	}
+	return accounts, nil
+}
+
+func (s *Service) getAccount(ctx context.Context, id string) (*models.Account, error) {
+	return s.repo.getAccount(ctx, id)
+}
@@ -0,0 +1,158 @@
+package aml
+
+import (
+	"fmt"
+
+	"github.com/stcol316/cushon-isa/internal/models"
+)
+
+// Note: Thresholds for the AML rules. All values are loaded from config so they can be tuned without a release
+type Config struct {
+	// Single deposits above these amounts are held or rejected
+	SingleDepositHoldAbove   float64
+	SingleDepositRejectAbove float64
+
+	// Deposits within the velocity window above either limit are held
+	VelocityWindowDays int
+	VelocityMaxCount   int
+	VelocityMaxAmount  float64
+
+	// Withdrawals within this many days of a deposit are held
+	RapidWithdrawalDays int
+}
+
+// Transaction is the money movement being screened
+type Transaction struct {
+	CustomerID string
+	FundID     string
+	Type       string
+	Amount     float64
+}
+
+// Rule evaluates a transaction against a customer's recent activity.
+// A nil result means the rule did not fire
+type Rule interface {
+	Name() string
+	Evaluate(txn Transaction, activity models.AMLActivity) *models.AMLRuleResult
+}
+
+type Engine struct {
+	config Config
+	rules  []Rule
+}
+
+func NewEngine(cfg Config) *Engine {
+	return &Engine{
+		config: cfg,
+		rules: []Rule{
+			&singleDepositRule{holdAbove: cfg.SingleDepositHoldAbove, rejectAbove: cfg.SingleDepositRejectAbove},
+			&velocityRule{windowDays: cfg.VelocityWindowDays, maxCount: cfg.VelocityMaxCount, maxAmount: cfg.VelocityMaxAmount},
+			&rapidWithdrawalRule{windowDays: cfg.RapidWithdrawalDays},
+		},
+	}
+}
+
+// Windows returns the look back periods the engine needs activity for
+func (e *Engine) Windows() (velocityDays, rapidWithdrawalDays int) {
+	return e.config.VelocityWindowDays, e.config.RapidWithdrawalDays
+}
+
+// Evaluate runs every rule and returns the most severe outcome along with every rule that fired
+func (e *Engine) Evaluate(txn Transaction, activity models.AMLActivity) models.AMLDecision {
+	decision := models.AMLDecision{Outcome: models.AMLOutcomeAllow}
+	for _, rule := range e.rules {
+		result := rule.Evaluate(txn, activity)
+		if result == nil {
+			continue
+		}
+		decision.Results = append(decision.Results, *result)
+		if severity[result.Outcome] > severity[decision.Outcome] {
+			decision.Outcome = result.Outcome
+		}
+	}
+	return decision
+}
+
+var severity = map[string]int{
+	models.AMLOutcomeAllow:  0,
+	models.AMLOutcomeHold:   1,
+	models.AMLOutcomeReject: 2,
+}
+
+type singleDepositRule struct {
+	holdAbove   float64
+	rejectAbove float64
+}
+
+func (r *singleDepositRule) Name() string { return "single_deposit_threshold" }
+
+func (r *singleDepositRule) Evaluate(txn Transaction, _ models.AMLActivity) *models.AMLRuleResult {
+	if txn.Type != models.InvestmentTypeDeposit {
+		return nil
+	}
+	switch {
+	case r.rejectAbove > 0 && txn.Amount > r.rejectAbove:
+		return &models.AMLRuleResult{
+			Rule:    r.Name(),
+			Outcome: models.AMLOutcomeReject,
+			Reason:  fmt.Sprintf("deposit of %.2f exceeds maximum of %.2f", txn.Amount, r.rejectAbove),
+		}
+	case r.holdAbove > 0 && txn.Amount > r.holdAbove:
+		return &models.AMLRuleResult{
+			Rule:    r.Name(),
+			Outcome: models.AMLOutcomeHold,
+			Reason:  fmt.Sprintf("deposit of %.2f exceeds review threshold of %.2f", txn.Amount, r.holdAbove),
+		}
+	}
+	return nil
+}
+
+type velocityRule struct {
+	windowDays int
+	maxCount   int
+	maxAmount  float64
+}
+
+func (r *velocityRule) Name() string { return "deposit_velocity" }
+
+// Note: The transaction being screened is included in the counts
+func (r *velocityRule) Evaluate(txn Transaction, activity models.AMLActivity) *models.AMLRuleResult {
+	if txn.Type != models.InvestmentTypeDeposit || r.windowDays <= 0 {
+		return nil
+	}
+	count := activity.VelocityDepositCount + 1
+	amount := activity.VelocityDepositAmount + txn.Amount
+
+	switch {
+	case r.maxCount > 0 && count > r.maxCount:
+		return &models.AMLRuleResult{
+			Rule:    r.Name(),
+			Outcome: models.AMLOutcomeHold,
+			Reason:  fmt.Sprintf("%d deposits in %d days exceeds limit of %d", count, r.windowDays, r.maxCount),
+		}
+	case r.maxAmount > 0 && amount > r.maxAmount:
+		return &models.AMLRuleResult{
+			Rule:    r.Name(),
+			Outcome: models.AMLOutcomeHold,
+			Reason:  fmt.Sprintf("deposits of %.2f in %d days exceeds limit of %.2f", amount, r.windowDays, r.maxAmount),
+		}
+	}
+	return nil
+}
+
+type rapidWithdrawalRule struct {
+	windowDays int
+}
+
+func (r *rapidWithdrawalRule) Name() string { return "rapid_deposit_withdrawal" }
+
+func (r *rapidWithdrawalRule) Evaluate(txn Transaction, activity models.AMLActivity) *models.AMLRuleResult {
+	if txn.Type != models.InvestmentTypeWithdrawal || r.windowDays <= 0 || activity.RecentDepositAmount <= 0 {
+		return nil
+	}
+	return &models.AMLRuleResult{
+		Rule:    r.Name(),
+		Outcome: models.AMLOutcomeHold,
+		Reason:  fmt.Sprintf("withdrawal requested within %d days of depositing %.2f", r.windowDays, activity.RecentDepositAmount),
+	}
+}
@@ -0,0 +1,72 @@
+package aml
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	mw "github.com/stcol316/cushon-isa/internal/middleware"
+	"github.com/stcol316/cushon-isa/internal/models"
+	helper "github.com/stcol316/cushon-isa/pkg/helpers"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+func (h *Handler) ListReviewsHandler(w http.ResponseWriter, r *http.Request) {
+	params, ok := mw.GetPaginationParams(r.Context())
+	if !ok {
+		log.Printf("Failed to get pagination params from context")
+		helper.RespondWithError(w, http.StatusInternalServerError, "pagination error")
+		return
+	}
+
+	result, err := h.service.listReviews(r.Context(), params.Page, params.PageSize)
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+	helper.RespondWithJSON(w, http.StatusOK, result)
+}
+
+func (h *Handler) ReleaseInvestmentHandler(w http.ResponseWriter, r *http.Request) {
+	h.resolve(w, r, h.service.releaseInvestment)
+}
+
+func (h *Handler) CancelInvestmentHandler(w http.ResponseWriter, r *http.Request) {
+	h.resolve(w, r, h.service.cancelInvestment)
+}
+
+// resolve handles both review decisions as they only differ in the service call
+func (h *Handler) resolve(w http.ResponseWriter, r *http.Request, decide func(ctx context.Context, investmentID, reviewer, note string) error) {
+	id := chi.URLParam(r, "investmentId")
+	if _, err := uuid.Parse(id); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "invalid investment ID format")
+		return
+	}
+
+	// The note is optional so an empty body is allowed
+	req := new(models.AMLReviewDecisionRequest)
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			helper.RespondWithProblem(w, r, isaerrors.ErrInvalidRequestBody.Wrap(err))
+			return
+		}
+	}
+
+	if err := decide(r.Context(), id, mw.GetUserID(r), req.Note); err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,143 @@
+package aml
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/ledger"
+	"github.com/stcol316/cushon-isa/internal/models"
+)
+
+type AMLRepository interface {
+	ListHeldInvestments(ctx context.Context, page, pageSize int) ([]models.AMLReview, int, error)
+	ResolveHeldInvestment(ctx context.Context, investmentID, status, resolution, reviewer, note string) error
+}
+
+type Repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Note: Review queue ordered oldest first so nothing is left waiting
+func (r *Repository) listHeldInvestments(ctx context.Context, page, pageSize int) ([]models.AMLReview, int, error) {
+	offset := (page - 1) * pageSize
+
+	var total int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM investments WHERE status = 'held'").Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get total count: %w", err)
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT i.id, i.customer_id, i.fund_id, i.amount, i.type, i.status, i.created_at,
+            ARRAY_AGG(a.rule ORDER BY a.created_at),
+            ARRAY_AGG(a.outcome ORDER BY a.created_at),
+            ARRAY_AGG(a.reason ORDER BY a.created_at)
+        FROM investments i
+        JOIN aml_alerts a ON a.investment_id = i.id
+        WHERE i.status = 'held'
+        GROUP BY i.id
+        ORDER BY i.created_at
+        LIMIT $1 OFFSET $2
+    `, pageSize, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query held investments: %w", err)
+	}
+	defer rows.Close()
+
+	var reviews []models.AMLReview
+	for rows.Next() {
+		var review models.AMLReview
+		var rules, outcomes, reasons []string
+		if err := rows.Scan(
+			&review.Investment.ID,
+			&review.Investment.CustomerID,
+			&review.Investment.FundID,
+			&review.Investment.Amount,
+			&review.Investment.Type,
+			&review.Investment.Status,
+			&review.Investment.CreatedAt,
+			pq.Array(&rules),
+			pq.Array(&outcomes),
+			pq.Array(&reasons),
+		); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan held investment: %w", err)
+		}
+		for i := range rules {
+			review.Alerts = append(review.Alerts, models.AMLRuleResult{
+				Rule:    rules[i],
+				Outcome: outcomes[i],
+				Reason:  reasons[i],
+			})
+		}
+		reviews = append(reviews, review)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to query held investments: %w", err)
+	}
+
+	return reviews, total, nil
+}
+
+// Note: Releasing or cancelling changes the investment status and closes its alerts in one transaction.
+// Released investments are now included in customer totals so the materialized view is refreshed
+func (r *Repository) resolveHeldInvestment(ctx context.Context, investmentID, status, resolution, reviewer, note string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+	UPDATE investments
+	SET status = $2, status_updated_at = CURRENT_TIMESTAMP
+	WHERE id = $1 AND status = 'held'
+`, investmentID, status)
+	if err != nil {
+		return fmt.Errorf("failed to update investment status: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return isaerrors.ErrReviewNotFound
+	}
+
+	if err := ledger.RecordStatusChange(ctx, tx, investmentID, models.InvestmentStatusHeld, status, "aml review "+resolution); err != nil {
+		return err
+	}
+
+	// Note: A released order may have missed the price load for its dealing date so price it now if we can
+	_, err = tx.ExecContext(ctx, `
+	UPDATE investments i
+	SET unit_price = p.price, units = ROUND(i.amount / p.price, 6), priced_at = CURRENT_TIMESTAMP
+	FROM fund_prices p
+	WHERE i.id = $1 AND i.status = 'pending' AND i.unit_price IS NULL
+		AND p.fund_id = i.fund_id AND p.price_date = i.dealing_date
+`, investmentID)
+	if err != nil {
+		return fmt.Errorf("failed to price released investment: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	UPDATE aml_alerts
+	SET resolution = $2, reviewed_by = NULLIF($3, ''), review_note = NULLIF($4, ''), reviewed_at = CURRENT_TIMESTAMP
+	WHERE investment_id = $1 AND resolution IS NULL
+`, investmentID, resolution, reviewer, note)
+	if err != nil {
+		return fmt.Errorf("failed to resolve aml alerts: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "REFRESH MATERIALIZED VIEW customer_fund_totals"); err != nil {
+		return fmt.Errorf("failed to refresh materialized view: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
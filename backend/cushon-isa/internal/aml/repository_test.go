@@ -0,0 +1,117 @@
+package aml
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository_ListHeldInvestments(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	t.Run("held investments with their alerts", func(t *testing.T) {
+		mock.ExpectQuery("SELECT COUNT.*FROM investments WHERE status = 'held'").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+		rows := sqlmock.NewRows([]string{
+			"id", "customer_id", "fund_id", "amount", "type", "status", "created_at", "rules", "outcomes", "reasons",
+		}).AddRow("inv1", "customer1", "fund1", 12000.0, models.InvestmentTypeDeposit, models.InvestmentStatusHeld, time.Now(),
+			"{single_deposit_threshold,deposit_velocity}", "{hold,hold}", `{"too large","too fast"}`)
+
+		mock.ExpectQuery("SELECT (.+) FROM investments i JOIN aml_alerts a").
+			WithArgs(10, 0).
+			WillReturnRows(rows)
+
+		reviews, total, err := repo.listHeldInvestments(ctx, 1, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, total)
+		require.Len(t, reviews, 1)
+		assert.Equal(t, "inv1", reviews[0].Investment.ID)
+		require.Len(t, reviews[0].Alerts, 2)
+		assert.Equal(t, "deposit_velocity", reviews[0].Alerts[1].Rule)
+		assert.Equal(t, "too fast", reviews[0].Alerts[1].Reason)
+	})
+
+	t.Run("error while reading rows", func(t *testing.T) {
+		mock.ExpectQuery("SELECT COUNT.*FROM investments WHERE status = 'held'").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectQuery("SELECT (.+) FROM investments i JOIN aml_alerts a").
+			WillReturnRows(sqlmock.NewRows([]string{
+				"id", "customer_id", "fund_id", "amount", "type", "status", "created_at", "rules", "outcomes", "reasons",
+			}).AddRow("inv1", "customer1", "fund1", 12000.0, models.InvestmentTypeDeposit, models.InvestmentStatusHeld, time.Now(),
+				"{single_deposit_threshold}", "{hold}", "{too large}").
+				RowError(0, sql.ErrConnDone))
+
+		reviews, _, err := repo.listHeldInvestments(ctx, 1, 10)
+		assert.ErrorIs(t, err, sql.ErrConnDone)
+		assert.Nil(t, reviews)
+	})
+
+	t.Run("database error on count", func(t *testing.T) {
+		mock.ExpectQuery("SELECT COUNT.*FROM investments").
+			WillReturnError(sql.ErrConnDone)
+
+		reviews, total, err := repo.listHeldInvestments(ctx, 1, 10)
+		assert.Error(t, err)
+		assert.Nil(t, reviews)
+		assert.Zero(t, total)
+	})
+}
+
+func TestRepository_ResolveHeldInvestment(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	t.Run("release updates investment and alerts", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE investments SET status").
+			WithArgs("inv1", models.InvestmentStatusPending).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO investment_status_history").
+			WithArgs("inv1", models.InvestmentStatusHeld, models.InvestmentStatusPending, "aml review released").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE investments i SET unit_price").
+			WithArgs("inv1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE aml_alerts").
+			WithArgs("inv1", models.AMLResolutionReleased, "1", "source of funds verified").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("REFRESH MATERIALIZED VIEW customer_fund_totals").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		err := repo.resolveHeldInvestment(ctx, "inv1", models.InvestmentStatusPending, models.AMLResolutionReleased, "1", "source of funds verified")
+		assert.NoError(t, err)
+	})
+
+	t.Run("investment not held", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE investments SET status").
+			WithArgs("inv2", models.InvestmentStatusCancelled).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		err := repo.resolveHeldInvestment(ctx, "inv2", models.InvestmentStatusCancelled, models.AMLResolutionCancelled, "1", "")
+		assert.ErrorIs(t, err, isaerrors.ErrReviewNotFound)
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,47 @@
+package aml
+
+import (
+	"context"
+	"fmt"
+
+	mw "github.com/stcol316/cushon-isa/internal/middleware"
+	"github.com/stcol316/cushon-isa/internal/models"
+)
+
+type Service struct {
+	repo *Repository
+}
+
+func NewService(repo *Repository) *Service {
+	return &Service{repo: repo}
+}
+
+func (s *Service) listReviews(ctx context.Context, page, pageSize int) (*mw.PaginatedResult, error) {
+	reviews, total, err := s.repo.listHeldInvestments(ctx, page, pageSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list aml reviews: %w", err)
+	}
+
+	// Calculate pagination metadata
+	totalPages := (total + pageSize - 1) / pageSize
+
+	result := &mw.PaginatedResult{
+		Data: reviews,
+	}
+	result.Pagination.CurrentPage = page
+	result.Pagination.PageSize = pageSize
+	result.Pagination.TotalItems = &total
+	result.Pagination.TotalPages = &totalPages
+	result.Pagination.HasNext = page < totalPages
+	result.Pagination.HasPrevious = page > 1
+
+	return result, nil
+}
+
+func (s *Service) releaseInvestment(ctx context.Context, investmentID, reviewer, note string) error {
+	return s.repo.resolveHeldInvestment(ctx, investmentID, models.InvestmentStatusPending, models.AMLResolutionReleased, reviewer, note)
+}
+
+func (s *Service) cancelInvestment(ctx context.Context, investmentID, reviewer, note string) error {
+	return s.repo.resolveHeldInvestment(ctx, investmentID, models.InvestmentStatusCancelled, models.AMLResolutionCancelled, reviewer, note)
+}
@@ -0,0 +1,92 @@
+package charges
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/models"
+	helper "github.com/stcol316/cushon-isa/pkg/helpers"
+)
+
+type Handler struct {
+	service   *Service
+	scheduler *Scheduler
+}
+
+func NewHandler(service *Service, scheduler *Scheduler) *Handler {
+	return &Handler{service: service, scheduler: scheduler}
+}
+
+// Note: Admin only. Accrues every valued date that has not been accrued yet
+func (h *Handler) AccrueChargesHandler(w http.ResponseWriter, r *http.Request) {
+	run, err := h.scheduler.AccrueNow(r.Context())
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, run)
+}
+
+// Note: Admin only. Collects a month that has ended, e.g. {"month": "2025-01"}
+func (h *Handler) CollectChargesHandler(w http.ResponseWriter, r *http.Request) {
+	req := new(models.CollectChargesRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		helper.RespondWithProblem(w, r, isaerrors.ErrInvalidRequestBody.Wrap(err))
+		return
+	}
+
+	run, err := h.scheduler.Collect(r.Context(), req.Month)
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, run)
+}
+
+func (h *Handler) GetFeeTiersHandler(w http.ResponseWriter, r *http.Request) {
+	tiers, err := h.service.getFeeTiers(r.Context())
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, tiers)
+}
+
+func (h *Handler) UpdateFeeTiersHandler(w http.ResponseWriter, r *http.Request) {
+	req := new(models.UpdateFeeTiersRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		helper.RespondWithProblem(w, r, isaerrors.ErrInvalidRequestBody.Wrap(err))
+		return
+	}
+
+	tiers, err := h.service.updateFeeTiers(r.Context(), req)
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, tiers)
+}
+
+// Note: Costs and charges disclosure, optionally for ?from=2025-01-01&to=2025-12-31
+func (h *Handler) GetCustomerChargesHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "invalid customer ID format")
+		return
+	}
+
+	summary, err := h.scheduler.CustomerCharges(r.Context(), id, r.URL.Query().Get("from"), r.URL.Query().Get("to"))
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, summary)
+}
@@ -0,0 +1,529 @@
+package charges
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/stcol316/cushon-isa/internal/ledger"
+	"github.com/stcol316/cushon-isa/internal/models"
+)
+
+// TODO: Use interfaces at service level instead of "repo *Repository"
+type ChargesRepository interface {
+	AccrueCharges(ctx context.Context, date time.Time) (int, error)
+	GetLastAccrualDate(ctx context.Context) (*time.Time, error)
+	GetLastValuationDate(ctx context.Context) (*time.Time, error)
+	GetFirstValuationDate(ctx context.Context) (*time.Time, error)
+	GetLastCollectedPeriod(ctx context.Context) (*time.Time, error)
+	IsCollected(ctx context.Context, periodStart time.Time) (bool, error)
+	ListPlatformCharges(ctx context.Context, start, end time.Time) ([]pendingCharge, error)
+	ListFixedCharges(ctx context.Context, start, end time.Time) ([]pendingCharge, error)
+	CollectCharge(ctx context.Context, charge pendingCharge, start, end time.Time) ([]models.Charge, error)
+	CompleteCollection(ctx context.Context, periodStart time.Time, charges int) error
+	GetFeeTiers(ctx context.Context) ([]models.PlatformFeeTier, error)
+	ReplaceFeeTiers(ctx context.Context, tiers []models.PlatformFeeTier) error
+	GetAccruedCharges(ctx context.Context, customerID string, from, to time.Time) (float64, float64, error)
+	ListCustomerCharges(ctx context.Context, customerID string, from, to time.Time) ([]models.Charge, error)
+}
+
+// pendingCharge is a charge that is due but not yet collected
+type pendingCharge struct {
+	customerID string
+	fundID     string
+	chargeType string
+	amount     float64
+}
+
+type Repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Note: Writes the accruals for one date from that date's valuation snapshot. Existing rows for the
+// date are replaced in the same transaction so reruns after a revaluation are idempotent.
+// The platform fee is tiered on the whole portfolio, each band's rate applying only to the value
+// within it, and is then split across the customer's holdings by value. Collection spreads each
+// holding's share across the accounts that hold it, see collectCharge. OCF is accrued per holding.
+// Annual rates are divided by the number of days in the year
+func (r *Repository) accrueCharges(ctx context.Context, date time.Time) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM charge_accruals WHERE accrual_date = $1::date`, date); err != nil {
+		return 0, fmt.Errorf("failed to clear accruals: %w", err)
+	}
+
+	res, err := tx.ExecContext(ctx, `
+	WITH year AS (
+		SELECT (DATE_TRUNC('year', $1::date) + INTERVAL '1 year')::date - DATE_TRUNC('year', $1::date)::date AS days
+	),
+	portfolios AS (
+		SELECT customer_id, SUM(market_value) AS total
+		FROM holding_valuations
+		WHERE valuation_date = $1::date
+		GROUP BY customer_id
+		HAVING SUM(market_value) > 0
+	),
+	fees AS (
+		SELECT p.customer_id,
+			SUM((LEAST(p.total, COALESCE(t.upper_bound, p.total)) - t.lower_bound) * t.annual_rate / 100) AS annual_fee
+		FROM portfolios p
+		JOIN platform_fee_tiers t ON t.lower_bound < p.total
+		GROUP BY p.customer_id
+	)
+	INSERT INTO charge_accruals (customer_id, fund_id, accrual_date, charge_type, amount)
+	SELECT v.customer_id, v.fund_id, $1::date, 'platform',
+		ROUND(f.annual_fee * v.market_value / p.total / y.days, 6)
+	FROM holding_valuations v
+	JOIN portfolios p ON p.customer_id = v.customer_id
+	JOIN fees f ON f.customer_id = v.customer_id
+	CROSS JOIN year y
+	WHERE v.valuation_date = $1::date AND v.market_value > 0 AND f.annual_fee > 0
+`, date)
+	if err != nil {
+		return 0, fmt.Errorf("failed to accrue platform charges: %w", err)
+	}
+	platform, _ := res.RowsAffected()
+
+	res, err = tx.ExecContext(ctx, `
+	INSERT INTO charge_accruals (customer_id, fund_id, accrual_date, charge_type, amount)
+	SELECT v.customer_id, v.fund_id, $1::date, 'ocf',
+		ROUND(v.market_value * f.ocf / 100 /
+			((DATE_TRUNC('year', $1::date) + INTERVAL '1 year')::date - DATE_TRUNC('year', $1::date)::date), 6)
+	FROM holding_valuations v
+	JOIN funds f ON f.id = v.fund_id
+	WHERE v.valuation_date = $1::date AND v.market_value > 0 AND f.ocf > 0
+`, date)
+	if err != nil {
+		return 0, fmt.Errorf("failed to accrue fund charges: %w", err)
+	}
+	ocf, _ := res.RowsAffected()
+
+	accruals := platform + ocf
+	_, err = tx.ExecContext(ctx, `
+	INSERT INTO charge_accrual_runs (accrual_date, accruals)
+	VALUES ($1::date, $2)
+	ON CONFLICT (accrual_date) DO UPDATE
+	SET accruals = EXCLUDED.accruals, completed_at = CURRENT_TIMESTAMP
+`, date, accruals)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record accrual run: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return int(accruals), nil
+}
+
+// getLastAccrualDate returns the latest accrued date, or nil if the job has never run
+func (r *Repository) getLastAccrualDate(ctx context.Context) (*time.Time, error) {
+	return r.queryDate(ctx, `SELECT MAX(accrual_date) FROM charge_accrual_runs`, "last accrual date")
+}
+
+// Note: Charges can only be accrued for dates that have been valued
+func (r *Repository) getLastValuationDate(ctx context.Context) (*time.Time, error) {
+	return r.queryDate(ctx, `SELECT MAX(valuation_date) FROM valuation_runs`, "last valuation date")
+}
+
+func (r *Repository) getFirstValuationDate(ctx context.Context) (*time.Time, error) {
+	return r.queryDate(ctx, `SELECT MIN(valuation_date) FROM valuation_runs`, "first valuation date")
+}
+
+// getLastCollectedPeriod returns the start of the latest collected month, or nil if nothing has been collected
+func (r *Repository) getLastCollectedPeriod(ctx context.Context) (*time.Time, error) {
+	return r.queryDate(ctx, `SELECT MAX(period_start) FROM charge_collection_runs`, "last collected period")
+}
+
+func (r *Repository) queryDate(ctx context.Context, query, name string) (*time.Time, error) {
+	var date sql.NullTime
+	if err := r.db.QueryRowContext(ctx, query).Scan(&date); err != nil {
+		return nil, fmt.Errorf("failed to get %s: %w", name, err)
+	}
+	if !date.Valid {
+		return nil, nil
+	}
+	return &date.Time, nil
+}
+
+func (r *Repository) isCollected(ctx context.Context, periodStart time.Time) (bool, error) {
+	var exists bool
+	err := r.db.QueryRowContext(ctx, `
+        SELECT EXISTS(SELECT 1 FROM charge_collection_runs WHERE period_start = $1::date)
+    `, periodStart).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check charge collection: %w", err)
+	}
+	return exists, nil
+}
+
+// listPlatformCharges sums the platform accruals in [start, end) for each holding
+func (r *Repository) listPlatformCharges(ctx context.Context, start, end time.Time) ([]pendingCharge, error) {
+	return r.queryPendingCharges(ctx, `
+        SELECT customer_id, fund_id, ROUND(SUM(amount), 2)
+        FROM charge_accruals
+        WHERE charge_type = 'platform' AND accrual_date >= $1::date AND accrual_date < $2::date
+        GROUP BY customer_id, fund_id
+        HAVING ROUND(SUM(amount), 2) > 0
+        ORDER BY customer_id, fund_id
+    `, models.ChargeTypePlatform, start, end)
+}
+
+// Note: Fixed fees are charged once per customer who held anything at the last valuation
+// in [start, end). They are taken from the customer's largest holding
+func (r *Repository) listFixedCharges(ctx context.Context, start, end time.Time) ([]pendingCharge, error) {
+	return r.queryPendingCharges(ctx, `
+        SELECT DISTINCT ON (v.customer_id) v.customer_id, v.fund_id, fees.amount
+        FROM holding_valuations v
+        CROSS JOIN (SELECT COALESCE(SUM(monthly_amount), 0) AS amount FROM fixed_fees WHERE active) fees
+        WHERE v.valuation_date = (
+                SELECT MAX(valuation_date) FROM valuation_runs
+                WHERE valuation_date >= $1::date AND valuation_date < $2::date
+            )
+            AND v.market_value > 0 AND fees.amount > 0
+        ORDER BY v.customer_id, v.market_value DESC
+    `, models.ChargeTypeFixed, start, end)
+}
+
+func (r *Repository) queryPendingCharges(ctx context.Context, query, chargeType string, start, end time.Time) ([]pendingCharge, error) {
+	rows, err := r.db.QueryContext(ctx, query, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s charges: %w", chargeType, err)
+	}
+	defer rows.Close()
+
+	var charges []pendingCharge
+	for rows.Next() {
+		charge := pendingCharge{chargeType: chargeType}
+		if err := rows.Scan(&charge.customerID, &charge.fundID, &charge.amount); err != nil {
+			return nil, fmt.Errorf("failed to scan %s charge: %w", chargeType, err)
+		}
+		charges = append(charges, charge)
+	}
+
+	return charges, nil
+}
+
+// accountHolding is what one of the customer's accounts holds of the fund being charged
+type accountHolding struct {
+	accountID    string
+	units        float64
+	unpricedCash float64
+	value        float64
+}
+
+// Note: Collects one charge. The fee is tiered on the whole portfolio, so it is spread across the
+// customer's accounts in proportion to what each holds, valued at the latest price up to the end of
+// the period, and capped at their total. Units are sold where the account has them, otherwise the
+// share is taken from the money held at cost. Each share is recorded as a settled charge investment
+// so it shows in the account's history and statements. Returns nil if the charge was already
+// collected or there was nothing to take it from
+func (r *Repository) collectCharge(ctx context.Context, charge pendingCharge, start, end time.Time) ([]models.Charge, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var price sql.NullFloat64
+	err = tx.QueryRowContext(ctx, `
+        SELECT price FROM fund_prices
+        WHERE fund_id = $1 AND price_date < $2::date
+        ORDER BY price_date DESC
+        LIMIT 1
+    `, charge.fundID, end).Scan(&price)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to get fund price: %w", err)
+	}
+
+	holdings, total, err := listAccountHoldings(ctx, tx, charge.customerID, charge.fundID, price)
+	if err != nil {
+		return nil, err
+	}
+	amount := roundPence(min(charge.amount, total))
+	if amount <= 0 {
+		return nil, nil
+	}
+
+	var collected []models.Charge
+	remaining := amount
+	for i, h := range holdings {
+		// Note: The last account takes whatever rounding left over so the shares add up to the charge
+		share := roundPence(amount * h.value / total)
+		if i == len(holdings)-1 {
+			share = roundPence(min(remaining, h.value))
+		}
+		remaining = roundPence(remaining - share)
+		if share <= 0 {
+			continue
+		}
+
+		c, err := collectAccountCharge(ctx, tx, charge, h, share, price, start, end)
+		if err != nil {
+			return nil, err
+		}
+		if c == nil {
+			// Note: Already collected by an earlier run, the rollback discards the other shares
+			return nil, nil
+		}
+		collected = append(collected, *c)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return collected, nil
+}
+
+// listAccountHoldings returns the customer's accounts holding the fund with their value, and the total
+func listAccountHoldings(ctx context.Context, tx *sql.Tx, customerID, fundID string, price sql.NullFloat64) ([]accountHolding, float64, error) {
+	rows, err := tx.QueryContext(ctx, `
+        SELECT account_id,
+            COALESCE(SUM(CASE WHEN type = 'deposit' THEN units ELSE -units END), 0),
+            COALESCE(SUM(CASE WHEN units IS NOT NULL THEN 0 WHEN type = 'deposit' THEN amount ELSE -amount END), 0)
+        FROM investments
+        WHERE customer_id = $1 AND fund_id = $2 AND status IN ('units_allocated', 'settled')
+        GROUP BY account_id
+        ORDER BY account_id
+    `, customerID, fundID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query account holdings: %w", err)
+	}
+	defer rows.Close()
+
+	var holdings []accountHolding
+	var total float64
+	for rows.Next() {
+		var h accountHolding
+		if err := rows.Scan(&h.accountID, &h.units, &h.unpricedCash); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan account holding: %w", err)
+		}
+		h.value = h.unpricedCash
+		if h.units > 0 && price.Valid {
+			h.value = h.units * price.Float64
+		}
+		if h.value <= 0 {
+			continue
+		}
+		holdings = append(holdings, h)
+		total += h.value
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating account holdings: %w", err)
+	}
+
+	return holdings, total, nil
+}
+
+// collectAccountCharge takes one account's share of a charge. Returns nil if it was already collected
+func collectAccountCharge(ctx context.Context, tx *sql.Tx, charge pendingCharge, h accountHolding, amount float64, price sql.NullFloat64, start, end time.Time) (*models.Charge, error) {
+	collected := &models.Charge{
+		CustomerID:  charge.customerID,
+		AccountID:   h.accountID,
+		FundID:      charge.fundID,
+		Type:        charge.chargeType,
+		PeriodStart: start.Format(dateLayout),
+		PeriodEnd:   end.AddDate(0, 0, -1).Format(dateLayout),
+		Amount:      amount,
+		Method:      models.ChargeMethodUnits,
+	}
+
+	var chargeUnits, unitPrice sql.NullFloat64
+	if h.units > 0 && price.Valid {
+		chargeUnits = sql.NullFloat64{Float64: roundUnits(amount / price.Float64), Valid: true}
+		unitPrice = price
+	} else {
+		collected.Method = models.ChargeMethodCash
+	}
+
+	// TODO: Take cash charges from the cash account once one exists rather than the holding at cost
+	err := tx.QueryRowContext(ctx, `
+	INSERT INTO investments (customer_id, fund_id, amount, type, status, dealing_date, unit_price, units, priced_at, account_id)
+	VALUES ($1, $2, $3, 'charge', 'settled', $4::date, $5, $6, CURRENT_TIMESTAMP, $7)
+	RETURNING id
+`, charge.customerID, charge.fundID, amount, end.AddDate(0, 0, -1), unitPrice, chargeUnits, h.accountID).Scan(&collected.InvestmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record charge investment: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	INSERT INTO investment_status_history (investment_id, from_status, to_status, reason)
+	VALUES ($1, NULL, 'settled', $2)
+`, collected.InvestmentID, fmt.Sprintf("%s charge for %s", charge.chargeType, start.Format(monthLayout)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to record status history: %w", err)
+	}
+
+	if err := ledger.PostStatusChange(ctx, tx, collected.InvestmentID, "", models.InvestmentStatusSettled); err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRowContext(ctx, `
+	INSERT INTO charges (customer_id, fund_id, investment_id, charge_type, period_start, period_end, amount, method, account_id)
+	VALUES ($1, $2, $3, $4, $5::date, $6::date, $7, $8, $9)
+	ON CONFLICT (account_id, fund_id, charge_type, period_start) DO NOTHING
+	RETURNING id, created_at
+`, charge.customerID, charge.fundID, collected.InvestmentID, charge.chargeType, start, end.AddDate(0, 0, -1),
+		amount, collected.Method, h.accountID).Scan(&collected.ID, &collected.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to record charge: %w", err)
+	}
+
+	return collected, nil
+}
+
+// completeCollection marks the month as collected and refreshes the customer totals
+func (r *Repository) completeCollection(ctx context.Context, periodStart time.Time, charges int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+	INSERT INTO charge_collection_runs (period_start, charges)
+	VALUES ($1::date, $2)
+	ON CONFLICT (period_start) DO UPDATE
+	SET charges = charge_collection_runs.charges + EXCLUDED.charges, completed_at = CURRENT_TIMESTAMP
+`, periodStart, charges)
+	if err != nil {
+		return fmt.Errorf("failed to record collection run: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "REFRESH MATERIALIZED VIEW customer_fund_totals"); err != nil {
+		return fmt.Errorf("failed to refresh materialized view: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) getFeeTiers(ctx context.Context) ([]models.PlatformFeeTier, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT lower_bound, upper_bound, annual_rate
+        FROM platform_fee_tiers
+        ORDER BY lower_bound
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query fee tiers: %w", err)
+	}
+	defer rows.Close()
+
+	tiers := []models.PlatformFeeTier{}
+	for rows.Next() {
+		var tier models.PlatformFeeTier
+		var upper sql.NullFloat64
+		if err := rows.Scan(&tier.LowerBound, &upper, &tier.AnnualRate); err != nil {
+			return nil, fmt.Errorf("failed to scan fee tier: %w", err)
+		}
+		if upper.Valid {
+			tier.UpperBound = &upper.Float64
+		}
+		tiers = append(tiers, tier)
+	}
+
+	return tiers, nil
+}
+
+// Note: Tiers are replaced as a whole. New rates apply from the next accrual
+func (r *Repository) replaceFeeTiers(ctx context.Context, tiers []models.PlatformFeeTier) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM platform_fee_tiers`); err != nil {
+		return fmt.Errorf("failed to clear fee tiers: %w", err)
+	}
+
+	for _, tier := range tiers {
+		_, err := tx.ExecContext(ctx, `
+		INSERT INTO platform_fee_tiers (lower_bound, upper_bound, annual_rate)
+		VALUES ($1, $2, $3)
+	`, tier.LowerBound, tier.UpperBound, tier.AnnualRate)
+		if err != nil {
+			return fmt.Errorf("failed to insert fee tier: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// getAccruedCharges returns the platform and fund charges accrued in [from, to] for a customer
+func (r *Repository) getAccruedCharges(ctx context.Context, customerID string, from, to time.Time) (float64, float64, error) {
+	var platform, ocf float64
+	err := r.db.QueryRowContext(ctx, `
+        SELECT
+            COALESCE(ROUND(SUM(amount) FILTER (WHERE charge_type = 'platform'), 2), 0),
+            COALESCE(ROUND(SUM(amount) FILTER (WHERE charge_type = 'ocf'), 2), 0)
+        FROM charge_accruals
+        WHERE customer_id = $1 AND accrual_date >= $2::date AND accrual_date <= $3::date
+    `, customerID, from, to).Scan(&platform, &ocf)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get accrued charges: %w", err)
+	}
+
+	return platform, ocf, nil
+}
+
+// listCustomerCharges returns the charges collected for periods starting in [from, to]
+func (r *Repository) listCustomerCharges(ctx context.Context, customerID string, from, to time.Time) ([]models.Charge, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT c.id, c.customer_id, c.account_id, c.fund_id, f.name, COALESCE(c.investment_id::text, ''), c.charge_type,
+            TO_CHAR(c.period_start, 'YYYY-MM-DD'), TO_CHAR(c.period_end, 'YYYY-MM-DD'), c.amount, c.method, c.created_at
+        FROM charges c
+        JOIN funds f ON f.id = c.fund_id
+        WHERE c.customer_id = $1 AND c.period_start >= $2::date AND c.period_start <= $3::date
+        ORDER BY c.period_start, f.name, c.charge_type
+    `, customerID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query charges: %w", err)
+	}
+	defer rows.Close()
+
+	charges := []models.Charge{}
+	for rows.Next() {
+		var charge models.Charge
+		if err := rows.Scan(
+			&charge.ID,
+			&charge.CustomerID,
+			&charge.AccountID,
+			&charge.FundID,
+			&charge.FundName,
+			&charge.InvestmentID,
+			&charge.Type,
+			&charge.PeriodStart,
+			&charge.PeriodEnd,
+			&charge.Amount,
+			&charge.Method,
+			&charge.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan charge: %w", err)
+		}
+		charges = append(charges, charge)
+	}
+
+	return charges, nil
+}
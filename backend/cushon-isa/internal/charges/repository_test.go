@@ -0,0 +1,241 @@
+package charges
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var holdingRowColumns = []string{"account_id", "units", "unpriced_cash"}
+
+func expectLedgerPosting(mock sqlmock.Sqlmock, investmentID string, amount, units float64) {
+	mock.ExpectQuery("SELECT type, customer_id, fund_id, amount, units FROM investments WHERE id = \\$1").
+		WithArgs(investmentID).
+		WillReturnRows(sqlmock.NewRows([]string{"type", "customer_id", "fund_id", "amount", "units"}).
+			AddRow(models.InvestmentTypeCharge, "customer1", "fund1", amount, units))
+	mock.ExpectExec("INSERT INTO ledger_accounts").
+		WillReturnResult(sqlmock.NewResult(0, 4))
+	mock.ExpectExec("INSERT INTO ledger_journals (.+) INSERT INTO ledger_postings").
+		WithArgs(investmentID, "charge", "charge settled", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), false).
+		WillReturnResult(sqlmock.NewResult(0, 4))
+}
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestRepository_AccrueCharges(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	day := date(2025, 1, 2)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM charge_accruals WHERE accrual_date = \\$1::date").
+		WithArgs(day).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("WITH year AS (.+) INSERT INTO charge_accruals (.+) 'platform'").
+		WithArgs(day).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("INSERT INTO charge_accruals (.+) 'ocf'").
+		WithArgs(day).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("INSERT INTO charge_accrual_runs (.+) ON CONFLICT").
+		WithArgs(day, int64(4)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	accruals, err := repo.accrueCharges(context.Background(), day)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, accruals)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_CollectCharge(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	start, end := date(2025, 1, 1), date(2025, 2, 1)
+	pending := pendingCharge{customerID: "customer1", fundID: "fund1", chargeType: models.ChargeTypePlatform, amount: 2.50}
+	expectPrice := func(price interface{}) {
+		rows := sqlmock.NewRows([]string{"price"})
+		if price != nil {
+			rows.AddRow(price)
+		}
+		mock.ExpectQuery("SELECT price FROM fund_prices WHERE fund_id = \\$1 AND price_date < \\$2::date").
+			WithArgs("fund1", end).
+			WillReturnRows(rows)
+	}
+	expectHoldings := func(rows *sqlmock.Rows) {
+		mock.ExpectQuery("SELECT account_id, (.+) FROM investments WHERE customer_id = \\$1 AND fund_id = \\$2 (.+) GROUP BY account_id").
+			WithArgs("customer1", "fund1").
+			WillReturnRows(rows)
+	}
+	expectCollected := func(investmentID, accountID string, amount float64, unitPrice, units sql.NullFloat64, method, chargeID string) {
+		mock.ExpectQuery("INSERT INTO investments (.+) 'charge', 'settled'").
+			WithArgs("customer1", "fund1", amount, date(2025, 1, 31), unitPrice, units, accountID).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(investmentID))
+		mock.ExpectExec("INSERT INTO investment_status_history").
+			WithArgs(investmentID, "platform charge for 2025-01").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectLedgerPosting(mock, investmentID, amount, units.Float64)
+		mock.ExpectQuery("INSERT INTO charges (.+) ON CONFLICT \\(account_id, fund_id, charge_type, period_start\\) DO NOTHING").
+			WithArgs("customer1", "fund1", investmentID, "platform", start, date(2025, 1, 31), amount, method, accountID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(chargeID, time.Now()))
+	}
+	price := sql.NullFloat64{Float64: 1.25, Valid: true}
+
+	t.Run("sells units", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectPrice(1.25)
+		expectHoldings(sqlmock.NewRows(holdingRowColumns).AddRow("account1", 100.0, 0.0))
+		expectCollected("investment1", "account1", 2.50, price, sql.NullFloat64{Float64: 2, Valid: true}, "units", "charge1")
+		mock.ExpectCommit()
+
+		charges, err := repo.collectCharge(context.Background(), pending, start, end)
+		require.NoError(t, err)
+		require.Len(t, charges, 1)
+		assert.Equal(t, "charge1", charges[0].ID)
+		assert.Equal(t, "account1", charges[0].AccountID)
+		assert.Equal(t, models.ChargeMethodUnits, charges[0].Method)
+		assert.Equal(t, "2025-01-31", charges[0].PeriodEnd)
+	})
+
+	// Note: The fee was tiered on both accounts so each pays its share by value
+	t.Run("spread across accounts", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectPrice(1.25)
+		expectHoldings(sqlmock.NewRows(holdingRowColumns).
+			AddRow("account1", 300.0, 0.0).
+			AddRow("account2", 100.0, 0.0))
+		expectCollected("investment2", "account1", 1.88, price, sql.NullFloat64{Float64: 1.504, Valid: true}, "units", "charge2")
+		expectCollected("investment3", "account2", 0.62, price, sql.NullFloat64{Float64: 0.496, Valid: true}, "units", "charge3")
+		mock.ExpectCommit()
+
+		charges, err := repo.collectCharge(context.Background(), pending, start, end)
+		require.NoError(t, err)
+		require.Len(t, charges, 2)
+		assert.Equal(t, 2.50, charges[0].Amount+charges[1].Amount)
+		assert.Equal(t, "account2", charges[1].AccountID)
+	})
+
+	t.Run("capped at holding value", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectPrice(1.25)
+		expectHoldings(sqlmock.NewRows(holdingRowColumns).AddRow("account1", 1.0, 0.0))
+		expectCollected("investment4", "account1", 1.25, price, sql.NullFloat64{Float64: 1, Valid: true}, "units", "charge4")
+		mock.ExpectCommit()
+
+		charges, err := repo.collectCharge(context.Background(), pending, start, end)
+		require.NoError(t, err)
+		require.Len(t, charges, 1)
+		assert.Equal(t, 1.25, charges[0].Amount)
+	})
+
+	t.Run("held at cost", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectPrice(nil)
+		expectHoldings(sqlmock.NewRows(holdingRowColumns).AddRow("account1", 0.0, 500.0))
+		expectCollected("investment5", "account1", 2.50, sql.NullFloat64{}, sql.NullFloat64{}, "cash", "charge5")
+		mock.ExpectCommit()
+
+		charges, err := repo.collectCharge(context.Background(), pending, start, end)
+		require.NoError(t, err)
+		require.Len(t, charges, 1)
+		assert.Equal(t, models.ChargeMethodCash, charges[0].Method)
+	})
+
+	t.Run("already collected", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectPrice(1.25)
+		expectHoldings(sqlmock.NewRows(holdingRowColumns).AddRow("account1", 100.0, 0.0))
+		mock.ExpectQuery("INSERT INTO investments").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("investment6"))
+		mock.ExpectExec("INSERT INTO investment_status_history").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectLedgerPosting(mock, "investment6", 2.50, 2)
+		mock.ExpectQuery("INSERT INTO charges").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}))
+		mock.ExpectRollback()
+
+		charges, err := repo.collectCharge(context.Background(), pending, start, end)
+		assert.NoError(t, err)
+		assert.Nil(t, charges)
+	})
+
+	t.Run("nothing held", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectPrice(1.25)
+		expectHoldings(sqlmock.NewRows(holdingRowColumns).AddRow("account1", 0.0, 0.0))
+		mock.ExpectRollback()
+
+		charges, err := repo.collectCharge(context.Background(), pending, start, end)
+		assert.NoError(t, err)
+		assert.Nil(t, charges)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestService_Collect(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	service := NewService(NewRepository(db))
+	ctx := context.Background()
+
+	_, err = service.collect(ctx, "January", date(2025, 2, 1))
+	assert.ErrorIs(t, err, isaerrors.ErrInvalidChargePeriod)
+
+	_, err = service.collect(ctx, "2025-01", date(2025, 1, 31))
+	assert.ErrorIs(t, err, isaerrors.ErrChargePeriodNotEnded)
+
+	mock.ExpectQuery("SELECT EXISTS\\(SELECT 1 FROM charge_collection_runs").
+		WithArgs(date(2025, 1, 1)).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	_, err = service.collect(ctx, "2025-01", date(2025, 2, 1))
+	assert.ErrorIs(t, err, isaerrors.ErrChargesAlreadyCollected)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestValidateFeeTiers(t *testing.T) {
+	bound := func(v float64) *float64 { return &v }
+
+	valid := []models.PlatformFeeTier{
+		{LowerBound: 0, UpperBound: bound(250000), AnnualRate: 0.30},
+		{LowerBound: 250000, UpperBound: nil, AnnualRate: 0.15},
+	}
+	assert.NoError(t, validateFeeTiers(valid))
+
+	tests := []struct {
+		name  string
+		tiers []models.PlatformFeeTier
+	}{
+		{"empty", nil},
+		{"not from zero", []models.PlatformFeeTier{{LowerBound: 100, AnnualRate: 0.3}}},
+		{"gap", []models.PlatformFeeTier{
+			{LowerBound: 0, UpperBound: bound(100), AnnualRate: 0.3},
+			{LowerBound: 200, AnnualRate: 0.1},
+		}},
+		{"bounded top tier", []models.PlatformFeeTier{{LowerBound: 0, UpperBound: bound(100), AnnualRate: 0.3}}},
+		{"negative rate", []models.PlatformFeeTier{{LowerBound: 0, AnnualRate: -0.1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, validateFeeTiers(tt.tiers), isaerrors.ErrValidation)
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package charges
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/stcol316/cushon-isa/internal/models"
+)
+
+// Note: Accrues charges once a night after the valuation job, and collects the previous
+// month on the first run of each month
+type Scheduler struct {
+	service  *Service
+	hour     int
+	minute   int
+	location *time.Location
+}
+
+// NewScheduler takes the run time as HH:MM in the given IANA time zone
+func NewScheduler(service *Service, runAt, timezone string) (*Scheduler, error) {
+	parsed, err := time.Parse("15:04", runAt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid charges time %q: %w", runAt, err)
+	}
+
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid charges time zone %q: %w", timezone, err)
+	}
+
+	return &Scheduler{
+		service:  service,
+		hour:     parsed.Hour(),
+		minute:   parsed.Minute(),
+		location: location,
+	}, nil
+}
+
+func (s *Scheduler) today(now time.Time) time.Time {
+	return dateOnly(now.In(s.location))
+}
+
+func (s *Scheduler) nextRun(now time.Time) time.Time {
+	now = now.In(s.location)
+	next := time.Date(now.Year(), now.Month(), now.Day(), s.hour, s.minute, 0, 0, s.location)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// Note: Charges go routine. Stops when the context is cancelled
+func (s *Scheduler) Start(ctx context.Context) {
+	go func() {
+		for {
+			next := s.nextRun(time.Now())
+			log.Printf("Next charges run at %s", next.Format(time.RFC3339))
+
+			timer := time.NewTimer(time.Until(next))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			if _, err := s.service.accrue(ctx); err != nil {
+				log.Printf("Charge accrual failed: %v", err)
+				continue
+			}
+			if _, err := s.service.collectDue(ctx, s.today(next)); err != nil {
+				log.Printf("Charge collection failed: %v", err)
+			}
+		}
+	}()
+}
+
+// AccrueNow brings accruals up to the latest valuation immediately
+func (s *Scheduler) AccrueNow(ctx context.Context) (*models.ChargeAccrualRun, error) {
+	return s.service.accrue(ctx)
+}
+
+// Collect collects a specific month, e.g. after the scheduled run failed
+func (s *Scheduler) Collect(ctx context.Context, month string) (*models.ChargeCollectionRun, error) {
+	return s.service.collect(ctx, month, s.today(time.Now()))
+}
+
+// CustomerCharges returns a customer's charges disclosure, defaulting to the last twelve months
+func (s *Scheduler) CustomerCharges(ctx context.Context, customerID, from, to string) (*models.ChargeSummary, error) {
+	return s.service.getCustomerCharges(ctx, customerID, from, to, s.today(time.Now()))
+}
@@ -0,0 +1,254 @@
+package charges
+
+import (
+	"context"
+	"log"
+	"math"
+	"time"
+
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/models"
+)
+
+const (
+	dateLayout  = "2006-01-02"
+	monthLayout = "2006-01"
+	// Note: Matches the valuation job, which revalues the last few days to pick up late allocations
+	reaccrueDays = 5
+)
+
+type Service struct {
+	repo *Repository
+}
+
+func NewService(repo *Repository) *Service {
+	return &Service{repo: repo}
+}
+
+// Note: Accrues every valued date since the last run, plus the trailing window that the valuation
+// job may have revalued. Dates in months that have already been collected are never reaccrued
+func (s *Service) accrue(ctx context.Context) (*models.ChargeAccrualRun, error) {
+	to, err := s.repo.getLastValuationDate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if to == nil {
+		return &models.ChargeAccrualRun{}, nil
+	}
+
+	from := to.AddDate(0, 0, -reaccrueDays)
+	last, err := s.repo.getLastAccrualDate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if last != nil {
+		if next := last.AddDate(0, 0, 1); next.Before(from) {
+			from = next
+		}
+	} else {
+		first, err := s.repo.getFirstValuationDate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		from = *first
+	}
+
+	collected, err := s.repo.getLastCollectedPeriod(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if collected != nil {
+		if open := collected.AddDate(0, 1, 0); from.Before(open) {
+			from = open
+		}
+	}
+
+	from, until := dateOnly(from), dateOnly(*to)
+	run := &models.ChargeAccrualRun{From: from.Format(dateLayout), To: until.Format(dateLayout)}
+	for date := from; !date.After(until); date = date.AddDate(0, 0, 1) {
+		accruals, err := s.repo.accrueCharges(ctx, date)
+		if err != nil {
+			return nil, err
+		}
+		run.Dates++
+		run.Accruals += accruals
+	}
+
+	log.Printf("Charge accrual %s to %s: %d dates, %d accruals", run.From, run.To, run.Dates, run.Accruals)
+	return run, nil
+}
+
+// Note: Collects the platform and fixed charges for a month once it has ended.
+// Accruals are brought up to date first so the last days of the month are included
+func (s *Service) collect(ctx context.Context, month string, today time.Time) (*models.ChargeCollectionRun, error) {
+	start, err := time.Parse(monthLayout, month)
+	if err != nil {
+		return nil, isaerrors.ErrInvalidChargePeriod
+	}
+	end := start.AddDate(0, 1, 0)
+	if end.After(today) {
+		return nil, isaerrors.ErrChargePeriodNotEnded
+	}
+
+	collected, err := s.repo.isCollected(ctx, start)
+	if err != nil {
+		return nil, err
+	}
+	if collected {
+		return nil, isaerrors.ErrChargesAlreadyCollected
+	}
+
+	if _, err := s.accrue(ctx); err != nil {
+		return nil, err
+	}
+
+	platform, err := s.repo.listPlatformCharges(ctx, start, end)
+	if err != nil {
+		return nil, err
+	}
+	fixed, err := s.repo.listFixedCharges(ctx, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	run := &models.ChargeCollectionRun{Month: month}
+	for _, pending := range append(platform, fixed...) {
+		charges, err := s.repo.collectCharge(ctx, pending, start, end)
+		if err != nil {
+			return nil, err
+		}
+		for _, charge := range charges {
+			run.Charges++
+			run.Amount += charge.Amount
+		}
+	}
+	run.Amount = roundPence(run.Amount)
+
+	if err := s.repo.completeCollection(ctx, start, run.Charges); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Charge collection for %s: %d charges, %.2f collected", run.Month, run.Charges, run.Amount)
+	return run, nil
+}
+
+// collectDue collects the previous month if it has not been collected yet
+func (s *Service) collectDue(ctx context.Context, today time.Time) (*models.ChargeCollectionRun, error) {
+	month := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -1, 0)
+	collected, err := s.repo.isCollected(ctx, month)
+	if err != nil {
+		return nil, err
+	}
+	if collected {
+		return nil, nil
+	}
+
+	return s.collect(ctx, month.Format(monthLayout), today)
+}
+
+func (s *Service) getFeeTiers(ctx context.Context) ([]models.PlatformFeeTier, error) {
+	return s.repo.getFeeTiers(ctx)
+}
+
+func (s *Service) updateFeeTiers(ctx context.Context, req *models.UpdateFeeTiersRequest) ([]models.PlatformFeeTier, error) {
+	if err := validateFeeTiers(req.Tiers); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.replaceFeeTiers(ctx, req.Tiers); err != nil {
+		return nil, err
+	}
+
+	return req.Tiers, nil
+}
+
+// Note: Every portfolio value must fall in exactly one band, so the bands start at zero,
+// each starts where the previous one ends and the last has no upper bound
+func validateFeeTiers(tiers []models.PlatformFeeTier) error {
+	if len(tiers) == 0 || tiers[0].LowerBound != 0 {
+		return isaerrors.ErrInvalidFeeTiers
+	}
+
+	for i, tier := range tiers {
+		if tier.AnnualRate < 0 || tier.AnnualRate >= 100 {
+			return isaerrors.Validation("invalid_fee_rate", "annual rate must be a percentage between 0 and 100")
+		}
+
+		last := i == len(tiers)-1
+		if last != (tier.UpperBound == nil) {
+			return isaerrors.ErrInvalidFeeTiers
+		}
+		if last {
+			break
+		}
+		if *tier.UpperBound <= tier.LowerBound || tiers[i+1].LowerBound != *tier.UpperBound {
+			return isaerrors.ErrInvalidFeeTiers
+		}
+	}
+
+	return nil
+}
+
+// Note: Defaults to the last twelve months. Both dates are inclusive
+func (s *Service) getCustomerCharges(ctx context.Context, customerID, from, to string, today time.Time) (*models.ChargeSummary, error) {
+	end := today
+	if to != "" {
+		parsed, err := time.Parse(dateLayout, to)
+		if err != nil {
+			return nil, isaerrors.ErrInvalidDealingDate
+		}
+		end = parsed
+	}
+	start := end.AddDate(-1, 0, 1)
+	if from != "" {
+		parsed, err := time.Parse(dateLayout, from)
+		if err != nil {
+			return nil, isaerrors.ErrInvalidDealingDate
+		}
+		start = parsed
+	}
+	if start.After(end) {
+		return nil, isaerrors.Validation("invalid_date_range", "from cannot be after to")
+	}
+
+	platform, ocf, err := s.repo.getAccruedCharges(ctx, customerID, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	collected, err := s.repo.listCustomerCharges(ctx, customerID, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &models.ChargeSummary{
+		CustomerID:      customerID,
+		From:            start.Format(dateLayout),
+		To:              end.Format(dateLayout),
+		PlatformCharges: platform,
+		FundCharges:     ocf,
+		Collected:       collected,
+	}
+	for _, charge := range collected {
+		if charge.Type == models.ChargeTypeFixed {
+			summary.FixedCharges += charge.Amount
+		}
+	}
+	summary.FixedCharges = roundPence(summary.FixedCharges)
+	summary.TotalCharges = roundPence(summary.PlatformCharges + summary.FundCharges + summary.FixedCharges)
+
+	return summary, nil
+}
+
+func dateOnly(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func roundPence(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+func roundUnits(value float64) float64 {
+	return math.Round(value*1e6) / 1e6
+}
@@ -4,6 +4,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
 
 	"github.com/joho/godotenv"
 )
@@ -22,6 +23,51 @@ type Config struct {
 
 	// JWT
 	JWTSecret string
+
+	// Encryption
+	NIEncryptionKey string
+
+	// Email
+	EmailProvider        string
+	SMTPHost             string
+	SMTPPort             string
+	SMTPUsername         string
+	SMTPPassword         string
+	EmailFrom            string
+	EmailVerificationURL string
+
+	// KYC
+	KYCProvider       string
+	KYCCallbackSecret string
+
+	// AML
+	AMLSingleDepositHoldAbove   float64
+	AMLSingleDepositRejectAbove float64
+	AMLVelocityWindowDays       int
+	AMLVelocityMaxCount         int
+	AMLVelocityMaxAmount        float64
+	AMLRapidWithdrawalDays      int
+
+	// ISA
+	ISAAnnualAllowance          float64
+	JuniorISAAnnualAllowance    float64
+	JuniorISAConversionTime     string
+	JuniorISAConversionTimezone string
+	LifetimeISAAnnualAllowance  float64
+	LifetimeISABonusTime        string
+	LifetimeISABonusTimezone    string
+
+	// Settlement
+	SettlementCutoff   string
+	SettlementTimezone string
+
+	// Valuation
+	ValuationTime     string
+	ValuationTimezone string
+
+	// Charges
+	ChargesTime     string
+	ChargesTimezone string
 }
 
 func Load() (*Config, error) {
@@ -43,6 +89,51 @@ func Load() (*Config, error) {
 
 		// JWT
 		JWTSecret: requireEnv("JWT_SECRET"),
+
+		// Encryption
+		NIEncryptionKey: requireEnv("NI_ENCRYPTION_KEY"),
+
+		// Email
+		EmailProvider:        requireEnv("EMAIL_PROVIDER"),
+		SMTPHost:             os.Getenv("SMTP_HOST"),
+		SMTPPort:             getEnvWithDefault("SMTP_PORT", "587"),
+		SMTPUsername:         os.Getenv("SMTP_USERNAME"),
+		SMTPPassword:         os.Getenv("SMTP_PASSWORD"),
+		EmailFrom:            os.Getenv("EMAIL_FROM"),
+		EmailVerificationURL: os.Getenv("EMAIL_VERIFICATION_URL"),
+
+		// KYC
+		KYCProvider:       getEnvWithDefault("KYC_PROVIDER", "stub"),
+		KYCCallbackSecret: requireEnv("KYC_CALLBACK_SECRET"),
+
+		// AML
+		AMLSingleDepositHoldAbove:   getEnvFloatWithDefault("AML_SINGLE_DEPOSIT_HOLD_ABOVE", 10000),
+		AMLSingleDepositRejectAbove: getEnvFloatWithDefault("AML_SINGLE_DEPOSIT_REJECT_ABOVE", 20000),
+		AMLVelocityWindowDays:       getEnvIntWithDefault("AML_VELOCITY_WINDOW_DAYS", 7),
+		AMLVelocityMaxCount:         getEnvIntWithDefault("AML_VELOCITY_MAX_COUNT", 5),
+		AMLVelocityMaxAmount:        getEnvFloatWithDefault("AML_VELOCITY_MAX_AMOUNT", 15000),
+		AMLRapidWithdrawalDays:      getEnvIntWithDefault("AML_RAPID_WITHDRAWAL_DAYS", 30),
+
+		// ISA
+		ISAAnnualAllowance:          getEnvFloatWithDefault("ISA_ANNUAL_ALLOWANCE", 20000),
+		JuniorISAAnnualAllowance:    getEnvFloatWithDefault("JUNIOR_ISA_ANNUAL_ALLOWANCE", 9000),
+		JuniorISAConversionTime:     getEnvWithDefault("JUNIOR_ISA_CONVERSION_TIME", "01:00"),
+		JuniorISAConversionTimezone: getEnvWithDefault("JUNIOR_ISA_CONVERSION_TIMEZONE", "Europe/London"),
+		LifetimeISAAnnualAllowance:  getEnvFloatWithDefault("LIFETIME_ISA_ANNUAL_ALLOWANCE", 4000),
+		LifetimeISABonusTime:        getEnvWithDefault("LIFETIME_ISA_BONUS_TIME", "02:00"),
+		LifetimeISABonusTimezone:    getEnvWithDefault("LIFETIME_ISA_BONUS_TIMEZONE", "Europe/London"),
+
+		// Settlement
+		SettlementCutoff:   getEnvWithDefault("SETTLEMENT_CUTOFF", "12:00"),
+		SettlementTimezone: getEnvWithDefault("SETTLEMENT_TIMEZONE", "Europe/London"),
+
+		// Valuation
+		ValuationTime:     getEnvWithDefault("VALUATION_TIME", "22:00"),
+		ValuationTimezone: getEnvWithDefault("VALUATION_TIMEZONE", "Europe/London"),
+
+		// Charges
+		ChargesTime:     getEnvWithDefault("CHARGES_TIME", "23:00"),
+		ChargesTimezone: getEnvWithDefault("CHARGES_TIMEZONE", "Europe/London"),
 	}
 
 	if err := config.Validate(); err != nil {
@@ -58,6 +149,8 @@ func (c *Config) Validate() error {
 	}{
 		{"DB_PASSWORD", c.DBPassword},
 		{"JWT_SECRET", c.JWTSecret},
+		{"NI_ENCRYPTION_KEY", c.NIEncryptionKey},
+		{"EMAIL_PROVIDER", c.EmailProvider},
 	}
 
 	for _, r := range required {
@@ -76,6 +169,32 @@ func getEnvWithDefault(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getEnvIntWithDefault(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		fmt.Printf("Warning: environment variable %s is not a valid integer, using default %d\n", key, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+func getEnvFloatWithDefault(key string, defaultValue float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		fmt.Printf("Warning: environment variable %s is not a valid number, using default %v\n", key, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
 func requireEnv(key string) string {
 	value := os.Getenv(key)
 	if value == "" {
@@ -1,24 +1,24 @@
 package customer
 
 import (
-	"database/sql"
 	"encoding/json"
-	"errors"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
 	"github.com/stcol316/cushon-isa/internal/models"
 	"github.com/stcol316/cushon-isa/pkg/helpers"
 	helper "github.com/stcol316/cushon-isa/pkg/helpers"
 )
 
 type Handler struct {
-	service *Service
+	service    *Service
+	conversion *ConversionScheduler
 }
 
-func NewHandler(service *Service) *Handler {
-	return &Handler{service: service}
+func NewHandler(service *Service, conversion *ConversionScheduler) *Handler {
+	return &Handler{service: service, conversion: conversion}
 }
 
 func (h *Handler) CreateRetailCustomerHandler(w http.ResponseWriter, r *http.Request) {
@@ -30,13 +30,13 @@ func (h *Handler) CreateRetailCustomerHandler(w http.ResponseWriter, r *http.Req
 	// We could probably split this out into a common decode and validate helper function
 	req := new(models.CreateRetailCustomerRequest)
 	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
-		h.handleCustomerError(w, err)
+		helper.RespondWithProblem(w, r, isaerrors.ErrInvalidRequestBody.Wrap(err))
 		return
 	}
 
 	customer, err := h.service.createRetailCustomer(r.Context(), req)
 	if err != nil {
-		h.handleCustomerError(w, err)
+		helper.RespondWithProblem(w, r, err)
 		return
 	}
 
@@ -57,7 +57,7 @@ func (h *Handler) GetRetailCustomerByIdHandler(w http.ResponseWriter, r *http.Re
 
 	customer, err := h.service.getRetailCustomerByID(r.Context(), id)
 	if err != nil {
-		h.handleCustomerError(w, err)
+		helper.RespondWithProblem(w, r, err)
 		return
 	}
 
@@ -73,18 +73,173 @@ func (h *Handler) GetRetailCustomerByEmailHandler(w http.ResponseWriter, r *http
 
 	customer, err := h.service.getRetailCustomerByEmail(r.Context(), id)
 	if err != nil {
-		h.handleCustomerError(w, err)
+		helper.RespondWithProblem(w, r, err)
 		return
 	}
 
 	helper.RespondWithJSON(w, http.StatusOK, customer)
 }
 
-func (h *Handler) handleCustomerError(w http.ResponseWriter, err error) {
-	switch {
-	case errors.Is(err, sql.ErrNoRows):
-		helper.RespondWithError(w, http.StatusNotFound, "customer not found")
-	default:
-		helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+func (h *Handler) UpdateRetailCustomerHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := customerIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+		helpers.RespondWithError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/json")
+		return
+	}
+
+	req := new(models.UpdateRetailCustomerRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		helper.RespondWithProblem(w, r, isaerrors.ErrInvalidRequestBody.Wrap(err))
+		return
+	}
+
+	customer, err := h.service.updateRetailCustomer(r.Context(), id, req)
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
 	}
+
+	helper.RespondWithJSON(w, http.StatusOK, customer)
+}
+
+func (h *Handler) VerifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := customerIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+		helpers.RespondWithError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/json")
+		return
+	}
+
+	req := new(models.VerifyEmailRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		helper.RespondWithProblem(w, r, isaerrors.ErrInvalidRequestBody.Wrap(err))
+		return
+	}
+
+	if err := h.service.verifyEmail(r.Context(), id, req.Token); err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) ResendEmailVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := customerIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.service.resendEmailVerification(r.Context(), id); err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *Handler) CloseRetailCustomerHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := customerIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	customer, err := h.service.closeRetailCustomer(r.Context(), id)
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, customer)
+}
+
+func (h *Handler) EraseRetailCustomerHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := customerIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	customer, err := h.service.eraseRetailCustomer(r.Context(), id)
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, customer)
+}
+
+func (h *Handler) CreateJuniorISAHandler(w http.ResponseWriter, r *http.Request) {
+	if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+		helpers.RespondWithError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/json")
+		return
+	}
+
+	req := new(models.CreateJuniorISARequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		helper.RespondWithProblem(w, r, isaerrors.ErrInvalidRequestBody.Wrap(err))
+		return
+	}
+
+	if _, err := uuid.Parse(req.RegisteredContactID); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "invalid registered contact ID format")
+		return
+	}
+
+	customer, err := h.service.createJuniorISA(r.Context(), req)
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusCreated, customer)
+}
+
+// Note: The Junior ISAs a customer runs as registered contact
+func (h *Handler) ListJuniorISAsHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := customerIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	juniors, err := h.service.listJuniorISAs(r.Context(), id)
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, juniors)
+}
+
+// Note: Admin only. Runs the junior ISA conversion immediately, e.g. after the scheduled run failed
+func (h *Handler) RunJuniorISAConversionHandler(w http.ResponseWriter, r *http.Request) {
+	run, err := h.conversion.RunNow(r.Context())
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, run)
+}
+
+// customerIDParam extracts and validates the customer ID path parameter, writing an error response if invalid
+func customerIDParam(w http.ResponseWriter, r *http.Request) (string, bool) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "customer ID is required")
+		return "", false
+	}
+
+	if _, err := uuid.Parse(id); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "invalid customer ID format")
+		return "", false
+	}
+
+	return id, true
 }
@@ -4,7 +4,10 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"time"
 
+	"github.com/lib/pq"
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
 	"github.com/stcol316/cushon-isa/internal/models"
 )
 
@@ -12,8 +15,35 @@ type CustomerRepository interface {
 	CreateRetailCustomer(ctx context.Context, customer *models.RetailCustomer) error
 	GetRetailCustomerByEmail(ctx context.Context, email string) (*models.RetailCustomer, error)
 	GetRetailCustomerByID(ctx context.Context, id string) (*models.RetailCustomer, error)
+	UpdateRetailCustomer(ctx context.Context, customer *models.RetailCustomer, changedFields []string, verificationToken string) error
+	VerifyEmail(ctx context.Context, id, token string) error
+	SetEmailVerificationToken(ctx context.Context, id, token string) error
+	CloseRetailCustomer(ctx context.Context, id string) error
+	EraseRetailCustomer(ctx context.Context, id string) error
+	CreateJuniorISA(ctx context.Context, customer *models.RetailCustomer) error
+	ListJuniorISAs(ctx context.Context, registeredContactID string) ([]models.RetailCustomer, error)
+	ConvertJuniorISAs(ctx context.Context, on time.Time) (int, error)
 }
 
+// Audit event types
+const (
+	auditProfileUpdated = "profile_updated"
+	auditEmailVerified  = "email_verified"
+	auditEmailResent    = "email_verification_resent"
+	auditAccountClosed  = "account_closed"
+	auditErased         = "personal_data_erased"
+	auditJuniorOpened   = "junior_isa_opened"
+	auditJuniorConvert  = "junior_isa_converted"
+)
+
+// Note: Optional columns are coalesced so they can be scanned directly into strings
+const customerColumns = `
+	id, COALESCE(first_name, ''), COALESCE(last_name, ''), COALESCE(email, ''), email_verified,
+	COALESCE(address_line1, ''), COALESCE(address_line2, ''), COALESCE(city, ''),
+	COALESCE(postcode, ''), COALESCE(country, ''), status,
+	COALESCE(TO_CHAR(date_of_birth, 'YYYY-MM-DD'), ''), uk_resident, COALESCE(ni_number_encrypted, ''),
+	kyc_status, isa_product, COALESCE(registered_contact_id::text, ''), converted_at`
+
 type Repository struct {
 	db *sql.DB
 }
@@ -22,18 +52,71 @@ func NewRepository(db *sql.DB) *Repository {
 	return &Repository{db: db}
 }
 
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanCustomer(row rowScanner) (*models.RetailCustomer, error) {
+	var customer models.RetailCustomer
+	var converted sql.NullTime
+	err := row.Scan(
+		&customer.ID,
+		&customer.FirstName,
+		&customer.LastName,
+		&customer.Email,
+		&customer.EmailVerified,
+		&customer.Address.Line1,
+		&customer.Address.Line2,
+		&customer.Address.City,
+		&customer.Address.Postcode,
+		&customer.Address.Country,
+		&customer.Status,
+		&customer.DateOfBirth,
+		&customer.UKResident,
+		&customer.EncryptedNINumber,
+		&customer.KYCStatus,
+		&customer.Product,
+		&customer.RegisteredContactID,
+		&converted,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, isaerrors.ErrCustomerNotFound
+		}
+		return nil, fmt.Errorf("failed to get customer: %w", err)
+	}
+	if converted.Valid {
+		customer.ConvertedAt = &converted.Time
+	}
+
+	return &customer, nil
+}
+
 func (r *Repository) createRetailCustomer(ctx context.Context, customer *models.RetailCustomer) error {
+	// Note: Customers open with an ISA account, written in the same statement as the customer
 	query := `
-	INSERT INTO retail_customers (first_name, last_name, email)
-	VALUES ($1, $2, $3)
+	WITH customer AS (
+		INSERT INTO retail_customers (first_name, last_name, email, date_of_birth, uk_resident, ni_number_encrypted)
+		VALUES ($1, $2, $3, NULLIF($4, '')::date, $5, NULLIF($6, ''))
+		RETURNING id
+	)
+	INSERT INTO accounts (customer_id, product)
+	SELECT id, 'isa' FROM customer
 `
 
 	_, err := r.db.ExecContext(ctx, query,
 		customer.FirstName,
 		customer.LastName,
 		customer.Email,
+		customer.DateOfBirth,
+		customer.UKResident,
+		customer.EncryptedNINumber,
 	)
 	if err != nil {
+		if isaerrors.IsUniqueViolation(err) {
+			return isaerrors.ErrEmailAlreadyExists.Wrap(err)
+		}
 		return fmt.Errorf("failed to create retail customer: %w", err)
 	}
 
@@ -42,48 +125,373 @@ func (r *Repository) createRetailCustomer(ctx context.Context, customer *models.
 
 func (r *Repository) getRetailCustomerByEmail(ctx context.Context, email string) (*models.RetailCustomer, error) {
 	query := `
-	SELECT id, first_name, last_name, email
+	SELECT` + customerColumns + `
 	FROM retail_customers
 	WHERE email = $1
 `
-	var customer models.RetailCustomer
-	err := r.db.QueryRowContext(ctx, query, email).Scan(
-		&customer.ID,
-		&customer.FirstName,
-		&customer.LastName,
-		&customer.Email,
-	)
-
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("customer not found")
-		}
-		return nil, fmt.Errorf("failed to get customer: %w", err)
-	}
-
-	return &customer, nil
+	return scanCustomer(r.db.QueryRowContext(ctx, query, email))
 }
 
 func (r *Repository) getRetailCustomerByID(ctx context.Context, id string) (*models.RetailCustomer, error) {
 	query := `
-	SELECT id, first_name, last_name, email
+	SELECT` + customerColumns + `
 	FROM retail_customers
 	WHERE id = $1
 `
-	var customer models.RetailCustomer
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&customer.ID,
-		&customer.FirstName,
-		&customer.LastName,
-		&customer.Email,
+	return scanCustomer(r.db.QueryRowContext(ctx, query, id))
+}
+
+// Note: The update and its audit event are written in a single transaction
+func (r *Repository) updateRetailCustomer(ctx context.Context, customer *models.RetailCustomer, changedFields []string, verificationToken string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+	UPDATE retail_customers
+	SET first_name = $2, last_name = $3, email = NULLIF($4, ''), email_verified = $5,
+		email_verification_token = COALESCE(NULLIF($6, ''), email_verification_token),
+		address_line1 = $7, address_line2 = $8, city = $9, postcode = $10, country = $11,
+		date_of_birth = NULLIF($12, '')::date, uk_resident = $13, ni_number_encrypted = NULLIF($14, ''),
+		kyc_status = $15, updated_at = CURRENT_TIMESTAMP
+	WHERE id = $1 AND status <> 'erased'
+`
+	res, err := tx.ExecContext(ctx, query,
+		customer.ID,
+		customer.FirstName,
+		customer.LastName,
+		customer.Email,
+		customer.EmailVerified,
+		verificationToken,
+		customer.Address.Line1,
+		customer.Address.Line2,
+		customer.Address.City,
+		customer.Address.Postcode,
+		customer.Address.Country,
+		customer.DateOfBirth,
+		customer.UKResident,
+		customer.EncryptedNINumber,
+		customer.KYCStatus,
 	)
+	if err != nil {
+		if isaerrors.IsUniqueViolation(err) {
+			return isaerrors.ErrEmailAlreadyExists.Wrap(err)
+		}
+		return fmt.Errorf("failed to update retail customer: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return isaerrors.ErrCustomerNotFound
+	}
+
+	if err := insertAuditEvent(ctx, tx, customer.ID, auditProfileUpdated, changedFields); err != nil {
+		return err
+	}
 
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) verifyEmail(ctx context.Context, id, token string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("customer not found")
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+	UPDATE retail_customers
+	SET email_verified = TRUE, email_verification_token = NULL, updated_at = CURRENT_TIMESTAMP
+	WHERE id = $1 AND email_verification_token = $2
+`, id, token)
+	if err != nil {
+		return fmt.Errorf("failed to verify email: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return isaerrors.ErrInvalidVerificationToken
+	}
+
+	if err := insertAuditEvent(ctx, tx, id, auditEmailVerified, nil); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// Note: Replaces the outstanding token so only the most recently sent link works
+func (r *Repository) setEmailVerificationToken(ctx context.Context, id, token string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+	UPDATE retail_customers
+	SET email_verification_token = $2, updated_at = CURRENT_TIMESTAMP
+	WHERE id = $1 AND status <> 'erased' AND email IS NOT NULL AND email_verified = FALSE
+`, id, token)
+	if err != nil {
+		return fmt.Errorf("failed to set email verification token: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return isaerrors.ErrEmailAlreadyVerified
+	}
+
+	if err := insertAuditEvent(ctx, tx, id, auditEmailResent, nil); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// Note: Closing an account only changes its status. Investments are retained
+// and the investment service refuses new deposits for closed customers
+func (r *Repository) closeRetailCustomer(ctx context.Context, id string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+	UPDATE retail_customers
+	SET status = 'closed', closed_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+	WHERE id = $1 AND status = 'active'
+`, id)
+	if err != nil {
+		return fmt.Errorf("failed to close customer account: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return isaerrors.ErrCustomerClosed
+	}
+
+	if err := closeAccounts(ctx, tx, id); err != nil {
+		return err
+	}
+
+	if err := insertAuditEvent(ctx, tx, id, auditAccountClosed, nil); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// closeAccounts closes every account the customer still has open
+func closeAccounts(ctx context.Context, tx *sql.Tx, customerID string) error {
+	_, err := tx.ExecContext(ctx, `
+	UPDATE accounts
+	SET status = 'closed', closed_at = CURRENT_TIMESTAMP
+	WHERE customer_id = $1 AND status = 'open'
+`, customerID)
+	if err != nil {
+		return fmt.Errorf("failed to close accounts: %w", err)
+	}
+	return nil
+}
+
+// Note: GDPR right to erasure. We must retain financial records for regulatory purposes
+// so rather than deleting the customer we pseudonymise their personal fields.
+// The customer ID is kept so investments and the audit trail remain intact.
+// The materialized view holds names and emails so it must also be refreshed.
+func (r *Repository) eraseRetailCustomer(ctx context.Context, id string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Note: A Junior ISA must always have a registered contact, so the contact for an open one is kept
+	// until the child's account is closed or converted. Closed ones keep pointing at the erased record
+	var contact bool
+	err = tx.QueryRowContext(ctx, `
+	SELECT EXISTS (
+		SELECT 1 FROM retail_customers
+		WHERE registered_contact_id = $1 AND isa_product = 'junior_isa' AND status = 'active'
+	)
+`, id).Scan(&contact)
+	if err != nil {
+		return fmt.Errorf("failed to check junior isas: %w", err)
+	}
+	if contact {
+		return isaerrors.ErrRegisteredContactErasure
+	}
+
+	res, err := tx.ExecContext(ctx, `
+	UPDATE retail_customers
+	SET first_name = 'Erased', last_name = 'Customer',
+		email = id::text || '@erased.invalid', email_verified = FALSE, email_verification_token = NULL,
+		address_line1 = NULL, address_line2 = NULL, city = NULL, postcode = NULL, country = NULL,
+		date_of_birth = NULL, uk_resident = FALSE, ni_number_encrypted = NULL,
+		status = 'erased', closed_at = COALESCE(closed_at, CURRENT_TIMESTAMP),
+		erased_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+	WHERE id = $1 AND status <> 'erased'
+`, id)
+	if err != nil {
+		return fmt.Errorf("failed to erase customer: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return isaerrors.ErrCustomerErased
+	}
+
+	// Note: The provider's reference identifies the person to the provider and the reason can quote
+	// their details. The outcome is kept, the reference stays unique per provider
+	_, err = tx.ExecContext(ctx, `
+	UPDATE kyc_verifications
+	SET provider_reference = 'erased-' || id::text, reason = NULL, updated_at = CURRENT_TIMESTAMP
+	WHERE customer_id = $1
+`, id)
+	if err != nil {
+		return fmt.Errorf("failed to erase identity verifications: %w", err)
+	}
+
+	if err := closeAccounts(ctx, tx, id); err != nil {
+		return err
+	}
+
+	if err := insertAuditEvent(ctx, tx, id, auditErased, nil); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "REFRESH MATERIALIZED VIEW customer_fund_totals"); err != nil {
+		return fmt.Errorf("failed to refresh materialized view: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// Note: The child's record and the audit event are written together. Unlike sign up the new
+// ID is returned as the registered contact needs it to invest on the child's behalf
+func (r *Repository) createJuniorISA(ctx context.Context, customer *models.RetailCustomer) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+	WITH customer AS (
+		INSERT INTO retail_customers (first_name, last_name, email, date_of_birth, uk_resident, ni_number_encrypted,
+			isa_product, registered_contact_id)
+		VALUES ($1, $2, NULLIF($3, ''), $4::date, $5, NULLIF($6, ''), $8, $7)
+		RETURNING id
+	)
+	INSERT INTO accounts (customer_id, product)
+	SELECT id, $8 FROM customer
+	RETURNING customer_id
+`,
+		customer.FirstName,
+		customer.LastName,
+		customer.Email,
+		customer.DateOfBirth,
+		customer.UKResident,
+		customer.EncryptedNINumber,
+		customer.RegisteredContactID,
+		models.ISAProductJunior,
+	).Scan(&customer.ID)
+	if err != nil {
+		if isaerrors.IsUniqueViolation(err) {
+			return isaerrors.ErrEmailAlreadyExists.Wrap(err)
 		}
-		return nil, fmt.Errorf("failed to get customer: %w", err)
+		if isaerrors.IsForeignKeyViolation(err) {
+			return isaerrors.ErrInvalidRegisteredContact
+		}
+		return fmt.Errorf("failed to create junior isa: %w", err)
 	}
 
-	return &customer, nil
+	if err := insertAuditEvent(ctx, tx, customer.ID, auditJuniorOpened, nil); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) listJuniorISAs(ctx context.Context, registeredContactID string) ([]models.RetailCustomer, error) {
+	rows, err := r.db.QueryContext(ctx, `
+	SELECT`+customerColumns+`
+	FROM retail_customers
+	WHERE registered_contact_id = $1
+	ORDER BY date_of_birth, id
+`, registeredContactID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list junior isas: %w", err)
+	}
+	defer rows.Close()
+
+	juniors := []models.RetailCustomer{}
+	for rows.Next() {
+		customer, err := scanCustomer(rows)
+		if err != nil {
+			return nil, err
+		}
+		juniors = append(juniors, *customer)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list junior isas: %w", err)
+	}
+
+	return juniors, nil
+}
+
+// Note: Converts every Junior ISA whose holder is 18 on or before the given date in a single
+// statement, so a failed run leaves nothing half converted and the next run picks them all up.
+// The registered contact's authority ends on conversion and the Junior ISA account becomes the
+// adult ISA account
+func (r *Repository) convertJuniorISAs(ctx context.Context, on time.Time) (int, error) {
+	res, err := r.db.ExecContext(ctx, `
+	WITH converted AS (
+		UPDATE retail_customers
+		SET isa_product = 'isa', registered_contact_id = NULL,
+			converted_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE isa_product = 'junior_isa' AND date_of_birth <= ($1::date - INTERVAL '18 years')
+		RETURNING id
+	), accounts_converted AS (
+		UPDATE accounts
+		SET product = 'isa'
+		WHERE product = 'junior_isa' AND customer_id IN (SELECT id FROM converted)
+	)
+	INSERT INTO customer_audit_events (customer_id, event_type, changed_fields)
+	SELECT id, $2, $3 FROM converted
+`, on.Format(dateLayout), auditJuniorConvert, pq.Array([]string{"product", "registeredContactId"}))
+	if err != nil {
+		return 0, fmt.Errorf("failed to convert junior isas: %w", err)
+	}
+
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+func insertAuditEvent(ctx context.Context, tx *sql.Tx, customerID, eventType string, changedFields []string) error {
+	_, err := tx.ExecContext(ctx, `
+	INSERT INTO customer_audit_events (customer_id, event_type, changed_fields)
+	VALUES ($1, $2, $3)
+`, customerID, eventType, pq.Array(changedFields))
+	if err != nil {
+		return fmt.Errorf("failed to record audit event: %w", err)
+	}
+	return nil
 }
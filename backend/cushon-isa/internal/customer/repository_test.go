@@ -2,13 +2,24 @@ package customer
 
 import (
 	"context"
+	"database/sql"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
 	"github.com/stcol316/cushon-isa/internal/models"
 	"github.com/stretchr/testify/assert"
 )
 
+var customerRowColumns = []string{
+	"id", "first_name", "last_name", "email", "email_verified",
+	"address_line1", "address_line2", "city", "postcode", "country", "status",
+	"date_of_birth", "uk_resident", "ni_number_encrypted", "kyc_status",
+	"isa_product", "registered_contact_id", "converted_at",
+}
+
 func TestCreateRetailCustomer(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -25,8 +36,8 @@ func TestCreateRetailCustomer(t *testing.T) {
 		Email:     "john.doe@example.com",
 	}
 
-	mock.ExpectExec("INSERT INTO retail_customers").
-		WithArgs(customer.FirstName, customer.LastName, customer.Email).
+	mock.ExpectExec("INSERT INTO retail_customers (.+) INSERT INTO accounts (.+) 'isa' FROM customer").
+		WithArgs(customer.FirstName, customer.LastName, customer.Email, "", false, "").
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	err = repo.createRetailCustomer(ctx, customer)
@@ -49,8 +60,9 @@ func TestGetRetailCustomerByEmail(t *testing.T) {
 	ctx := context.Background()
 	email := "john.doe@example.com"
 
-	rows := sqlmock.NewRows([]string{"id", "first_name", "last_name", "email"}).
-		AddRow("1", "John", "Doe", email)
+	rows := sqlmock.NewRows(customerRowColumns).
+		AddRow("1", "John", "Doe", email, true, "1 High St", "", "London", "SW1A 1AA", "UK", models.CustomerStatusActive,
+			"1990-01-31", true, "encrypted", models.KYCStatusVerified, models.ISAProductStandard, "", nil)
 
 	mock.ExpectQuery("SELECT (.+) FROM retail_customers").
 		WithArgs(email).
@@ -62,6 +74,9 @@ func TestGetRetailCustomerByEmail(t *testing.T) {
 	assert.Equal(t, "John", customer.FirstName)
 	assert.Equal(t, "Doe", customer.LastName)
 	assert.Equal(t, email, customer.Email)
+	assert.Equal(t, "London", customer.Address.City)
+	assert.Equal(t, "1990-01-31", customer.DateOfBirth)
+	assert.Equal(t, "encrypted", customer.EncryptedNINumber)
 
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unfulfilled expectations: %s", err)
@@ -80,8 +95,9 @@ func TestGetRetailCustomerByID(t *testing.T) {
 	ctx := context.Background()
 	id := "1"
 
-	rows := sqlmock.NewRows([]string{"id", "first_name", "last_name", "email"}).
-		AddRow(id, "John", "Doe", "john.doe@test.com")
+	rows := sqlmock.NewRows(customerRowColumns).
+		AddRow(id, "John", "Doe", "john.doe@test.com", false, "", "", "", "", "", models.CustomerStatusActive,
+			"", false, "", models.KYCStatusNotStarted, models.ISAProductStandard, "", nil)
 
 	mock.ExpectQuery("SELECT (.+) FROM retail_customers").
 		WithArgs(id).
@@ -99,3 +115,448 @@ func TestGetRetailCustomerByID(t *testing.T) {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
 }
+
+func TestCreateRetailCustomerDuplicateEmail(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewRepository(db)
+
+	ctx := context.Background()
+	customer := &models.RetailCustomer{
+		FirstName: "John",
+		LastName:  "Doe",
+		Email:     "john.doe@example.com",
+	}
+
+	mock.ExpectExec("INSERT INTO retail_customers").
+		WithArgs(customer.FirstName, customer.LastName, customer.Email, "", false, "").
+		WillReturnError(&pq.Error{Code: "23505"})
+
+	err = repo.createRetailCustomer(ctx, customer)
+	assert.ErrorIs(t, err, isaerrors.ErrEmailAlreadyExists)
+	assert.ErrorIs(t, err, isaerrors.ErrConflict)
+}
+
+func TestGetRetailCustomerByIDNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewRepository(db)
+
+	mock.ExpectQuery("SELECT (.+) FROM retail_customers").
+		WithArgs("missing").
+		WillReturnError(sql.ErrNoRows)
+
+	customer, err := repo.getRetailCustomerByID(context.Background(), "missing")
+	assert.Nil(t, customer)
+	assert.ErrorIs(t, err, isaerrors.ErrNotFound)
+}
+
+func TestUpdateRetailCustomer(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	customer := &models.RetailCustomer{
+		ID:        "1",
+		FirstName: "John",
+		LastName:  "Doe",
+		Email:     "new@example.com",
+		Address:   models.Address{Line1: "1 High St", City: "London", Postcode: "SW1A 1AA", Country: "UK"},
+		KYCStatus: models.KYCStatusVerified,
+	}
+
+	t.Run("successful update records audit event", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE retail_customers").
+			WithArgs(customer.ID, customer.FirstName, customer.LastName, customer.Email, false, "token",
+				"1 High St", "", "London", "SW1A 1AA", "UK", "", false, "", models.KYCStatusVerified).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO customer_audit_events").
+			WithArgs(customer.ID, auditProfileUpdated, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		err := repo.updateRetailCustomer(ctx, customer, []string{"email"}, "token")
+		assert.NoError(t, err)
+	})
+
+	t.Run("duplicate email", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE retail_customers").
+			WillReturnError(&pq.Error{Code: "23505"})
+		mock.ExpectRollback()
+
+		err := repo.updateRetailCustomer(ctx, customer, []string{"email"}, "token")
+		assert.ErrorIs(t, err, isaerrors.ErrEmailAlreadyExists)
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+type stubNotifier struct {
+	customerID string
+	email      string
+	token      string
+}
+
+func (n *stubNotifier) SendEmailVerification(ctx context.Context, customerID, email, token string) error {
+	n.customerID, n.email, n.token = customerID, email, token
+	return nil
+}
+
+func TestUpdateRetailCustomerSendsVerificationEmail(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	notifier := &stubNotifier{}
+	service := NewService(NewRepository(db), nil, notifier)
+	ctx := context.Background()
+	id := "1"
+
+	mock.ExpectQuery("SELECT (.+) FROM retail_customers").
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows(customerRowColumns).
+			AddRow(id, "John", "Doe", "old@example.com", true, "", "", "", "", "", models.CustomerStatusActive,
+				"", false, "", models.KYCStatusNotStarted, models.ISAProductStandard, "", nil))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE retail_customers").
+		WithArgs(id, "John", "Doe", "new@example.com", false, sqlmock.AnyArg(),
+			"", "", "", "", "", "", false, "", models.KYCStatusNotStarted).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO customer_audit_events").
+		WithArgs(id, auditProfileUpdated, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	email := "new@example.com"
+	customer, err := service.updateRetailCustomer(ctx, id, &models.UpdateRetailCustomerRequest{Email: &email})
+	assert.NoError(t, err)
+	assert.False(t, customer.EmailVerified)
+	assert.Equal(t, id, notifier.customerID)
+	assert.Equal(t, email, notifier.email)
+	assert.Len(t, notifier.token, 64)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUpdateRetailCustomerResetsKYC(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	service := NewService(NewRepository(db), nil, &stubNotifier{})
+	ctx := context.Background()
+	id := "1"
+
+	expectVerifiedCustomer := func() {
+		mock.ExpectQuery("SELECT (.+) FROM retail_customers").
+			WithArgs(id).
+			WillReturnRows(sqlmock.NewRows(customerRowColumns).
+				AddRow(id, "John", "Doe", "john.doe@test.com", true, "", "", "", "", "", models.CustomerStatusActive,
+					"1990-01-01", true, "", models.KYCStatusVerified, models.ISAProductStandard, "", nil))
+	}
+
+	t.Run("identity change resets kyc status", func(t *testing.T) {
+		expectVerifiedCustomer()
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE retail_customers (.+) kyc_status = \\$15").
+			WithArgs(id, "John", "Smith", "john.doe@test.com", true, "",
+				"", "", "", "", "", "1990-01-01", true, "", models.KYCStatusNotStarted).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO customer_audit_events").
+			WithArgs(id, auditProfileUpdated, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		lastName := "Smith"
+		customer, err := service.updateRetailCustomer(ctx, id, &models.UpdateRetailCustomerRequest{LastName: &lastName})
+		assert.NoError(t, err)
+		assert.Equal(t, models.KYCStatusNotStarted, customer.KYCStatus)
+	})
+
+	t.Run("other changes keep kyc status", func(t *testing.T) {
+		expectVerifiedCustomer()
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE retail_customers").
+			WithArgs(id, "John", "Doe", "john.doe@test.com", true, "",
+				"", "", "", "", "", "1990-01-01", false, "", models.KYCStatusVerified).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO customer_audit_events").
+			WithArgs(id, auditProfileUpdated, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		ukResident := false
+		customer, err := service.updateRetailCustomer(ctx, id, &models.UpdateRetailCustomerRequest{UKResident: &ukResident})
+		assert.NoError(t, err)
+		assert.Equal(t, models.KYCStatusVerified, customer.KYCStatus)
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestVerifyEmailInvalidToken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE retail_customers").
+		WithArgs("1", "wrong").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	err = repo.verifyEmail(context.Background(), "1", "wrong")
+	assert.ErrorIs(t, err, isaerrors.ErrInvalidVerificationToken)
+}
+
+func TestSetEmailVerificationToken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE retail_customers SET email_verification_token = \\$2").
+		WithArgs("1", "token").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO customer_audit_events").
+		WithArgs("1", auditEmailResent, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	assert.NoError(t, repo.setEmailVerificationToken(context.Background(), "1", "token"))
+
+	// Note: Verified in the meantime
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE retail_customers SET email_verification_token = \\$2").
+		WithArgs("2", "token").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	err = repo.setEmailVerificationToken(context.Background(), "2", "token")
+	assert.ErrorIs(t, err, isaerrors.ErrEmailAlreadyVerified)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCloseRetailCustomer(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE retail_customers SET status = 'closed'").
+		WithArgs("1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE accounts SET status = 'closed'").
+		WithArgs("1").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("INSERT INTO customer_audit_events").
+		WithArgs("1", auditAccountClosed, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err = repo.closeRetailCustomer(context.Background(), "1")
+	assert.NoError(t, err)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestEraseRetailCustomer(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	t.Run("personal data is pseudonymised and view refreshed", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT EXISTS \\( SELECT 1 FROM retail_customers WHERE registered_contact_id = \\$1").
+			WithArgs("1").
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectExec("UPDATE retail_customers SET first_name = 'Erased'").
+			WithArgs("1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE kyc_verifications SET provider_reference = 'erased-' \\|\\| id::text, reason = NULL").
+			WithArgs("1").
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec("UPDATE accounts SET status = 'closed'").
+			WithArgs("1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO customer_audit_events").
+			WithArgs("1", auditErased, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("REFRESH MATERIALIZED VIEW customer_fund_totals").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		err := repo.eraseRetailCustomer(ctx, "1")
+		assert.NoError(t, err)
+	})
+
+	t.Run("registered contact for an open junior isa", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT EXISTS").
+			WithArgs("1").
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectRollback()
+
+		err := repo.eraseRetailCustomer(ctx, "1")
+		assert.ErrorIs(t, err, isaerrors.ErrRegisteredContactErasure)
+	})
+
+	t.Run("already erased", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT EXISTS").
+			WithArgs("1").
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectExec("UPDATE retail_customers").
+			WithArgs("1").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		err := repo.eraseRetailCustomer(ctx, "1")
+		assert.ErrorIs(t, err, isaerrors.ErrCustomerErased)
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCreateJuniorISA(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+	newJunior := func() *models.RetailCustomer {
+		return &models.RetailCustomer{
+			FirstName:           "Jane",
+			LastName:            "Doe",
+			DateOfBirth:         "2015-03-01",
+			UKResident:          true,
+			RegisteredContactID: "parent1",
+		}
+	}
+
+	t.Run("created with the registered contact", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO retail_customers (.+) \\$8, \\$7\\) RETURNING id \\) INSERT INTO accounts \\(customer_id, product\\) SELECT id, \\$8 FROM customer").
+			WithArgs("Jane", "Doe", "", "2015-03-01", true, "", "parent1", models.ISAProductJunior).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("child1"))
+		mock.ExpectExec("INSERT INTO customer_audit_events").
+			WithArgs("child1", auditJuniorOpened, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		junior := newJunior()
+		assert.NoError(t, repo.createJuniorISA(ctx, junior))
+		assert.Equal(t, "child1", junior.ID)
+	})
+
+	t.Run("unknown registered contact", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO retail_customers").
+			WillReturnError(&pq.Error{Code: "23503"})
+		mock.ExpectRollback()
+
+		assert.ErrorIs(t, repo.createJuniorISA(ctx, newJunior()), isaerrors.ErrInvalidRegisteredContact)
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestListJuniorISAs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewRepository(db)
+
+	mock.ExpectQuery("SELECT (.+) FROM retail_customers WHERE registered_contact_id = \\$1").
+		WithArgs("parent1").
+		WillReturnRows(sqlmock.NewRows(customerRowColumns).
+			AddRow("child1", "Jane", "Doe", "", false, "", "", "", "", "", models.CustomerStatusActive,
+				"2015-03-01", true, "", models.KYCStatusNotStarted, models.ISAProductJunior, "parent1", nil))
+
+	juniors, err := repo.listJuniorISAs(context.Background(), "parent1")
+	assert.NoError(t, err)
+	assert.Len(t, juniors, 1)
+	assert.Equal(t, models.ISAProductJunior, juniors[0].Product)
+	assert.Equal(t, "parent1", juniors[0].RegisteredContactID)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestConvertJuniorISAs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewRepository(db)
+	on := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectExec("WITH converted AS \\( UPDATE retail_customers SET isa_product = 'isa', (.+) UPDATE accounts SET product = 'isa' WHERE product = 'junior_isa' (.+) INSERT INTO customer_audit_events").
+		WithArgs("2025-06-01", auditJuniorConvert, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	converted, err := repo.convertJuniorISAs(context.Background(), on)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, converted)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,80 @@
+package customer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/stcol316/cushon-isa/internal/models"
+)
+
+// Note: Converts Junior ISAs to adult ISAs once a day, early enough that an 18th birthday
+// is picked up before the holder can try to use their account
+type ConversionScheduler struct {
+	service  *Service
+	hour     int
+	minute   int
+	location *time.Location
+}
+
+// NewConversionScheduler takes the run time as HH:MM in the given IANA time zone
+func NewConversionScheduler(service *Service, runAt, timezone string) (*ConversionScheduler, error) {
+	parsed, err := time.Parse("15:04", runAt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid junior isa conversion time %q: %w", runAt, err)
+	}
+
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid junior isa conversion time zone %q: %w", timezone, err)
+	}
+
+	return &ConversionScheduler{
+		service:  service,
+		hour:     parsed.Hour(),
+		minute:   parsed.Minute(),
+		location: location,
+	}, nil
+}
+
+func (s *ConversionScheduler) today(now time.Time) time.Time {
+	now = now.In(s.location)
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, s.location)
+}
+
+func (s *ConversionScheduler) nextRun(now time.Time) time.Time {
+	now = now.In(s.location)
+	next := time.Date(now.Year(), now.Month(), now.Day(), s.hour, s.minute, 0, 0, s.location)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// Note: Junior ISA conversion go routine. Stops when the context is cancelled
+func (s *ConversionScheduler) Start(ctx context.Context) {
+	go func() {
+		for {
+			next := s.nextRun(time.Now())
+			log.Printf("Next junior ISA conversion run at %s", next.Format(time.RFC3339))
+
+			timer := time.NewTimer(time.Until(next))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			if _, err := s.service.convertJuniorISAs(ctx, s.today(next)); err != nil {
+				log.Printf("Junior ISA conversion failed: %v", err)
+			}
+		}
+	}()
+}
+
+// RunNow converts every Junior ISA whose holder has turned 18 by today
+func (s *ConversionScheduler) RunNow(ctx context.Context) (*models.JuniorISAConversionRun, error) {
+	return s.service.convertJuniorISAs(ctx, s.today(time.Now()))
+}
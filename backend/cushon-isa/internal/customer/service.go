@@ -2,32 +2,353 @@ package customer
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"log"
+	"time"
 
+	"github.com/stcol316/cushon-isa/internal/encryption"
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
 	"github.com/stcol316/cushon-isa/internal/models"
 )
 
 type Service struct {
-	repo *Repository
+	repo     *Repository
+	cipher   *encryption.Cipher
+	notifier Notifier
+	now      func() time.Time
 }
 
-func NewService(repo *Repository) *Service {
-	return &Service{repo: repo}
+func NewService(repo *Repository, cipher *encryption.Cipher, notifier Notifier) *Service {
+	return &Service{repo: repo, cipher: cipher, notifier: notifier, now: time.Now}
 }
 
+// Note: KYC fields are optional at sign up but validated whenever they are supplied.
+// Customers cannot invest until they are all present, see models.CustomerEligibility
 func (s *Service) createRetailCustomer(ctx context.Context, req *models.CreateRetailCustomerRequest) (*models.RetailCustomer, error) {
 	customer := models.NewRetailCustomer(req.FirstName, req.LastName, req.Email)
+	customer.UKResident = req.UKResident
+
+	if req.DateOfBirth != "" {
+		if err := validateDateOfBirth(req.DateOfBirth, s.now()); err != nil {
+			return nil, err
+		}
+		customer.DateOfBirth = req.DateOfBirth
+	}
+
+	if req.NINumber != "" {
+		if err := s.setNINumber(&customer, req.NINumber); err != nil {
+			return nil, err
+		}
+	}
+
 	if err := s.repo.createRetailCustomer(ctx, &customer); err != nil {
 		return nil, fmt.Errorf("failed to create customer: %w", err)
 	}
 
-	return &customer, nil
+	return s.present(&customer)
 }
 
 func (s *Service) getRetailCustomerByID(ctx context.Context, id string) (*models.RetailCustomer, error) {
-	return s.repo.getRetailCustomerByID(ctx, id)
+	customer, err := s.repo.getRetailCustomerByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.present(customer)
 }
 
 func (s *Service) getRetailCustomerByEmail(ctx context.Context, email string) (*models.RetailCustomer, error) {
-	return s.repo.getRetailCustomerByEmail(ctx, email)
+	customer, err := s.repo.getRetailCustomerByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	return s.present(customer)
+}
+
+// setNINumber validates and encrypts a National Insurance number onto the customer
+func (s *Service) setNINumber(customer *models.RetailCustomer, ni string) error {
+	ni = normaliseNINumber(ni)
+	if err := validateNINumber(ni); err != nil {
+		return err
+	}
+
+	encrypted, err := s.cipher.Encrypt(ni)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt national insurance number: %w", err)
+	}
+
+	customer.NINumber = ni
+	customer.EncryptedNINumber = encrypted
+	return nil
+}
+
+// present prepares a customer for a response. The NI number is decrypted and masked
+func (s *Service) present(customer *models.RetailCustomer) (*models.RetailCustomer, error) {
+	if customer.NINumber == "" && customer.EncryptedNINumber != "" {
+		ni, err := s.cipher.Decrypt(customer.EncryptedNINumber)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt national insurance number: %w", err)
+		}
+		customer.NINumber = ni
+	}
+	customer.NINumber = maskNINumber(customer.NINumber)
+	return customer, nil
+}
+
+// Note: Partial update. Only fields present in the request are changed.
+// Changing email resets verification and issues a new token, changing identity details resets KYC
+func (s *Service) updateRetailCustomer(ctx context.Context, id string, req *models.UpdateRetailCustomerRequest) (*models.RetailCustomer, error) {
+	customer, err := s.repo.getRetailCustomerByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if customer.Status == models.CustomerStatusErased {
+		return nil, isaerrors.ErrCustomerErased
+	}
+
+	var changed []string
+	if req.FirstName != nil && *req.FirstName != customer.FirstName {
+		customer.FirstName = *req.FirstName
+		changed = append(changed, "firstname")
+	}
+	if req.LastName != nil && *req.LastName != customer.LastName {
+		customer.LastName = *req.LastName
+		changed = append(changed, "lastname")
+	}
+	if req.Address != nil && *req.Address != customer.Address {
+		customer.Address = *req.Address
+		changed = append(changed, "address")
+	}
+	if req.DateOfBirth != nil && *req.DateOfBirth != customer.DateOfBirth {
+		validate := validateDateOfBirth
+		if customer.Product == models.ISAProductJunior {
+			validate = validateJuniorDateOfBirth
+		}
+		if err := validate(*req.DateOfBirth, s.now()); err != nil {
+			return nil, err
+		}
+		customer.DateOfBirth = *req.DateOfBirth
+		changed = append(changed, "dateOfBirth")
+	}
+	if req.UKResident != nil && *req.UKResident != customer.UKResident {
+		customer.UKResident = *req.UKResident
+		changed = append(changed, "ukResident")
+	}
+	if req.NINumber != nil {
+		if err := s.setNINumber(customer, *req.NINumber); err != nil {
+			return nil, err
+		}
+		changed = append(changed, "niNumber")
+	}
+
+	// Note: A verification only holds for the identity that was checked, so changing any of it
+	// means the customer has to be verified again before they can invest
+	if customer.KYCStatus != models.KYCStatusNotStarted && changesIdentity(changed) {
+		customer.KYCStatus = models.KYCStatusNotStarted
+		changed = append(changed, "kycStatus")
+	}
+
+	var token string
+	if req.Email != nil && *req.Email != customer.Email {
+		if *req.Email == "" {
+			return nil, isaerrors.Validation("email_required", "email cannot be empty")
+		}
+		customer.Email = *req.Email
+		customer.EmailVerified = false
+		changed = append(changed, "email")
+
+		token, err = newVerificationToken()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(changed) == 0 {
+		return s.present(customer)
+	}
+
+	if err := s.repo.updateRetailCustomer(ctx, customer, changed, token); err != nil {
+		return nil, fmt.Errorf("failed to update customer: %w", err)
+	}
+
+	// Note: The update is already committed so a failed send is logged rather than returned.
+	// The customer can request another verification email, see resendEmailVerification
+	if token != "" {
+		if err := s.notifier.SendEmailVerification(ctx, customer.ID, customer.Email, token); err != nil {
+			log.Printf("Failed to send email verification for customer %s: %v", customer.ID, err)
+		} else {
+			log.Printf("Email verification required for customer %s", customer.ID)
+		}
+	}
+
+	return s.present(customer)
+}
+
+func changesIdentity(changed []string) bool {
+	for _, field := range changed {
+		switch field {
+		case "firstname", "lastname", "address", "dateOfBirth", "niNumber":
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Service) verifyEmail(ctx context.Context, id, token string) error {
+	if token == "" {
+		return isaerrors.ErrInvalidVerificationToken
+	}
+	return s.repo.verifyEmail(ctx, id, token)
+}
+
+// Note: Issues a new token, replacing any earlier one, and sends it to the customer's current email.
+// Unlike an email change the send is the whole point, so a failure is returned for the caller to retry
+func (s *Service) resendEmailVerification(ctx context.Context, id string) error {
+	customer, err := s.repo.getRetailCustomerByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if customer.Status == models.CustomerStatusErased {
+		return isaerrors.ErrCustomerErased
+	}
+	if customer.Email == "" {
+		return isaerrors.Validation("email_required", "customer has no email address to verify")
+	}
+	if customer.EmailVerified {
+		return isaerrors.ErrEmailAlreadyVerified
+	}
+
+	token, err := newVerificationToken()
+	if err != nil {
+		return err
+	}
+	if err := s.repo.setEmailVerificationToken(ctx, id, token); err != nil {
+		return err
+	}
+
+	if err := s.notifier.SendEmailVerification(ctx, customer.ID, customer.Email, token); err != nil {
+		return fmt.Errorf("failed to send email verification: %w", err)
+	}
+	log.Printf("Email verification resent for customer %s", customer.ID)
+	return nil
+}
+
+func (s *Service) closeRetailCustomer(ctx context.Context, id string) (*models.RetailCustomer, error) {
+	customer, err := s.repo.getRetailCustomerByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	switch customer.Status {
+	case models.CustomerStatusErased:
+		return nil, isaerrors.ErrCustomerErased
+	case models.CustomerStatusClosed:
+		return nil, isaerrors.ErrCustomerClosed
+	}
+
+	if err := s.repo.closeRetailCustomer(ctx, id); err != nil {
+		return nil, fmt.Errorf("failed to close customer: %w", err)
+	}
+
+	return s.getRetailCustomerByID(ctx, id)
+}
+
+// CloseAccount closes an active customer's account. Exported for the transfer service which closes
+// the account once everything has been transferred out. Accounts that are already closed are left alone
+func (s *Service) CloseAccount(ctx context.Context, id string) error {
+	err := s.repo.closeRetailCustomer(ctx, id)
+	if err != nil && !errors.Is(err, isaerrors.ErrCustomerClosed) {
+		return fmt.Errorf("failed to close customer: %w", err)
+	}
+	return nil
+}
+
+func (s *Service) eraseRetailCustomer(ctx context.Context, id string) (*models.RetailCustomer, error) {
+	customer, err := s.repo.getRetailCustomerByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if customer.Status == models.CustomerStatusErased {
+		return nil, isaerrors.ErrCustomerErased
+	}
+
+	if err := s.repo.eraseRetailCustomer(ctx, id); err != nil {
+		return nil, fmt.Errorf("failed to erase customer: %w", err)
+	}
+
+	return s.getRetailCustomerByID(ctx, id)
+}
+
+// Note: The registered contact must be an adult with a standard ISA of their own. Their identity
+// verification stands in for the child's, see models.CustomerEligibility
+func (s *Service) createJuniorISA(ctx context.Context, req *models.CreateJuniorISARequest) (*models.RetailCustomer, error) {
+	if err := validateJuniorDateOfBirth(req.DateOfBirth, s.now()); err != nil {
+		return nil, err
+	}
+
+	contact, err := s.repo.getRetailCustomerByID(ctx, req.RegisteredContactID)
+	if err != nil {
+		if errors.Is(err, isaerrors.ErrCustomerNotFound) {
+			return nil, isaerrors.ErrInvalidRegisteredContact
+		}
+		return nil, err
+	}
+	if contact.Status != models.CustomerStatusActive || contact.Product != models.ISAProductStandard ||
+		validateDateOfBirth(contact.DateOfBirth, s.now()) != nil {
+		return nil, isaerrors.ErrInvalidRegisteredContact
+	}
+
+	customer := models.NewRetailCustomer(req.FirstName, req.LastName, req.Email)
+	customer.Product = models.ISAProductJunior
+	customer.RegisteredContactID = contact.ID
+	customer.DateOfBirth = req.DateOfBirth
+	customer.UKResident = req.UKResident
+
+	if req.NINumber != "" {
+		if err := s.setNINumber(&customer, req.NINumber); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.repo.createJuniorISA(ctx, &customer); err != nil {
+		return nil, err
+	}
+
+	return s.present(&customer)
+}
+
+func (s *Service) listJuniorISAs(ctx context.Context, registeredContactID string) ([]models.RetailCustomer, error) {
+	juniors, err := s.repo.listJuniorISAs(ctx, registeredContactID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range juniors {
+		if _, err := s.present(&juniors[i]); err != nil {
+			return nil, err
+		}
+	}
+	return juniors, nil
+}
+
+// Note: Junior ISAs become adult ISAs on the holder's 18th birthday. The new adult must supply
+// an NI number and verify their own identity before making further deposits
+func (s *Service) convertJuniorISAs(ctx context.Context, on time.Time) (*models.JuniorISAConversionRun, error) {
+	converted, err := s.repo.convertJuniorISAs(ctx, on)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Converted %d junior ISAs to adult ISAs", converted)
+	return &models.JuniorISAConversionRun{On: on.Format(dateLayout), Converted: converted}, nil
+}
+
+func newVerificationToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate verification token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
 }
@@ -0,0 +1,93 @@
+package customer
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+)
+
+const (
+	dateLayout      = "2006-01-02"
+	minimumISAAge   = 18
+	maximumAgeYears = 130
+)
+
+// Note: HMRC National Insurance number format
+// The first letter cannot be D, F, I, Q, U or V and the second letter cannot be D, F, I, O, Q, U or V.
+// Some prefixes are never allocated. The suffix is A, B, C or D.
+var niNumberPattern = regexp.MustCompile(`^[A-CEGHJ-PR-TW-Z][A-CEGHJ-NPR-TW-Z][0-9]{6}[A-D]$`)
+
+var invalidNIPrefixes = map[string]struct{}{
+	"BG": {}, "GB": {}, "KN": {}, "NK": {}, "NT": {}, "TN": {}, "ZZ": {},
+}
+
+// normaliseNINumber strips spaces and uppercases so "ab 12 34 56 c" is accepted
+func normaliseNINumber(ni string) string {
+	return strings.ToUpper(strings.ReplaceAll(ni, " ", ""))
+}
+
+func validateNINumber(ni string) error {
+	if !niNumberPattern.MatchString(ni) {
+		return isaerrors.Validation("invalid_ni_number", "national insurance number format is invalid")
+	}
+	if _, ok := invalidNIPrefixes[ni[:2]]; ok {
+		return isaerrors.Validation("invalid_ni_number", "national insurance number prefix is not valid")
+	}
+	return nil
+}
+
+// maskNINumber only reveals the last three characters, e.g. ******56C
+func maskNINumber(ni string) string {
+	if len(ni) <= 3 {
+		return ni
+	}
+	return strings.Repeat("*", len(ni)-3) + ni[len(ni)-3:]
+}
+
+func validateDateOfBirth(dob string, now time.Time) error {
+	age, err := ageFromDateOfBirth(dob, now)
+	if err != nil {
+		return err
+	}
+	if age < minimumISAAge {
+		return isaerrors.Validation("underage", "customers must be at least 18 years old")
+	}
+	return nil
+}
+
+// Note: Junior ISA holders must be under 18, they convert to an adult ISA on their birthday
+func validateJuniorDateOfBirth(dob string, now time.Time) error {
+	age, err := ageFromDateOfBirth(dob, now)
+	if err != nil {
+		return err
+	}
+	if age >= minimumISAAge {
+		return isaerrors.ErrJuniorISAHolderTooOld
+	}
+	return nil
+}
+
+func ageFromDateOfBirth(dob string, now time.Time) (int, error) {
+	parsed, err := time.Parse(dateLayout, dob)
+	if err != nil {
+		return 0, isaerrors.Validation("invalid_date_of_birth", "date of birth must be in YYYY-MM-DD format")
+	}
+	if parsed.After(now) {
+		return 0, isaerrors.Validation("invalid_date_of_birth", "date of birth cannot be in the future")
+	}
+	if ageOn(parsed, now) > maximumAgeYears {
+		return 0, isaerrors.Validation("invalid_date_of_birth", "date of birth is not plausible")
+	}
+	return ageOn(parsed, now), nil
+}
+
+// ageOn returns the age in whole years on the given date
+func ageOn(dob, on time.Time) int {
+	age := on.Year() - dob.Year()
+	if on.Month() < dob.Month() || (on.Month() == dob.Month() && on.Day() < dob.Day()) {
+		age--
+	}
+	return age
+}
@@ -0,0 +1,95 @@
+package employee
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/models"
+	helper "github.com/stcol316/cushon-isa/pkg/helpers"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+func (h *Handler) CreateEmployeeHandler(w http.ResponseWriter, r *http.Request) {
+	if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+		helper.RespondWithError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/json")
+		return
+	}
+	req := new(models.CreateEmployeeRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		helper.RespondWithProblem(w, r, isaerrors.ErrInvalidRequestBody.Wrap(err))
+		return
+	}
+	if req.EmployerID != "" {
+		if _, err := uuid.Parse(req.EmployerID); err != nil {
+			helper.RespondWithError(w, http.StatusBadRequest, "invalid employer ID format")
+			return
+		}
+	}
+
+	employee, err := h.service.createEmployee(r.Context(), req, time.Now())
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusCreated, employee)
+}
+
+func (h *Handler) GetEmployeeHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "invalid employee ID format")
+		return
+	}
+
+	employee, err := h.service.getEmployee(r.Context(), id)
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, employee)
+}
+
+func (h *Handler) GetPensionHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "invalid employee ID format")
+		return
+	}
+
+	pension, err := h.service.getPension(r.Context(), id)
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, pension)
+}
+
+func (h *Handler) ListContributionsHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "invalid employee ID format")
+		return
+	}
+
+	contributions, err := h.service.listContributions(r.Context(), id)
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, contributions)
+}
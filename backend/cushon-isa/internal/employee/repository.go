@@ -0,0 +1,210 @@
+package employee
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/models"
+)
+
+// TODO: Use interfaces at service level instead of "repo *Repository"
+type EmployeeRepository interface {
+	CreateEmployee(ctx context.Context, employee *models.Employee) error
+	GetEmployee(ctx context.Context, id string) (*models.Employee, error)
+	GetPension(ctx context.Context, employeeID string) (*models.Pension, error)
+	ListHoldings(ctx context.Context, accountID string) ([]models.PensionHolding, error)
+	ListContributions(ctx context.Context, employeeID string) ([]models.PensionContribution, error)
+	EmployeeExists(ctx context.Context, id string) (bool, error)
+}
+
+type Repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Note: The employee's pension account is opened in the same statement
+func (r *Repository) createEmployee(ctx context.Context, employee *models.Employee) error {
+	err := r.db.QueryRowContext(ctx, `
+	WITH employee AS (
+		INSERT INTO employees (employer_id, payroll_reference, first_name, last_name, email, date_of_birth)
+		VALUES ($1, $2, $3, $4, $5, $6::date)
+		RETURNING id, created_at
+	)
+	INSERT INTO accounts (employee_id, product, opened_at)
+	SELECT id, 'pension', created_at FROM employee
+	RETURNING employee_id, id, opened_at
+`, employee.EmployerID, employee.PayrollReference, employee.FirstName, employee.LastName, employee.Email, employee.DateOfBirth,
+	).Scan(&employee.ID, &employee.AccountID, &employee.CreatedAt)
+	if err != nil {
+		if isaerrors.IsUniqueViolation(err) {
+			return isaerrors.ErrPayrollReferenceExists
+		}
+		if isaerrors.IsForeignKeyViolation(err) {
+			return isaerrors.ErrEmployerNotFound
+		}
+		return fmt.Errorf("failed to create employee: %w", err)
+	}
+	employee.Status = models.EmployeeStatusActive
+	return nil
+}
+
+func (r *Repository) getEmployee(ctx context.Context, id string) (*models.Employee, error) {
+	var employee models.Employee
+	var left sql.NullTime
+	err := r.db.QueryRowContext(ctx, `
+        SELECT e.id, e.employer_id, e.payroll_reference, e.first_name, e.last_name, e.email,
+            TO_CHAR(e.date_of_birth, 'YYYY-MM-DD'), e.status, COALESCE(a.id::text, ''), e.created_at, e.left_at
+        FROM employees e
+        LEFT JOIN LATERAL (
+            SELECT id FROM accounts
+            WHERE employee_id = e.id
+            ORDER BY status = 'closed', opened_at DESC
+            LIMIT 1
+        ) a ON TRUE
+        WHERE e.id = $1
+    `, id).Scan(
+		&employee.ID,
+		&employee.EmployerID,
+		&employee.PayrollReference,
+		&employee.FirstName,
+		&employee.LastName,
+		&employee.Email,
+		&employee.DateOfBirth,
+		&employee.Status,
+		&employee.AccountID,
+		&employee.CreatedAt,
+		&left,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, isaerrors.ErrEmployeeNotFound
+		}
+		return nil, fmt.Errorf("failed to get employee: %w", err)
+	}
+	if left.Valid {
+		employee.LeftAt = &left.Time
+	}
+	return &employee, nil
+}
+
+// Note: The employee's open pension account, or their most recently closed one
+func (r *Repository) getPension(ctx context.Context, employeeID string) (*models.Pension, error) {
+	var pension models.Pension
+	err := r.db.QueryRowContext(ctx, `
+        SELECT a.id, e.id, e.employer_id, a.status, a.opened_at
+        FROM accounts a
+        JOIN employees e ON e.id = a.employee_id
+        WHERE a.employee_id = $1
+        ORDER BY a.status = 'closed', a.opened_at DESC
+        LIMIT 1
+    `, employeeID).Scan(&pension.AccountID, &pension.EmployeeID, &pension.EmployerID, &pension.Status, &pension.OpenedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, isaerrors.ErrEmployeeNotFound
+		}
+		return nil, fmt.Errorf("failed to get pension: %w", err)
+	}
+	return &pension, nil
+}
+
+// Note: Pensions are not in customer_fund_totals, so the totals are worked out from the investments
+// in the same way
+func (r *Repository) listHoldings(ctx context.Context, accountID string) ([]models.PensionHolding, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT f.id, f.name,
+            SUM(CASE WHEN i.type = 'deposit' THEN i.amount ELSE -i.amount END),
+            COALESCE(SUM(CASE WHEN i.type = 'deposit' THEN i.amount ELSE -i.amount END)
+                FILTER (WHERE i.status = 'settled'), 0),
+            COALESCE(SUM(CASE WHEN i.type = 'deposit' THEN i.amount ELSE -i.amount END)
+                FILTER (WHERE i.status IN ('pending', 'cash_received', 'units_allocated')), 0)
+        FROM investments i
+        JOIN funds f ON f.id = i.fund_id
+        WHERE i.account_id = $1 AND i.status NOT IN ('held', 'cancelled', 'failed')
+        GROUP BY f.id, f.name
+        ORDER BY f.name
+    `, accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pension holdings: %w", err)
+	}
+	defer rows.Close()
+
+	holdings := []models.PensionHolding{}
+	for rows.Next() {
+		var holding models.PensionHolding
+		if err := rows.Scan(
+			&holding.FundID,
+			&holding.FundName,
+			&holding.TotalInvestment,
+			&holding.SettledInvestment,
+			&holding.PendingInvestment,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan pension holding: %w", err)
+		}
+		holdings = append(holdings, holding)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list pension holdings: %w", err)
+	}
+
+	return holdings, nil
+}
+
+// Note: Most recent pay period first
+func (r *Repository) listContributions(ctx context.Context, employeeID string) ([]models.PensionContribution, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT c.id, c.employer_id, c.employee_id, c.account_id, c.investment_id, i.fund_id,
+            TO_CHAR(c.period_start, 'YYYY-MM-DD'), TO_CHAR(c.period_end, 'YYYY-MM-DD'),
+            c.employee_amount, c.employer_amount, c.created_at
+        FROM pension_contributions c
+        JOIN investments i ON i.id = c.investment_id
+        WHERE c.employee_id = $1
+        ORDER BY c.period_start DESC
+    `, employeeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pension contributions: %w", err)
+	}
+	defer rows.Close()
+
+	contributions := []models.PensionContribution{}
+	for rows.Next() {
+		var contribution models.PensionContribution
+		if err := rows.Scan(
+			&contribution.ID,
+			&contribution.EmployerID,
+			&contribution.EmployeeID,
+			&contribution.AccountID,
+			&contribution.InvestmentID,
+			&contribution.FundID,
+			&contribution.PeriodStart,
+			&contribution.PeriodEnd,
+			&contribution.EmployeeAmount,
+			&contribution.EmployerAmount,
+			&contribution.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan pension contribution: %w", err)
+		}
+		contributions = append(contributions, contribution)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list pension contributions: %w", err)
+	}
+
+	return contributions, nil
+}
+
+func (r *Repository) employeeExists(ctx context.Context, id string) (bool, error) {
+	var exists bool
+	err := r.db.QueryRowContext(ctx, `
+        SELECT EXISTS (SELECT 1 FROM employees WHERE id = $1)
+    `, id).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check employee: %w", err)
+	}
+	return exists, nil
+}
@@ -0,0 +1,138 @@
+package employee
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository_CreateEmployee(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	createdAt := time.Date(2025, 6, 1, 9, 0, 0, 0, time.UTC)
+	newEmployee := func() *models.Employee {
+		return &models.Employee{
+			EmployerID:       "employer1",
+			PayrollReference: "E001",
+			FirstName:        "Ada",
+			LastName:         "Lovelace",
+			Email:            "ada@example.com",
+			DateOfBirth:      "1990-12-10",
+		}
+	}
+
+	t.Run("created with pension account", func(t *testing.T) {
+		mock.ExpectQuery("WITH employee AS \\( INSERT INTO employees (.+) INSERT INTO accounts (.+) 'pension'").
+			WithArgs("employer1", "E001", "Ada", "Lovelace", "ada@example.com", "1990-12-10").
+			WillReturnRows(sqlmock.NewRows([]string{"employee_id", "id", "opened_at"}).AddRow("employee1", "account1", createdAt))
+
+		employee := newEmployee()
+		require.NoError(t, repo.createEmployee(context.Background(), employee))
+		assert.Equal(t, "employee1", employee.ID)
+		assert.Equal(t, "account1", employee.AccountID)
+		assert.Equal(t, models.EmployeeStatusActive, employee.Status)
+	})
+
+	t.Run("payroll reference taken", func(t *testing.T) {
+		mock.ExpectQuery("INSERT INTO employees").
+			WillReturnError(&pq.Error{Code: "23505"})
+
+		assert.ErrorIs(t, repo.createEmployee(context.Background(), newEmployee()), isaerrors.ErrPayrollReferenceExists)
+	})
+
+	t.Run("employer not found", func(t *testing.T) {
+		mock.ExpectQuery("INSERT INTO employees").
+			WillReturnError(&pq.Error{Code: "23503"})
+
+		assert.ErrorIs(t, repo.createEmployee(context.Background(), newEmployee()), isaerrors.ErrEmployerNotFound)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestService_CreateEmployeeValidation(t *testing.T) {
+	service := NewService(nil)
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	_, err := service.createEmployee(context.Background(), &models.CreateEmployeeRequest{EmployerID: "employer1", FirstName: "Ada"}, now)
+	assert.ErrorIs(t, err, isaerrors.ErrEmployeeDetailsRequired)
+
+	_, err = service.createEmployee(context.Background(), &models.CreateEmployeeRequest{
+		EmployerID: "employer1", PayrollReference: "E001", FirstName: "Ada", LastName: "Lovelace",
+		Email: "ada@example.com", DateOfBirth: "2030-01-01",
+	}, now)
+	assert.ErrorIs(t, err, isaerrors.ErrValidation)
+}
+
+func TestService_GetPension(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	service := NewService(NewRepository(db))
+	opened := time.Date(2025, 6, 1, 9, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery("SELECT (.+) FROM accounts a JOIN employees e ON e.id = a.employee_id WHERE a.employee_id = \\$1").
+		WithArgs("employee1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "employee_id", "employer_id", "status", "opened_at"}).
+			AddRow("account1", "employee1", "employer1", models.AccountStatusOpen, opened))
+	mock.ExpectQuery("SELECT (.+) FROM investments i JOIN funds f ON f.id = i.fund_id WHERE i.account_id = \\$1").
+		WithArgs("account1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "total", "settled", "pending"}).
+			AddRow("fund1", "Global Equity", 480.0, 240.0, 240.0))
+
+	pension, err := service.getPension(context.Background(), "employee1")
+	require.NoError(t, err)
+	assert.Equal(t, "employer1", pension.EmployerID)
+	assert.Equal(t, []models.PensionHolding{{
+		FundID: "fund1", FundName: "Global Equity", TotalInvestment: 480, SettledInvestment: 240, PendingInvestment: 240,
+	}}, pension.Holdings)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestService_ListContributions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	service := NewService(NewRepository(db))
+	contributionColumns := []string{"id", "employer_id", "employee_id", "account_id", "investment_id", "fund_id",
+		"period_start", "period_end", "employee_amount", "employer_amount", "created_at"}
+
+	t.Run("none yet", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM pension_contributions c").
+			WithArgs("employee1").
+			WillReturnRows(sqlmock.NewRows(contributionColumns))
+		mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM employees").
+			WithArgs("employee1").
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+		contributions, err := service.listContributions(context.Background(), "employee1")
+		require.NoError(t, err)
+		assert.Empty(t, contributions)
+	})
+
+	t.Run("unknown employee", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM pension_contributions c").
+			WithArgs("missing").
+			WillReturnRows(sqlmock.NewRows(contributionColumns))
+		mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM employees").
+			WithArgs("missing").
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+		_, err := service.listContributions(context.Background(), "missing")
+		assert.ErrorIs(t, err, isaerrors.ErrEmployeeNotFound)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
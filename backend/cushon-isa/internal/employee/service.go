@@ -0,0 +1,91 @@
+package employee
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/models"
+)
+
+const dateLayout = "2006-01-02"
+
+type Service struct {
+	repo *Repository
+}
+
+func NewService(repo *Repository) *Service {
+	return &Service{repo: repo}
+}
+
+// Note: Employees are enrolled by their employer's payroll, so unlike retail customers there is
+// no KYC or ISA eligibility to check before contributions can be taken
+func (s *Service) createEmployee(ctx context.Context, req *models.CreateEmployeeRequest, now time.Time) (*models.Employee, error) {
+	employee := models.Employee{
+		EmployerID:       req.EmployerID,
+		PayrollReference: strings.TrimSpace(req.PayrollReference),
+		FirstName:        strings.TrimSpace(req.FirstName),
+		LastName:         strings.TrimSpace(req.LastName),
+		Email:            strings.TrimSpace(req.Email),
+		DateOfBirth:      req.DateOfBirth,
+	}
+	if employee.EmployerID == "" || employee.PayrollReference == "" || employee.FirstName == "" ||
+		employee.LastName == "" || employee.Email == "" || employee.DateOfBirth == "" {
+		return nil, isaerrors.ErrEmployeeDetailsRequired
+	}
+	if err := validateDateOfBirth(employee.DateOfBirth, now); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.createEmployee(ctx, &employee); err != nil {
+		return nil, err
+	}
+	return &employee, nil
+}
+
+func validateDateOfBirth(dob string, now time.Time) error {
+	parsed, err := time.Parse(dateLayout, dob)
+	if err != nil {
+		return isaerrors.Validation("invalid_date_of_birth", "date of birth must be in YYYY-MM-DD format")
+	}
+	if parsed.After(now) {
+		return isaerrors.Validation("invalid_date_of_birth", "date of birth cannot be in the future")
+	}
+	return nil
+}
+
+func (s *Service) getEmployee(ctx context.Context, id string) (*models.Employee, error) {
+	return s.repo.getEmployee(ctx, id)
+}
+
+func (s *Service) getPension(ctx context.Context, employeeID string) (*models.Pension, error) {
+	pension, err := s.repo.getPension(ctx, employeeID)
+	if err != nil {
+		return nil, err
+	}
+
+	pension.Holdings, err = s.repo.listHoldings(ctx, pension.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	return pension, nil
+}
+
+// Note: An employee with no contributions yet is an empty list, an unknown employee is not found
+func (s *Service) listContributions(ctx context.Context, employeeID string) ([]models.PensionContribution, error) {
+	contributions, err := s.repo.listContributions(ctx, employeeID)
+	if err != nil {
+		return nil, err
+	}
+	if len(contributions) == 0 {
+		exists, err := s.repo.employeeExists(ctx, employeeID)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, isaerrors.ErrEmployeeNotFound
+		}
+	}
+	return contributions, nil
+}
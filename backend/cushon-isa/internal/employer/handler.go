@@ -0,0 +1,84 @@
+package employer
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/models"
+	helper "github.com/stcol316/cushon-isa/pkg/helpers"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+// Note: Admin only. Employers are onboarded by us rather than signing themselves up
+func (h *Handler) CreateEmployerHandler(w http.ResponseWriter, r *http.Request) {
+	req := new(models.CreateEmployerRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		helper.RespondWithProblem(w, r, isaerrors.ErrInvalidRequestBody.Wrap(err))
+		return
+	}
+	if req.DefaultFundID != "" {
+		if _, err := uuid.Parse(req.DefaultFundID); err != nil {
+			helper.RespondWithError(w, http.StatusBadRequest, "invalid fund ID format")
+			return
+		}
+	}
+
+	employer, err := h.service.createEmployer(r.Context(), req)
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusCreated, employer)
+}
+
+func (h *Handler) GetEmployerHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "invalid employer ID format")
+		return
+	}
+
+	employer, err := h.service.getEmployer(r.Context(), id)
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, employer)
+}
+
+// Note: Admin only until employers have their own login. Takes the payroll schedule for one period, e.g.
+// {"periodStart": "2025-06-01", "periodEnd": "2025-06-30", "contributions": [{"payrollReference": "E001", "employeeAmount": 150, "employerAmount": 90}]}
+func (h *Handler) SubmitContributionsHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "invalid employer ID format")
+		return
+	}
+
+	req := new(models.SubmitContributionsRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		helper.RespondWithProblem(w, r, isaerrors.ErrInvalidRequestBody.Wrap(err))
+		return
+	}
+
+	run, err := h.service.submitContributions(r.Context(), id, req, time.Now())
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusCreated, run)
+}
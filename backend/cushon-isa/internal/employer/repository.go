@@ -0,0 +1,140 @@
+package employer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/ledger"
+	"github.com/stcol316/cushon-isa/internal/models"
+)
+
+// TODO: Use interfaces at service level instead of "repo *Repository"
+type EmployerRepository interface {
+	CreateEmployer(ctx context.Context, employer *models.Employer) error
+	GetEmployer(ctx context.Context, id string) (*models.Employer, error)
+	SubmitContributions(ctx context.Context, employer *models.Employer, periodStart, periodEnd string, lines []models.ContributionLine, dealingDate string) ([]models.PensionContribution, error)
+}
+
+type Repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) createEmployer(ctx context.Context, employer *models.Employer) error {
+	err := r.db.QueryRowContext(ctx, `
+	INSERT INTO employers (name, paye_reference, default_fund_id)
+	VALUES ($1, $2, $3)
+	RETURNING id, created_at
+`, employer.Name, employer.PAYEReference, employer.DefaultFundID).Scan(&employer.ID, &employer.CreatedAt)
+	if err != nil {
+		if isaerrors.IsUniqueViolation(err) {
+			return isaerrors.ErrPAYEReferenceExists
+		}
+		if isaerrors.IsForeignKeyViolation(err) {
+			return isaerrors.ErrFundNotFound
+		}
+		return fmt.Errorf("failed to create employer: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) getEmployer(ctx context.Context, id string) (*models.Employer, error) {
+	var employer models.Employer
+	err := r.db.QueryRowContext(ctx, `
+        SELECT id, name, paye_reference, default_fund_id, created_at
+        FROM employers
+        WHERE id = $1
+    `, id).Scan(&employer.ID, &employer.Name, &employer.PAYEReference, &employer.DefaultFundID, &employer.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, isaerrors.ErrEmployerNotFound
+		}
+		return nil, fmt.Errorf("failed to get employer: %w", err)
+	}
+	return &employer, nil
+}
+
+// Note: A payroll submission is all or nothing, so an employer never has to work out which
+// employees were paid when one line is rejected. Each employee's contribution is a single deposit
+// of the employee and employer amounts. The employer pays the schedule alongside the submission,
+// so contributions are received as cash rather than waiting for bank reconciliation.
+// Pensions are not in customer_fund_totals so there is no view to refresh
+func (r *Repository) submitContributions(ctx context.Context, employer *models.Employer, periodStart, periodEnd string, lines []models.ContributionLine, dealingDate string) ([]models.PensionContribution, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	contributions := make([]models.PensionContribution, 0, len(lines))
+	for _, line := range lines {
+		contribution := models.PensionContribution{
+			EmployerID:     employer.ID,
+			FundID:         employer.DefaultFundID,
+			PeriodStart:    periodStart,
+			PeriodEnd:      periodEnd,
+			EmployeeAmount: line.EmployeeAmount,
+			EmployerAmount: line.EmployerAmount,
+		}
+
+		err := tx.QueryRowContext(ctx, `
+        SELECT e.id, a.id
+        FROM employees e
+        JOIN accounts a ON a.employee_id = e.id AND a.status = 'open'
+        WHERE e.employer_id = $1 AND e.payroll_reference = $2 AND e.status = 'active'
+    `, employer.ID, line.PayrollReference).Scan(&contribution.EmployeeID, &contribution.AccountID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, isaerrors.ErrEmployeeNotFound.Wrap(fmt.Errorf("payroll reference %s", line.PayrollReference))
+			}
+			return nil, fmt.Errorf("failed to get employee: %w", err)
+		}
+
+		err = tx.QueryRowContext(ctx, `
+	INSERT INTO investments (employee_id, fund_id, amount, type, status, dealing_date, product, account_id)
+	VALUES ($1, $2, $3, 'deposit', 'cash_received', $4::date, 'pension', $5)
+	RETURNING id
+`, contribution.EmployeeID, employer.DefaultFundID, line.EmployeeAmount+line.EmployerAmount, dealingDate, contribution.AccountID).Scan(&contribution.InvestmentID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to record pension contribution: %w", err)
+		}
+
+		_, err = tx.ExecContext(ctx, `
+	INSERT INTO investment_status_history (investment_id, from_status, to_status, reason)
+	VALUES ($1, NULL, 'cash_received', $2)
+`, contribution.InvestmentID, fmt.Sprintf("pension contribution for %s to %s", periodStart, periodEnd))
+		if err != nil {
+			return nil, fmt.Errorf("failed to record status history: %w", err)
+		}
+
+		if err := ledger.PostStatusChange(ctx, tx, contribution.InvestmentID, "", models.InvestmentStatusCashReceived); err != nil {
+			return nil, err
+		}
+
+		err = tx.QueryRowContext(ctx, `
+	INSERT INTO pension_contributions (employer_id, employee_id, account_id, investment_id, period_start, period_end, employee_amount, employer_amount)
+	VALUES ($1, $2, $3, $4, $5::date, $6::date, $7, $8)
+	RETURNING id, created_at
+`, employer.ID, contribution.EmployeeID, contribution.AccountID, contribution.InvestmentID,
+			periodStart, periodEnd, line.EmployeeAmount, line.EmployerAmount).Scan(&contribution.ID, &contribution.CreatedAt)
+		if err != nil {
+			if isaerrors.IsUniqueViolation(err) {
+				return nil, isaerrors.ErrContributionAlreadySubmitted.Wrap(fmt.Errorf("payroll reference %s", line.PayrollReference))
+			}
+			return nil, fmt.Errorf("failed to record pension contribution: %w", err)
+		}
+
+		contributions = append(contributions, contribution)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return contributions, nil
+}
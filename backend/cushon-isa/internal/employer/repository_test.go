@@ -0,0 +1,159 @@
+package employer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository_CreateEmployer(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	createdAt := time.Date(2025, 6, 1, 9, 0, 0, 0, time.UTC)
+
+	t.Run("created", func(t *testing.T) {
+		mock.ExpectQuery("INSERT INTO employers").
+			WithArgs("Acme Ltd", "123/AB456", "fund1").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow("employer1", createdAt))
+
+		employer := &models.Employer{Name: "Acme Ltd", PAYEReference: "123/AB456", DefaultFundID: "fund1"}
+		require.NoError(t, repo.createEmployer(context.Background(), employer))
+		assert.Equal(t, "employer1", employer.ID)
+		assert.Equal(t, createdAt, employer.CreatedAt)
+	})
+
+	t.Run("paye reference taken", func(t *testing.T) {
+		mock.ExpectQuery("INSERT INTO employers").
+			WithArgs("Acme Ltd", "123/AB456", "fund1").
+			WillReturnError(&pq.Error{Code: "23505"})
+
+		employer := &models.Employer{Name: "Acme Ltd", PAYEReference: "123/AB456", DefaultFundID: "fund1"}
+		assert.ErrorIs(t, repo.createEmployer(context.Background(), employer), isaerrors.ErrPAYEReferenceExists)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_SubmitContributions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	employer := &models.Employer{ID: "employer1", DefaultFundID: "fund1"}
+	lines := []models.ContributionLine{{PayrollReference: "E001", EmployeeAmount: 150, EmployerAmount: 90}}
+	createdAt := time.Date(2025, 7, 1, 9, 0, 0, 0, time.UTC)
+
+	expectEmployee := func() {
+		mock.ExpectQuery("SELECT e.id, a.id FROM employees e JOIN accounts a").
+			WithArgs("employer1", "E001").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "id"}).AddRow("employee1", "account1"))
+	}
+
+	expectContributionDeposit := func() {
+		mock.ExpectQuery("INSERT INTO investments (.+) 'deposit', 'cash_received', \\$4::date, 'pension', \\$5").
+			WithArgs("employee1", "fund1", 240.0, "2025-07-02", "account1").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("inv1"))
+		mock.ExpectExec("INSERT INTO investment_status_history").
+			WithArgs("inv1", "pension contribution for 2025-06-01 to 2025-06-30").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		// Note: Pension investments have no customer, the ledger posts to the employee instead
+		mock.ExpectQuery("SELECT type, customer_id, fund_id, amount, units FROM investments WHERE id = \\$1").
+			WithArgs("inv1").
+			WillReturnRows(sqlmock.NewRows([]string{"type", "customer_id", "fund_id", "amount", "units"}).
+				AddRow(models.InvestmentTypeDeposit, nil, "fund1", 240.0, nil))
+		mock.ExpectQuery("SELECT employee_id FROM investments WHERE id = \\$1").
+			WithArgs("inv1").
+			WillReturnRows(sqlmock.NewRows([]string{"employee_id"}).AddRow("employee1"))
+		mock.ExpectExec("INSERT INTO ledger_accounts (.+) ON CONFLICT \\(account_type, customer_id, employee_id, fund_id\\)").
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec("INSERT INTO ledger_journals (.+) INSERT INTO ledger_postings").
+			WithArgs("inv1", models.InvestmentStatusCashReceived, "deposit cash_received", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), true).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+	}
+
+	t.Run("submitted", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectEmployee()
+		expectContributionDeposit()
+		mock.ExpectQuery("INSERT INTO pension_contributions").
+			WithArgs("employer1", "employee1", "account1", "inv1", "2025-06-01", "2025-06-30", 150.0, 90.0).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow("contribution1", createdAt))
+		mock.ExpectCommit()
+
+		contributions, err := repo.submitContributions(context.Background(), employer, "2025-06-01", "2025-06-30", lines, "2025-07-02")
+		require.NoError(t, err)
+		assert.Equal(t, []models.PensionContribution{{
+			ID:             "contribution1",
+			EmployerID:     "employer1",
+			EmployeeID:     "employee1",
+			AccountID:      "account1",
+			InvestmentID:   "inv1",
+			FundID:         "fund1",
+			PeriodStart:    "2025-06-01",
+			PeriodEnd:      "2025-06-30",
+			EmployeeAmount: 150,
+			EmployerAmount: 90,
+			CreatedAt:      createdAt,
+		}}, contributions)
+	})
+
+	t.Run("already submitted for the period", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectEmployee()
+		expectContributionDeposit()
+		mock.ExpectQuery("INSERT INTO pension_contributions").
+			WillReturnError(&pq.Error{Code: "23505"})
+		mock.ExpectRollback()
+
+		_, err := repo.submitContributions(context.Background(), employer, "2025-06-01", "2025-06-30", lines, "2025-07-02")
+		assert.ErrorIs(t, err, isaerrors.ErrContributionAlreadySubmitted)
+	})
+
+	t.Run("unknown payroll reference", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT e.id, a.id FROM employees e JOIN accounts a").
+			WithArgs("employer1", "E001").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "id"}))
+		mock.ExpectRollback()
+
+		_, err := repo.submitContributions(context.Background(), employer, "2025-06-01", "2025-06-30", lines, "2025-07-02")
+		assert.ErrorIs(t, err, isaerrors.ErrEmployeeNotFound)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestValidateContributions(t *testing.T) {
+	assert.NoError(t, validateContributions([]models.ContributionLine{
+		{PayrollReference: "E001", EmployeeAmount: 150, EmployerAmount: 90},
+		{PayrollReference: "E002", EmployeeAmount: 0, EmployerAmount: 50},
+	}))
+
+	assert.ErrorIs(t, validateContributions(nil), isaerrors.ErrInvalidContributions)
+	assert.ErrorIs(t, validateContributions([]models.ContributionLine{
+		{PayrollReference: "E001", EmployeeAmount: 150},
+		{PayrollReference: "E001", EmployeeAmount: 20},
+	}), isaerrors.ErrInvalidContributions)
+	assert.ErrorIs(t, validateContributions([]models.ContributionLine{{PayrollReference: "E001"}}), isaerrors.ErrInvalidContributions)
+	assert.ErrorIs(t, validateContributions([]models.ContributionLine{{PayrollReference: "E001", EmployeeAmount: -10, EmployerAmount: 50}}), isaerrors.ErrInvalidContributions)
+	assert.ErrorIs(t, validateContributions([]models.ContributionLine{{EmployeeAmount: 10}}), isaerrors.ErrInvalidContributions)
+}
+
+func TestValidatePayPeriod(t *testing.T) {
+	assert.NoError(t, validatePayPeriod("2025-06-01", "2025-06-30"))
+	assert.NoError(t, validatePayPeriod("2025-06-02", "2025-06-02"))
+	assert.ErrorIs(t, validatePayPeriod("2025-06-30", "2025-06-01"), isaerrors.ErrInvalidPayPeriod)
+	assert.ErrorIs(t, validatePayPeriod("2025-06", "2025-06-30"), isaerrors.ErrInvalidPayPeriod)
+	assert.ErrorIs(t, validatePayPeriod("2025-06-01", ""), isaerrors.ErrInvalidPayPeriod)
+}
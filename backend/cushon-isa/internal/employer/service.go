@@ -0,0 +1,119 @@
+package employer
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/fund"
+	"github.com/stcol316/cushon-isa/internal/models"
+)
+
+const dateLayout = "2006-01-02"
+
+type Service struct {
+	repo  *Repository
+	funds *fund.Service
+}
+
+func NewService(repo *Repository, funds *fund.Service) *Service {
+	return &Service{repo: repo, funds: funds}
+}
+
+func (s *Service) createEmployer(ctx context.Context, req *models.CreateEmployerRequest) (*models.Employer, error) {
+	employer := models.Employer{
+		Name:          strings.TrimSpace(req.Name),
+		PAYEReference: strings.ToUpper(strings.TrimSpace(req.PAYEReference)),
+		DefaultFundID: req.DefaultFundID,
+	}
+	if employer.Name == "" || employer.PAYEReference == "" || employer.DefaultFundID == "" {
+		return nil, isaerrors.ErrEmployerDetailsRequired
+	}
+
+	// Note: The default fund must be open to new investment or the first payroll would fail
+	if _, err := s.funds.GetOpenFund(ctx, employer.DefaultFundID); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.createEmployer(ctx, &employer); err != nil {
+		return nil, err
+	}
+	return &employer, nil
+}
+
+func (s *Service) getEmployer(ctx context.Context, id string) (*models.Employer, error) {
+	return s.repo.getEmployer(ctx, id)
+}
+
+// Note: Contributions are invested in the employer's default fund at the next dealing date
+// TODO: Let employees choose their own funds
+func (s *Service) submitContributions(ctx context.Context, employerID string, req *models.SubmitContributionsRequest, now time.Time) (*models.ContributionRun, error) {
+	if err := validatePayPeriod(req.PeriodStart, req.PeriodEnd); err != nil {
+		return nil, err
+	}
+	if err := validateContributions(req.Contributions); err != nil {
+		return nil, err
+	}
+
+	employer, err := s.repo.getEmployer(ctx, employerID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.funds.GetOpenFund(ctx, employer.DefaultFundID); err != nil {
+		return nil, err
+	}
+	dealingDate, err := s.funds.DealingDate(ctx, employer.DefaultFundID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	contributions, err := s.repo.submitContributions(ctx, employer, req.PeriodStart, req.PeriodEnd, req.Contributions, dealingDate)
+	if err != nil {
+		return nil, err
+	}
+
+	run := &models.ContributionRun{
+		EmployerID:    employer.ID,
+		PeriodStart:   req.PeriodStart,
+		PeriodEnd:     req.PeriodEnd,
+		Contributions: contributions,
+	}
+	for _, contribution := range contributions {
+		run.EmployeeTotal += contribution.EmployeeAmount
+		run.EmployerTotal += contribution.EmployerAmount
+	}
+
+	log.Printf("Employer %s submitted pension contributions for %d employees for %s to %s", employer.ID, len(contributions), req.PeriodStart, req.PeriodEnd)
+	return run, nil
+}
+
+func validatePayPeriod(start, end string) error {
+	from, err := time.Parse(dateLayout, start)
+	if err != nil {
+		return isaerrors.ErrInvalidPayPeriod
+	}
+	to, err := time.Parse(dateLayout, end)
+	if err != nil || to.Before(from) {
+		return isaerrors.ErrInvalidPayPeriod
+	}
+	return nil
+}
+
+func validateContributions(lines []models.ContributionLine) error {
+	if len(lines) == 0 {
+		return isaerrors.ErrInvalidContributions
+	}
+	seen := make(map[string]bool, len(lines))
+	for _, line := range lines {
+		if line.PayrollReference == "" || seen[line.PayrollReference] {
+			return isaerrors.ErrInvalidContributions
+		}
+		if line.EmployeeAmount < 0 || line.EmployerAmount < 0 || line.EmployeeAmount+line.EmployerAmount <= 0 {
+			return isaerrors.ErrInvalidContributions
+		}
+		seen[line.PayrollReference] = true
+	}
+	return nil
+}
@@ -1,5 +1,232 @@
 package isaerrors
 
-import "errors"
+import (
+	"errors"
+	"fmt"
 
-var ErrDifferentFundNotAllowed = errors.New("customers can only invest in one fund at this time")
+	"github.com/lib/pq"
+)
+
+// Kind classifies a domain error so the presentation layer can map it to an HTTP status
+// without needing to know anything about the layer the error came from
+type Kind string
+
+const (
+	KindNotFound     Kind = "not_found"
+	KindConflict     Kind = "conflict"
+	KindValidation   Kind = "validation"
+	KindForbidden    Kind = "forbidden"
+	KindBusinessRule Kind = "business_rule"
+)
+
+// Note: Typed domain error. Code is a stable, machine readable identifier that clients can rely on
+// while Message is a human readable explanation that is safe to return to clients
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is allows errors.Is(err, isaerrors.ErrNotFound) style checks against a kind
+// as well as direct comparison against a specific error value
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok {
+		return false
+	}
+	if t.Code == "" {
+		return e.Kind == t.Kind
+	}
+	return e.Kind == t.Kind && e.Code == t.Code
+}
+
+// Sentinels used for matching on kind only
+var (
+	ErrNotFound     = &Error{Kind: KindNotFound}
+	ErrConflict     = &Error{Kind: KindConflict}
+	ErrValidation   = &Error{Kind: KindValidation}
+	ErrForbidden    = &Error{Kind: KindForbidden}
+	ErrBusinessRule = &Error{Kind: KindBusinessRule}
+)
+
+func NotFound(code, message string) *Error {
+	return &Error{Kind: KindNotFound, Code: code, Message: message}
+}
+
+func Conflict(code, message string) *Error {
+	return &Error{Kind: KindConflict, Code: code, Message: message}
+}
+
+func Validation(code, message string) *Error {
+	return &Error{Kind: KindValidation, Code: code, Message: message}
+}
+
+func Forbidden(code, message string) *Error {
+	return &Error{Kind: KindForbidden, Code: code, Message: message}
+}
+
+func BusinessRule(code, message string) *Error {
+	return &Error{Kind: KindBusinessRule, Code: code, Message: message}
+}
+
+// Wrap attaches an underlying cause to a copy of a domain error
+func (e *Error) Wrap(err error) *Error {
+	return &Error{Kind: e.Kind, Code: e.Code, Message: e.Message, Err: err}
+}
+
+// As extracts a domain error from an error chain
+func As(err error) (*Error, bool) {
+	var domainErr *Error
+	if errors.As(err, &domainErr) {
+		return domainErr, true
+	}
+	return nil, false
+}
+
+// Note: Postgres error codes we care about
+// See https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+	pgCheckViolation      = "23514"
+)
+
+// IsUniqueViolation reports whether err was caused by a unique constraint violation
+func IsUniqueViolation(err error) bool {
+	return hasPgCode(err, pgUniqueViolation)
+}
+
+// IsForeignKeyViolation reports whether err was caused by a foreign key violation
+func IsForeignKeyViolation(err error) bool {
+	return hasPgCode(err, pgForeignKeyViolation)
+}
+
+// IsCheckViolation reports whether err was caused by a check constraint violation
+func IsCheckViolation(err error) bool {
+	return hasPgCode(err, pgCheckViolation)
+}
+
+func hasPgCode(err error, code string) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return string(pqErr.Code) == code
+	}
+	return false
+}
+
+var (
+	ErrDifferentFundNotAllowed = BusinessRule("different_fund_not_allowed", "customers can only invest in one fund at this time")
+
+	ErrCustomerNotFound         = NotFound("customer_not_found", "customer not found")
+	ErrEmailAlreadyExists       = Conflict("email_already_registered", "a customer with this email already exists")
+	ErrCustomerClosed           = BusinessRule("customer_account_closed", "customer account is closed")
+	ErrCustomerErased           = Conflict("customer_erased", "customer personal data has been erased")
+	ErrInvalidVerificationToken = Validation("invalid_verification_token", "email verification token is invalid")
+	ErrEmailAlreadyVerified     = Conflict("email_already_verified", "email address is already verified")
+	ErrEligibilityIncomplete    = BusinessRule("isa_eligibility_incomplete", "customer must be a UK resident aged 18 or over with a date of birth and national insurance number on record")
+
+	ErrVerificationNotFound       = NotFound("verification_not_found", "identity verification not found")
+	ErrInvalidKYCTransition       = Conflict("invalid_kyc_transition", "identity verification cannot move to the requested status")
+	ErrVerificationDataIncomplete = Validation("verification_data_incomplete", "name, date of birth and national insurance number are required for identity verification")
+	ErrIdentityNotVerified        = Forbidden("identity_not_verified", "customer identity must be verified before investing")
+
+	ErrFundNotFound       = NotFound("fund_not_found", "fund not found")
+	ErrFundRetired        = BusinessRule("fund_retired", "fund has been retired and is closed to new investment")
+	ErrISINAlreadyExists  = Conflict("isin_already_registered", "a fund with this ISIN already exists")
+	ErrInvalidRiskLevel   = Validation("invalid_risk_level", "risk level does not exist")
+	ErrRiskLevelNotFound  = NotFound("risk_level_not_found", "risk level not found")
+	ErrFundPriceExists    = Conflict("fund_price_exists", "a price has already been loaded for this fund and date")
+	ErrInvalidFundPrice   = Validation("invalid_fund_price", "price must be greater than zero")
+	ErrInvalidDealingDate = Validation("invalid_dealing_date", "date must be in YYYY-MM-DD format")
+
+	ErrInvestmentNotFound        = NotFound("investment_not_found", "investment not found")
+	ErrCustomerFundTotalNotFound = NotFound("customer_fund_total_not_found", "no investments found for this customer and fund")
+	ErrInvalidInvestmentRef      = Validation("invalid_investment_reference", "customer or fund does not exist")
+	ErrInvalidAmount             = Validation("invalid_amount", "amount must be greater than zero")
+	ErrInvalidInvestmentType     = Validation("invalid_investment_type", "type must be deposit or withdrawal")
+	ErrInsufficientBalance       = BusinessRule("insufficient_balance", "withdrawal exceeds the available balance")
+	ErrTransactionRejected       = BusinessRule("transaction_rejected", "transaction was rejected by compliance checks")
+	ErrInvalidStatusTransition   = Conflict("invalid_status_transition", "investment cannot move to the requested status")
+	ErrInvestmentStatusChanged   = Conflict("investment_status_changed", "investment status was changed by another request")
+	ErrInvestmentNotPriced       = BusinessRule("investment_not_priced", "units cannot be allocated until the fund price for the dealing date is loaded")
+
+	ErrRiskProfileNotFound         = NotFound("risk_profile_not_found", "customer has not completed the risk questionnaire")
+	ErrInvalidRiskAnswers          = Validation("invalid_risk_answers", "every question must be answered exactly once with one of its options")
+	ErrRiskAcknowledgementRequired = BusinessRule("risk_acknowledgement_required", "fund risk level is higher than the customer's risk profile, set riskAcknowledged to proceed")
+
+	ErrInvalidPerformancePeriod = Validation("invalid_performance_period", "period must be one of 1M, 3M, 1Y or inception")
+
+	ErrInvalidStatementPeriod  = Validation("invalid_statement_period", "period must be a year (2025) or a quarter (2025-Q1)")
+	ErrStatementPeriodNotEnded = BusinessRule("statement_period_not_ended", "statements are only available once the period has ended")
+
+	ErrInvalidChargePeriod     = Validation("invalid_charge_period", "month must be in YYYY-MM format")
+	ErrChargePeriodNotEnded    = BusinessRule("charge_period_not_ended", "charges can only be collected once the month has ended")
+	ErrChargesAlreadyCollected = Conflict("charges_already_collected", "charges have already been collected for this month")
+	ErrInvalidFeeTiers         = Validation("invalid_fee_tiers", "tiers must start at zero, be contiguous and end with an unbounded tier")
+
+	ErrInvalidStatementFormat   = Validation("invalid_statement_format", "bank statement must be CSV or CAMT.053 XML")
+	ErrInvalidBankStatement     = Validation("invalid_bank_statement", "bank statement could not be read")
+	ErrStatementAlreadyImported = Conflict("statement_already_imported", "this bank statement has already been imported")
+
+	ErrAllowanceExceeded          = BusinessRule("isa_allowance_exceeded", "deposit would exceed the annual ISA allowance")
+	ErrTransferNotFound           = NotFound("transfer_not_found", "isa transfer not found")
+	ErrInvalidTransferScope       = Validation("invalid_transfer_scope", "scope must be full or partial")
+	ErrInvalidTransferAmounts     = Validation("invalid_transfer_amounts", "transfer amounts cannot be negative and must not both be zero")
+	ErrCurrentYearTransferPartial = Validation("current_year_transfer_partial", "current tax year subscriptions can only be transferred in full")
+	ErrTransferProviderRequired   = Validation("transfer_provider_required", "provider name and account reference are required")
+	ErrInvalidTransferTransition  = Conflict("invalid_transfer_transition", "isa transfer cannot move to the requested status")
+	ErrTransferStatusChanged      = Conflict("transfer_status_changed", "isa transfer status was changed by another request")
+	ErrReceivedAmountRequired     = Validation("received_amount_required", "received amount must be greater than zero to complete a transfer")
+	ErrInvalidTransferMethod      = Validation("invalid_transfer_method", "method must be cash or in_specie")
+	ErrInvalidProviderFRN         = Validation("invalid_provider_frn", "receiving manager FCA firm reference number must be 6 or 7 digits")
+	ErrNothingToTransfer          = BusinessRule("nothing_to_transfer", "customer has no holding to transfer")
+	ErrTransferOutInProgress      = Conflict("transfer_out_in_progress", "customer already has a transfer out in progress")
+	ErrInvestmentsInFlight        = BusinessRule("investments_in_flight", "investments and transfers in must complete before the holding can be transferred out")
+	ErrTransferNotSettled         = BusinessRule("transfer_not_settled", "the holding has not finished being sold")
+
+	ErrJuniorISAWithdrawal      = BusinessRule("junior_isa_withdrawal", "withdrawals cannot be made from a junior ISA before the holder turns 18")
+	ErrInvalidRegisteredContact = BusinessRule("invalid_registered_contact", "the registered contact must be an active adult customer")
+	ErrJuniorISAHolderTooOld    = Validation("junior_isa_holder_too_old", "junior ISAs are only available to children under 18")
+	ErrRegisteredContactErasure = BusinessRule("registered_contact_erasure", "the registered contact for an open junior ISA cannot be erased until it is closed or converted")
+
+	ErrInvalidISAProduct             = Validation("invalid_isa_product", "product must be isa or lifetime_isa")
+	ErrInvalidWithdrawalReason       = Validation("invalid_withdrawal_reason", "withdrawal reason must be first_home or terminal_illness, and only applies to lifetime ISA withdrawals")
+	ErrLifetimeISANotOpen            = BusinessRule("lifetime_isa_not_open", "customer does not have a lifetime ISA")
+	ErrLifetimeISAAlreadyOpen        = Conflict("lifetime_isa_already_open", "customer already has a lifetime ISA")
+	ErrLifetimeISAAge                = BusinessRule("lifetime_isa_age", "lifetime ISAs can only be opened between the ages of 18 and 39")
+	ErrLifetimeISAContributionsEnded = BusinessRule("lifetime_isa_contributions_ended", "lifetime ISA contributions stop at age 50")
+	ErrLifetimeISAAllowanceExceeded  = BusinessRule("lifetime_isa_allowance_exceeded", "deposit would exceed the annual lifetime ISA limit")
+	ErrInvalidBonusMonth             = Validation("invalid_bonus_month", "month must be in YYYY-MM format")
+	ErrNoBonusToPay                  = NotFound("no_bonus_to_pay", "no lifetime ISA bonus is awaiting payment for this month")
+
+	ErrAccountNotFound        = NotFound("account_not_found", "account not found")
+	ErrAccountClosed          = BusinessRule("account_closed", "account is closed")
+	ErrAccountProductMismatch = Validation("account_product_mismatch", "product does not match the account")
+
+	ErrEmployerNotFound             = NotFound("employer_not_found", "employer not found")
+	ErrEmployerDetailsRequired      = Validation("employer_details_required", "employer name, PAYE reference and default fund are required")
+	ErrPAYEReferenceExists          = Conflict("paye_reference_registered", "an employer with this PAYE reference already exists")
+	ErrEmployeeNotFound             = NotFound("employee_not_found", "employee not found")
+	ErrEmployeeDetailsRequired      = Validation("employee_details_required", "employer, payroll reference, name, email and date of birth are required")
+	ErrPayrollReferenceExists       = Conflict("payroll_reference_registered", "the employer already has an employee with this payroll reference")
+	ErrInvalidPayPeriod             = Validation("invalid_pay_period", "pay period start and end must be YYYY-MM-DD dates with the end on or after the start")
+	ErrInvalidContributions         = Validation("invalid_contributions", "each employee must be listed once with amounts that are not negative and not both zero")
+	ErrContributionAlreadySubmitted = Conflict("contribution_already_submitted", "contributions have already been submitted for an employee in this pay period")
+
+	ErrReviewNotFound = NotFound("aml_review_not_found", "no held investment awaiting review")
+
+	ErrInvalidRequestBody = Validation("invalid_request_body", "request body could not be decoded")
+	ErrInvalidCursor      = Validation("invalid_cursor", "cursor is invalid")
+)
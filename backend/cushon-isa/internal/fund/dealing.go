@@ -0,0 +1,110 @@
+package fund
+
+import (
+	"fmt"
+	"time"
+
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/models"
+)
+
+const dateLayout = "2006-01-02"
+
+// Note: A year of non-dealing days means the configuration is broken rather than the calendar
+const maxDealingLookahead = 366
+
+// dealingDate returns the date an order placed at placedAt deals at.
+// Orders placed on a dealing day before the cut-off deal that day, anything later deals on the next dealing day
+func dealingDate(dealing *models.FundDealing, placedAt time.Time) (string, error) {
+	location, err := time.LoadLocation(dealing.Timezone)
+	if err != nil {
+		return "", fmt.Errorf("invalid dealing time zone %q: %w", dealing.Timezone, err)
+	}
+
+	cutoff, err := time.Parse("15:04", dealing.Cutoff)
+	if err != nil {
+		return "", fmt.Errorf("invalid dealing cut-off %q: %w", dealing.Cutoff, err)
+	}
+
+	local := placedAt.In(location)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, location)
+	cutoffAt := time.Date(local.Year(), local.Month(), local.Day(), cutoff.Hour(), cutoff.Minute(), 0, 0, location)
+
+	if isDealingDay(dealing, day) && local.Before(cutoffAt) {
+		return day.Format(dateLayout), nil
+	}
+
+	for i := 0; i < maxDealingLookahead; i++ {
+		day = day.AddDate(0, 0, 1)
+		if isDealingDay(dealing, day) {
+			return day.Format(dateLayout), nil
+		}
+	}
+
+	return "", fmt.Errorf("no dealing day found within %d days", maxDealingLookahead)
+}
+
+func isDealingDay(dealing *models.FundDealing, day time.Time) bool {
+	weekday := isoWeekday(day)
+	dealsToday := false
+	for _, d := range dealing.DealingDays {
+		if d == weekday {
+			dealsToday = true
+			break
+		}
+	}
+	if !dealsToday {
+		return false
+	}
+
+	date := day.Format(dateLayout)
+	for _, holiday := range dealing.Holidays {
+		if holiday.Date == date {
+			return false
+		}
+	}
+	return true
+}
+
+// isoWeekday converts Go's Sunday = 0 weekday to ISO 8601 where Monday = 1 and Sunday = 7
+func isoWeekday(day time.Time) int {
+	if day.Weekday() == time.Sunday {
+		return 7
+	}
+	return int(day.Weekday())
+}
+
+func validateDealing(dealing *models.FundDealing) error {
+	if _, err := time.LoadLocation(dealing.Timezone); err != nil || dealing.Timezone == "" {
+		return isaerrors.Validation("invalid_dealing_timezone", "timezone must be a valid IANA time zone")
+	}
+
+	valuation, err := time.Parse("15:04", dealing.ValuationTime)
+	if err != nil {
+		return isaerrors.Validation("invalid_valuation_time", "valuationTime must be in HH:MM format")
+	}
+	cutoff, err := time.Parse("15:04", dealing.Cutoff)
+	if err != nil {
+		return isaerrors.Validation("invalid_dealing_cutoff", "cutoff must be in HH:MM format")
+	}
+	if cutoff.After(valuation) {
+		return isaerrors.Validation("cutoff_after_valuation", "cutoff cannot be later than the valuation time")
+	}
+
+	if len(dealing.DealingDays) == 0 {
+		return isaerrors.Validation("no_dealing_days", "at least one dealing day is required")
+	}
+	for _, d := range dealing.DealingDays {
+		if d < 1 || d > 7 {
+			return isaerrors.Validation("invalid_dealing_day", "dealing days must be ISO weekdays between 1 (Monday) and 7 (Sunday)")
+		}
+	}
+
+	for _, holiday := range dealing.Holidays {
+		if _, err := time.Parse(dateLayout, holiday.Date); err != nil {
+			return isaerrors.ErrInvalidDealingDate
+		}
+	}
+
+	return nil
+}
@@ -1,15 +1,18 @@
 package fund
 
 import (
-	"database/sql"
-	"errors"
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
 	mw "github.com/stcol316/cushon-isa/internal/middleware"
+	"github.com/stcol316/cushon-isa/internal/models"
 	helper "github.com/stcol316/cushon-isa/pkg/helpers"
 )
 
@@ -31,15 +34,80 @@ func (h *Handler) ListFundsHandler(w http.ResponseWriter, r *http.Request) {
 
 	fmt.Printf("Pagination Params: Page=%d, PageSize=%d\n", params.Page, params.PageSize)
 
-	result, err := h.service.listFunds(r.Context(), params.Page, params.PageSize)
+	filter, err := parseFundFilter(r)
 	if err != nil {
-		h.handleFundError(w, err)
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	result, err := h.service.listFunds(r.Context(), filter, params.Page, params.PageSize)
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
 		return
 	}
 	helper.RespondWithJSON(w, http.StatusOK, result)
 
 }
 
+// parseFundFilter reads the list filters from the query string, e.g.
+// ?q=ethical bonds&risk_level=low&max_ocf=0.3&asset_class=fixed_income&ethical=true&sort=ocf&order=desc
+// risk_level accepts either the risk level ID or its name
+func parseFundFilter(r *http.Request) (*models.FundFilter, error) {
+	query := r.URL.Query()
+	filter := &models.FundFilter{
+		Search:     strings.TrimSpace(query.Get("q")),
+		AssetClass: query.Get("asset_class"),
+		SortBy:     query.Get("sort"),
+	}
+
+	if riskLevel := query.Get("risk_level"); riskLevel != "" {
+		if id, err := strconv.Atoi(riskLevel); err == nil {
+			if id <= 0 {
+				return nil, isaerrors.Validation("invalid_risk_level_filter", "risk_level must be a positive ID or a risk level name")
+			}
+			filter.RiskLevelID = id
+		} else {
+			filter.RiskLevelName = riskLevel
+		}
+	}
+
+	var err error
+	if filter.MinOCF, err = parseOptionalFloat(query.Get("min_ocf")); err != nil {
+		return nil, isaerrors.Validation("invalid_ocf_filter", "min_ocf must be a number")
+	}
+	if filter.MaxOCF, err = parseOptionalFloat(query.Get("max_ocf")); err != nil {
+		return nil, isaerrors.Validation("invalid_ocf_filter", "max_ocf must be a number")
+	}
+
+	if ethical := query.Get("ethical"); ethical != "" {
+		value, err := strconv.ParseBool(ethical)
+		if err != nil {
+			return nil, isaerrors.Validation("invalid_ethical_filter", "ethical must be true or false")
+		}
+		filter.Ethical = &value
+	}
+
+	switch order := strings.ToLower(query.Get("order")); order {
+	case "", "asc", "desc":
+		filter.SortOrder = order
+	default:
+		return nil, isaerrors.Validation("invalid_order", "order must be asc or desc")
+	}
+
+	return filter, nil
+}
+
+func parseOptionalFloat(value string) (*float64, error) {
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &parsed, nil
+}
+
 func (h *Handler) GetFundByIdHandler(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	if id == "" {
@@ -54,18 +122,143 @@ func (h *Handler) GetFundByIdHandler(w http.ResponseWriter, r *http.Request) {
 
 	customer, err := h.service.getFundByID(r.Context(), id)
 	if err != nil {
-		h.handleFundError(w, err)
+		helper.RespondWithProblem(w, r, err)
 		return
 	}
 
 	helper.RespondWithJSON(w, http.StatusOK, customer)
 }
 
-func (h *Handler) handleFundError(w http.ResponseWriter, err error) {
-	switch {
-	case errors.Is(err, sql.ErrNoRows):
-		helper.RespondWithError(w, http.StatusNotFound, "no funds found")
-	default:
-		helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+// Note: Admin only. Replaces the fund's dealing times, days and holiday calendar
+func (h *Handler) UpdateFundDealingHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "invalid fund ID format")
+		return
+	}
+
+	req := new(models.FundDealing)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		helper.RespondWithProblem(w, r, isaerrors.ErrInvalidRequestBody.Wrap(err))
+		return
+	}
+
+	dealing, err := h.service.updateFundDealing(r.Context(), id, req)
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, dealing)
+}
+
+// Note: Admin only. Loads the price for a dealing date and prices the orders waiting on it
+func (h *Handler) LoadFundPriceHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "invalid fund ID format")
+		return
 	}
+
+	req := new(models.LoadFundPriceRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		helper.RespondWithProblem(w, r, isaerrors.ErrInvalidRequestBody.Wrap(err))
+		return
+	}
+
+	price, err := h.service.loadFundPrice(r.Context(), id, req)
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusCreated, price)
+}
+
+// Note: Admin only
+func (h *Handler) CreateFundHandler(w http.ResponseWriter, r *http.Request) {
+	if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+		helper.RespondWithError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/json")
+		return
+	}
+
+	req := new(models.CreateFundRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		helper.RespondWithProblem(w, r, isaerrors.ErrInvalidRequestBody.Wrap(err))
+		return
+	}
+
+	fund, err := h.service.createFund(r.Context(), req)
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusCreated, fund)
+}
+
+// Note: Admin only
+func (h *Handler) UpdateFundHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "invalid fund ID format")
+		return
+	}
+
+	req := new(models.UpdateFundRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		helper.RespondWithProblem(w, r, isaerrors.ErrInvalidRequestBody.Wrap(err))
+		return
+	}
+
+	fund, err := h.service.updateFund(r.Context(), id, req)
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, fund)
+}
+
+// Note: Admin only. Closes the fund to new investment, existing holdings are kept
+func (h *Handler) RetireFundHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "invalid fund ID format")
+		return
+	}
+
+	fund, err := h.service.retireFund(r.Context(), id)
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, fund)
+}
+
+func (h *Handler) ListRiskLevelsHandler(w http.ResponseWriter, r *http.Request) {
+	riskLevels, err := h.service.listRiskLevels(r.Context())
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, riskLevels)
+}
+
+func (h *Handler) GetRiskLevelByIDHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || id <= 0 {
+		helper.RespondWithError(w, http.StatusBadRequest, "invalid risk level ID format")
+		return
+	}
+
+	riskLevel, err := h.service.getRiskLevelByID(r.Context(), id)
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, riskLevel)
 }
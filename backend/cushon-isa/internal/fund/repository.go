@@ -4,13 +4,24 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 
+	"github.com/lib/pq"
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
 	"github.com/stcol316/cushon-isa/internal/models"
 )
 
 type FundRepository interface {
-	ListFunds(ctx context.Context, page, pageSize int) ([]models.Fund, int, error)
+	ListFunds(ctx context.Context, filter *models.FundFilter, page, pageSize int) ([]models.Fund, int, error)
 	GetFundByID(ctx context.Context, id string) (*models.Fund, error)
+	ListRiskLevels(ctx context.Context) ([]models.RiskLevel, error)
+	GetRiskLevelByID(ctx context.Context, id int) (*models.RiskLevel, error)
+	GetFundDealing(ctx context.Context, fundID string) (*models.FundDealing, error)
+	UpdateFundDealing(ctx context.Context, fundID string, dealing *models.FundDealing) error
+	LoadFundPrice(ctx context.Context, price *models.FundPrice) error
+	CreateFund(ctx context.Context, req *models.CreateFundRequest) (string, error)
+	UpdateFund(ctx context.Context, id string, req *models.UpdateFundRequest) error
+	RetireFund(ctx context.Context, id string) error
 }
 
 type Repository struct {
@@ -21,23 +32,147 @@ func NewRepository(db *sql.DB) *Repository {
 	return &Repository{db: db}
 }
 
-func (r *Repository) listFunds(ctx context.Context, page, pageSize int) ([]models.Fund, int, error) {
+// Note: Risk level is joined so funds can be returned with a structured risk object.
+// It is nullable in the schema so the joined columns are scanned as nullable types
+const fundColumns = `
+	f.id, f.name, COALESCE(f.description, ''), rl.id, rl.name, rl.description,
+	COALESCE(f.isin, ''), f.ocf, f.currency, f.asset_class, f.ethical, f.status`
+
+const fundFrom = `
+	FROM funds f
+	LEFT JOIN risk_levels rl ON rl.id = f.risk_level_id`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanFund(row rowScanner) (*models.Fund, error) {
+	var fund models.Fund
+	var riskID sql.NullInt64
+	var riskName, riskDescription sql.NullString
+	err := row.Scan(
+		&fund.ID,
+		&fund.Name,
+		&fund.Description,
+		&riskID,
+		&riskName,
+		&riskDescription,
+		&fund.ISIN,
+		&fund.OCF,
+		&fund.Currency,
+		&fund.AssetClass,
+		&fund.Ethical,
+		&fund.Status,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if riskID.Valid {
+		fund.RiskLevel = &models.RiskLevel{
+			ID:          int(riskID.Int64),
+			Name:        riskName.String,
+			Description: riskDescription.String,
+		}
+	}
+
+	return &fund, nil
+}
+
+// Note: Only these sort fields are accepted. User input selects a key here
+// and is never written into the ORDER BY clause itself
+var fundSortColumns = map[string]string{
+	"name":       "f.name",
+	"ocf":        "f.ocf",
+	"risk_level": "f.risk_level_id",
+	"created_at": "f.created_at",
+	"relevance":  "", // Built from the search term, see buildFundOrder
+}
+
+// buildFundFilter composes the WHERE clause from the filter. Values are always passed as
+// placeholders, never concatenated into the SQL
+func buildFundFilter(filter *models.FundFilter) (string, []interface{}) {
+	// Note: Retired funds are closed to new investment so are not listed
+	conditions := []string{"f.status = 'active'"}
+	var args []interface{}
+
+	add := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	// Note: Search is always the first argument so the relevance sort can refer to it as $1
+	if filter.Search != "" {
+		add("f.search_vector @@ websearch_to_tsquery('english', $%d)", filter.Search)
+	}
+	if filter.RiskLevelID != 0 {
+		add("f.risk_level_id = $%d", filter.RiskLevelID)
+	}
+	if filter.RiskLevelName != "" {
+		add("LOWER(rl.name) = LOWER($%d)", filter.RiskLevelName)
+	}
+	if filter.MinOCF != nil {
+		add("f.ocf >= $%d", *filter.MinOCF)
+	}
+	if filter.MaxOCF != nil {
+		add("f.ocf <= $%d", *filter.MaxOCF)
+	}
+	if filter.AssetClass != "" {
+		add("f.asset_class = $%d", filter.AssetClass)
+	}
+	if filter.Ethical != nil {
+		add("f.ethical = $%d", *filter.Ethical)
+	}
+
+	return "\n\tWHERE " + strings.Join(conditions, " AND "), args
+}
+
+// buildFundOrder returns the ORDER BY clause. Searches default to relevance, everything else to name.
+// The fund ID is always the final key so pages are stable when sort values tie
+func buildFundOrder(filter *models.FundFilter) string {
+	sortBy := filter.SortBy
+	if sortBy == "" {
+		sortBy = "name"
+		if filter.Search != "" {
+			sortBy = "relevance"
+		}
+	}
+
+	column := fundSortColumns[sortBy]
+	direction := "ASC"
+	if sortBy == "relevance" {
+		column = "ts_rank(f.search_vector, websearch_to_tsquery('english', $1))"
+		// Note: Most relevant first unless explicitly reversed
+		direction = "DESC"
+	}
+
+	switch filter.SortOrder {
+	case "asc":
+		direction = "ASC"
+	case "desc":
+		direction = "DESC"
+	}
+
+	return fmt.Sprintf("\n\tORDER BY %s %s NULLS LAST, f.id", column, direction)
+}
+
+func (r *Repository) listFunds(ctx context.Context, filter *models.FundFilter, page, pageSize int) ([]models.Fund, int, error) {
 	offset := (page - 1) * pageSize
+	where, args := buildFundFilter(filter)
 
 	// First, get total count
+	// Note: The count uses the same filters so pagination reflects the filtered result
 	var total int
-	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM funds").Scan(&total)
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*)"+fundFrom+where, args...).Scan(&total)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to get total count: %w", err)
 	}
 
 	// Then get paginated data
-	rows, err := r.db.QueryContext(ctx, `
-        SELECT id, name, description 
-        FROM funds 
-        ORDER BY name 
-        LIMIT $1 OFFSET $2
-    `, pageSize, offset)
+	query := "SELECT" + fundColumns + fundFrom + where + buildFundOrder(filter) +
+		fmt.Sprintf("\n\tLIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+	rows, err := r.db.QueryContext(ctx, query, append(args, pageSize, offset)...)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to query funds: %w", err)
 	}
@@ -45,36 +180,277 @@ func (r *Repository) listFunds(ctx context.Context, page, pageSize int) ([]model
 
 	var funds []models.Fund
 	for rows.Next() {
-		var fund models.Fund
-		if err := rows.Scan(&fund.ID, &fund.Name, &fund.Description); err != nil {
+		fund, err := scanFund(rows)
+		if err != nil {
 			return nil, 0, fmt.Errorf("failed to scan fund: %w", err)
 		}
-		funds = append(funds, fund)
+		funds = append(funds, *fund)
 	}
 
 	return funds, total, nil
 }
 
 func (r *Repository) getFundByID(ctx context.Context, id string) (*models.Fund, error) {
-	query := `
-	SELECT id, name, description, risk_level_id
-	FROM funds
-	WHERE id = $1
+	query := "SELECT" + fundColumns + fundFrom + `
+	WHERE f.id = $1
 `
-	var fund models.Fund
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&fund.ID,
-		&fund.Name,
-		&fund.Description,
-		&fund.RiskLevel,
+	fund, err := scanFund(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, isaerrors.ErrFundNotFound
+		}
+		return nil, fmt.Errorf("failed to get fund: %w", err)
+	}
+
+	return fund, nil
+}
+
+func (r *Repository) listRiskLevels(ctx context.Context) ([]models.RiskLevel, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT id, name, COALESCE(description, '')
+        FROM risk_levels
+        ORDER BY id
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query risk levels: %w", err)
+	}
+	defer rows.Close()
+
+	riskLevels := []models.RiskLevel{}
+	for rows.Next() {
+		var riskLevel models.RiskLevel
+		if err := rows.Scan(&riskLevel.ID, &riskLevel.Name, &riskLevel.Description); err != nil {
+			return nil, fmt.Errorf("failed to scan risk level: %w", err)
+		}
+		riskLevels = append(riskLevels, riskLevel)
+	}
+
+	return riskLevels, nil
+}
+
+func (r *Repository) getRiskLevelByID(ctx context.Context, id int) (*models.RiskLevel, error) {
+	var riskLevel models.RiskLevel
+	err := r.db.QueryRowContext(ctx, `
+	SELECT id, name, COALESCE(description, '')
+	FROM risk_levels
+	WHERE id = $1
+`, id).Scan(&riskLevel.ID, &riskLevel.Name, &riskLevel.Description)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, isaerrors.ErrRiskLevelNotFound
+		}
+		return nil, fmt.Errorf("failed to get risk level: %w", err)
+	}
+
+	return &riskLevel, nil
+}
+
+func (r *Repository) createFund(ctx context.Context, req *models.CreateFundRequest) (string, error) {
+	var id string
+	err := r.db.QueryRowContext(ctx, `
+	INSERT INTO funds (name, description, risk_level_id, isin, ocf, currency, asset_class, ethical)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	RETURNING id
+`,
+		req.Name,
+		req.Description,
+		req.RiskLevelID,
+		req.ISIN,
+		req.OCF,
+		req.Currency,
+		req.AssetClass,
+		req.Ethical,
+	).Scan(&id)
+	if err != nil {
+		return "", mapFundWriteError(err, "failed to create fund")
+	}
+
+	return id, nil
+}
+
+// Note: Partial update. Nil fields are passed as NULL and COALESCE keeps the current value
+func (r *Repository) updateFund(ctx context.Context, id string, req *models.UpdateFundRequest) error {
+	res, err := r.db.ExecContext(ctx, `
+	UPDATE funds
+	SET name = COALESCE($2, name), description = COALESCE($3, description),
+		risk_level_id = COALESCE($4, risk_level_id), isin = COALESCE($5, isin),
+		ocf = COALESCE($6, ocf), currency = COALESCE($7, currency),
+		asset_class = COALESCE($8, asset_class), ethical = COALESCE($9, ethical),
+		updated_at = CURRENT_TIMESTAMP
+	WHERE id = $1
+`,
+		id,
+		req.Name,
+		req.Description,
+		req.RiskLevelID,
+		req.ISIN,
+		req.OCF,
+		req.Currency,
+		req.AssetClass,
+		req.Ethical,
 	)
+	if err != nil {
+		return mapFundWriteError(err, "failed to update fund")
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return isaerrors.ErrFundNotFound
+	}
+
+	return nil
+}
+
+// Note: Retiring only changes the status. Investments in the fund are untouched
+// and the investment service refuses new deposits into retired funds
+func (r *Repository) retireFund(ctx context.Context, id string) error {
+	res, err := r.db.ExecContext(ctx, `
+	UPDATE funds
+	SET status = 'retired', retired_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+	WHERE id = $1 AND status = 'active'
+`, id)
+	if err != nil {
+		return fmt.Errorf("failed to retire fund: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return isaerrors.ErrFundRetired
+	}
+
+	return nil
+}
 
+func mapFundWriteError(err error, msg string) error {
+	if isaerrors.IsUniqueViolation(err) {
+		return isaerrors.ErrISINAlreadyExists.Wrap(err)
+	}
+	if isaerrors.IsForeignKeyViolation(err) {
+		return isaerrors.ErrInvalidRiskLevel.Wrap(err)
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+func (r *Repository) getFundDealing(ctx context.Context, fundID string) (*models.FundDealing, error) {
+	var dealing models.FundDealing
+	var days []int64
+	err := r.db.QueryRowContext(ctx, `
+	SELECT TO_CHAR(valuation_time, 'HH24:MI'), TO_CHAR(dealing_cutoff, 'HH24:MI'), dealing_days, dealing_timezone
+	FROM funds
+	WHERE id = $1
+`, fundID).Scan(&dealing.ValuationTime, &dealing.Cutoff, pq.Array(&days), &dealing.Timezone)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("fund not found")
+			return nil, isaerrors.ErrFundNotFound
 		}
-		return nil, fmt.Errorf("failed to get fund: %w", err)
+		return nil, fmt.Errorf("failed to get fund dealing configuration: %w", err)
 	}
 
-	return &fund, nil
+	for _, d := range days {
+		dealing.DealingDays = append(dealing.DealingDays, int(d))
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT TO_CHAR(holiday_date, 'YYYY-MM-DD'), COALESCE(description, '')
+        FROM fund_dealing_holidays
+        WHERE fund_id = $1
+        ORDER BY holiday_date
+    `, fundID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dealing holidays: %w", err)
+	}
+	defer rows.Close()
+
+	dealing.Holidays = []models.DealingHoliday{}
+	for rows.Next() {
+		var holiday models.DealingHoliday
+		if err := rows.Scan(&holiday.Date, &holiday.Description); err != nil {
+			return nil, fmt.Errorf("failed to scan dealing holiday: %w", err)
+		}
+		dealing.Holidays = append(dealing.Holidays, holiday)
+	}
+
+	return &dealing, nil
+}
+
+// Note: The holiday calendar is replaced as a whole alongside the dealing times
+func (r *Repository) updateFundDealing(ctx context.Context, fundID string, dealing *models.FundDealing) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+	UPDATE funds
+	SET valuation_time = $2::time, dealing_cutoff = $3::time, dealing_days = $4, dealing_timezone = $5
+	WHERE id = $1
+`, fundID, dealing.ValuationTime, dealing.Cutoff, pq.Array(dealing.DealingDays), dealing.Timezone)
+	if err != nil {
+		return fmt.Errorf("failed to update fund dealing configuration: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return isaerrors.ErrFundNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM fund_dealing_holidays WHERE fund_id = $1", fundID); err != nil {
+		return fmt.Errorf("failed to clear dealing holidays: %w", err)
+	}
+
+	for _, holiday := range dealing.Holidays {
+		_, err := tx.ExecContext(ctx, `
+		INSERT INTO fund_dealing_holidays (fund_id, holiday_date, description)
+		VALUES ($1, $2::date, NULLIF($3, ''))
+	`, fundID, holiday.Date, holiday.Description)
+		if err != nil {
+			if isaerrors.IsUniqueViolation(err) {
+				return isaerrors.Validation("duplicate_dealing_holiday", "holiday dates must be unique").Wrap(err)
+			}
+			return fmt.Errorf("failed to insert dealing holiday: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// Note: Loading a price also prices every live order waiting on that dealing date.
+// Held, failed and cancelled orders are skipped. Held orders are priced if they are released
+func (r *Repository) loadFundPrice(ctx context.Context, price *models.FundPrice) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+	INSERT INTO fund_prices (fund_id, price_date, price)
+	VALUES ($1, $2::date, $3)
+`, price.FundID, price.PriceDate, price.Price)
+	if err != nil {
+		if isaerrors.IsUniqueViolation(err) {
+			return isaerrors.ErrFundPriceExists.Wrap(err)
+		}
+		if isaerrors.IsForeignKeyViolation(err) {
+			return isaerrors.ErrFundNotFound.Wrap(err)
+		}
+		return fmt.Errorf("failed to insert fund price: %w", err)
+	}
+
+	res, err := tx.ExecContext(ctx, `
+	UPDATE investments
+	SET unit_price = $3, units = ROUND(amount / $3, 6), priced_at = CURRENT_TIMESTAMP
+	WHERE fund_id = $1 AND dealing_date = $2::date AND unit_price IS NULL
+		AND status NOT IN ('held', 'failed', 'cancelled')
+`, price.FundID, price.PriceDate, price.Price)
+	if err != nil {
+		return fmt.Errorf("failed to price investments: %w", err)
+	}
+	priced, _ := res.RowsAffected()
+	price.PricedInvestments = int(priced)
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
 }
@@ -6,11 +6,15 @@ import (
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/models"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+var fundRowColumns = []string{"id", "name", "description", "risk_id", "risk_name", "risk_description", "isin", "ocf", "currency", "asset_class", "ethical", "status"}
+
 func TestRepository_ListFunds(t *testing.T) {
 	// Setup
 	db, mock, err := sqlmock.New()
@@ -27,28 +31,69 @@ func TestRepository_ListFunds(t *testing.T) {
 			WillReturnRows(countRows)
 
 		// Mock data query
-		rows := sqlmock.NewRows([]string{"id", "name", "description"}).
-			AddRow("1", "Fund A", "Description A").
-			AddRow("2", "Fund B", "Description B")
+		rows := sqlmock.NewRows(fundRowColumns).
+			AddRow("1", "Fund A", "Description A", 1, "Low", "Low risk", "GB00CUSH0016", 0.25, "GBP", "mixed", false, "active").
+			AddRow("2", "Fund B", "Description B", nil, nil, nil, "GB00CUSH0024", 0.22, "GBP", "mixed", false, "active")
 
-		mock.ExpectQuery("SELECT id, name, description.*FROM funds.*ORDER BY name.*LIMIT.*OFFSET.*").
+		mock.ExpectQuery("SELECT f.id, f.name.*FROM funds f.*WHERE f.status = 'active'.*ORDER BY f.name.*LIMIT.*OFFSET.*").
 			WithArgs(2, 0). // pageSize=2, offset=0
 			WillReturnRows(rows)
 
-		funds, total, err := repo.listFunds(ctx, 1, 2)
+		funds, total, err := repo.listFunds(ctx, &models.FundFilter{}, 1, 2)
 
 		assert.NoError(t, err)
 		assert.Equal(t, 3, total)
 		assert.Len(t, funds, 2)
 		assert.Equal(t, "Fund A", funds[0].Name)
 		assert.Equal(t, "Fund B", funds[1].Name)
+		assert.Equal(t, "Low", funds[0].RiskLevel.Name)
+		assert.Nil(t, funds[1].RiskLevel)
+	})
+
+	t.Run("filter by risk level", func(t *testing.T) {
+		mock.ExpectQuery("SELECT COUNT.*FROM funds f.*LEFT JOIN risk_levels.*WHERE f.status = 'active' AND LOWER\\(rl.name\\) = LOWER\\(\\$1\\)").
+			WithArgs("medium").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+		mock.ExpectQuery("SELECT f.id, f.name.*FROM funds f.*LOWER\\(rl.name\\) = LOWER\\(\\$1\\).*LIMIT \\$2 OFFSET \\$3").
+			WithArgs("medium", 10, 0).
+			WillReturnRows(sqlmock.NewRows(fundRowColumns).
+				AddRow("2", "Fund B", "Description B", 2, "Medium", "Medium risk", "GB00CUSH0024", 0.22, "GBP", "mixed", false, "active"))
+
+		funds, total, err := repo.listFunds(ctx, &models.FundFilter{RiskLevelName: "medium"}, 1, 10)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, total)
+		assert.Len(t, funds, 1)
+		assert.Equal(t, 2, funds[0].RiskLevel.ID)
+	})
+
+	t.Run("search with filters and sort", func(t *testing.T) {
+		maxOCF := 0.3
+		ethical := true
+		filter := &models.FundFilter{Search: "bond", MaxOCF: &maxOCF, Ethical: &ethical, SortBy: "ocf", SortOrder: "desc"}
+
+		mock.ExpectQuery("SELECT COUNT.*WHERE f.status = 'active' AND f.search_vector @@ websearch_to_tsquery\\('english', \\$1\\) AND f.ocf <= \\$2 AND f.ethical = \\$3").
+			WithArgs("bond", 0.3, true).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+		mock.ExpectQuery("SELECT f.id, f.name.*ORDER BY f.ocf DESC NULLS LAST, f.id.*LIMIT \\$4 OFFSET \\$5").
+			WithArgs("bond", 0.3, true, 10, 0).
+			WillReturnRows(sqlmock.NewRows(fundRowColumns).
+				AddRow("1", "Ethical Bond Fund", "Description A", 1, "Low", "Low risk", "GB00CUSH0016", 0.25, "GBP", "fixed_income", true, "active"))
+
+		funds, total, err := repo.listFunds(ctx, filter, 1, 10)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, total)
+		assert.True(t, funds[0].Ethical)
 	})
 
 	t.Run("database error on count", func(t *testing.T) {
 		mock.ExpectQuery("SELECT COUNT.*FROM funds").
 			WillReturnError(sql.ErrConnDone)
 
-		funds, total, err := repo.listFunds(ctx, 1, 10)
+		funds, total, err := repo.listFunds(ctx, &models.FundFilter{}, 1, 10)
 
 		assert.Error(t, err)
 		assert.Nil(t, funds)
@@ -60,10 +105,10 @@ func TestRepository_ListFunds(t *testing.T) {
 		mock.ExpectQuery("SELECT COUNT.*FROM funds").
 			WillReturnRows(countRows)
 
-		mock.ExpectQuery("SELECT id, name, description.*FROM funds").
+		mock.ExpectQuery("SELECT f.id, f.name.*FROM funds").
 			WillReturnError(sql.ErrConnDone)
 
-		funds, total, err := repo.listFunds(ctx, 1, 10)
+		funds, total, err := repo.listFunds(ctx, &models.FundFilter{}, 1, 10)
 
 		assert.Error(t, err)
 		assert.Nil(t, funds)
@@ -81,10 +126,10 @@ func TestRepository_GetFundByID(t *testing.T) {
 	ctx := context.Background()
 
 	t.Run("successful fund retrieval", func(t *testing.T) {
-		rows := sqlmock.NewRows([]string{"id", "name", "description", "_id"}).
-			AddRow("1", "Fund A", "Description A", "Low")
+		rows := sqlmock.NewRows(fundRowColumns).
+			AddRow("1", "Fund A", "Description A", 1, "Low", "Low risk", "GB00CUSH0016", 0.25, "GBP", "mixed", false, "active")
 
-		mock.ExpectQuery("SELECT id, name, description, risk_level_id.*FROM funds.*WHERE id = .*").
+		mock.ExpectQuery("SELECT f.id, f.name.*FROM funds f.*LEFT JOIN risk_levels rl.*WHERE f.id = .*").
 			WithArgs("1").
 			WillReturnRows(rows)
 
@@ -95,11 +140,13 @@ func TestRepository_GetFundByID(t *testing.T) {
 		assert.Equal(t, "1", fund.ID)
 		assert.Equal(t, "Fund A", fund.Name)
 		assert.Equal(t, "Description A", fund.Description)
-		assert.Equal(t, "Low", fund.RiskLevel)
+		assert.Equal(t, &models.RiskLevel{ID: 1, Name: "Low", Description: "Low risk"}, fund.RiskLevel)
+		assert.Equal(t, "GB00CUSH0016", fund.ISIN)
+		assert.Equal(t, models.FundStatusActive, fund.Status)
 	})
 
 	t.Run("fund not found", func(t *testing.T) {
-		mock.ExpectQuery("SELECT id, name, description, risk_level_id.*FROM funds.*WHERE id = .*").
+		mock.ExpectQuery("SELECT f.id, f.name.*FROM funds f.*LEFT JOIN risk_levels rl.*WHERE f.id = .*").
 			WithArgs("999").
 			WillReturnError(sql.ErrNoRows)
 
@@ -111,7 +158,7 @@ func TestRepository_GetFundByID(t *testing.T) {
 	})
 
 	t.Run("database error", func(t *testing.T) {
-		mock.ExpectQuery("SELECT id, name, description, risk_level_id.*FROM funds.*WHERE id = .*").
+		mock.ExpectQuery("SELECT f.id, f.name.*FROM funds f.*LEFT JOIN risk_levels rl.*WHERE f.id = .*").
 			WithArgs("1").
 			WillReturnError(sql.ErrConnDone)
 
@@ -122,3 +169,136 @@ func TestRepository_GetFundByID(t *testing.T) {
 		assert.Contains(t, err.Error(), "failed to get fund")
 	})
 }
+
+func TestRepository_ListRiskLevels(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	mock.ExpectQuery("SELECT id, name, .* FROM risk_levels ORDER BY id").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "description"}).
+			AddRow(1, "Low", "Low risk").
+			AddRow(2, "Medium", "Medium risk").
+			AddRow(3, "High", "High risk"))
+
+	riskLevels, err := repo.listRiskLevels(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, riskLevels, 3)
+	assert.Equal(t, "Medium", riskLevels[1].Name)
+}
+
+func TestRepository_GetFundDealing(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	mock.ExpectQuery("SELECT TO_CHAR\\(valuation_time.*FROM funds.*WHERE id = .*").
+		WithArgs("1").
+		WillReturnRows(sqlmock.NewRows([]string{"valuation_time", "dealing_cutoff", "dealing_days", "dealing_timezone"}).
+			AddRow("12:00", "11:30", "{1,2,3,4,5}", "Europe/London"))
+	mock.ExpectQuery("SELECT TO_CHAR\\(holiday_date.*FROM fund_dealing_holidays").
+		WithArgs("1").
+		WillReturnRows(sqlmock.NewRows([]string{"holiday_date", "description"}).
+			AddRow("2026-12-25", "Christmas Day"))
+
+	dealing, err := repo.getFundDealing(ctx, "1")
+	assert.NoError(t, err)
+	assert.Equal(t, "11:30", dealing.Cutoff)
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, dealing.DealingDays)
+	assert.Len(t, dealing.Holidays, 1)
+	assert.Equal(t, "2026-12-25", dealing.Holidays[0].Date)
+}
+
+func TestRepository_LoadFundPrice(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	t.Run("prices waiting orders", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT INTO fund_prices").
+			WithArgs("1", "2026-01-05", 1.25).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE investments SET unit_price").
+			WithArgs("1", "2026-01-05", 1.25).
+			WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectCommit()
+
+		price := &models.FundPrice{FundID: "1", PriceDate: "2026-01-05", Price: 1.25}
+		err := repo.loadFundPrice(ctx, price)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, price.PricedInvestments)
+	})
+
+	t.Run("price already loaded", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT INTO fund_prices").
+			WithArgs("1", "2026-01-05", 1.30).
+			WillReturnError(&pq.Error{Code: "23505"})
+		mock.ExpectRollback()
+
+		err := repo.loadFundPrice(ctx, &models.FundPrice{FundID: "1", PriceDate: "2026-01-05", Price: 1.30})
+		assert.ErrorIs(t, err, isaerrors.ErrFundPriceExists)
+	})
+}
+
+func TestRepository_RetireFund(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	t.Run("active fund is retired", func(t *testing.T) {
+		mock.ExpectExec("UPDATE funds SET status = 'retired'").
+			WithArgs("1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		assert.NoError(t, repo.retireFund(ctx, "1"))
+	})
+
+	t.Run("already retired", func(t *testing.T) {
+		mock.ExpectExec("UPDATE funds SET status = 'retired'").
+			WithArgs("1").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		assert.ErrorIs(t, repo.retireFund(ctx, "1"), isaerrors.ErrFundRetired)
+	})
+}
+
+func TestBuildFundOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   *models.FundFilter
+		expected string
+	}{
+		{"name by default", &models.FundFilter{}, "f.name ASC"},
+		{"search defaults to relevance", &models.FundFilter{Search: "bond"}, "ts_rank(f.search_vector, websearch_to_tsquery('english', $1)) DESC"},
+		{"relevance without order", &models.FundFilter{Search: "bond", SortBy: "relevance"}, "ts_rank(f.search_vector, websearch_to_tsquery('english', $1)) DESC"},
+		{"relevance reversed", &models.FundFilter{Search: "bond", SortBy: "relevance", SortOrder: "asc"}, "ts_rank(f.search_vector, websearch_to_tsquery('english', $1)) ASC"},
+		{"ocf descending", &models.FundFilter{SortBy: "ocf", SortOrder: "desc"}, "f.ocf DESC"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, "\n\tORDER BY "+test.expected+" NULLS LAST, f.id", buildFundOrder(test.filter))
+		})
+	}
+}
+
+func TestValidateISIN(t *testing.T) {
+	assert.NoError(t, validateISIN("US0378331005"))
+	assert.NoError(t, validateISIN("GB00CUSH0016"))
+	assert.Error(t, validateISIN("US0378331006"))
+	assert.Error(t, validateISIN("US03783310"))
+}
@@ -3,7 +3,9 @@ package fund
 import (
 	"context"
 	"fmt"
+	"time"
 
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
 	mw "github.com/stcol316/cushon-isa/internal/middleware"
 	"github.com/stcol316/cushon-isa/internal/models"
 )
@@ -16,9 +18,12 @@ func NewService(repo *Repository) *Service {
 	return &Service{repo: repo}
 }
 
-func (s *Service) listFunds(ctx context.Context, page, pageSize int) (*mw.PaginatedResult, error) {
+func (s *Service) listFunds(ctx context.Context, filter *models.FundFilter, page, pageSize int) (*mw.PaginatedResult, error) {
+	if err := validateFundFilter(filter); err != nil {
+		return nil, err
+	}
 
-	funds, total, err := s.repo.listFunds(ctx, page, pageSize)
+	funds, total, err := s.repo.listFunds(ctx, filter, page, pageSize)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list funds: %w", err)
 	}
@@ -31,8 +36,8 @@ func (s *Service) listFunds(ctx context.Context, page, pageSize int) (*mw.Pagina
 	}
 	result.Pagination.CurrentPage = page
 	result.Pagination.PageSize = pageSize
-	result.Pagination.TotalItems = total
-	result.Pagination.TotalPages = totalPages
+	result.Pagination.TotalItems = &total
+	result.Pagination.TotalPages = &totalPages
 	result.Pagination.HasNext = page < totalPages
 	result.Pagination.HasPrevious = page > 1
 
@@ -40,5 +45,116 @@ func (s *Service) listFunds(ctx context.Context, page, pageSize int) (*mw.Pagina
 }
 
 func (s *Service) getFundByID(ctx context.Context, id string) (*models.Fund, error) {
-	return s.repo.getFundByID(ctx, id)
+	fund, err := s.repo.getFundByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	fund.Dealing, err = s.repo.getFundDealing(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return fund, nil
+}
+
+func (s *Service) listRiskLevels(ctx context.Context) ([]models.RiskLevel, error) {
+	return s.repo.listRiskLevels(ctx)
+}
+
+func (s *Service) getRiskLevelByID(ctx context.Context, id int) (*models.RiskLevel, error) {
+	return s.repo.getRiskLevelByID(ctx, id)
+}
+
+func (s *Service) createFund(ctx context.Context, req *models.CreateFundRequest) (*models.Fund, error) {
+	if err := validateCreateFund(req); err != nil {
+		return nil, err
+	}
+
+	id, err := s.repo.createFund(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.getFundByID(ctx, id)
+}
+
+func (s *Service) updateFund(ctx context.Context, id string, req *models.UpdateFundRequest) (*models.Fund, error) {
+	if err := validateUpdateFund(req); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.updateFund(ctx, id, req); err != nil {
+		return nil, err
+	}
+
+	return s.getFundByID(ctx, id)
+}
+
+func (s *Service) retireFund(ctx context.Context, id string) (*models.Fund, error) {
+	// Note: Fetch first so a missing fund is reported as not found rather than already retired
+	if _, err := s.repo.getFundByID(ctx, id); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.retireFund(ctx, id); err != nil {
+		return nil, err
+	}
+
+	return s.getFundByID(ctx, id)
+}
+
+// GetOpenFund returns the fund if it exists and is active.
+// Exported for the investment service which checks it before accepting deposits
+func (s *Service) GetOpenFund(ctx context.Context, fundID string) (*models.Fund, error) {
+	fund, err := s.repo.getFundByID(ctx, fundID)
+	if err != nil {
+		return nil, err
+	}
+	if fund.Status != models.FundStatusActive {
+		return nil, isaerrors.ErrFundRetired
+	}
+	return fund, nil
+}
+
+func (s *Service) updateFundDealing(ctx context.Context, fundID string, dealing *models.FundDealing) (*models.FundDealing, error) {
+	if err := validateDealing(dealing); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.updateFundDealing(ctx, fundID, dealing); err != nil {
+		return nil, err
+	}
+
+	return s.repo.getFundDealing(ctx, fundID)
+}
+
+func (s *Service) loadFundPrice(ctx context.Context, fundID string, req *models.LoadFundPriceRequest) (*models.FundPrice, error) {
+	if req.Price <= 0 {
+		return nil, isaerrors.ErrInvalidFundPrice
+	}
+	if _, err := time.Parse(dateLayout, req.PriceDate); err != nil {
+		return nil, isaerrors.ErrInvalidDealingDate
+	}
+
+	price := &models.FundPrice{
+		FundID:    fundID,
+		PriceDate: req.PriceDate,
+		Price:     req.Price,
+	}
+	if err := s.repo.loadFundPrice(ctx, price); err != nil {
+		return nil, err
+	}
+
+	return price, nil
+}
+
+// DealingDate returns the dealing date for an order in the fund placed at placedAt.
+// Exported for the investment service which assigns it on creation
+func (s *Service) DealingDate(ctx context.Context, fundID string, placedAt time.Time) (string, error) {
+	dealing, err := s.repo.getFundDealing(ctx, fundID)
+	if err != nil {
+		return "", err
+	}
+	return dealingDate(dealing, placedAt)
 }
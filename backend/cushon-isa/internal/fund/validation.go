@@ -0,0 +1,180 @@
+package fund
+
+import (
+	"regexp"
+	"strings"
+
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/models"
+)
+
+const (
+	defaultCurrency   = "GBP"
+	defaultAssetClass = models.AssetClassMixed
+)
+
+var assetClasses = map[string]struct{}{
+	models.AssetClassEquity:      {},
+	models.AssetClassFixedIncome: {},
+	models.AssetClassMixed:       {},
+	models.AssetClassProperty:    {},
+	models.AssetClassMoneyMarket: {},
+}
+
+// Note: ISO 6166. Two letter country code, nine character national code and a check digit
+var isinPattern = regexp.MustCompile(`^[A-Z]{2}[A-Z0-9]{9}[0-9]$`)
+
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+func normaliseISIN(isin string) string {
+	return strings.ToUpper(strings.ReplaceAll(isin, " ", ""))
+}
+
+// validateISIN checks the format and the Luhn check digit.
+// Letters are expanded to two digits (A = 10 ... Z = 35) before the Luhn calculation
+func validateISIN(isin string) error {
+	if !isinPattern.MatchString(isin) {
+		return isaerrors.Validation("invalid_isin", "ISIN must be 12 characters: country code, national code and check digit")
+	}
+
+	var digits strings.Builder
+	for _, c := range isin {
+		if c >= 'A' && c <= 'Z' {
+			n := int(c-'A') + 10
+			digits.WriteByte(byte('0' + n/10))
+			digits.WriteByte(byte('0' + n%10))
+			continue
+		}
+		digits.WriteRune(c)
+	}
+
+	expanded := digits.String()
+	sum := 0
+	double := false
+	for i := len(expanded) - 1; i >= 0; i-- {
+		d := int(expanded[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+
+	if sum%10 != 0 {
+		return isaerrors.Validation("invalid_isin", "ISIN check digit is incorrect")
+	}
+	return nil
+}
+
+func validateOCF(ocf float64) error {
+	if ocf < 0 || ocf >= 100 {
+		return isaerrors.Validation("invalid_ocf", "ocf must be a percentage between 0 and 100")
+	}
+	return nil
+}
+
+func validateCurrency(currency string) error {
+	if !currencyPattern.MatchString(currency) {
+		return isaerrors.Validation("invalid_currency", "currency must be a three letter ISO 4217 code")
+	}
+	return nil
+}
+
+func validateAssetClass(assetClass string) error {
+	if _, ok := assetClasses[assetClass]; !ok {
+		return isaerrors.Validation("invalid_asset_class", "assetClass must be one of equity, fixed_income, mixed, property or money_market")
+	}
+	return nil
+}
+
+func validateFundFilter(filter *models.FundFilter) error {
+	if filter.MinOCF != nil && filter.MaxOCF != nil && *filter.MinOCF > *filter.MaxOCF {
+		return isaerrors.Validation("invalid_ocf_range", "min_ocf cannot be greater than max_ocf")
+	}
+	if filter.AssetClass != "" {
+		if err := validateAssetClass(filter.AssetClass); err != nil {
+			return err
+		}
+	}
+	if filter.SortBy != "" {
+		if _, ok := fundSortColumns[filter.SortBy]; !ok {
+			return isaerrors.Validation("invalid_sort", "sort must be one of name, ocf, risk_level, created_at or relevance")
+		}
+		if filter.SortBy == "relevance" && filter.Search == "" {
+			return isaerrors.Validation("invalid_sort", "relevance sort requires a search term")
+		}
+	}
+	return nil
+}
+
+// validateCreateFund normalises the request in place and checks every field
+func validateCreateFund(req *models.CreateFundRequest) error {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return isaerrors.Validation("fund_name_required", "name is required")
+	}
+	if req.RiskLevelID == 0 {
+		return isaerrors.Validation("risk_level_required", "riskLevelId is required")
+	}
+
+	req.ISIN = normaliseISIN(req.ISIN)
+	if err := validateISIN(req.ISIN); err != nil {
+		return err
+	}
+
+	if err := validateOCF(req.OCF); err != nil {
+		return err
+	}
+
+	if req.AssetClass == "" {
+		req.AssetClass = defaultAssetClass
+	}
+	if err := validateAssetClass(req.AssetClass); err != nil {
+		return err
+	}
+
+	if req.Currency == "" {
+		req.Currency = defaultCurrency
+	}
+	req.Currency = strings.ToUpper(req.Currency)
+	return validateCurrency(req.Currency)
+}
+
+// validateUpdateFund checks only the fields present in the request
+func validateUpdateFund(req *models.UpdateFundRequest) error {
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return isaerrors.Validation("fund_name_required", "name cannot be empty")
+		}
+		req.Name = &name
+	}
+	if req.ISIN != nil {
+		isin := normaliseISIN(*req.ISIN)
+		if err := validateISIN(isin); err != nil {
+			return err
+		}
+		req.ISIN = &isin
+	}
+	if req.OCF != nil {
+		if err := validateOCF(*req.OCF); err != nil {
+			return err
+		}
+	}
+	if req.Currency != nil {
+		currency := strings.ToUpper(*req.Currency)
+		if err := validateCurrency(currency); err != nil {
+			return err
+		}
+		req.Currency = &currency
+	}
+	if req.AssetClass != nil {
+		if err := validateAssetClass(*req.AssetClass); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package investment
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/models"
+)
+
+// Note: UK tax years run from 6 April to 5 April, London time
+var taxYearLocation = loadTaxYearLocation()
+
+func loadTaxYearLocation() *time.Location {
+	loc, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// TaxYear returns the start and end of the tax year containing t.
+// Exported for the transfer service which reports subscriptions by tax year
+func TaxYear(t time.Time) (time.Time, time.Time) {
+	t = t.In(taxYearLocation)
+	year := t.Year()
+	start := time.Date(year, time.April, 6, 0, 0, 0, 0, taxYearLocation)
+	if t.Before(start) {
+		start = start.AddDate(-1, 0, 0)
+	}
+	return start, start.AddDate(1, 0, 0)
+}
+
+// TaxYearName formats a tax year the way HMRC does, e.g. 2025/26
+func TaxYearName(start time.Time) string {
+	return fmt.Sprintf("%d/%02d", start.Year(), (start.Year()+1)%100)
+}
+
+// Allowances are the annual subscription limits for each ISA product
+type Allowances struct {
+	Annual float64
+	Junior float64
+	// Note: Lifetime ISA contributions also count towards the annual allowance
+	Lifetime float64
+}
+
+// Note: The ISA is not flexible, so withdrawals do not give back allowance.
+// Junior ISAs have their own lower limit
+func (s *Service) getAllowance(ctx context.Context, customerID string) (*models.ISAAllowance, error) {
+	return s.allowance(ctx, s.repo, customerID)
+}
+
+func (s *Service) allowance(ctx context.Context, limits limitReader, customerID string) (*models.ISAAllowance, error) {
+	product, convertedAt, err := s.repo.getProduct(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := s.allowances.Annual
+	if product == models.ISAProductJunior {
+		limit = s.allowances.Junior
+	}
+
+	// Note: In the tax year a Junior ISA converts, subscriptions made to it before the 18th
+	// birthday used the junior limit and do not count against the adult allowance
+	start, end := TaxYear(s.now())
+	from := start
+	if convertedAt != nil && convertedAt.After(start) {
+		from = *convertedAt
+	}
+
+	allowance, lifetime, err := limits.getSubscriptions(ctx, customerID, from, end)
+	if err != nil {
+		return nil, err
+	}
+
+	allowance.CustomerID = customerID
+	allowance.TaxYear = TaxYearName(start)
+	allowance.Product = product
+	allowance.Allowance = limit
+	allowance.Subscribed = allowance.Deposits + allowance.TransferredInCurrentYear
+	allowance.Remaining = remaining(limit, allowance.Subscribed)
+
+	if product != models.ISAProductJunior {
+		eligibility, err := s.repo.getLifetimeISAEligibility(ctx, customerID)
+		if err != nil {
+			return nil, err
+		}
+		if eligibility.Open || lifetime > 0 {
+			allowance.LifetimeISA = &models.LifetimeISAAllowance{
+				Allowance:  s.allowances.Lifetime,
+				Subscribed: lifetime,
+				// Note: Whichever runs out first, the Lifetime ISA limit or the overall allowance
+				Remaining: math.Min(remaining(s.allowances.Lifetime, lifetime), allowance.Remaining),
+			}
+		}
+	}
+	return allowance, nil
+}
+
+func remaining(limit, subscribed float64) float64 {
+	return math.Max(0, math.Round((limit-subscribed)*100)/100)
+}
+
+// Note: Every deposit must fit within the overall allowance, Lifetime ISA contributions
+// must also fit within the Lifetime ISA limit
+func (s *Service) checkAllowance(ctx context.Context, limits limitReader, customerID, product string, amount float64) error {
+	allowance, err := s.allowance(ctx, limits, customerID)
+	if err != nil {
+		return err
+	}
+	if amount > allowance.Remaining {
+		return isaerrors.ErrAllowanceExceeded
+	}
+	if product == models.ISAProductLifetime && (allowance.LifetimeISA == nil || amount > allowance.LifetimeISA.Remaining) {
+		return isaerrors.ErrLifetimeISAAllowanceExceeded
+	}
+	return nil
+}
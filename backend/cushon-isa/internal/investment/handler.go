@@ -1,15 +1,18 @@
 package investment
 
 import (
-	"database/sql"
+	"encoding/csv"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
 	mw "github.com/stcol316/cushon-isa/internal/middleware"
 	"github.com/stcol316/cushon-isa/internal/models"
 	"github.com/stcol316/cushon-isa/pkg/helpers"
@@ -17,11 +20,12 @@ import (
 )
 
 type Handler struct {
-	service *Service
+	service    *Service
+	settlement *SettlementScheduler
 }
 
-func NewHandler(service *Service) *Handler {
-	return &Handler{service: service}
+func NewHandler(service *Service, settlement *SettlementScheduler) *Handler {
+	return &Handler{service: service, settlement: settlement}
 }
 
 func (h *Handler) CreateInvestmentHandler(w http.ResponseWriter, r *http.Request) {
@@ -32,13 +36,25 @@ func (h *Handler) CreateInvestmentHandler(w http.ResponseWriter, r *http.Request
 
 	req := new(models.CreateInvestmentRequest)
 	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
-		h.handleInvestmentError(w, err)
+		helper.RespondWithProblem(w, r, isaerrors.ErrInvalidRequestBody.Wrap(err))
 		return
 	}
+	if req.AccountID != "" {
+		if _, err := uuid.Parse(req.AccountID); err != nil {
+			helper.RespondWithProblem(w, r, isaerrors.Validation("invalid_account_id", "accountId must be a valid account ID"))
+			return
+		}
+	}
 
 	investment, err := h.service.createInvestment(r.Context(), req)
 	if err != nil {
-		h.handleInvestmentError(w, err)
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	// Note: Held investments have been accepted but are awaiting compliance review
+	if investment.Status == models.InvestmentStatusHeld {
+		helpers.RespondWithJSON(w, http.StatusAccepted, investment)
 		return
 	}
 
@@ -59,7 +75,7 @@ func (h *Handler) GetInvestmentByIDHandler(w http.ResponseWriter, r *http.Reques
 
 	investment, err := h.service.getInvestmentByID(r.Context(), id)
 	if err != nil {
-		h.handleInvestmentError(w, err)
+		helper.RespondWithProblem(w, r, err)
 		return
 	}
 
@@ -86,16 +102,162 @@ func (h *Handler) ListCustomerInvestmentsHandler(w http.ResponseWriter, r *http.
 		return
 	}
 
-	fmt.Printf("Pagination Params: Page=%d, PageSize=%d\n", params.Page, params.PageSize)
+	fmt.Printf("Pagination Params: Page=%d, PageSize=%d, CursorMode=%t\n", params.Page, params.PageSize, params.CursorMode)
+
+	filter, err := parseInvestmentFilter(r)
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	// Note: Content negotiation. CSV exports the whole filtered history and ignores pagination
+	if strings.Contains(r.Header.Get("Accept"), "text/csv") {
+		h.exportInvestments(w, r, id, filter)
+		return
+	}
 
-	result, err := h.service.listInvestmentsByCustomerID(r.Context(), id, params.Page, params.PageSize)
+	result, err := h.service.listInvestmentsByCustomerID(r.Context(), id, filter, params)
 	if err != nil {
-		h.handleInvestmentError(w, err)
+		helper.RespondWithProblem(w, r, err)
 		return
 	}
 	helper.RespondWithJSON(w, http.StatusOK, result)
 }
 
+// parseInvestmentFilter reads the history filters from the query string, e.g.
+// ?from=2025-04-06&to=2026-04-05&account_id=...&fund_id=...&type=deposit&status=settled
+func parseInvestmentFilter(r *http.Request) (*models.InvestmentFilter, error) {
+	query := r.URL.Query()
+	filter := &models.InvestmentFilter{
+		Type:   query.Get("type"),
+		Status: query.Get("status"),
+	}
+
+	if accountID := query.Get("account_id"); accountID != "" {
+		if _, err := uuid.Parse(accountID); err != nil {
+			return nil, isaerrors.Validation("invalid_account_filter", "account_id must be a valid account ID")
+		}
+		filter.AccountID = accountID
+	}
+
+	if fundID := query.Get("fund_id"); fundID != "" {
+		if _, err := uuid.Parse(fundID); err != nil {
+			return nil, isaerrors.Validation("invalid_fund_filter", "fund_id must be a valid fund ID")
+		}
+		filter.FundID = fundID
+	}
+
+	var err error
+	if filter.From, err = parseOptionalDate(query.Get("from")); err != nil {
+		return nil, isaerrors.Validation("invalid_date_filter", "from must be in YYYY-MM-DD format")
+	}
+	if filter.To, err = parseOptionalDate(query.Get("to")); err != nil {
+		return nil, isaerrors.Validation("invalid_date_filter", "to must be in YYYY-MM-DD format")
+	}
+
+	return filter, nil
+}
+
+func parseOptionalDate(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := time.Parse(dateLayout, value)
+	if err != nil {
+		return nil, err
+	}
+	return &parsed, nil
+}
+
+const (
+	dateLayout = "2006-01-02"
+	// Note: Rows are flushed to the client in batches while exporting
+	csvFlushEvery = 100
+)
+
+var csvHeader = []string{"id", "created_at", "fund_id", "type", "status", "amount", "dealing_date", "unit_price", "units", "account_id", "product"}
+
+// Note: Streams the export row by row. Headers are only written once the first row arrives
+// so a failed query can still be reported as a problem response. After that the status is
+// already sent and errors can only be logged
+func (h *Handler) exportInvestments(w http.ResponseWriter, r *http.Request, customerID string, filter *models.InvestmentFilter) {
+	writer := csv.NewWriter(w)
+	flusher, _ := w.(http.Flusher)
+	started := false
+	rows := 0
+
+	start := func() error {
+		started = true
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="investments-%s.csv"`, customerID))
+		w.WriteHeader(http.StatusOK)
+		return writer.Write(csvHeader)
+	}
+
+	err := h.service.exportInvestmentsByCustomerID(r.Context(), customerID, filter, func(investment *models.Investment) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		if err := writer.Write(investmentRecord(investment)); err != nil {
+			return err
+		}
+
+		rows++
+		if rows%csvFlushEvery == 0 {
+			writer.Flush()
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		return writer.Error()
+	})
+	if err != nil {
+		if !started {
+			helper.RespondWithProblem(w, r, err)
+			return
+		}
+		log.Printf("Failed to export investments for customer %s: %v", customerID, err)
+		return
+	}
+
+	// Note: An empty history still returns the header row
+	if !started {
+		if err := start(); err != nil {
+			log.Printf("Failed to export investments for customer %s: %v", customerID, err)
+			return
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		log.Printf("Failed to export investments for customer %s: %v", customerID, err)
+	}
+}
+
+func investmentRecord(investment *models.Investment) []string {
+	return []string{
+		investment.ID,
+		investment.CreatedAt.UTC().Format(time.RFC3339),
+		investment.FundID,
+		investment.Type,
+		investment.Status,
+		strconv.FormatFloat(investment.Amount, 'f', 2, 64),
+		investment.DealingDate,
+		formatOptionalFloat(investment.UnitPrice),
+		formatOptionalFloat(investment.Units),
+		investment.AccountID,
+		investment.Product,
+	}
+}
+
+func formatOptionalFloat(value *float64) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*value, 'f', -1, 64)
+}
+
 func (h *Handler) GetCustomerFundTotalHandler(w http.ResponseWriter, r *http.Request) {
 	customer_id := chi.URLParam(r, "customerId")
 	fund_id := chi.URLParam(r, "fundId")
@@ -116,18 +278,82 @@ func (h *Handler) GetCustomerFundTotalHandler(w http.ResponseWriter, r *http.Req
 
 	investment, err := h.service.getCustomerFundTotal(r.Context(), customer_id, fund_id)
 	if err != nil {
-		h.handleInvestmentError(w, err)
+		helper.RespondWithProblem(w, r, err)
 		return
 	}
 
 	helper.RespondWithJSON(w, http.StatusOK, investment)
 }
 
-func (h *Handler) handleInvestmentError(w http.ResponseWriter, err error) {
-	switch {
-	case errors.Is(err, sql.ErrNoRows):
-		helper.RespondWithError(w, http.StatusNotFound, "no investments found")
-	default:
-		helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+func (h *Handler) GetAccountFundTotalHandler(w http.ResponseWriter, r *http.Request) {
+	accountID := chi.URLParam(r, "accountId")
+	fundID := chi.URLParam(r, "fundId")
+	if _, err := uuid.Parse(accountID); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "invalid account ID format")
+		return
+	}
+
+	if _, err := uuid.Parse(fundID); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "invalid fund ID format")
+		return
+	}
+
+	summary, err := h.service.getAccountFundTotal(r.Context(), accountID, fundID)
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
 	}
+
+	helper.RespondWithJSON(w, http.StatusOK, summary)
+}
+
+// Note: Subscriptions against the annual ISA allowance for the current tax year
+func (h *Handler) GetAllowanceHandler(w http.ResponseWriter, r *http.Request) {
+	customerID := chi.URLParam(r, "customerId")
+	if _, err := uuid.Parse(customerID); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "invalid customer ID format")
+		return
+	}
+
+	allowance, err := h.service.getAllowance(r.Context(), customerID)
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, allowance)
+}
+
+// Note: Admin only. Moves an investment through its lifecycle manually
+func (h *Handler) TransitionInvestmentHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "invalid investment ID format")
+		return
+	}
+
+	req := new(models.TransitionInvestmentRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		helper.RespondWithProblem(w, r, isaerrors.ErrInvalidRequestBody.Wrap(err))
+		return
+	}
+
+	investment, err := h.service.transitionInvestment(r.Context(), id, req)
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, investment)
+}
+
+// Note: Admin only. Triggers the settlement batch for the most recent cut-off
+func (h *Handler) RunSettlementHandler(w http.ResponseWriter, r *http.Request) {
+	run, err := h.settlement.RunNow(r.Context())
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, run)
 }
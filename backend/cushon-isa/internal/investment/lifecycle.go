@@ -0,0 +1,73 @@
+package investment
+
+import (
+	"github.com/stcol316/cushon-isa/internal/models"
+)
+
+// Note: Investment state machine
+//
+//	held -> pending | cancelled (AML review)
+//	pending -> cash_received | failed | cancelled
+//	cash_received -> units_allocated | failed
+//	units_allocated -> settled | failed
+//
+// Withdrawals do not receive cash from the customer so they move straight from pending to units_allocated
+// when units are sold. settled, failed and cancelled are terminal
+var transitions = map[string]map[string]bool{
+	models.InvestmentStatusHeld: {
+		models.InvestmentStatusPending:   true,
+		models.InvestmentStatusCancelled: true,
+	},
+	models.InvestmentStatusPending: {
+		models.InvestmentStatusCashReceived: true,
+		models.InvestmentStatusFailed:       true,
+		models.InvestmentStatusCancelled:    true,
+	},
+	models.InvestmentStatusCashReceived: {
+		models.InvestmentStatusUnitsAllocated: true,
+		models.InvestmentStatusFailed:         true,
+	},
+	models.InvestmentStatusUnitsAllocated: {
+		models.InvestmentStatusSettled: true,
+		models.InvestmentStatusFailed:  true,
+	},
+}
+
+var statuses = map[string]bool{
+	models.InvestmentStatusHeld:           true,
+	models.InvestmentStatusPending:        true,
+	models.InvestmentStatusCashReceived:   true,
+	models.InvestmentStatusUnitsAllocated: true,
+	models.InvestmentStatusSettled:        true,
+	models.InvestmentStatusFailed:         true,
+	models.InvestmentStatusCancelled:      true,
+}
+
+func canTransition(investmentType, from, to string) bool {
+	if investmentType == models.InvestmentTypeWithdrawal {
+		switch {
+		case from == models.InvestmentStatusPending && to == models.InvestmentStatusUnitsAllocated:
+			return true
+		case to == models.InvestmentStatusCashReceived:
+			return false
+		}
+	}
+	return transitions[from][to]
+}
+
+// settlementPath returns the remaining steps needed to settle an investment from its current status
+func settlementPath(investmentType, from string) []string {
+	var path []string
+	if investmentType == models.InvestmentTypeWithdrawal {
+		path = []string{models.InvestmentStatusUnitsAllocated, models.InvestmentStatusSettled}
+	} else {
+		path = []string{models.InvestmentStatusCashReceived, models.InvestmentStatusUnitsAllocated, models.InvestmentStatusSettled}
+	}
+
+	for i, step := range path {
+		if canTransition(investmentType, from, step) {
+			return path[i:]
+		}
+	}
+	return nil
+}
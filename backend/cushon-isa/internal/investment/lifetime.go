@@ -0,0 +1,30 @@
+package investment
+
+import (
+	"math"
+
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/models"
+)
+
+// Note: The government withdrawal charge on Lifetime ISA withdrawals that do not qualify.
+// It recovers the 25% bonus and a little more besides
+const lifetimeISAWithdrawalChargeRate = 0.25
+
+// Note: Returns nil for qualifying withdrawals, buying a first home, terminal illness or from age 60.
+// The charge comes out of the amount withdrawn, so the customer receives the rest
+func lifetimeISAWithdrawalCharge(amount float64, reason string, over60 bool) (*float64, error) {
+	switch reason {
+	case models.LifetimeISAWithdrawalFirstHome, models.LifetimeISAWithdrawalTerminalIllness:
+		return nil, nil
+	case "":
+	default:
+		return nil, isaerrors.ErrInvalidWithdrawalReason
+	}
+	if over60 {
+		return nil, nil
+	}
+
+	charge := math.Round(amount*lifetimeISAWithdrawalChargeRate*100) / 100
+	return &charge, nil
+}
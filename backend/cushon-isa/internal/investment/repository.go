@@ -5,17 +5,27 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"strings"
+	"time"
 
+	"github.com/lib/pq"
 	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/ledger"
 	"github.com/stcol316/cushon-isa/internal/models"
 )
 
 // TODO: Use interfaces at service level instead of "repo *Repository"
 type InvestmentRepository interface {
-	CreateInvestment(ctx context.Context, investment *models.Investment) error
-	ListInvestmentsByCustomerID(ctx context.Context, id string, page, pageSize int) ([]models.Investment, int, error)
+	CreateInvestment(ctx context.Context, investment *models.Investment, decision *models.AMLDecision, recheck func(context.Context, limitReader) error) error
+	ListInvestmentsByCustomerID(ctx context.Context, id string, filter *models.InvestmentFilter, page, pageSize int) ([]models.Investment, int, error)
 	GetInvestmentByID(ctx context.Context, id string) (*models.Investment, error)
 	GetCustomerFundTotal(ctx context.Context, customerID, fundID string) (*models.InvestmentSummary, error)
+	GetAccountFundTotal(ctx context.Context, accountID, fundID string) (*models.InvestmentSummary, error)
+	GetAccount(ctx context.Context, id string) (*models.Account, error)
+	GetCustomerAccount(ctx context.Context, customerID, product string) (*models.Account, error)
+	GetCustomerEligibility(ctx context.Context, customerID string) (*models.CustomerEligibility, error)
+	GetProduct(ctx context.Context, customerID string) (string, *time.Time, error)
+	GetLifetimeISAEligibility(ctx context.Context, customerID string) (*models.LifetimeISAEligibility, error)
 }
 
 type Repository struct {
@@ -26,7 +36,34 @@ func NewRepository(db *sql.DB) *Repository {
 	return &Repository{db: db}
 }
 
-func (r *Repository) createInvestment(ctx context.Context, investment *models.Investment) error {
+// queryer is satisfied by both *sql.DB and *sql.Tx
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// limitReader reads the figures a new investment is checked against. The repository reads them
+// outside any transaction, createInvestment reads them again inside its own, see txLimits
+type limitReader interface {
+	getSubscriptions(ctx context.Context, customerID string, start, end time.Time) (*models.ISAAllowance, float64, error)
+	getAvailableBalance(ctx context.Context, accountID, fundID string) (float64, error)
+}
+
+// txLimits reads the limits inside the transaction that inserts the investment
+type txLimits struct {
+	tx *sql.Tx
+}
+
+func (l txLimits) getSubscriptions(ctx context.Context, customerID string, start, end time.Time) (*models.ISAAllowance, float64, error) {
+	return querySubscriptions(ctx, l.tx, customerID, start, end)
+}
+
+func (l txLimits) getAvailableBalance(ctx context.Context, accountID, fundID string) (float64, error) {
+	return queryAvailableBalance(ctx, l.tx, accountID, fundID)
+}
+
+// Note: recheck is run inside the insert transaction once the customer's accounts are locked, so
+// concurrent deposits and withdrawals are checked one after another against committed figures
+func (r *Repository) createInvestment(ctx context.Context, investment *models.Investment, decision *models.AMLDecision, recheck func(context.Context, limitReader) error) error {
 	// Note: Limit customers to one fund. Remove to allow multiple
 	var existingFundID *string
 	exerr := r.db.QueryRowContext(ctx, `
@@ -53,20 +90,57 @@ func (r *Repository) createInvestment(ctx context.Context, investment *models.In
 	}
 	defer tx.Rollback()
 
+	if recheck != nil {
+		if err := lockCustomerAccounts(ctx, tx, investment.CustomerID); err != nil {
+			return err
+		}
+		if err := recheck(ctx, txLimits{tx: tx}); err != nil {
+			return err
+		}
+	}
+
 	query := `
-	INSERT INTO investments (customer_id, fund_id, amount)
-	VALUES ($1, $2, $3)
+	INSERT INTO investments (customer_id, fund_id, amount, type, status, dealing_date, risk_acknowledged,
+		product, withdrawal_charge, account_id)
+	VALUES ($1, $2, $3, $4, $5, NULLIF($6, '')::date, $7, $8, $9, $10)
+	RETURNING id, created_at, COALESCE(payment_reference, '')
 `
 
-	_, err := r.db.ExecContext(ctx, query,
+	err := tx.QueryRowContext(ctx, query,
 		investment.CustomerID,
 		investment.FundID,
 		investment.Amount,
-	)
+		investment.Type,
+		investment.Status,
+		investment.DealingDate,
+		investment.RiskAcknowledged,
+		investment.Product,
+		investment.WithdrawalCharge,
+		investment.AccountID,
+	).Scan(&investment.ID, &investment.CreatedAt, &investment.PaymentReference)
 	if err != nil {
+		if isaerrors.IsForeignKeyViolation(err) {
+			return isaerrors.ErrInvalidInvestmentRef.Wrap(err)
+		}
+		if isaerrors.IsCheckViolation(err) {
+			return isaerrors.ErrInvalidAmount.Wrap(err)
+		}
 		return fmt.Errorf("failed to make investment: %w", err)
 	}
 
+	if err := ledger.RecordStatusChange(ctx, tx, investment.ID, "", investment.Status, "created"); err != nil {
+		return err
+	}
+
+	// Note: Any AML rules that fired are recorded against the investment for the review queue
+	if decision != nil {
+		for _, result := range decision.Results {
+			if err := insertAMLAlert(ctx, tx, investment.ID, investment.CustomerID, investment.Amount, result); err != nil {
+				return err
+			}
+		}
+	}
+
 	log.Printf("Attempting to refresh materialized view")
 	// Note: Refresh materialized view
 	_, err = tx.ExecContext(ctx, "REFRESH MATERIALIZED VIEW customer_fund_totals")
@@ -83,87 +157,520 @@ func (r *Repository) createInvestment(ctx context.Context, investment *models.In
 	return nil
 }
 
-func (r *Repository) listInvestmentsByCustomerID(ctx context.Context, id string, page, pageSize int) ([]models.Investment, int, error) {
+// Note: Rejected transactions never become investments but the alerts are kept for audit
+func (r *Repository) recordRejectedTransaction(ctx context.Context, customerID string, amount float64, decision *models.AMLDecision) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, result := range decision.Results {
+		if err := insertAMLAlert(ctx, tx, "", customerID, amount, result); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func insertAMLAlert(ctx context.Context, tx *sql.Tx, investmentID, customerID string, amount float64, result models.AMLRuleResult) error {
+	_, err := tx.ExecContext(ctx, `
+	INSERT INTO aml_alerts (investment_id, customer_id, rule, outcome, reason, amount)
+	VALUES (NULLIF($1, '')::uuid, $2, $3, $4, $5, $6)
+`, investmentID, customerID, result.Rule, result.Outcome, result.Reason, amount)
+	if err != nil {
+		return fmt.Errorf("failed to record aml alert: %w", err)
+	}
+	return nil
+}
+
+// Note: Recent activity used by the AML rules engine.
+// Cancelled investments are ignored but held ones still count towards velocity
+func (r *Repository) getAMLActivity(ctx context.Context, customerID string, velocityDays, rapidWithdrawalDays int) (*models.AMLActivity, error) {
+	var activity models.AMLActivity
+	err := r.db.QueryRowContext(ctx, `
+        SELECT
+            COUNT(*) FILTER (WHERE type = 'deposit' AND created_at >= NOW() - make_interval(days => $2)),
+            COALESCE(SUM(amount) FILTER (WHERE type = 'deposit' AND created_at >= NOW() - make_interval(days => $2)), 0),
+            COALESCE(SUM(amount) FILTER (WHERE type = 'deposit' AND created_at >= NOW() - make_interval(days => $3)), 0)
+        FROM investments
+        WHERE customer_id = $1 AND status NOT IN ('cancelled', 'failed')
+    `, customerID, velocityDays, rapidWithdrawalDays).Scan(
+		&activity.VelocityDepositCount,
+		&activity.VelocityDepositAmount,
+		&activity.RecentDepositAmount,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get aml activity: %w", err)
+	}
+
+	return &activity, nil
+}
+
+// Note: Available balance for withdrawals. Only settled deposits are available
+// but any live withdrawal is reserved so the same money cannot be requested twice. Charges reduce it too
+// Note: The allowance is shared across a customer's ISA and Lifetime ISA, so every account the
+// customer holds is locked. Accounts are locked in a fixed order so two transactions cannot deadlock
+func lockCustomerAccounts(ctx context.Context, tx *sql.Tx, customerID string) error {
+	_, err := tx.ExecContext(ctx, `
+        SELECT id FROM accounts WHERE customer_id = $1 ORDER BY id FOR UPDATE
+    `, customerID)
+	if err != nil {
+		return fmt.Errorf("failed to lock customer accounts: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) getAvailableBalance(ctx context.Context, accountID, fundID string) (float64, error) {
+	return queryAvailableBalance(ctx, r.db, accountID, fundID)
+}
+
+func queryAvailableBalance(ctx context.Context, q queryer, accountID, fundID string) (float64, error) {
+	var balance float64
+	err := q.QueryRowContext(ctx, `
+        SELECT COALESCE(SUM(
+            CASE
+                WHEN type IN ('withdrawal', 'charge', 'transfer_out') AND status NOT IN ('cancelled', 'failed') THEN -amount
+                WHEN type = 'deposit' AND status = 'settled' THEN amount
+                ELSE 0
+            END), 0)
+        FROM investments
+        WHERE account_id = $1 AND fund_id = $2
+    `, accountID, fundID).Scan(&balance)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get available balance: %w", err)
+	}
+
+	return balance, nil
+}
+
+// Note: Subscriptions for the tax year. Deposits that credited a transfer in are excluded because
+// the transfer's current year amount is counted instead, and its previous years' amount not at all.
+// Current year transfers count from when they are requested so the allowance cannot be spent twice.
+// Lifetime ISA bonuses are not subscriptions. Lifetime ISA contributions are also returned on their own
+func (r *Repository) getSubscriptions(ctx context.Context, customerID string, start, end time.Time) (*models.ISAAllowance, float64, error) {
+	return querySubscriptions(ctx, r.db, customerID, start, end)
+}
+
+func querySubscriptions(ctx context.Context, q queryer, customerID string, start, end time.Time) (*models.ISAAllowance, float64, error) {
+	var allowance models.ISAAllowance
+	var lifetime float64
+	err := q.QueryRowContext(ctx, `
+        WITH deposits AS (
+            SELECT i.amount, i.product
+            FROM investments i
+            WHERE i.customer_id = $1 AND i.type = 'deposit' AND i.status NOT IN ('failed', 'cancelled')
+                AND i.created_at >= $2 AND i.created_at < $3
+                AND NOT EXISTS (SELECT 1 FROM isa_transfers t WHERE t.investment_id = i.id)
+                AND NOT EXISTS (SELECT 1 FROM lifetime_isa_bonuses b WHERE b.paid_investment_id = i.id)
+        )
+        SELECT
+            (SELECT COALESCE(SUM(amount), 0) FROM deposits),
+            (SELECT COALESCE(SUM(amount), 0) FROM deposits WHERE product = 'lifetime_isa'),
+            (SELECT COALESCE(SUM(current_year_amount), 0)
+             FROM isa_transfers
+             WHERE customer_id = $1 AND direction = 'in' AND status NOT IN ('rejected', 'cancelled')
+                AND created_at >= $2 AND created_at < $3),
+            (SELECT COALESCE(SUM(previous_years_amount), 0)
+             FROM isa_transfers
+             WHERE customer_id = $1 AND direction = 'in' AND status NOT IN ('rejected', 'cancelled')
+                AND created_at >= $2 AND created_at < $3)
+    `, customerID, start, end).Scan(&allowance.Deposits, &lifetime, &allowance.TransferredInCurrentYear, &allowance.TransferredInPreviousYears)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get subscriptions: %w", err)
+	}
+
+	return &allowance, lifetime, nil
+}
+
+// Note: Ages are worked out in the database against today's date, as for getCustomerEligibility
+func (r *Repository) getLifetimeISAEligibility(ctx context.Context, customerID string) (*models.LifetimeISAEligibility, error) {
+	var eligibility models.LifetimeISAEligibility
+	err := r.db.QueryRowContext(ctx, `
+        SELECT EXISTS (SELECT 1 FROM lifetime_isas WHERE customer_id = c.id),
+            COALESCE(c.date_of_birth > CURRENT_DATE - INTERVAL '50 years', FALSE),
+            COALESCE(c.date_of_birth <= CURRENT_DATE - INTERVAL '60 years', FALSE)
+        FROM retail_customers c
+        WHERE c.id = $1
+    `, customerID).Scan(&eligibility.Open, &eligibility.CanContribute, &eligibility.Over60)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, isaerrors.ErrCustomerNotFound
+		}
+		return nil, fmt.Errorf("failed to get lifetime isa eligibility: %w", err)
+	}
+
+	return &eligibility, nil
+}
+
+// Note: The ISA product and, for adult ISAs that started as Junior ISAs, when they converted
+func (r *Repository) getProduct(ctx context.Context, customerID string) (string, *time.Time, error) {
+	var product string
+	var converted sql.NullTime
+	err := r.db.QueryRowContext(ctx, `
+        SELECT isa_product, converted_at FROM retail_customers WHERE id = $1
+    `, customerID).Scan(&product, &converted)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil, isaerrors.ErrCustomerNotFound
+		}
+		return "", nil, fmt.Errorf("failed to get isa product: %w", err)
+	}
+	if converted.Valid {
+		return product, &converted.Time, nil
+	}
+	return product, nil, nil
+}
+
+// Note: Pension accounts have no customer so they never match one
+const accountColumns = `
+	id, COALESCE(customer_id::text, ''), product, status, opened_at, closed_at`
+
+func scanAccount(row rowScanner) (*models.Account, error) {
+	var account models.Account
+	var closed sql.NullTime
+	err := row.Scan(&account.ID, &account.CustomerID, &account.Product, &account.Status, &account.OpenedAt, &closed)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, isaerrors.ErrAccountNotFound
+		}
+		return nil, fmt.Errorf("failed to get account: %w", err)
+	}
+	if closed.Valid {
+		account.ClosedAt = &closed.Time
+	}
+	return &account, nil
+}
+
+func (r *Repository) getAccount(ctx context.Context, id string) (*models.Account, error) {
+	return scanAccount(r.db.QueryRowContext(ctx, `
+        SELECT`+accountColumns+`
+        FROM accounts
+        WHERE id = $1
+    `, id))
+}
+
+// Note: The customer's open account for the product, or their most recently closed one
+// so withdrawals can still be made after closure. Standard ISA investments go into a Junior ISA
+// account until it converts
+func (r *Repository) getCustomerAccount(ctx context.Context, customerID, product string) (*models.Account, error) {
+	products := []string{product}
+	if product == models.ISAProductStandard {
+		products = append(products, models.ISAProductJunior)
+	}
+	return scanAccount(r.db.QueryRowContext(ctx, `
+        SELECT`+accountColumns+`
+        FROM accounts
+        WHERE customer_id = $1 AND product = ANY($2)
+        ORDER BY status = 'closed', opened_at DESC
+        LIMIT 1
+    `, customerID, pq.Array(products)))
+}
+
+// Note: Used to block deposits from customers whose accounts are closed or whose KYC data is incomplete.
+// A Junior ISA holder is a child so the registered contact's identity verification is used
+func (r *Repository) getCustomerEligibility(ctx context.Context, customerID string) (*models.CustomerEligibility, error) {
+	var eligibility models.CustomerEligibility
+	err := r.db.QueryRowContext(ctx, `
+        SELECT c.status, c.isa_product, COALESCE(c.registered_contact_id::text, ''),
+            c.date_of_birth IS NOT NULL,
+            COALESCE(c.date_of_birth <= CURRENT_DATE - INTERVAL '18 years', FALSE),
+            c.uk_resident,
+            c.ni_number_encrypted IS NOT NULL,
+            COALESCE(rc.kyc_status, c.kyc_status)
+        FROM retail_customers c
+        LEFT JOIN retail_customers rc ON rc.id = c.registered_contact_id
+        WHERE c.id = $1
+    `, customerID).Scan(
+		&eligibility.Status,
+		&eligibility.Product,
+		&eligibility.RegisteredContactID,
+		&eligibility.HasDOB,
+		&eligibility.IsAdult,
+		&eligibility.UKResident,
+		&eligibility.HasNINumber,
+		&eligibility.KYCStatus,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, isaerrors.ErrCustomerNotFound
+		}
+		return nil, fmt.Errorf("failed to get customer eligibility: %w", err)
+	}
+
+	return &eligibility, nil
+}
+
+// Note: Nullable pricing columns are scanned separately, see scanInvestment
+const investmentColumns = `
+	id, COALESCE(customer_id::text, ''), account_id, fund_id, amount, type, status, created_at,
+	COALESCE(TO_CHAR(dealing_date, 'YYYY-MM-DD'), ''), unit_price, units, risk_acknowledged,
+	COALESCE(payment_reference, ''), product, withdrawal_charge`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanInvestment(row rowScanner) (*models.Investment, error) {
+	var investment models.Investment
+	var unitPrice, units, withdrawalCharge sql.NullFloat64
+	err := row.Scan(
+		&investment.ID,
+		&investment.CustomerID,
+		&investment.AccountID,
+		&investment.FundID,
+		&investment.Amount,
+		&investment.Type,
+		&investment.Status,
+		&investment.CreatedAt,
+		&investment.DealingDate,
+		&unitPrice,
+		&units,
+		&investment.RiskAcknowledged,
+		&investment.PaymentReference,
+		&investment.Product,
+		&withdrawalCharge,
+	)
+	if err != nil {
+		return nil, err
+	}
+	setPricing(&investment, unitPrice, units)
+	if withdrawalCharge.Valid {
+		investment.WithdrawalCharge = &withdrawalCharge.Float64
+	}
+
+	return &investment, nil
+}
+
+func scanInvestments(rows *sql.Rows) ([]models.Investment, error) {
+	var investments []models.Investment
+	for rows.Next() {
+		investment, err := scanInvestment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan investment: %w", err)
+		}
+		investments = append(investments, *investment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating investments: %w", err)
+	}
+	return investments, nil
+}
+
+// buildInvestmentFilter returns the WHERE clause and arguments for a customer's investment history.
+// The customer ID is always $1
+func buildInvestmentFilter(customerID string, filter *models.InvestmentFilter) (string, []interface{}) {
+	conditions := []string{"customer_id = $1"}
+	args := []interface{}{customerID}
+
+	add := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter != nil {
+		if filter.AccountID != "" {
+			add("account_id = $%d", filter.AccountID)
+		}
+		if filter.From != nil {
+			add("created_at >= $%d", *filter.From)
+		}
+		// Note: To is inclusive so we compare against the start of the following day
+		if filter.To != nil {
+			add("created_at < $%d", filter.To.AddDate(0, 0, 1))
+		}
+		if filter.FundID != "" {
+			add("fund_id = $%d", filter.FundID)
+		}
+		if filter.Type != "" {
+			add("type = $%d", filter.Type)
+		}
+		if filter.Status != "" {
+			add("status = $%d", filter.Status)
+		}
+	}
+
+	return "\n\tWHERE " + strings.Join(conditions, " AND "), args
+}
+
+func (r *Repository) countInvestmentsByCustomerID(ctx context.Context, id string, filter *models.InvestmentFilter) (int, error) {
+	where, args := buildInvestmentFilter(id, filter)
+
+	var total int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM investments"+where, args...).Scan(&total)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get total count: %w", err)
+	}
+	return total, nil
+}
+
+func (r *Repository) listInvestmentsByCustomerID(ctx context.Context, id string, filter *models.InvestmentFilter, page, pageSize int) ([]models.Investment, int, error) {
 	offset := (page - 1) * pageSize
 
 	// First, get total count
-	var total int
-	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM investments").Scan(&total)
+	total, err := r.countInvestmentsByCustomerID(ctx, id, filter)
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to get total count: %w", err)
+		return nil, 0, err
 	}
 
 	// Then get paginated data
+	where, args := buildInvestmentFilter(id, filter)
+	args = append(args, pageSize, offset)
 	rows, err := r.db.QueryContext(ctx, `
-        SELECT id, customer_id, fund_id, amount, created_at 
-        FROM investments
-		WHERE customer_id = $1
-        ORDER BY created_at
-        LIMIT $2 OFFSET $3
-    `, id, pageSize, offset)
+        SELECT`+investmentColumns+`
+        FROM investments`+where+fmt.Sprintf(`
+        ORDER BY created_at, id
+        LIMIT $%d OFFSET $%d
+    `, len(args)-1, len(args)), args...)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to query investments: %w", err)
 	}
 	defer rows.Close()
 
-	// Scan rows into our investments slice
-	var investments []models.Investment
-	for rows.Next() {
-		var investment models.Investment
-		if err := rows.Scan(&investment.ID,
-			&investment.CustomerID,
-			&investment.FundID,
-			&investment.Amount,
-			&investment.CreatedAt,
-		); err != nil {
-			return nil, 0, fmt.Errorf("failed to scan investment: %w", err)
+	investments, err := scanInvestments(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return investments, total, nil
+}
+
+// Note: Keyset pagination. Seeks straight to the row after the cursor using the
+// (customer_id, created_at, id) index so deep pages cost the same as the first.
+// A nil afterCreatedAt starts from the beginning, and only then is the total counted
+func (r *Repository) listInvestmentsByCustomerIDAfter(ctx context.Context, id string, filter *models.InvestmentFilter, afterCreatedAt *time.Time, afterID string, limit int) ([]models.Investment, *int, error) {
+	var total *int
+	if afterCreatedAt == nil {
+		count, err := r.countInvestmentsByCustomerID(ctx, id, filter)
+		if err != nil {
+			return nil, nil, err
 		}
-		investments = append(investments, investment)
+		total = &count
+	}
+
+	where, args := buildInvestmentFilter(id, filter)
+	if afterCreatedAt != nil {
+		args = append(args, *afterCreatedAt, afterID)
+		where += fmt.Sprintf(" AND (created_at, id) > ($%d, $%d)", len(args)-1, len(args))
+	}
+	args = append(args, limit)
+
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT`+investmentColumns+`
+        FROM investments`+where+fmt.Sprintf(`
+        ORDER BY created_at, id
+        LIMIT $%d
+    `, len(args)), args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query investments: %w", err)
+	}
+	defer rows.Close()
+
+	investments, err := scanInvestments(rows)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	return investments, total, nil
+}
 
+// Note: Used for exports. Rows are handed to fn one at a time as they are read
+// so the full history is never held in memory. Returning an error from fn stops the scan
+func (r *Repository) streamInvestmentsByCustomerID(ctx context.Context, id string, filter *models.InvestmentFilter, fn func(*models.Investment) error) error {
+	where, args := buildInvestmentFilter(id, filter)
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT`+investmentColumns+`
+        FROM investments`+where+`
+        ORDER BY created_at, id
+    `, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query investments: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		investment, err := scanInvestment(rows)
+		if err != nil {
+			return fmt.Errorf("failed to scan investment: %w", err)
+		}
+		if err := fn(investment); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
 }
 
 func (r *Repository) getInvestmentByID(ctx context.Context, id string) (*models.Investment, error) {
 	query := `
-	SELECT id, customer_id, fund_id, amount
+	SELECT` + investmentColumns + `
 	FROM investments
 	WHERE id = $1
 `
-	var investment models.Investment
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&investment.ID,
-		&investment.CustomerID,
-		&investment.FundID,
-		&investment.Amount,
-	)
-
+	investment, err := scanInvestment(r.db.QueryRowContext(ctx, query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("investment not found")
+			return nil, isaerrors.ErrInvestmentNotFound
 		}
 		return nil, fmt.Errorf("failed to get investment: %w", err)
 	}
 
-	return &investment, nil
+	return investment, nil
 }
 
-// Note: This is fetching data from the materialized view
+// setPricing copies the nullable pricing columns onto the investment. Unpriced orders leave them nil
+func setPricing(investment *models.Investment, unitPrice, units sql.NullFloat64) {
+	if unitPrice.Valid {
+		investment.UnitPrice = &unitPrice.Float64
+	}
+	if units.Valid {
+		investment.Units = &units.Float64
+	}
+}
+
+// Note: This is fetching data from the materialized view, with the value from the latest valuation snapshot.
+// The view is kept per account so the customer's accounts in the fund are added up
 func (r *Repository) getCustomerFundTotal(ctx context.Context, customerID, fundID string) (*models.InvestmentSummary, error) {
 	query := `
+        WITH totals AS (
+            SELECT customer_id, first_name, last_name, email, fund_id, fund_name,
+                SUM(total_investment) AS total_investment,
+                SUM(settled_investment) AS settled_investment,
+                SUM(pending_investment) AS pending_investment
+            FROM customer_fund_totals
+            WHERE customer_id = $1 AND fund_id = $2
+            GROUP BY customer_id, first_name, last_name, email, fund_id, fund_name
+        )
         SELECT 
-            customer_id,
-            first_name,
-            last_name,
-            email,
-            fund_id,
-            fund_name,
-            total_investment
-        FROM customer_fund_totals
-        WHERE customer_id = $1 AND fund_id = $2`
+            t.customer_id,
+            t.first_name,
+            t.last_name,
+            COALESCE(t.email, ''),
+            t.fund_id,
+            t.fund_name,
+            t.total_investment,
+            t.settled_investment,
+            t.pending_investment,
+            v.units,
+            v.market_value,
+            COALESCE(TO_CHAR(v.valuation_date, 'YYYY-MM-DD'), '')
+        FROM totals t
+        LEFT JOIN LATERAL (
+            SELECT units, market_value, valuation_date
+            FROM holding_valuations
+            WHERE customer_id = t.customer_id AND fund_id = t.fund_id
+            ORDER BY valuation_date DESC
+            LIMIT 1
+        ) v ON TRUE`
 
 	var summary models.InvestmentSummary
+	var units, marketValue sql.NullFloat64
 	err := r.db.QueryRowContext(ctx, query, customerID, fundID).Scan(
 		&summary.CustomerID,
 		&summary.FirstName,
@@ -172,10 +679,200 @@ func (r *Repository) getCustomerFundTotal(ctx context.Context, customerID, fundI
 		&summary.FundID,
 		&summary.FundName,
 		&summary.TotalInvestment,
+		&summary.SettledInvestment,
+		&summary.PendingInvestment,
+		&units,
+		&marketValue,
+		&summary.ValuationDate,
 	)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, isaerrors.ErrCustomerFundTotalNotFound
+		}
 		return nil, fmt.Errorf("error querying investment summary: %w", err)
 	}
+	if units.Valid {
+		summary.Units = &units.Float64
+	}
+	if marketValue.Valid {
+		summary.MarketValue = &marketValue.Float64
+	}
 
 	return &summary, nil
 }
+
+// Note: A single account's totals from the materialized view. Nightly valuations are taken per
+// customer and fund, so the account's own holding is valued here the same way: units at the latest
+// fund price and unpriced money at cost. The valuation date is the date of that price
+func (r *Repository) getAccountFundTotal(ctx context.Context, accountID, fundID string) (*models.InvestmentSummary, error) {
+	var summary models.InvestmentSummary
+	var units, marketValue sql.NullFloat64
+	err := r.db.QueryRowContext(ctx, `
+        SELECT t.account_id, t.product, t.customer_id, t.first_name, t.last_name, COALESCE(t.email, ''),
+            t.fund_id, t.fund_name, t.total_investment, t.settled_investment, t.pending_investment,
+            h.units, ROUND(COALESCE(h.units, 0) * COALESCE(p.price, 0) + h.unpriced_cash, 2),
+            COALESCE(TO_CHAR(p.price_date, 'YYYY-MM-DD'), '')
+        FROM customer_fund_totals t
+        LEFT JOIN LATERAL (
+            SELECT SUM(CASE WHEN type = 'deposit' THEN units ELSE -units END) AS units,
+                COALESCE(SUM(CASE WHEN units IS NOT NULL THEN 0 WHEN type = 'deposit' THEN amount ELSE -amount END), 0) AS unpriced_cash
+            FROM investments
+            WHERE account_id = t.account_id AND fund_id = t.fund_id AND status IN ('units_allocated', 'settled')
+        ) h ON TRUE
+        LEFT JOIN LATERAL (
+            SELECT price, price_date
+            FROM fund_prices
+            WHERE fund_id = t.fund_id AND price_date <= CURRENT_DATE
+            ORDER BY price_date DESC
+            LIMIT 1
+        ) p ON TRUE
+        WHERE t.account_id = $1 AND t.fund_id = $2
+    `, accountID, fundID).Scan(
+		&summary.AccountID,
+		&summary.Product,
+		&summary.CustomerID,
+		&summary.FirstName,
+		&summary.LastName,
+		&summary.Email,
+		&summary.FundID,
+		&summary.FundName,
+		&summary.TotalInvestment,
+		&summary.SettledInvestment,
+		&summary.PendingInvestment,
+		&units,
+		&marketValue,
+		&summary.ValuationDate,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, isaerrors.ErrCustomerFundTotalNotFound
+		}
+		return nil, fmt.Errorf("error querying account summary: %w", err)
+	}
+	if units.Valid {
+		summary.Units = &units.Float64
+	}
+	if marketValue.Valid {
+		summary.MarketValue = &marketValue.Float64
+	}
+
+	return &summary, nil
+}
+
+// Note: Moves an investment through one or more lifecycle steps in a single transaction.
+// The update is conditional on the current status so concurrent transitions cannot both succeed
+func (r *Repository) applyTransitions(ctx context.Context, investmentID, from string, steps []string, reason string) error {
+	if len(steps) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+	UPDATE investments
+	SET status = $3, status_updated_at = CURRENT_TIMESTAMP
+	WHERE id = $1 AND status = $2
+`, investmentID, from, steps[len(steps)-1])
+	if err != nil {
+		return fmt.Errorf("failed to update investment status: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return isaerrors.ErrInvestmentStatusChanged
+	}
+
+	current := from
+	for _, step := range steps {
+		if err := ledger.RecordStatusChange(ctx, tx, investmentID, current, step, reason); err != nil {
+			return err
+		}
+		current = step
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) getStatusHistory(ctx context.Context, investmentID string) ([]models.InvestmentStatusChange, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT id, investment_id, COALESCE(from_status, ''), to_status, COALESCE(reason, ''), created_at
+        FROM investment_status_history
+        WHERE investment_id = $1
+        ORDER BY created_at, id
+    `, investmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query status history: %w", err)
+	}
+	defer rows.Close()
+
+	var history []models.InvestmentStatusChange
+	for rows.Next() {
+		var change models.InvestmentStatusChange
+		if err := rows.Scan(
+			&change.ID,
+			&change.InvestmentID,
+			&change.FromStatus,
+			&change.ToStatus,
+			&change.Reason,
+			&change.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan status change: %w", err)
+		}
+		history = append(history, change)
+	}
+
+	return history, nil
+}
+
+// Note: Investments placed before the settlement cut-off that have not yet settled.
+// Orders are only settled once they have been priced at their dealing date.
+// Deposits wait in pending until bank reconciliation confirms the cash has arrived.
+// Pension investments are settled the same way, their holder is the employee
+func (r *Repository) listInvestmentsForSettlement(ctx context.Context, cutoff time.Time) ([]models.Investment, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT id, COALESCE(customer_id, employee_id), fund_id, amount, type, status, created_at
+        FROM investments
+        WHERE status IN ('pending', 'cash_received', 'units_allocated') AND created_at < $1
+            AND unit_price IS NOT NULL
+            AND NOT (type = 'deposit' AND status = 'pending')
+        ORDER BY created_at
+    `, cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query investments for settlement: %w", err)
+	}
+	defer rows.Close()
+
+	var investments []models.Investment
+	for rows.Next() {
+		var investment models.Investment
+		if err := rows.Scan(&investment.ID,
+			&investment.CustomerID,
+			&investment.FundID,
+			&investment.Amount,
+			&investment.Type,
+			&investment.Status,
+			&investment.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan investment: %w", err)
+		}
+		investments = append(investments, investment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating investments for settlement: %w", err)
+	}
+
+	return investments, nil
+}
+
+func (r *Repository) refreshCustomerTotals(ctx context.Context) error {
+	if _, err := r.db.ExecContext(ctx, "REFRESH MATERIALIZED VIEW customer_fund_totals"); err != nil {
+		return fmt.Errorf("failed to refresh materialized view: %w", err)
+	}
+	return nil
+}
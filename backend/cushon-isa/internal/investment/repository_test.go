@@ -7,9 +7,11 @@ import (
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
 	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
 	"github.com/stcol316/cushon-isa/internal/models"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCreateInvestment(t *testing.T) {
@@ -25,15 +27,22 @@ func TestCreateInvestment(t *testing.T) {
 	tests := []struct {
 		name        string
 		investment  *models.Investment
+		decision    *models.AMLDecision
+		recheck     func(context.Context, limitReader) error
 		setupMock   func(sqlmock.Sqlmock)
 		expectError error
 	}{
 		{
 			name: "successful investment creation",
 			investment: &models.Investment{
-				CustomerID: "customer1",
-				FundID:     "fund1",
-				Amount:     float64(100),
+				CustomerID:  "customer1",
+				AccountID:   "account1",
+				FundID:      "fund1",
+				Amount:      float64(100),
+				Type:        models.InvestmentTypeDeposit,
+				Status:      models.InvestmentStatusPending,
+				DealingDate: "2025-01-02",
+				Product:     models.ISAProductStandard,
 			},
 			setupMock: func(mock sqlmock.Sqlmock) {
 				// Expect check for existing fund
@@ -45,8 +54,13 @@ func TestCreateInvestment(t *testing.T) {
 				mock.ExpectBegin()
 
 				// Expect investment insert
-				mock.ExpectExec("INSERT INTO investments").
-					WithArgs("customer1", "fund1", float64(100)).
+				mock.ExpectQuery("INSERT INTO investments").
+					WithArgs("customer1", "fund1", float64(100), models.InvestmentTypeDeposit, models.InvestmentStatusPending, "2025-01-02", false, models.ISAProductStandard, nil, "account1").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "payment_reference"}).AddRow("inv1", time.Now(), "ISA0123456789"))
+
+				// Expect initial status to be recorded
+				mock.ExpectExec("INSERT INTO investment_status_history").
+					WithArgs("inv1", "", models.InvestmentStatusPending, "created").
 					WillReturnResult(sqlmock.NewResult(1, 1))
 
 				// Expect materialized view refresh
@@ -58,6 +72,44 @@ func TestCreateInvestment(t *testing.T) {
 			},
 			expectError: nil,
 		},
+		{
+			name: "held investment records aml alerts",
+			investment: &models.Investment{
+				CustomerID:  "customer1",
+				AccountID:   "account1",
+				FundID:      "fund1",
+				Amount:      float64(12000),
+				Type:        models.InvestmentTypeDeposit,
+				Status:      models.InvestmentStatusHeld,
+				DealingDate: "2025-01-02",
+				Product:     models.ISAProductStandard,
+			},
+			decision: &models.AMLDecision{
+				Outcome: models.AMLOutcomeHold,
+				Results: []models.AMLRuleResult{
+					{Rule: "single_deposit_threshold", Outcome: models.AMLOutcomeHold, Reason: "too large"},
+				},
+			},
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT DISTINCT fund_id").
+					WithArgs("customer1").
+					WillReturnRows(sqlmock.NewRows([]string{"fund_id"}))
+				mock.ExpectBegin()
+				mock.ExpectQuery("INSERT INTO investments").
+					WithArgs("customer1", "fund1", float64(12000), models.InvestmentTypeDeposit, models.InvestmentStatusHeld, "2025-01-02", false, models.ISAProductStandard, nil, "account1").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "payment_reference"}).AddRow("inv2", time.Now(), "ISA9876543210"))
+				mock.ExpectExec("INSERT INTO investment_status_history").
+					WithArgs("inv2", "", models.InvestmentStatusHeld, "created").
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("INSERT INTO aml_alerts").
+					WithArgs("inv2", "customer1", "single_deposit_threshold", models.AMLOutcomeHold, "too large", float64(12000)).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("REFRESH MATERIALIZED VIEW customer_fund_totals").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectCommit()
+			},
+			expectError: nil,
+		},
 		{
 			name: "different fund not allowed",
 			investment: &models.Investment{
@@ -73,17 +125,55 @@ func TestCreateInvestment(t *testing.T) {
 			},
 			expectError: isaerrors.ErrDifferentFundNotAllowed,
 		},
+		{
+			name: "recheck runs with the customer's accounts locked",
+			investment: &models.Investment{
+				CustomerID: "customer1",
+				AccountID:  "account1",
+				FundID:     "fund1",
+				Amount:     float64(2500),
+				Type:       models.InvestmentTypeDeposit,
+				Product:    models.ISAProductStandard,
+			},
+			recheck: func(ctx context.Context, limits limitReader) error {
+				allowance, _, err := limits.getSubscriptions(ctx, "customer1", time.Time{}, time.Time{})
+				if err != nil {
+					return err
+				}
+				if allowance.Deposits+2500 > 20000 {
+					return isaerrors.ErrAllowanceExceeded
+				}
+				return nil
+			},
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT DISTINCT fund_id").
+					WithArgs("customer1").
+					WillReturnRows(sqlmock.NewRows([]string{"fund_id"}))
+				mock.ExpectBegin()
+				mock.ExpectExec("SELECT id FROM accounts WHERE customer_id = \\$1 ORDER BY id FOR UPDATE").
+					WithArgs("customer1").
+					WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectQuery("SELECT (.+) FROM investments i").
+					WithArgs("customer1", time.Time{}, time.Time{}).
+					WillReturnRows(sqlmock.NewRows([]string{"deposits", "lifetime", "current_year", "previous_years"}).AddRow(18000.0, 0.0, 0.0, 0.0))
+				mock.ExpectRollback()
+			},
+			expectError: isaerrors.ErrAllowanceExceeded,
+		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			test.setupMock(mock)
-			err := repo.createInvestment(ctx, test.investment)
+			err := repo.createInvestment(ctx, test.investment, test.decision, test.recheck)
 			assert.Equal(t, test.expectError, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
 		})
 	}
 }
 
+var investmentRowColumns = []string{"id", "customer_id", "account_id", "fund_id", "amount", "type", "status", "created_at", "dealing_date", "unit_price", "units", "risk_acknowledged", "payment_reference", "product", "withdrawal_charge"}
+
 func TestListInvestmentsByCustomerID(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -100,6 +190,8 @@ func TestListInvestmentsByCustomerID(t *testing.T) {
 			CustomerID: "customer1",
 			FundID:     "fund1",
 			Amount:     float64(100),
+			Type:       models.InvestmentTypeDeposit,
+			Status:     models.InvestmentStatusPending,
 			CreatedAt:  time.Now(),
 		},
 		{
@@ -107,6 +199,8 @@ func TestListInvestmentsByCustomerID(t *testing.T) {
 			CustomerID: "customer1",
 			FundID:     "fund1",
 			Amount:     float64(200),
+			Type:       models.InvestmentTypeDeposit,
+			Status:     models.InvestmentStatusPending,
 			CreatedAt:  time.Now(),
 		},
 	}
@@ -119,20 +213,21 @@ func TestListInvestmentsByCustomerID(t *testing.T) {
 		offset := 0
 
 		// Expect count query
-		mock.ExpectQuery("SELECT COUNT").
+		mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM investments WHERE customer_id = \\$1").
+			WithArgs(customerID).
 			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(expectedTotal))
 
 		// Expect investments query
-		rows := sqlmock.NewRows([]string{"id", "customer_id", "fund_id", "amount", "created_at"})
+		rows := sqlmock.NewRows(investmentRowColumns)
 		for _, inv := range expectedInvestments {
-			rows.AddRow(inv.ID, inv.CustomerID, inv.FundID, inv.Amount, inv.CreatedAt)
+			rows.AddRow(inv.ID, inv.CustomerID, inv.AccountID, inv.FundID, inv.Amount, inv.Type, inv.Status, inv.CreatedAt, inv.DealingDate, nil, nil, false, "", models.ISAProductStandard, nil)
 		}
 
 		mock.ExpectQuery("SELECT (.+) FROM investments").
 			WithArgs(customerID, pageSize, offset).
 			WillReturnRows(rows)
 
-		investments, total, err := repo.listInvestmentsByCustomerID(ctx, customerID, page, pageSize)
+		investments, total, err := repo.listInvestmentsByCustomerID(ctx, customerID, nil, page, pageSize)
 		assert.NoError(t, err)
 		assert.Equal(t, expectedTotal, total)
 		assert.Len(t, investments, len(expectedInvestments))
@@ -147,26 +242,126 @@ func TestListInvestmentsByCustomerID(t *testing.T) {
 		offset := 0
 
 		// Expect count query
-		mock.ExpectQuery("SELECT COUNT").
+		mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM investments WHERE customer_id = \\$1").
+			WithArgs(customerID).
 			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(expectedTotal))
 
 		// Expect investments query
-		rows := sqlmock.NewRows([]string{"id", "customer_id", "fund_id", "amount", "created_at"})
+		rows := sqlmock.NewRows(investmentRowColumns)
 		for _, inv := range expectedInvestments {
-			rows.AddRow(inv.ID, inv.CustomerID, inv.FundID, inv.Amount, inv.CreatedAt)
+			rows.AddRow(inv.ID, inv.CustomerID, inv.AccountID, inv.FundID, inv.Amount, inv.Type, inv.Status, inv.CreatedAt, inv.DealingDate, nil, nil, false, "", models.ISAProductStandard, nil)
 		}
 
 		mock.ExpectQuery("SELECT (.+) FROM investments").
 			WithArgs(customerID, pageSize, offset).
 			WillReturnRows(rows)
 
-		investments, total, err := repo.listInvestmentsByCustomerID(ctx, customerID, page, pageSize)
+		investments, total, err := repo.listInvestmentsByCustomerID(ctx, customerID, nil, page, pageSize)
 		assert.NoError(t, err)
 		assert.Equal(t, expectedTotal, total)
 		assert.Len(t, investments, len(expectedInvestments))
 	})
 }
 
+func TestListInvestmentsByCustomerIDAfter(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+	createdAt := time.Date(2025, 1, 1, 9, 30, 0, 0, time.UTC)
+
+	t.Run("first page", func(t *testing.T) {
+		mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM investments WHERE customer_id = \\$1").
+			WithArgs("customer1").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+		mock.ExpectQuery("SELECT (.+) FROM investments WHERE customer_id = \\$1 ORDER BY created_at, id LIMIT \\$2").
+			WithArgs("customer1", 3).
+			WillReturnRows(sqlmock.NewRows(investmentRowColumns).
+				AddRow("inv1", "customer1", "account1", "fund1", 100.0, models.InvestmentTypeDeposit, models.InvestmentStatusSettled, createdAt, "2025-01-01", nil, nil, false, "", models.ISAProductStandard, nil).
+				AddRow("inv2", "customer1", "account1", "fund1", 200.0, models.InvestmentTypeDeposit, models.InvestmentStatusSettled, createdAt, "2025-01-01", nil, nil, false, "", models.ISAProductStandard, nil))
+
+		investments, total, err := repo.listInvestmentsByCustomerIDAfter(ctx, "customer1", nil, nil, "", 3)
+		assert.NoError(t, err)
+		require.NotNil(t, total)
+		assert.Equal(t, 3, *total)
+		assert.Len(t, investments, 2)
+	})
+
+	t.Run("after cursor", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM investments WHERE customer_id = \\$1 AND \\(created_at, id\\) > \\(\\$2, \\$3\\)").
+			WithArgs("customer1", createdAt, "inv2", 3).
+			WillReturnRows(sqlmock.NewRows(investmentRowColumns).
+				AddRow("inv3", "customer1", "account1", "fund1", 300.0, models.InvestmentTypeDeposit, models.InvestmentStatusPending, createdAt, "2025-01-02", nil, nil, false, "", models.ISAProductStandard, nil))
+
+		investments, total, err := repo.listInvestmentsByCustomerIDAfter(ctx, "customer1", nil, &createdAt, "inv2", 3)
+		assert.NoError(t, err)
+		assert.Nil(t, total)
+		assert.Len(t, investments, 1)
+		assert.Equal(t, "inv3", investments[0].ID)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListInvestmentsByCustomerIDFiltered(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+	from := time.Date(2025, 4, 6, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 4, 5, 0, 0, 0, 0, time.UTC)
+	filter := &models.InvestmentFilter{
+		From:   &from,
+		To:     &to,
+		FundID: "fund1",
+		Type:   models.InvestmentTypeDeposit,
+		Status: models.InvestmentStatusSettled,
+	}
+	where := "WHERE customer_id = \\$1 AND created_at >= \\$2 AND created_at < \\$3 AND fund_id = \\$4 AND type = \\$5 AND status = \\$6"
+	// Note: The end date is inclusive so the bound is the start of the next day
+	toBound := time.Date(2026, 4, 6, 0, 0, 0, 0, time.UTC)
+
+	t.Run("offset", func(t *testing.T) {
+		mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM investments "+where).
+			WithArgs("customer1", from, toBound, "fund1", models.InvestmentTypeDeposit, models.InvestmentStatusSettled).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectQuery("SELECT (.+) FROM investments "+where+" ORDER BY created_at, id LIMIT \\$7 OFFSET \\$8").
+			WithArgs("customer1", from, toBound, "fund1", models.InvestmentTypeDeposit, models.InvestmentStatusSettled, 10, 0).
+			WillReturnRows(sqlmock.NewRows(investmentRowColumns).
+				AddRow("inv1", "customer1", "account1", "fund1", 100.0, models.InvestmentTypeDeposit, models.InvestmentStatusSettled, from, "2025-04-07", nil, nil, false, "", models.ISAProductStandard, nil))
+
+		investments, total, err := repo.listInvestmentsByCustomerID(ctx, "customer1", filter, 1, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, total)
+		assert.Len(t, investments, 1)
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM investments "+where+" ORDER BY created_at, id").
+			WithArgs("customer1", from, toBound, "fund1", models.InvestmentTypeDeposit, models.InvestmentStatusSettled).
+			WillReturnRows(sqlmock.NewRows(investmentRowColumns).
+				AddRow("inv1", "customer1", "account1", "fund1", 100.0, models.InvestmentTypeDeposit, models.InvestmentStatusSettled, from, "2025-04-07", nil, nil, false, "", models.ISAProductStandard, nil).
+				AddRow("inv2", "customer1", "account1", "fund1", 200.0, models.InvestmentTypeDeposit, models.InvestmentStatusSettled, from, "2025-04-07", nil, nil, false, "", models.ISAProductStandard, nil))
+
+		var ids []string
+		err := repo.streamInvestmentsByCustomerID(ctx, "customer1", filter, func(investment *models.Investment) error {
+			ids = append(ids, investment.ID)
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"inv1", "inv2"}, ids)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
 func TestGetInvestmentByID(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -178,17 +373,28 @@ func TestGetInvestmentByID(t *testing.T) {
 	ctx := context.Background()
 
 	t.Run("successful get", func(t *testing.T) {
+		unitPrice, units := 1.25, 80.0
 		expectedInvestment := &models.Investment{
-			ID:         "inv1",
-			CustomerID: "customer1",
-			FundID:     "fund1",
-			Amount:     float64(100),
+			ID:          "inv1",
+			CustomerID:  "customer1",
+			AccountID:   "account1",
+			FundID:      "fund1",
+			Amount:      float64(100),
+			Type:        models.InvestmentTypeDeposit,
+			Status:      models.InvestmentStatusPending,
+			CreatedAt:   time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			DealingDate: "2025-01-02",
+			UnitPrice:   &unitPrice,
+			Product:     models.ISAProductStandard,
+			Units:       &units,
 		}
 
 		mock.ExpectQuery("SELECT (.+) FROM investments").
 			WithArgs(expectedInvestment.ID).
-			WillReturnRows(sqlmock.NewRows([]string{"id", "customer_id", "fund_id", "amount"}).
-				AddRow(expectedInvestment.ID, expectedInvestment.CustomerID, expectedInvestment.FundID, expectedInvestment.Amount))
+			WillReturnRows(sqlmock.NewRows(investmentRowColumns).
+				AddRow(expectedInvestment.ID, expectedInvestment.CustomerID, expectedInvestment.AccountID, expectedInvestment.FundID, expectedInvestment.Amount,
+					expectedInvestment.Type, expectedInvestment.Status, expectedInvestment.CreatedAt,
+					expectedInvestment.DealingDate, unitPrice, units, false, "", models.ISAProductStandard, nil))
 
 		investment, err := repo.getInvestmentByID(ctx, expectedInvestment.ID)
 		assert.NoError(t, err)
@@ -216,31 +422,414 @@ func TestGetCustomerFundTotal(t *testing.T) {
 	repo := NewRepository(db)
 	ctx := context.Background()
 
+	// Note: The outer query must read the customer's filtered totals, not the whole view
+	customerFundTotalQuery := "WITH totals AS \\( SELECT (.+) FROM customer_fund_totals WHERE customer_id = \\$1 AND fund_id = \\$2 GROUP BY (.+) \\) " +
+		"SELECT (.+) FROM totals t LEFT JOIN LATERAL \\( SELECT (.+) FROM holding_valuations WHERE customer_id = t.customer_id AND fund_id = t.fund_id"
+
 	t.Run("successful get total", func(t *testing.T) {
 		expectedSummary := &models.InvestmentSummary{
-			CustomerID:      "customer1",
-			FirstName:       "John",
-			LastName:        "Doe",
-			Email:           "john@example.com",
-			FundID:          "fund1",
-			FundName:        "Test Fund",
-			TotalInvestment: float64(300),
+			CustomerID:        "customer1",
+			FirstName:         "John",
+			LastName:          "Doe",
+			Email:             "john@example.com",
+			FundID:            "fund1",
+			FundName:          "Test Fund",
+			TotalInvestment:   float64(300),
+			SettledInvestment: float64(200),
+			PendingInvestment: float64(100),
 		}
 
-		mock.ExpectQuery("SELECT (.+) FROM customer_fund_totals").
+		mock.ExpectQuery(customerFundTotalQuery).
 			WithArgs(expectedSummary.CustomerID, expectedSummary.FundID).
 			WillReturnRows(sqlmock.NewRows([]string{
 				"customer_id", "first_name", "last_name", "email",
-				"fund_id", "fund_name", "total_investment",
+				"fund_id", "fund_name", "total_investment", "settled_investment", "pending_investment",
+				"units", "market_value", "valuation_date",
 			}).AddRow(
 				expectedSummary.CustomerID, expectedSummary.FirstName,
 				expectedSummary.LastName, expectedSummary.Email,
 				expectedSummary.FundID, expectedSummary.FundName,
 				expectedSummary.TotalInvestment,
+				expectedSummary.SettledInvestment,
+				expectedSummary.PendingInvestment,
+				nil, nil, "",
 			))
 
 		summary, err := repo.getCustomerFundTotal(ctx, expectedSummary.CustomerID, expectedSummary.FundID)
 		assert.NoError(t, err)
 		assert.Equal(t, expectedSummary, summary)
 	})
+
+	t.Run("with valuation snapshot", func(t *testing.T) {
+		mock.ExpectQuery(customerFundTotalQuery).
+			WithArgs("customer1", "fund1").
+			WillReturnRows(sqlmock.NewRows([]string{
+				"customer_id", "first_name", "last_name", "email",
+				"fund_id", "fund_name", "total_investment", "settled_investment", "pending_investment",
+				"units", "market_value", "valuation_date",
+			}).AddRow("customer1", "John", "Doe", "john@example.com", "fund1", "Test Fund",
+				300.0, 300.0, 0.0, 250.0, 325.5, "2025-01-31"))
+
+		summary, err := repo.getCustomerFundTotal(ctx, "customer1", "fund1")
+		assert.NoError(t, err)
+		require.NotNil(t, summary.MarketValue)
+		assert.Equal(t, 325.5, *summary.MarketValue)
+		assert.Equal(t, 250.0, *summary.Units)
+		assert.Equal(t, "2025-01-31", summary.ValuationDate)
+	})
+}
+
+func TestGetAccountFundTotal(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+
+	// Note: Valued from the account's own units at the latest price rather than reported at cost
+	mock.ExpectQuery("SELECT (.+) FROM customer_fund_totals t LEFT JOIN LATERAL \\( SELECT (.+) FROM investments WHERE account_id = t.account_id (.+) FROM fund_prices (.+) WHERE t.account_id = \\$1 AND t.fund_id = \\$2").
+		WithArgs("account1", "fund1").
+		WillReturnRows(sqlmock.NewRows([]string{
+			"account_id", "product", "customer_id", "first_name", "last_name", "email",
+			"fund_id", "fund_name", "total_investment", "settled_investment", "pending_investment",
+			"units", "market_value", "valuation_date",
+		}).AddRow("account1", models.ISAProductStandard, "customer1", "John", "Doe", "john@example.com", "fund1", "Test Fund",
+			300.0, 300.0, 0.0, 250.0, 337.5, "2025-02-03"))
+
+	summary, err := repo.getAccountFundTotal(context.Background(), "account1", "fund1")
+	require.NoError(t, err)
+	assert.Equal(t, "account1", summary.AccountID)
+	assert.Equal(t, 300.0, summary.TotalInvestment)
+	require.NotNil(t, summary.MarketValue)
+	assert.Equal(t, 337.5, *summary.MarketValue)
+	assert.Equal(t, 250.0, *summary.Units)
+	assert.Equal(t, "2025-02-03", summary.ValuationDate)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetCustomerEligibility(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+	columns := []string{"status", "isa_product", "registered_contact_id", "has_dob", "is_adult", "uk_resident", "has_ni_number", "kyc_status"}
+
+	t.Run("eligible customer", func(t *testing.T) {
+		mock.ExpectQuery("SELECT c.status, (.+) FROM retail_customers c").
+			WithArgs("customer1").
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(models.CustomerStatusActive, models.ISAProductStandard, "", true, true, true, true, models.KYCStatusVerified))
+
+		eligibility, err := repo.getCustomerEligibility(ctx, "customer1")
+		assert.NoError(t, err)
+		assert.True(t, eligibility.IsComplete())
+	})
+
+	t.Run("missing NI number", func(t *testing.T) {
+		mock.ExpectQuery("SELECT c.status, (.+) FROM retail_customers c").
+			WithArgs("customer1").
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(models.CustomerStatusActive, models.ISAProductStandard, "", true, true, true, false, models.KYCStatusVerified))
+
+		eligibility, err := repo.getCustomerEligibility(ctx, "customer1")
+		assert.NoError(t, err)
+		assert.False(t, eligibility.IsComplete())
+	})
+
+	t.Run("closed customer", func(t *testing.T) {
+		mock.ExpectQuery("SELECT c.status, (.+) FROM retail_customers c").
+			WithArgs("customer1").
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(models.CustomerStatusClosed, models.ISAProductStandard, "", true, true, true, true, models.KYCStatusVerified))
+
+		eligibility, err := repo.getCustomerEligibility(ctx, "customer1")
+		assert.NoError(t, err)
+		assert.Equal(t, models.CustomerStatusClosed, eligibility.Status)
+	})
+
+	t.Run("junior isa without NI number", func(t *testing.T) {
+		mock.ExpectQuery("SELECT c.status, (.+) FROM retail_customers c").
+			WithArgs("child1").
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(models.CustomerStatusActive, models.ISAProductJunior, "customer1", true, false, true, false, models.KYCStatusVerified))
+
+		eligibility, err := repo.getCustomerEligibility(ctx, "child1")
+		assert.NoError(t, err)
+		assert.True(t, eligibility.IsComplete())
+		assert.Equal(t, "customer1", eligibility.RegisteredContactID)
+	})
+
+	t.Run("junior isa holder turned 18", func(t *testing.T) {
+		mock.ExpectQuery("SELECT c.status, (.+) FROM retail_customers c").
+			WithArgs("child1").
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(models.CustomerStatusActive, models.ISAProductJunior, "customer1", true, true, true, false, models.KYCStatusVerified))
+
+		eligibility, err := repo.getCustomerEligibility(ctx, "child1")
+		assert.NoError(t, err)
+		assert.False(t, eligibility.IsComplete())
+	})
+
+	t.Run("customer not found", func(t *testing.T) {
+		mock.ExpectQuery("SELECT c.status, (.+) FROM retail_customers c").
+			WithArgs("missing").
+			WillReturnError(sql.ErrNoRows)
+
+		_, err := repo.getCustomerEligibility(ctx, "missing")
+		assert.ErrorIs(t, err, isaerrors.ErrCustomerNotFound)
+	})
+}
+
+func TestGetAMLActivity(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewRepository(db)
+
+	mock.ExpectQuery("SELECT (.+) FROM investments").
+		WithArgs("customer1", 7, 30).
+		WillReturnRows(sqlmock.NewRows([]string{"velocity_count", "velocity_amount", "recent_amount"}).AddRow(3, 4500.0, 6000.0))
+
+	activity, err := repo.getAMLActivity(context.Background(), "customer1", 7, 30)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, activity.VelocityDepositCount)
+	assert.Equal(t, 4500.0, activity.VelocityDepositAmount)
+	assert.Equal(t, 6000.0, activity.RecentDepositAmount)
+}
+
+func TestApplyTransitions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	t.Run("settlement records every step", func(t *testing.T) {
+		steps := []string{
+			models.InvestmentStatusCashReceived,
+			models.InvestmentStatusUnitsAllocated,
+			models.InvestmentStatusSettled,
+		}
+
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE investments SET status").
+			WithArgs("inv1", models.InvestmentStatusPending, models.InvestmentStatusSettled).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		from := models.InvestmentStatusPending
+		for _, step := range steps {
+			mock.ExpectExec("INSERT INTO investment_status_history").
+				WithArgs("inv1", from, step, "settlement").
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			// Note: Each step is posted to the ledger
+			mock.ExpectQuery("SELECT type, customer_id, fund_id, amount, units FROM investments WHERE id = \\$1").
+				WithArgs("inv1").
+				WillReturnRows(sqlmock.NewRows([]string{"type", "customer_id", "fund_id", "amount", "units"}).
+					AddRow(models.InvestmentTypeDeposit, "customer1", "fund1", 100.0, 80.0))
+			mock.ExpectExec("INSERT INTO ledger_accounts").
+				WillReturnResult(sqlmock.NewResult(0, 2))
+			mock.ExpectExec("INSERT INTO ledger_journals (.+) INSERT INTO ledger_postings").
+				WillReturnResult(sqlmock.NewResult(0, 2))
+			from = step
+		}
+		mock.ExpectCommit()
+
+		err := repo.applyTransitions(ctx, "inv1", models.InvestmentStatusPending, steps, "settlement")
+		assert.NoError(t, err)
+	})
+
+	t.Run("status changed concurrently", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE investments SET status").
+			WithArgs("inv1", models.InvestmentStatusPending, models.InvestmentStatusCancelled).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		err := repo.applyTransitions(ctx, "inv1", models.InvestmentStatusPending, []string{models.InvestmentStatusCancelled}, "")
+		assert.ErrorIs(t, err, isaerrors.ErrInvestmentStatusChanged)
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestTaxYear(t *testing.T) {
+	london := taxYearLocation
+
+	start, end := TaxYear(time.Date(2025, 4, 5, 23, 0, 0, 0, london))
+	assert.Equal(t, time.Date(2024, 4, 6, 0, 0, 0, 0, london), start)
+	assert.Equal(t, time.Date(2025, 4, 6, 0, 0, 0, 0, london), end)
+	assert.Equal(t, "2024/25", TaxYearName(start))
+
+	start, _ = TaxYear(time.Date(2025, 4, 6, 0, 30, 0, 0, london))
+	assert.Equal(t, "2025/26", TaxYearName(start))
+}
+
+func TestGetAllowance(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	service := NewService(NewRepository(db), nil, nil, nil, Allowances{Annual: 20000, Junior: 9000, Lifetime: 4000})
+	service.now = func() time.Time { return time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC) }
+	start, end := TaxYear(service.now())
+	productColumns := []string{"isa_product", "converted_at"}
+	subscriptionColumns := []string{"deposits", "lifetime", "current_year", "previous_years"}
+	eligibilityColumns := []string{"open", "can_contribute", "over_60"}
+
+	// Note: Previous years' transfers in are reported but do not use allowance
+	mock.ExpectQuery("SELECT isa_product, converted_at FROM retail_customers").
+		WithArgs("customer1").
+		WillReturnRows(sqlmock.NewRows(productColumns).AddRow(models.ISAProductStandard, nil))
+	mock.ExpectQuery("SELECT (.+) FROM investments i (.+) FROM isa_transfers").
+		WithArgs("customer1", start, end).
+		WillReturnRows(sqlmock.NewRows(subscriptionColumns).AddRow(5000.0, 0.0, 3000.0, 40000.0))
+
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM lifetime_isas").
+		WithArgs("customer1").
+		WillReturnRows(sqlmock.NewRows(eligibilityColumns).AddRow(false, true, false))
+
+	allowance, err := service.getAllowance(context.Background(), "customer1")
+	require.NoError(t, err)
+	assert.Equal(t, "2025/26", allowance.TaxYear)
+	assert.Equal(t, 8000.0, allowance.Subscribed)
+	assert.Equal(t, 40000.0, allowance.TransferredInPreviousYears)
+	assert.Equal(t, 12000.0, allowance.Remaining)
+
+	mock.ExpectQuery("SELECT isa_product, converted_at FROM retail_customers").
+		WithArgs("customer1").
+		WillReturnRows(sqlmock.NewRows(productColumns).AddRow(models.ISAProductStandard, nil))
+	mock.ExpectQuery("SELECT (.+) FROM investments i").
+		WithArgs("customer1", start, end).
+		WillReturnRows(sqlmock.NewRows(subscriptionColumns).AddRow(15000.0, 0.0, 3000.0, 0.0))
+
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM lifetime_isas").
+		WithArgs("customer1").
+		WillReturnRows(sqlmock.NewRows(eligibilityColumns).AddRow(false, true, false))
+
+	assert.ErrorIs(t, service.checkAllowance(context.Background(), service.repo, "customer1", models.ISAProductStandard, 2500), isaerrors.ErrAllowanceExceeded)
+
+	t.Run("junior isa limit", func(t *testing.T) {
+		mock.ExpectQuery("SELECT isa_product, converted_at FROM retail_customers").
+			WithArgs("child1").
+			WillReturnRows(sqlmock.NewRows(productColumns).AddRow(models.ISAProductJunior, nil))
+		mock.ExpectQuery("SELECT (.+) FROM investments i").
+			WithArgs("child1", start, end).
+			WillReturnRows(sqlmock.NewRows(subscriptionColumns).AddRow(6000.0, 0.0, 0.0, 0.0))
+
+		allowance, err := service.getAllowance(context.Background(), "child1")
+		require.NoError(t, err)
+		assert.Equal(t, 9000.0, allowance.Allowance)
+		assert.Equal(t, 3000.0, allowance.Remaining)
+	})
+
+	t.Run("converted this tax year", func(t *testing.T) {
+		convertedAt := time.Date(2025, 5, 10, 1, 0, 0, 0, time.UTC)
+		mock.ExpectQuery("SELECT isa_product, converted_at FROM retail_customers").
+			WithArgs("child1").
+			WillReturnRows(sqlmock.NewRows(productColumns).AddRow(models.ISAProductStandard, convertedAt))
+		mock.ExpectQuery("SELECT (.+) FROM investments i").
+			WithArgs("child1", convertedAt, end).
+			WillReturnRows(sqlmock.NewRows(subscriptionColumns).AddRow(1000.0, 0.0, 0.0, 0.0))
+
+		mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM lifetime_isas").
+			WithArgs("child1").
+			WillReturnRows(sqlmock.NewRows(eligibilityColumns).AddRow(false, true, false))
+
+		allowance, err := service.getAllowance(context.Background(), "child1")
+		require.NoError(t, err)
+		assert.Equal(t, 20000.0, allowance.Allowance)
+		assert.Equal(t, 19000.0, allowance.Remaining)
+	})
+
+	// Note: Lifetime ISA contributions use both limits, the lower remaining one applies
+	t.Run("lifetime isa limit", func(t *testing.T) {
+		mock.ExpectQuery("SELECT isa_product, converted_at FROM retail_customers").
+			WithArgs("customer2").
+			WillReturnRows(sqlmock.NewRows(productColumns).AddRow(models.ISAProductStandard, nil))
+		mock.ExpectQuery("SELECT (.+) FROM investments i").
+			WithArgs("customer2", start, end).
+			WillReturnRows(sqlmock.NewRows(subscriptionColumns).AddRow(5000.0, 3500.0, 0.0, 0.0))
+		mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM lifetime_isas").
+			WithArgs("customer2").
+			WillReturnRows(sqlmock.NewRows(eligibilityColumns).AddRow(true, true, false))
+
+		allowance, err := service.getAllowance(context.Background(), "customer2")
+		require.NoError(t, err)
+		require.NotNil(t, allowance.LifetimeISA)
+		assert.Equal(t, 15000.0, allowance.Remaining)
+		assert.Equal(t, 3500.0, allowance.LifetimeISA.Subscribed)
+		assert.Equal(t, 500.0, allowance.LifetimeISA.Remaining)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestResolveAccount(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	service := NewService(NewRepository(db), nil, nil, nil, Allowances{})
+	ctx := context.Background()
+	accountColumns := []string{"id", "customer_id", "product", "status", "opened_at", "closed_at"}
+	opened := time.Date(2025, 1, 2, 9, 0, 0, 0, time.UTC)
+
+	t.Run("defaults to the customer's isa", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM accounts WHERE customer_id = \\$1 AND product = ANY\\(\\$2\\)").
+			WithArgs("customer1", pq.Array([]string{models.ISAProductStandard, models.ISAProductJunior})).
+			WillReturnRows(sqlmock.NewRows(accountColumns).
+				AddRow("account1", "customer1", models.ISAProductStandard, models.AccountStatusOpen, opened, nil))
+
+		account, err := service.resolveAccount(ctx, &models.CreateInvestmentRequest{CustomerID: "customer1"})
+		require.NoError(t, err)
+		assert.Equal(t, "account1", account.ID)
+	})
+
+	t.Run("junior isa account takes standard isa investments", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM accounts WHERE id = \\$1").
+			WithArgs("account3").
+			WillReturnRows(sqlmock.NewRows(accountColumns).
+				AddRow("account3", "customer1", models.ISAProductJunior, models.AccountStatusOpen, opened, nil))
+
+		account, err := service.resolveAccount(ctx, &models.CreateInvestmentRequest{CustomerID: "customer1", AccountID: "account3", Product: models.ISAProductStandard})
+		require.NoError(t, err)
+		assert.Equal(t, models.ISAProductStandard, account.InvestmentProduct())
+	})
+
+	t.Run("no lifetime isa", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM accounts WHERE customer_id = \\$1 AND product = ANY\\(\\$2\\)").
+			WithArgs("customer1", pq.Array([]string{models.ISAProductLifetime})).
+			WillReturnError(sql.ErrNoRows)
+
+		_, err := service.resolveAccount(ctx, &models.CreateInvestmentRequest{CustomerID: "customer1", Product: models.ISAProductLifetime})
+		assert.ErrorIs(t, err, isaerrors.ErrLifetimeISANotOpen)
+	})
+
+	t.Run("another customer's account", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM accounts WHERE id = \\$1").
+			WithArgs("account2").
+			WillReturnRows(sqlmock.NewRows(accountColumns).
+				AddRow("account2", "customer2", models.ISAProductStandard, models.AccountStatusOpen, opened, nil))
+
+		_, err := service.resolveAccount(ctx, &models.CreateInvestmentRequest{CustomerID: "customer1", AccountID: "account2"})
+		assert.ErrorIs(t, err, isaerrors.ErrAccountNotFound)
+	})
+
+	t.Run("product does not match the account", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM accounts WHERE id = \\$1").
+			WithArgs("account1").
+			WillReturnRows(sqlmock.NewRows(accountColumns).
+				AddRow("account1", "customer1", models.ISAProductStandard, models.AccountStatusOpen, opened, nil))
+
+		_, err := service.resolveAccount(ctx, &models.CreateInvestmentRequest{CustomerID: "customer1", AccountID: "account1", Product: models.ISAProductLifetime})
+		assert.ErrorIs(t, err, isaerrors.ErrAccountProductMismatch)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
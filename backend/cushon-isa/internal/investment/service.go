@@ -2,31 +2,299 @@ package investment
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"time"
 
+	"github.com/stcol316/cushon-isa/internal/aml"
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/fund"
 	mw "github.com/stcol316/cushon-isa/internal/middleware"
 	"github.com/stcol316/cushon-isa/internal/models"
+	"github.com/stcol316/cushon-isa/internal/riskprofile"
 )
 
+// Note: Risk profiles older than this are still used but the customer is warned to review them
+const riskProfileReviewAge = 2 * 365 * 24 * time.Hour
+
 type Service struct {
-	repo *Repository
+	repo         *Repository
+	aml          *aml.Engine
+	funds        *fund.Service
+	riskProfiles *riskprofile.Service
+	allowances   Allowances
+	now          func() time.Time
 }
 
-func NewService(repo *Repository) *Service {
-	return &Service{repo: repo}
+func NewService(repo *Repository, amlEngine *aml.Engine, funds *fund.Service, riskProfiles *riskprofile.Service, allowances Allowances) *Service {
+	return &Service{repo: repo, aml: amlEngine, funds: funds, riskProfiles: riskProfiles, allowances: allowances, now: time.Now}
 }
 
 func (s *Service) createInvestment(ctx context.Context, req *models.CreateInvestmentRequest) (*models.Investment, error) {
+	if req.Amount <= 0 {
+		return nil, isaerrors.ErrInvalidAmount
+	}
+
 	investment := models.NewInvestment(req.CustomerID, req.FundID, req.Amount)
-	if err := s.repo.createInvestment(ctx, &investment); err != nil {
+	switch req.Type {
+	case "", models.InvestmentTypeDeposit:
+	case models.InvestmentTypeWithdrawal:
+		investment.Type = models.InvestmentTypeWithdrawal
+	default:
+		return nil, isaerrors.ErrInvalidInvestmentType
+	}
+
+	switch req.Product {
+	case "", models.ISAProductStandard, models.ISAProductLifetime:
+	default:
+		return nil, isaerrors.ErrInvalidISAProduct
+	}
+
+	eligibility, err := s.repo.getCustomerEligibility(ctx, req.CustomerID)
+	if err != nil {
+		return nil, err
+	}
+
+	account, err := s.resolveAccount(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	investment.AccountID = account.ID
+	investment.Product = account.InvestmentProduct()
+
+	if req.WithdrawalReason != "" && (investment.Product != models.ISAProductLifetime || investment.Type != models.InvestmentTypeWithdrawal) {
+		return nil, isaerrors.ErrInvalidWithdrawalReason
+	}
+
+	var lifetime *models.LifetimeISAEligibility
+	if investment.Product == models.ISAProductLifetime {
+		lifetime, err = s.repo.getLifetimeISAEligibility(ctx, req.CustomerID)
+		if err != nil {
+			return nil, err
+		}
+		if !lifetime.Open {
+			return nil, isaerrors.ErrLifetimeISANotOpen
+		}
+	}
+
+	// Note: Closed accounts and retired funds retain their investments so withdrawals are still allowed
+	if investment.Type == models.InvestmentTypeDeposit {
+		if err := checkDepositEligibility(eligibility); err != nil {
+			return nil, err
+		}
+		if account.Status != models.AccountStatusOpen {
+			return nil, isaerrors.ErrAccountClosed
+		}
+		if lifetime != nil && !lifetime.CanContribute {
+			return nil, isaerrors.ErrLifetimeISAContributionsEnded
+		}
+		if err := s.checkAllowance(ctx, s.repo, req.CustomerID, investment.Product, req.Amount); err != nil {
+			return nil, err
+		}
+		// Note: Retired funds keep their holdings but take no new money
+		fund, err := s.funds.GetOpenFund(ctx, req.FundID)
+		if err != nil {
+			if errors.Is(err, isaerrors.ErrFundNotFound) {
+				return nil, isaerrors.ErrInvalidInvestmentRef
+			}
+			return nil, err
+		}
+
+		// Note: The registered contact answers the risk questionnaire for a Junior ISA
+		profileCustomerID := req.CustomerID
+		if eligibility.Product == models.ISAProductJunior {
+			profileCustomerID = eligibility.RegisteredContactID
+		}
+		profile, err := s.riskProfiles.LatestProfile(ctx, profileCustomerID)
+		if err != nil {
+			return nil, err
+		}
+		investment.Warnings, investment.RiskAcknowledged, err = checkSuitability(profile, fund, req.RiskAcknowledged, s.now())
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		// Note: Junior ISA money belongs to the child and is locked in until they turn 18
+		if eligibility.Product == models.ISAProductJunior {
+			return nil, isaerrors.ErrJuniorISAWithdrawal
+		}
+		if err := checkBalance(ctx, s.repo, account.ID, req.FundID, req.Amount); err != nil {
+			return nil, err
+		}
+		if lifetime != nil {
+			investment.WithdrawalCharge, err = lifetimeISAWithdrawalCharge(req.Amount, req.WithdrawalReason, lifetime.Over60)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Note: AML screening. Held investments are created but excluded from totals until reviewed
+	velocityDays, rapidDays := s.aml.Windows()
+	activity, err := s.repo.getAMLActivity(ctx, req.CustomerID, velocityDays, rapidDays)
+	if err != nil {
+		return nil, err
+	}
+
+	decision := s.aml.Evaluate(aml.Transaction{
+		CustomerID: investment.CustomerID,
+		FundID:     investment.FundID,
+		Type:       investment.Type,
+		Amount:     investment.Amount,
+	}, *activity)
+
+	switch decision.Outcome {
+	case models.AMLOutcomeReject:
+		if err := s.repo.recordRejectedTransaction(ctx, req.CustomerID, req.Amount, &decision); err != nil {
+			return nil, err
+		}
+		return nil, isaerrors.ErrTransactionRejected
+	case models.AMLOutcomeHold:
+		investment.Status = models.InvestmentStatusHeld
+	}
+
+	// Note: Forward pricing. The order is priced later at the fund price for its dealing date
+	investment.DealingDate, err = s.funds.DealingDate(ctx, req.FundID, s.now())
+	if err != nil {
+		if errors.Is(err, isaerrors.ErrFundNotFound) {
+			return nil, isaerrors.ErrInvalidInvestmentRef
+		}
+		return nil, err
+	}
+
+	// Note: The checks above are repeated in the insert transaction so that concurrent deposits
+	// cannot each fit within the allowance and together exceed it, and concurrent withdrawals
+	// cannot each fit within the balance and together overdraw it
+	recheck := func(ctx context.Context, limits limitReader) error {
+		return checkBalance(ctx, limits, account.ID, req.FundID, req.Amount)
+	}
+	if investment.Type == models.InvestmentTypeDeposit {
+		recheck = func(ctx context.Context, limits limitReader) error {
+			return s.checkAllowance(ctx, limits, req.CustomerID, investment.Product, req.Amount)
+		}
+	}
+
+	if err := s.repo.createInvestment(ctx, &investment, &decision, recheck); err != nil {
 		return nil, fmt.Errorf("failed to make investment: %w", err)
 	}
 
 	return &investment, nil
 }
 
-func (s *Service) listInvestmentsByCustomerID(ctx context.Context, id string, page, pageSize int) (*mw.PaginatedResult, error) {
-	funds, total, err := s.repo.listInvestmentsByCustomerID(ctx, id, page, pageSize)
+func checkBalance(ctx context.Context, limits limitReader, accountID, fundID string, amount float64) error {
+	balance, err := limits.getAvailableBalance(ctx, accountID, fundID)
+	if err != nil {
+		return err
+	}
+	if amount > balance {
+		return isaerrors.ErrInsufficientBalance
+	}
+	return nil
+}
+
+// Note: Investments go into the given account, or the customer's account for the product when none
+// is given. The account must belong to the customer and match the product if one was asked for
+func (s *Service) resolveAccount(ctx context.Context, req *models.CreateInvestmentRequest) (*models.Account, error) {
+	if req.AccountID == "" {
+		product := req.Product
+		if product == "" {
+			product = models.ISAProductStandard
+		}
+		account, err := s.repo.getCustomerAccount(ctx, req.CustomerID, product)
+		if errors.Is(err, isaerrors.ErrAccountNotFound) && product == models.ISAProductLifetime {
+			return nil, isaerrors.ErrLifetimeISANotOpen
+		}
+		return account, err
+	}
+
+	account, err := s.repo.getAccount(ctx, req.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	if account.CustomerID != req.CustomerID {
+		return nil, isaerrors.ErrAccountNotFound
+	}
+	if req.Product != "" && req.Product != account.InvestmentProduct() {
+		return nil, isaerrors.ErrAccountProductMismatch
+	}
+	return account, nil
+}
+
+// CheckDepositEligibility is used by other services that put money into a customer's ISA
+func (s *Service) CheckDepositEligibility(ctx context.Context, customerID string) error {
+	eligibility, err := s.repo.getCustomerEligibility(ctx, customerID)
+	if err != nil {
+		return err
+	}
+	return checkDepositEligibility(eligibility)
+}
+
+func checkDepositEligibility(eligibility *models.CustomerEligibility) error {
+	if eligibility.Status != models.CustomerStatusActive {
+		return isaerrors.ErrCustomerClosed
+	}
+	if !eligibility.IsComplete() {
+		return isaerrors.ErrEligibilityIncomplete
+	}
+	if eligibility.KYCStatus != models.KYCStatusVerified {
+		return isaerrors.ErrIdentityNotVerified
+	}
+	return nil
+}
+
+// Note: Suitability check. Investing in a fund riskier than the customer's profile is allowed
+// but only once they have explicitly acknowledged it. Missing or stale profiles only produce warnings.
+// The returned bool reports whether an acknowledgement was needed and given
+func checkSuitability(profile *models.RiskProfile, fund *models.Fund, acknowledged bool, now time.Time) ([]string, bool, error) {
+	var warnings []string
+
+	if profile == nil {
+		return append(warnings, "customer has not completed the risk questionnaire"), false, nil
+	}
+	if now.Sub(profile.CompletedAt) > riskProfileReviewAge {
+		warnings = append(warnings, "risk profile is more than two years old and should be reviewed")
+	}
+
+	// Note: Risk level IDs are ordered from lowest to highest risk
+	if fund.RiskLevel != nil && fund.RiskLevel.ID > profile.RiskLevel.ID {
+		if !acknowledged {
+			return nil, false, isaerrors.ErrRiskAcknowledgementRequired
+		}
+		warnings = append(warnings, fmt.Sprintf("fund risk level %s is higher than the customer's %s risk profile",
+			fund.RiskLevel.Name, profile.RiskLevel.Name))
+		return warnings, true, nil
+	}
+
+	return warnings, false, nil
+}
+
+func validateInvestmentFilter(filter *models.InvestmentFilter) error {
+	if filter.From != nil && filter.To != nil && filter.From.After(*filter.To) {
+		return isaerrors.Validation("invalid_date_range", "from cannot be after to")
+	}
+	switch filter.Type {
+	case "", models.InvestmentTypeDeposit, models.InvestmentTypeWithdrawal, models.InvestmentTypeCharge, models.InvestmentTypeTransferOut:
+	default:
+		return isaerrors.Validation("invalid_type_filter", "type must be deposit, withdrawal, charge or transfer_out")
+	}
+	if filter.Status != "" && !statuses[filter.Status] {
+		return isaerrors.Validation("invalid_status_filter", "status is not a valid investment status")
+	}
+	return nil
+}
+
+func (s *Service) listInvestmentsByCustomerID(ctx context.Context, id string, filter *models.InvestmentFilter, params mw.PaginationParams) (*mw.PaginatedResult, error) {
+	if err := validateInvestmentFilter(filter); err != nil {
+		return nil, err
+	}
+
+	if params.CursorMode {
+		return s.listInvestmentsByCustomerIDAfter(ctx, id, filter, params.Cursor, params.PageSize)
+	}
+
+	page, pageSize := params.Page, params.PageSize
+	funds, total, err := s.repo.listInvestmentsByCustomerID(ctx, id, filter, page, pageSize)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list funds: %w", err)
 	}
@@ -39,16 +307,130 @@ func (s *Service) listInvestmentsByCustomerID(ctx context.Context, id string, pa
 	}
 	result.Pagination.CurrentPage = page
 	result.Pagination.PageSize = pageSize
-	result.Pagination.TotalItems = total
-	result.Pagination.TotalPages = totalPages
+	result.Pagination.TotalItems = &total
+	result.Pagination.TotalPages = &totalPages
 	result.Pagination.HasNext = page < totalPages
 	result.Pagination.HasPrevious = page > 1
 
 	return result, nil
 }
 
+// Note: Cursor mode. One extra row is fetched to tell whether there is a next page
+// without a second query. Current page is not meaningful here so is left as zero
+func (s *Service) listInvestmentsByCustomerIDAfter(ctx context.Context, id string, filter *models.InvestmentFilter, cursor *mw.Cursor, pageSize int) (*mw.PaginatedResult, error) {
+	var afterCreatedAt *time.Time
+	var afterID string
+	if cursor != nil {
+		afterCreatedAt, afterID = &cursor.CreatedAt, cursor.ID
+	}
+
+	investments, total, err := s.repo.listInvestmentsByCustomerIDAfter(ctx, id, filter, afterCreatedAt, afterID, pageSize+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list investments: %w", err)
+	}
+
+	hasNext := len(investments) > pageSize
+	if hasNext {
+		investments = investments[:pageSize]
+	}
+
+	result := &mw.PaginatedResult{
+		Data: investments,
+	}
+	result.Pagination.PageSize = pageSize
+	if total != nil {
+		totalPages := (*total + pageSize - 1) / pageSize
+		result.Pagination.TotalItems = total
+		result.Pagination.TotalPages = &totalPages
+	}
+	result.Pagination.HasNext = hasNext
+	result.Pagination.HasPrevious = cursor != nil
+	if hasNext {
+		last := investments[len(investments)-1]
+		result.Pagination.NextCursor = mw.EncodeCursor(last.CreatedAt, last.ID)
+	}
+
+	return result, nil
+}
+
+// Note: Export of the same history the list endpoint returns, without pagination
+func (s *Service) exportInvestmentsByCustomerID(ctx context.Context, id string, filter *models.InvestmentFilter, fn func(*models.Investment) error) error {
+	if err := validateInvestmentFilter(filter); err != nil {
+		return err
+	}
+	return s.repo.streamInvestmentsByCustomerID(ctx, id, filter, fn)
+}
+
 func (s *Service) getInvestmentByID(ctx context.Context, id string) (*models.Investment, error) {
-	return s.repo.getInvestmentByID(ctx, id)
+	investment, err := s.repo.getInvestmentByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	investment.StatusHistory, err = s.repo.getStatusHistory(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return investment, nil
+}
+
+// Note: Manual status changes, e.g. operations marking an investment as failed
+func (s *Service) transitionInvestment(ctx context.Context, id string, req *models.TransitionInvestmentRequest) (*models.Investment, error) {
+	investment, err := s.repo.getInvestmentByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !canTransition(investment.Type, investment.Status, req.Status) {
+		return nil, isaerrors.ErrInvalidStatusTransition
+	}
+	if req.Status == models.InvestmentStatusUnitsAllocated && investment.UnitPrice == nil {
+		return nil, isaerrors.ErrInvestmentNotPriced
+	}
+
+	if err := s.repo.applyTransitions(ctx, id, investment.Status, []string{req.Status}, req.Reason); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.refreshCustomerTotals(ctx); err != nil {
+		return nil, err
+	}
+
+	return s.getInvestmentByID(ctx, id)
+}
+
+// Note: Settles every investment placed before the cut-off.
+// A failure on one investment is logged and does not stop the rest of the batch
+func (s *Service) runSettlement(ctx context.Context, cutoff time.Time) (*models.SettlementRun, error) {
+	investments, err := s.repo.listInvestmentsForSettlement(ctx, cutoff)
+	if err != nil {
+		return nil, err
+	}
+
+	run := &models.SettlementRun{Cutoff: cutoff}
+	for _, investment := range investments {
+		path := settlementPath(investment.Type, investment.Status)
+		if err := s.repo.applyTransitions(ctx, investment.ID, investment.Status, path, "settlement"); err != nil {
+			log.Printf("Failed to settle investment %s: %v", investment.ID, err)
+			run.Errors++
+			continue
+		}
+		run.Settled++
+	}
+
+	if run.Settled > 0 {
+		if err := s.repo.refreshCustomerTotals(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	log.Printf("Settlement run for cut-off %s: %d settled, %d errors", cutoff.Format(time.RFC3339), run.Settled, run.Errors)
+	return run, nil
+}
+
+func (s *Service) getAccountFundTotal(ctx context.Context, accountID, fundID string) (*models.InvestmentSummary, error) {
+	return s.repo.getAccountFundTotal(ctx, accountID, fundID)
 }
 
 func (s *Service) getCustomerFundTotal(ctx context.Context, customer_id, fund_id string) (*models.InvestmentSummary, error) {
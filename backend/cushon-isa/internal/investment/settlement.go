@@ -0,0 +1,79 @@
+package investment
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/stcol316/cushon-isa/internal/models"
+)
+
+// Note: Runs the settlement batch once a day at the dealing cut-off
+type SettlementScheduler struct {
+	service  *Service
+	hour     int
+	minute   int
+	location *time.Location
+}
+
+// NewSettlementScheduler takes the cut-off as HH:MM in the given IANA time zone
+func NewSettlementScheduler(service *Service, cutoff, timezone string) (*SettlementScheduler, error) {
+	parsed, err := time.Parse("15:04", cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("invalid settlement cut-off %q: %w", cutoff, err)
+	}
+
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid settlement time zone %q: %w", timezone, err)
+	}
+
+	return &SettlementScheduler{
+		service:  service,
+		hour:     parsed.Hour(),
+		minute:   parsed.Minute(),
+		location: location,
+	}, nil
+}
+
+// LatestCutoff returns the most recent cut-off at or before now
+func (s *SettlementScheduler) LatestCutoff(now time.Time) time.Time {
+	now = now.In(s.location)
+	cutoff := time.Date(now.Year(), now.Month(), now.Day(), s.hour, s.minute, 0, 0, s.location)
+	if cutoff.After(now) {
+		cutoff = cutoff.AddDate(0, 0, -1)
+	}
+	return cutoff
+}
+
+func (s *SettlementScheduler) nextCutoff(now time.Time) time.Time {
+	return s.LatestCutoff(now).AddDate(0, 0, 1)
+}
+
+// Note: Settlement go routine. Stops when the context is cancelled
+func (s *SettlementScheduler) Start(ctx context.Context) {
+	go func() {
+		for {
+			next := s.nextCutoff(time.Now())
+			log.Printf("Next settlement run at %s", next.Format(time.RFC3339))
+
+			timer := time.NewTimer(time.Until(next))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			if _, err := s.service.runSettlement(ctx, next); err != nil {
+				log.Printf("Settlement run failed: %v", err)
+			}
+		}
+	}()
+}
+
+// RunNow settles everything up to the most recent cut-off
+func (s *SettlementScheduler) RunNow(ctx context.Context) (*models.SettlementRun, error) {
+	return s.service.runSettlement(ctx, s.LatestCutoff(time.Now()))
+}
@@ -0,0 +1,114 @@
+package kyc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/models"
+	helper "github.com/stcol316/cushon-isa/pkg/helpers"
+)
+
+// Note: Providers sign the raw callback body with our shared secret, e.g. X-KYC-Signature: sha256=<hex>
+const (
+	signatureHeader = "X-KYC-Signature"
+	signaturePrefix = "sha256="
+	// Note: Callbacks are small, anything larger is not from the provider
+	maxCallbackBytes = 64 << 10
+)
+
+type Handler struct {
+	service        *Service
+	callbackSecret []byte
+}
+
+func NewHandler(service *Service, callbackSecret string) *Handler {
+	return &Handler{service: service, callbackSecret: []byte(callbackSecret)}
+}
+
+func (h *Handler) SubmitVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "invalid customer ID format")
+		return
+	}
+
+	verification, err := h.service.submitVerification(r.Context(), id)
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusAccepted, verification)
+}
+
+func (h *Handler) GetVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "invalid customer ID format")
+		return
+	}
+
+	verification, err := h.service.getLatestVerification(r.Context(), id)
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, verification)
+}
+
+// Note: Only the provider can report an outcome. Customers can see their provider reference so a
+// user JWT is not enough, the body must be signed with the shared callback secret
+func (h *Handler) ProviderCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+		helper.RespondWithError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/json")
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxCallbackBytes))
+	if err != nil {
+		helper.RespondWithProblem(w, r, isaerrors.ErrInvalidRequestBody.Wrap(err))
+		return
+	}
+	if !h.validSignature(body, r.Header.Get(signatureHeader)) {
+		helper.RespondWithError(w, http.StatusUnauthorized, "invalid callback signature")
+		return
+	}
+
+	req := new(models.KYCCallbackRequest)
+	if err := json.Unmarshal(body, req); err != nil {
+		helper.RespondWithProblem(w, r, isaerrors.ErrInvalidRequestBody.Wrap(err))
+		return
+	}
+
+	verification, err := h.service.handleCallback(r.Context(), req)
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, verification)
+}
+
+// validSignature checks the body was signed with the callback secret. Without a secret nothing is accepted
+func (h *Handler) validSignature(body []byte, header string) bool {
+	if len(h.callbackSecret) == 0 || !strings.HasPrefix(header, signaturePrefix) {
+		return false
+	}
+	signature, err := hex.DecodeString(strings.TrimPrefix(header, signaturePrefix))
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, h.callbackSecret)
+	mac.Write(body)
+	return hmac.Equal(signature, mac.Sum(nil))
+}
@@ -0,0 +1,32 @@
+package kyc
+
+import (
+	"context"
+
+	"github.com/stcol316/cushon-isa/internal/models"
+)
+
+// Note: Pluggable identity verification provider.
+// Real providers are usually asynchronous so Submit may return a pending result
+// with the final outcome delivered later via the callback endpoint
+type KYCProvider interface {
+	Name() string
+	Submit(ctx context.Context, subject VerificationSubject) (*VerificationResult, error)
+}
+
+// VerificationSubject holds the personal data sent to the provider
+type VerificationSubject struct {
+	CustomerID  string
+	FirstName   string
+	LastName    string
+	DateOfBirth string
+	NINumber    string
+	Address     models.Address
+	KYCStatus   string
+}
+
+type VerificationResult struct {
+	Reference string
+	Status    string
+	Reason    string
+}
@@ -0,0 +1,186 @@
+package kyc
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/models"
+)
+
+type KYCRepository interface {
+	GetVerificationSubject(ctx context.Context, customerID string) (*subjectRecord, error)
+	CreateVerification(ctx context.Context, verification *models.KYCVerification) error
+	GetVerificationByReference(ctx context.Context, provider, reference string) (*models.KYCVerification, error)
+	GetLatestVerification(ctx context.Context, customerID string) (*models.KYCVerification, error)
+	UpdateVerificationStatus(ctx context.Context, verification *models.KYCVerification) error
+}
+
+// subjectRecord is the stored customer data needed to build a VerificationSubject
+type subjectRecord struct {
+	VerificationSubject
+	EncryptedNINumber string
+	CustomerStatus    string
+}
+
+type Repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) getVerificationSubject(ctx context.Context, customerID string) (*subjectRecord, error) {
+	query := `
+	SELECT id, COALESCE(first_name, ''), COALESCE(last_name, ''),
+		COALESCE(TO_CHAR(date_of_birth, 'YYYY-MM-DD'), ''), COALESCE(ni_number_encrypted, ''),
+		COALESCE(address_line1, ''), COALESCE(address_line2, ''), COALESCE(city, ''),
+		COALESCE(postcode, ''), COALESCE(country, ''), kyc_status, status
+	FROM retail_customers
+	WHERE id = $1
+`
+	var record subjectRecord
+	err := r.db.QueryRowContext(ctx, query, customerID).Scan(
+		&record.CustomerID,
+		&record.FirstName,
+		&record.LastName,
+		&record.DateOfBirth,
+		&record.EncryptedNINumber,
+		&record.Address.Line1,
+		&record.Address.Line2,
+		&record.Address.City,
+		&record.Address.Postcode,
+		&record.Address.Country,
+		&record.KYCStatus,
+		&record.CustomerStatus,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, isaerrors.ErrCustomerNotFound
+		}
+		return nil, fmt.Errorf("failed to get customer: %w", err)
+	}
+
+	return &record, nil
+}
+
+// Note: The verification record and the customer's denormalised status are written together
+func (r *Repository) createVerification(ctx context.Context, verification *models.KYCVerification) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+	INSERT INTO kyc_verifications (customer_id, provider, provider_reference, status, reason)
+	VALUES ($1, $2, $3, $4, NULLIF($5, ''))
+	RETURNING id, created_at, updated_at
+`,
+		verification.CustomerID,
+		verification.Provider,
+		verification.ProviderReference,
+		verification.Status,
+		verification.Reason,
+	).Scan(&verification.ID, &verification.CreatedAt, &verification.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create verification: %w", err)
+	}
+
+	if err := setCustomerKYCStatus(ctx, tx, verification.CustomerID, verification.Status); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) getVerificationByReference(ctx context.Context, provider, reference string) (*models.KYCVerification, error) {
+	query := `
+	SELECT id, customer_id, provider, provider_reference, status, COALESCE(reason, ''), created_at, updated_at
+	FROM kyc_verifications
+	WHERE provider = $1 AND provider_reference = $2
+`
+	return scanVerification(r.db.QueryRowContext(ctx, query, provider, reference))
+}
+
+func (r *Repository) getLatestVerification(ctx context.Context, customerID string) (*models.KYCVerification, error) {
+	query := `
+	SELECT id, customer_id, provider, provider_reference, status, COALESCE(reason, ''), created_at, updated_at
+	FROM kyc_verifications
+	WHERE customer_id = $1
+	ORDER BY created_at DESC
+	LIMIT 1
+`
+	return scanVerification(r.db.QueryRowContext(ctx, query, customerID))
+}
+
+func (r *Repository) updateVerificationStatus(ctx context.Context, verification *models.KYCVerification) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+	UPDATE kyc_verifications
+	SET status = $2, reason = NULLIF($3, ''), updated_at = CURRENT_TIMESTAMP
+	WHERE id = $1
+	RETURNING updated_at
+`, verification.ID, verification.Status, verification.Reason).Scan(&verification.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return isaerrors.ErrVerificationNotFound
+		}
+		return fmt.Errorf("failed to update verification: %w", err)
+	}
+
+	if err := setCustomerKYCStatus(ctx, tx, verification.CustomerID, verification.Status); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func setCustomerKYCStatus(ctx context.Context, tx *sql.Tx, customerID, status string) error {
+	_, err := tx.ExecContext(ctx, `
+	UPDATE retail_customers
+	SET kyc_status = $2, updated_at = CURRENT_TIMESTAMP
+	WHERE id = $1
+`, customerID, status)
+	if err != nil {
+		return fmt.Errorf("failed to update customer kyc status: %w", err)
+	}
+	return nil
+}
+
+func scanVerification(row *sql.Row) (*models.KYCVerification, error) {
+	var verification models.KYCVerification
+	err := row.Scan(
+		&verification.ID,
+		&verification.CustomerID,
+		&verification.Provider,
+		&verification.ProviderReference,
+		&verification.Status,
+		&verification.Reason,
+		&verification.CreatedAt,
+		&verification.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, isaerrors.ErrVerificationNotFound
+		}
+		return nil, fmt.Errorf("failed to get verification: %w", err)
+	}
+
+	return &verification, nil
+}
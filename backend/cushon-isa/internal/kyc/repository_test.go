@@ -0,0 +1,187 @@
+package kyc
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-chi/jwtauth/v5"
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository_GetVerificationSubject(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	t.Run("successful retrieval", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{
+			"id", "first_name", "last_name", "date_of_birth", "ni_number_encrypted",
+			"address_line1", "address_line2", "city", "postcode", "country", "kyc_status", "status",
+		}).AddRow("1", "John", "Doe", "1990-01-31", "encrypted", "1 High St", "", "London", "SW1A 1AA", "UK",
+			models.KYCStatusNotStarted, models.CustomerStatusActive)
+
+		mock.ExpectQuery("SELECT (.+) FROM retail_customers").
+			WithArgs("1").
+			WillReturnRows(rows)
+
+		record, err := repo.getVerificationSubject(ctx, "1")
+		assert.NoError(t, err)
+		assert.Equal(t, "Doe", record.LastName)
+		assert.Equal(t, "encrypted", record.EncryptedNINumber)
+		assert.Equal(t, models.KYCStatusNotStarted, record.KYCStatus)
+	})
+
+	t.Run("customer not found", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM retail_customers").
+			WithArgs("missing").
+			WillReturnError(sql.ErrNoRows)
+
+		_, err := repo.getVerificationSubject(ctx, "missing")
+		assert.ErrorIs(t, err, isaerrors.ErrCustomerNotFound)
+	})
+}
+
+func TestRepository_CreateVerification(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	now := time.Now()
+
+	verification := &models.KYCVerification{
+		CustomerID:        "customer1",
+		Provider:          "stub",
+		ProviderReference: "ref1",
+		Status:            models.KYCStatusVerified,
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO kyc_verifications").
+		WithArgs("customer1", "stub", "ref1", models.KYCStatusVerified, "").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).AddRow("v1", now, now))
+	mock.ExpectExec("UPDATE retail_customers SET kyc_status").
+		WithArgs("customer1", models.KYCStatusVerified).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = repo.createVerification(context.Background(), verification)
+	assert.NoError(t, err)
+	assert.Equal(t, "v1", verification.ID)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRepository_UpdateVerificationStatus(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	verification := &models.KYCVerification{
+		ID:         "v1",
+		CustomerID: "customer1",
+		Status:     models.KYCStatusRejected,
+		Reason:     "document mismatch",
+	}
+
+	t.Run("status updated on verification and customer", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("UPDATE kyc_verifications").
+			WithArgs("v1", models.KYCStatusRejected, "document mismatch").
+			WillReturnRows(sqlmock.NewRows([]string{"updated_at"}).AddRow(time.Now()))
+		mock.ExpectExec("UPDATE retail_customers SET kyc_status").
+			WithArgs("customer1", models.KYCStatusRejected).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := repo.updateVerificationStatus(ctx, verification)
+		assert.NoError(t, err)
+	})
+
+	t.Run("verification not found", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("UPDATE kyc_verifications").
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
+
+		err := repo.updateVerificationStatus(ctx, verification)
+		assert.ErrorIs(t, err, isaerrors.ErrVerificationNotFound)
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestProviderCallbackHandler(t *testing.T) {
+	handler := NewHandler(NewService(nil, NewStubProvider(), nil), "callback-secret")
+	sign := func(body, secret string) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(body))
+		return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+	}
+	callback := func(body, signature, token string) int {
+		req := httptest.NewRequest(http.MethodPost, "/v1/kyc/callbacks", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if signature != "" {
+			req.Header.Set(signatureHeader, signature)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ProviderCallbackHandler(rec, req)
+		return rec.Code
+	}
+
+	forged := `{"providerReference": "stub-123", "status": "verified"}`
+
+	t.Run("customer token is not enough", func(t *testing.T) {
+		_, token, err := jwtauth.New("HS256", []byte("secret"), nil).Encode(map[string]interface{}{"user_id": 123, "role": "customer"})
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, callback(forged, "", token))
+	})
+
+	t.Run("signed with the wrong secret", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, callback(forged, sign(forged, "guessed"), ""))
+	})
+
+	t.Run("body changed after signing", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, callback(forged, sign(`{"providerReference": "stub-123", "status": "rejected"}`, "callback-secret"), ""))
+	})
+
+	t.Run("signed by the provider", func(t *testing.T) {
+		// Note: Reaches the service, which rejects the empty callback before touching the database
+		assert.Equal(t, http.StatusBadRequest, callback(`{}`, sign(`{}`, "callback-secret"), ""))
+	})
+
+	t.Run("no secret configured", func(t *testing.T) {
+		unconfigured := NewHandler(NewService(nil, NewStubProvider(), nil), "")
+		req := httptest.NewRequest(http.MethodPost, "/v1/kyc/callbacks", strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(signatureHeader, sign(`{}`, ""))
+		rec := httptest.NewRecorder()
+		unconfigured.ProviderCallbackHandler(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
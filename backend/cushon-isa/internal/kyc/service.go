@@ -0,0 +1,128 @@
+package kyc
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/stcol316/cushon-isa/internal/encryption"
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/models"
+)
+
+// Note: Verification state machine
+// not_started -> pending | verified | referred | rejected (providers may resolve synchronously)
+// pending     -> verified | referred | rejected
+// referred    -> pending | verified | rejected (resubmission or outcome of manual review)
+// verified and rejected are terminal
+var transitions = map[string]map[string]bool{
+	models.KYCStatusNotStarted: {
+		models.KYCStatusPending:  true,
+		models.KYCStatusVerified: true,
+		models.KYCStatusReferred: true,
+		models.KYCStatusRejected: true,
+	},
+	models.KYCStatusPending: {
+		models.KYCStatusVerified: true,
+		models.KYCStatusReferred: true,
+		models.KYCStatusRejected: true,
+	},
+	models.KYCStatusReferred: {
+		models.KYCStatusPending:  true,
+		models.KYCStatusVerified: true,
+		models.KYCStatusRejected: true,
+	},
+}
+
+func canTransition(from, to string) bool {
+	return transitions[from][to]
+}
+
+type Service struct {
+	repo     *Repository
+	provider KYCProvider
+	cipher   *encryption.Cipher
+}
+
+func NewService(repo *Repository, provider KYCProvider, cipher *encryption.Cipher) *Service {
+	return &Service{repo: repo, provider: provider, cipher: cipher}
+}
+
+func (s *Service) submitVerification(ctx context.Context, customerID string) (*models.KYCVerification, error) {
+	record, err := s.repo.getVerificationSubject(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch record.CustomerStatus {
+	case models.CustomerStatusErased:
+		return nil, isaerrors.ErrCustomerErased
+	case models.CustomerStatusClosed:
+		return nil, isaerrors.ErrCustomerClosed
+	}
+
+	// Resubmission from referred goes back through pending
+	if !canTransition(record.KYCStatus, models.KYCStatusPending) {
+		return nil, isaerrors.ErrInvalidKYCTransition
+	}
+
+	if record.FirstName == "" || record.LastName == "" || record.DateOfBirth == "" || record.EncryptedNINumber == "" {
+		return nil, isaerrors.ErrVerificationDataIncomplete
+	}
+
+	subject := record.VerificationSubject
+	subject.NINumber, err = s.cipher.Decrypt(record.EncryptedNINumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt national insurance number: %w", err)
+	}
+
+	result, err := s.provider.Submit(ctx, subject)
+	if err != nil {
+		return nil, fmt.Errorf("failed to submit verification to %s: %w", s.provider.Name(), err)
+	}
+
+	verification := &models.KYCVerification{
+		CustomerID:        customerID,
+		Provider:          s.provider.Name(),
+		ProviderReference: result.Reference,
+		Status:            result.Status,
+		Reason:            result.Reason,
+	}
+	if err := s.repo.createVerification(ctx, verification); err != nil {
+		return nil, fmt.Errorf("failed to record verification: %w", err)
+	}
+
+	return verification, nil
+}
+
+func (s *Service) getLatestVerification(ctx context.Context, customerID string) (*models.KYCVerification, error) {
+	return s.repo.getLatestVerification(ctx, customerID)
+}
+
+// Note: Providers call back with the outcome of asynchronous checks or manual reviews
+func (s *Service) handleCallback(ctx context.Context, req *models.KYCCallbackRequest) (*models.KYCVerification, error) {
+	if req.ProviderReference == "" || req.Status == "" {
+		return nil, isaerrors.Validation("invalid_callback", "providerReference and status are required")
+	}
+
+	provider := req.Provider
+	if provider == "" {
+		provider = s.provider.Name()
+	}
+
+	verification, err := s.repo.getVerificationByReference(ctx, provider, req.ProviderReference)
+	if err != nil {
+		return nil, err
+	}
+
+	if !canTransition(verification.Status, req.Status) {
+		return nil, isaerrors.ErrInvalidKYCTransition
+	}
+
+	verification.Status = req.Status
+	verification.Reason = req.Reason
+	if err := s.repo.updateVerificationStatus(ctx, verification); err != nil {
+		return nil, fmt.Errorf("failed to update verification: %w", err)
+	}
+
+	return verification, nil
+}
@@ -0,0 +1,45 @@
+package kyc
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/stcol316/cushon-isa/internal/models"
+)
+
+// Note: Deterministic provider for development and tests.
+// The outcome is driven by the customer's last name so every state can be exercised:
+//   - "Refer"   -> referred
+//   - "Reject"  -> rejected
+//   - "Pending" -> pending, to be resolved via the callback endpoint
+//   - anything else -> verified
+type StubProvider struct{}
+
+func NewStubProvider() *StubProvider {
+	return &StubProvider{}
+}
+
+func (p *StubProvider) Name() string {
+	return "stub"
+}
+
+func (p *StubProvider) Submit(ctx context.Context, subject VerificationSubject) (*VerificationResult, error) {
+	result := &VerificationResult{
+		Reference: uuid.NewString(),
+		Status:    models.KYCStatusVerified,
+	}
+
+	switch strings.ToLower(subject.LastName) {
+	case "refer":
+		result.Status = models.KYCStatusReferred
+		result.Reason = "manual review required"
+	case "reject":
+		result.Status = models.KYCStatusRejected
+		result.Reason = "identity could not be verified"
+	case "pending":
+		result.Status = models.KYCStatusPending
+	}
+
+	return result, nil
+}
@@ -0,0 +1,37 @@
+package ledger
+
+import (
+	"net/http"
+
+	helper "github.com/stcol316/cushon-isa/pkg/helpers"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+// Note: Admin only. Proves the ledger balances and agrees with the investments
+func (h *Handler) CheckLedgerHandler(w http.ResponseWriter, r *http.Request) {
+	check, err := h.service.check(r.Context())
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, check)
+}
+
+// Note: Admin only. Posts journals for investments that pre-date the ledger
+func (h *Handler) BackfillLedgerHandler(w http.ResponseWriter, r *http.Request) {
+	result, err := h.service.backfill(r.Context())
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, result)
+}
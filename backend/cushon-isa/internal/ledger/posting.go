@@ -0,0 +1,289 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+
+	"github.com/lib/pq"
+	"github.com/stcol316/cushon-isa/internal/models"
+)
+
+// Account types, see the ledger migration for what each one holds
+const (
+	AccountClientMoneyBank       = "client_money_bank"
+	AccountCustomerCash          = "customer_cash"
+	AccountCustomerPayable       = "customer_payable"
+	AccountCustomerUnits         = "customer_units"
+	AccountFundManagerSettlement = "fund_manager_settlement"
+	AccountFundUnits             = "fund_units"
+	AccountHMRCPayable           = "hmrc_payable"
+	AccountPlatformFeeIncome     = "platform_fee_income"
+)
+
+// Journal entry types
+const (
+	EntryReversal    = "reversal"
+	EntryCharge      = "charge"
+	EntryTransferOut = "transfer_out"
+)
+
+// posting is one side of a journal. Debits are positive, credits negative
+type posting struct {
+	accountType string
+	customerID  string
+	fundID      string
+	amount      float64
+	units       float64
+}
+
+// movement is the part of an investment a journal is built from
+type movement struct {
+	investmentType string
+	customerID     string
+	fundID         string
+	amount         float64
+	units          float64
+	// Note: Lifetime ISA withdrawal charge, kept back from the customer's payout for HMRC
+	withdrawalCharge float64
+	// Note: Set for workplace pensions, customerID then holds the employee
+	employee bool
+}
+
+// RecordStatusChange is used by every package that moves an investment between statuses. The change
+// is written to the status history and posted to the ledger in the caller's transaction
+func RecordStatusChange(ctx context.Context, tx *sql.Tx, investmentID, from, to, reason string) error {
+	_, err := tx.ExecContext(ctx, `
+	INSERT INTO investment_status_history (investment_id, from_status, to_status, reason)
+	VALUES ($1, NULLIF($2, ''), $3, NULLIF($4, ''))
+`, investmentID, from, to, reason)
+	if err != nil {
+		return fmt.Errorf("failed to record status change: %w", err)
+	}
+	return PostStatusChange(ctx, tx, investmentID, from, to)
+}
+
+// Note: Posts the journal for an investment moving from one status to another. Called in the same
+// transaction as the status change so the ledger can never disagree with the investment.
+// Statuses that do not move money or units (held, pending, cancelled) post nothing
+func PostStatusChange(ctx context.Context, tx *sql.Tx, investmentID, from, to string) error {
+	switch to {
+	case models.InvestmentStatusCashReceived, models.InvestmentStatusUnitsAllocated, models.InvestmentStatusSettled:
+	case models.InvestmentStatusFailed:
+		if from == models.InvestmentStatusPending || from == models.InvestmentStatusHeld {
+			return nil
+		}
+		return postReversal(ctx, tx, investmentID)
+	default:
+		return nil
+	}
+
+	var m movement
+	var customerID sql.NullString
+	var units sql.NullFloat64
+	err := tx.QueryRowContext(ctx, `
+        SELECT type, customer_id, fund_id, amount, units
+        FROM investments
+        WHERE id = $1
+    `, investmentID).Scan(&m.investmentType, &customerID, &m.fundID, &m.amount, &units)
+	if err != nil {
+		return fmt.Errorf("failed to get investment for ledger: %w", err)
+	}
+	// Note: Investments placed before forward pricing have no units and are held at cost
+	m.units = units.Float64
+	m.customerID = customerID.String
+
+	// Note: Pension investments have no customer, their holder accounts belong to the employee
+	if !customerID.Valid {
+		err := tx.QueryRowContext(ctx, `SELECT employee_id FROM investments WHERE id = $1`, investmentID).Scan(&m.customerID)
+		if err != nil {
+			return fmt.Errorf("failed to get investment holder for ledger: %w", err)
+		}
+		m.employee = true
+	}
+
+	// Note: Only Lifetime ISA withdrawals carry a charge and it is only needed when the proceeds are paid out
+	if m.investmentType == models.InvestmentTypeWithdrawal && to == models.InvestmentStatusSettled {
+		err := tx.QueryRowContext(ctx, `SELECT COALESCE(withdrawal_charge, 0) FROM investments WHERE id = $1`, investmentID).Scan(&m.withdrawalCharge)
+		if err != nil {
+			return fmt.Errorf("failed to get withdrawal charge for ledger: %w", err)
+		}
+	}
+
+	entryType := to
+	switch m.investmentType {
+	case models.InvestmentTypeCharge:
+		entryType = EntryCharge
+	case models.InvestmentTypeTransferOut:
+		entryType = EntryTransferOut
+	}
+
+	return postJournal(ctx, tx, investmentID, entryType, fmt.Sprintf("%s %s", m.investmentType, to), m.employee, journalFor(m, to))
+}
+
+// Note: The journals for each investment type. Money is received into the bank, applied to units
+// owed by the fund manager, then settled with the fund manager. Withdrawals run the other way.
+// Charges sell units and the proceeds become fee income in one step.
+// Transfers out in specie hand the units to the receiving manager without any cash moving
+func journalFor(m movement, to string) []posting {
+	c, f, a, u := m.customerID, m.fundID, m.amount, m.units
+
+	switch m.investmentType {
+	case models.InvestmentTypeDeposit:
+		switch to {
+		case models.InvestmentStatusCashReceived:
+			return []posting{
+				{AccountClientMoneyBank, "", "", a, 0},
+				{AccountCustomerCash, c, f, -a, 0},
+			}
+		case models.InvestmentStatusUnitsAllocated:
+			return []posting{
+				{AccountCustomerCash, c, f, a, 0},
+				{AccountCustomerUnits, c, f, -a, -u},
+				{AccountFundUnits, "", f, a, u},
+				{AccountFundManagerSettlement, c, f, -a, 0},
+			}
+		case models.InvestmentStatusSettled:
+			return []posting{
+				{AccountFundManagerSettlement, c, f, a, 0},
+				{AccountClientMoneyBank, "", "", -a, 0},
+			}
+		}
+	case models.InvestmentTypeWithdrawal:
+		switch to {
+		case models.InvestmentStatusUnitsAllocated:
+			return []posting{
+				{AccountCustomerUnits, c, f, a, u},
+				{AccountCustomerPayable, c, f, -a, 0},
+				{AccountFundManagerSettlement, c, f, a, 0},
+				{AccountFundUnits, "", f, -a, -u},
+			}
+		case models.InvestmentStatusSettled:
+			// Note: The fund manager pays us and we pay the customer. Any Lifetime ISA withdrawal charge
+			// is kept back in client money and owed to HMRC instead
+			postings := []posting{
+				{AccountClientMoneyBank, "", "", a, 0},
+				{AccountFundManagerSettlement, c, f, -a, 0},
+				{AccountCustomerPayable, c, f, a, 0},
+				{AccountClientMoneyBank, "", "", -(a - m.withdrawalCharge), 0},
+			}
+			if m.withdrawalCharge > 0 {
+				postings = append(postings, posting{AccountHMRCPayable, "", "", -m.withdrawalCharge, 0})
+			}
+			return postings
+		}
+	case models.InvestmentTypeCharge:
+		if to == models.InvestmentStatusSettled {
+			return []posting{
+				{AccountCustomerUnits, c, f, a, u},
+				{AccountPlatformFeeIncome, "", "", -a, 0},
+				{AccountClientMoneyBank, "", "", a, 0},
+				{AccountFundUnits, "", f, -a, -u},
+			}
+		}
+	case models.InvestmentTypeTransferOut:
+		if to == models.InvestmentStatusSettled {
+			return []posting{
+				{AccountCustomerUnits, c, f, a, u},
+				{AccountFundUnits, "", f, -a, -u},
+			}
+		}
+	}
+
+	return nil
+}
+
+// balanced reports whether the postings net to zero in both money and units
+func balanced(postings []posting) bool {
+	var amount, units float64
+	for _, p := range postings {
+		amount += p.amount
+		units += p.units
+	}
+	return math.Abs(amount) < 0.005 && math.Abs(units) < 0.0000005
+}
+
+// Note: Accounts are created on first use. The journal and its postings are written in one
+// statement, and the database checks the journal balances when the transaction commits.
+// For an employee's journal the customer on each posting is the employee
+func postJournal(ctx context.Context, tx *sql.Tx, investmentID, entryType, description string, employee bool, postings []posting) error {
+	if len(postings) == 0 {
+		return nil
+	}
+	if !balanced(postings) {
+		return fmt.Errorf("ledger journal for investment %s does not balance", investmentID)
+	}
+
+	types := make([]string, len(postings))
+	customers := make([]string, len(postings))
+	funds := make([]string, len(postings))
+	amounts := make([]float64, len(postings))
+	units := make([]float64, len(postings))
+	for i, p := range postings {
+		types[i], customers[i], funds[i], amounts[i], units[i] = p.accountType, p.customerID, p.fundID, p.amount, p.units
+	}
+
+	_, err := tx.ExecContext(ctx, `
+	INSERT INTO ledger_accounts (account_type, customer_id, employee_id, fund_id)
+	SELECT DISTINCT t,
+		CASE WHEN NOT $4 THEN NULLIF(c, '')::uuid END,
+		CASE WHEN $4 THEN NULLIF(c, '')::uuid END,
+		NULLIF(f, '')::uuid
+	FROM UNNEST($1::text[], $2::text[], $3::text[]) AS p(t, c, f)
+	ON CONFLICT (account_type, customer_id, employee_id, fund_id) DO NOTHING
+`, pq.Array(types), pq.Array(customers), pq.Array(funds), employee)
+	if err != nil {
+		return fmt.Errorf("failed to create ledger accounts: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	WITH journal AS (
+		INSERT INTO ledger_journals (investment_id, entry_type, description)
+		VALUES ($1, $2, $3)
+		RETURNING id
+	)
+	INSERT INTO ledger_postings (journal_id, account_id, amount, units)
+	SELECT journal.id, a.id, p.amount, p.units
+	FROM journal
+	CROSS JOIN UNNEST($4::text[], $5::text[], $6::text[], $7::numeric[], $8::numeric[]) AS p(t, c, f, amount, units)
+	JOIN ledger_accounts a ON a.account_type = p.t
+		AND a.customer_id IS NOT DISTINCT FROM CASE WHEN NOT $9 THEN NULLIF(p.c, '')::uuid END
+		AND a.employee_id IS NOT DISTINCT FROM CASE WHEN $9 THEN NULLIF(p.c, '')::uuid END
+		AND a.fund_id IS NOT DISTINCT FROM NULLIF(p.f, '')::uuid
+`, investmentID, entryType, description,
+		pq.Array(types), pq.Array(customers), pq.Array(funds), pq.Array(amounts), pq.Array(units), employee)
+	if err != nil {
+		return fmt.Errorf("failed to post ledger journal: %w", err)
+	}
+
+	return nil
+}
+
+// Note: Unwinds everything posted for an investment so a failure leaves no balances behind
+func postReversal(ctx context.Context, tx *sql.Tx, investmentID string) error {
+	_, err := tx.ExecContext(ctx, `
+	WITH totals AS (
+		SELECT p.account_id, SUM(p.amount) AS amount, SUM(p.units) AS units
+		FROM ledger_postings p
+		JOIN ledger_journals j ON j.id = p.journal_id
+		WHERE j.investment_id = $1
+		GROUP BY p.account_id
+		HAVING SUM(p.amount) <> 0 OR SUM(p.units) <> 0
+	),
+	journal AS (
+		INSERT INTO ledger_journals (investment_id, entry_type, description)
+		SELECT $1, $2, 'investment failed'
+		WHERE EXISTS (SELECT 1 FROM totals)
+		RETURNING id
+	)
+	INSERT INTO ledger_postings (journal_id, account_id, amount, units)
+	SELECT journal.id, totals.account_id, -totals.amount, -totals.units
+	FROM journal CROSS JOIN totals
+`, investmentID, EntryReversal)
+	if err != nil {
+		return fmt.Errorf("failed to post ledger reversal: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,182 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/stcol316/cushon-isa/internal/models"
+)
+
+// TODO: Use interfaces at service level instead of "repo *Repository"
+type LedgerRepository interface {
+	CountJournals(ctx context.Context) (int, error)
+	GetTrialBalance(ctx context.Context) ([]models.LedgerAccountBalance, error)
+	ListUnbalancedJournals(ctx context.Context) ([]string, error)
+	ListMismatches(ctx context.Context) ([]models.LedgerMismatch, error)
+	ListUnpostedInvestments(ctx context.Context) ([]unpostedInvestment, error)
+	PostSteps(ctx context.Context, investmentID string, steps []string) error
+}
+
+// unpostedInvestment is an investment that moved money before the ledger existed
+type unpostedInvestment struct {
+	id             string
+	investmentType string
+	status         string
+}
+
+type Repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) countJournals(ctx context.Context) (int, error) {
+	var count int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM ledger_journals`).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count journals: %w", err)
+	}
+	return count, nil
+}
+
+func (r *Repository) getTrialBalance(ctx context.Context) ([]models.LedgerAccountBalance, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT a.account_type, COALESCE(SUM(p.amount), 0), COALESCE(SUM(p.units), 0)
+        FROM ledger_accounts a
+        LEFT JOIN ledger_postings p ON p.account_id = a.id
+        GROUP BY a.account_type
+        ORDER BY a.account_type
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trial balance: %w", err)
+	}
+	defer rows.Close()
+
+	balances := []models.LedgerAccountBalance{}
+	for rows.Next() {
+		var balance models.LedgerAccountBalance
+		if err := rows.Scan(&balance.AccountType, &balance.Amount, &balance.Units); err != nil {
+			return nil, fmt.Errorf("failed to scan account balance: %w", err)
+		}
+		balances = append(balances, balance)
+	}
+
+	return balances, nil
+}
+
+// Note: The database will not commit an unbalanced journal, so anything found here means the
+// postings were changed outside the application
+func (r *Repository) listUnbalancedJournals(ctx context.Context) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT journal_id
+        FROM ledger_postings
+        GROUP BY journal_id
+        HAVING SUM(amount) <> 0 OR SUM(units) <> 0
+        ORDER BY journal_id
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query unbalanced journals: %w", err)
+	}
+	defer rows.Close()
+
+	journals := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan journal: %w", err)
+		}
+		journals = append(journals, id)
+	}
+
+	return journals, nil
+}
+
+// Note: Compares the totals derived from ledger balances with the same totals from the investments.
+// Pending orders have not moved money so they are left out of both. Pensions are compared by employee
+func (r *Repository) listMismatches(ctx context.Context) ([]models.LedgerMismatch, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        WITH expected AS (
+            SELECT COALESCE(customer_id, employee_id) AS customer_id, fund_id,
+                SUM(CASE WHEN type = 'deposit' THEN amount ELSE -amount END) AS total_investment,
+                COALESCE(SUM(CASE WHEN type = 'deposit' THEN amount ELSE -amount END)
+                    FILTER (WHERE status = 'settled'), 0) AS settled_investment
+            FROM investments
+            WHERE status IN ('cash_received', 'units_allocated', 'settled')
+            GROUP BY COALESCE(customer_id, employee_id), fund_id
+        )
+        SELECT COALESCE(e.customer_id, l.customer_id), COALESCE(e.fund_id, l.fund_id),
+            COALESCE(l.total_investment, 0), COALESCE(e.total_investment, 0),
+            COALESCE(l.settled_investment, 0), COALESCE(e.settled_investment, 0)
+        FROM expected e
+        FULL OUTER JOIN ledger_customer_fund_totals l ON l.customer_id = e.customer_id AND l.fund_id = e.fund_id
+        WHERE ABS(COALESCE(l.total_investment, 0) - COALESCE(e.total_investment, 0)) >= 0.01
+            OR ABS(COALESCE(l.settled_investment, 0) - COALESCE(e.settled_investment, 0)) >= 0.01
+        ORDER BY 1, 2
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare ledger totals: %w", err)
+	}
+	defer rows.Close()
+
+	mismatches := []models.LedgerMismatch{}
+	for rows.Next() {
+		var m models.LedgerMismatch
+		if err := rows.Scan(&m.CustomerID, &m.FundID, &m.LedgerTotal, &m.ExpectedTotal, &m.LedgerSettled, &m.ExpectedSettled); err != nil {
+			return nil, fmt.Errorf("failed to scan ledger mismatch: %w", err)
+		}
+		mismatches = append(mismatches, m)
+	}
+
+	return mismatches, nil
+}
+
+// listUnpostedInvestments returns investments that have moved money but have no journals
+func (r *Repository) listUnpostedInvestments(ctx context.Context) ([]unpostedInvestment, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT i.id, i.type, i.status
+        FROM investments i
+        WHERE i.status IN ('cash_received', 'units_allocated', 'settled')
+            AND NOT EXISTS (SELECT 1 FROM ledger_journals j WHERE j.investment_id = i.id)
+        ORDER BY i.created_at, i.id
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query unposted investments: %w", err)
+	}
+	defer rows.Close()
+
+	var investments []unpostedInvestment
+	for rows.Next() {
+		var i unpostedInvestment
+		if err := rows.Scan(&i.id, &i.investmentType, &i.status); err != nil {
+			return nil, fmt.Errorf("failed to scan investment: %w", err)
+		}
+		investments = append(investments, i)
+	}
+
+	return investments, nil
+}
+
+// postSteps posts the journals for each step an investment has already been through
+func (r *Repository) postSteps(ctx context.Context, investmentID string, steps []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	from := models.InvestmentStatusPending
+	for _, step := range steps {
+		if err := PostStatusChange(ctx, tx, investmentID, from, step); err != nil {
+			return err
+		}
+		from = step
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,200 @@
+package ledger
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stcol316/cushon-isa/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var investmentRowColumns = []string{"type", "customer_id", "fund_id", "amount", "units"}
+
+func TestJournalFor(t *testing.T) {
+	tests := []struct {
+		investmentType string
+		to             string
+		postings       int
+	}{
+		{models.InvestmentTypeDeposit, models.InvestmentStatusCashReceived, 2},
+		{models.InvestmentTypeDeposit, models.InvestmentStatusUnitsAllocated, 4},
+		{models.InvestmentTypeDeposit, models.InvestmentStatusSettled, 2},
+		{models.InvestmentTypeWithdrawal, models.InvestmentStatusUnitsAllocated, 4},
+		{models.InvestmentTypeWithdrawal, models.InvestmentStatusSettled, 4},
+		{models.InvestmentTypeCharge, models.InvestmentStatusSettled, 4},
+		{models.InvestmentTypeTransferOut, models.InvestmentStatusSettled, 2},
+		{models.InvestmentTypeWithdrawal, models.InvestmentStatusCashReceived, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.investmentType+" "+tt.to, func(t *testing.T) {
+			m := movement{investmentType: tt.investmentType, customerID: "customer1", fundID: "fund1", amount: 100, units: 83.333333}
+			postings := journalFor(m, tt.to)
+			assert.Len(t, postings, tt.postings)
+			assert.True(t, balanced(postings))
+		})
+	}
+}
+
+// Note: Walks a deposit through its lifecycle and derives the customer totals the way the
+// ledger_customer_fund_totals view does, checking they match customer_fund_totals at each step
+func TestJournalFor_DerivesCustomerTotals(t *testing.T) {
+	m := movement{investmentType: models.InvestmentTypeDeposit, customerID: "customer1", fundID: "fund1", amount: 100, units: 80}
+	balances := make(map[string]float64)
+	post := func(to string) {
+		for _, p := range journalFor(m, to) {
+			balances[p.accountType] += p.amount
+		}
+	}
+	totals := func() (float64, float64, float64) {
+		total := -balances[AccountCustomerUnits] - balances[AccountCustomerCash]
+		settled := -balances[AccountCustomerUnits] + balances[AccountFundManagerSettlement]
+		pending := -balances[AccountCustomerCash] - balances[AccountFundManagerSettlement]
+		return total, settled, pending
+	}
+
+	post(models.InvestmentStatusCashReceived)
+	total, settled, pending := totals()
+	assert.Equal(t, []float64{100, 0, 100}, []float64{total, settled, pending})
+
+	post(models.InvestmentStatusUnitsAllocated)
+	total, settled, pending = totals()
+	assert.Equal(t, []float64{100, 0, 100}, []float64{total, settled, pending})
+
+	post(models.InvestmentStatusSettled)
+	total, settled, pending = totals()
+	assert.Equal(t, []float64{100, 100, 0}, []float64{total, settled, pending})
+	assert.Equal(t, 0.0, balances[AccountClientMoneyBank])
+}
+
+// Note: A Lifetime ISA withdrawal pays the customer the amount less the charge and leaves the
+// charge in client money owed to HMRC
+func TestJournalFor_WithdrawalCharge(t *testing.T) {
+	m := movement{investmentType: models.InvestmentTypeWithdrawal, customerID: "customer1", fundID: "fund1", amount: 100, units: 80, withdrawalCharge: 25}
+	balances := make(map[string]float64)
+	for _, to := range []string{models.InvestmentStatusUnitsAllocated, models.InvestmentStatusSettled} {
+		postings := journalFor(m, to)
+		require.True(t, balanced(postings))
+		for _, p := range postings {
+			balances[p.accountType] += p.amount
+		}
+	}
+
+	assert.Equal(t, 0.0, balances[AccountCustomerPayable])
+	assert.Equal(t, 0.0, balances[AccountFundManagerSettlement])
+	assert.Equal(t, -25.0, balances[AccountHMRCPayable])
+	assert.Equal(t, 25.0, balances[AccountClientMoneyBank])
+	assert.Equal(t, 100.0, balances[AccountCustomerUnits])
+	assert.Equal(t, -100.0, balances[AccountFundUnits])
+
+	m.withdrawalCharge = 0
+	postings := journalFor(m, models.InvestmentStatusSettled)
+	assert.Len(t, postings, 4)
+	assert.True(t, balanced(postings))
+}
+
+func TestPostStatusChange(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+
+	t.Run("no movement", func(t *testing.T) {
+		mock.ExpectBegin()
+		tx, err := db.Begin()
+		require.NoError(t, err)
+
+		assert.NoError(t, PostStatusChange(ctx, tx, "inv1", "", models.InvestmentStatusPending))
+		assert.NoError(t, PostStatusChange(ctx, tx, "inv1", models.InvestmentStatusPending, models.InvestmentStatusFailed))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("cash received", func(t *testing.T) {
+		mock.ExpectBegin()
+		tx, err := db.Begin()
+		require.NoError(t, err)
+
+		mock.ExpectQuery("SELECT type, customer_id, fund_id, amount, units FROM investments WHERE id = \\$1").
+			WithArgs("inv1").
+			WillReturnRows(sqlmock.NewRows(investmentRowColumns).AddRow("deposit", "customer1", "fund1", 100.0, nil))
+		mock.ExpectExec("INSERT INTO ledger_accounts (.+) ON CONFLICT").
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec("WITH journal AS \\( INSERT INTO ledger_journals (.+) INSERT INTO ledger_postings").
+			WithArgs("inv1", "cash_received", "deposit cash_received",
+				sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), false).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+
+		assert.NoError(t, PostStatusChange(ctx, tx, "inv1", models.InvestmentStatusPending, models.InvestmentStatusCashReceived))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("settled withdrawal reads the charge", func(t *testing.T) {
+		mock.ExpectBegin()
+		tx, err := db.Begin()
+		require.NoError(t, err)
+
+		mock.ExpectQuery("SELECT type, customer_id, fund_id, amount, units FROM investments WHERE id = \\$1").
+			WithArgs("inv1").
+			WillReturnRows(sqlmock.NewRows(investmentRowColumns).AddRow("withdrawal", "customer1", "fund1", 100.0, 80.0))
+		mock.ExpectQuery("SELECT COALESCE\\(withdrawal_charge, 0\\) FROM investments WHERE id = \\$1").
+			WithArgs("inv1").
+			WillReturnRows(sqlmock.NewRows([]string{"withdrawal_charge"}).AddRow(25.0))
+		mock.ExpectExec("INSERT INTO ledger_accounts (.+) ON CONFLICT").
+			WillReturnResult(sqlmock.NewResult(0, 5))
+		mock.ExpectExec("WITH journal AS \\( INSERT INTO ledger_journals (.+) INSERT INTO ledger_postings").
+			WithArgs("inv1", "settled", "withdrawal settled",
+				sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), false).
+			WillReturnResult(sqlmock.NewResult(0, 5))
+
+		assert.NoError(t, PostStatusChange(ctx, tx, "inv1", models.InvestmentStatusUnitsAllocated, models.InvestmentStatusSettled))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("failure is reversed", func(t *testing.T) {
+		mock.ExpectBegin()
+		tx, err := db.Begin()
+		require.NoError(t, err)
+
+		mock.ExpectExec("WITH totals AS (.+) INSERT INTO ledger_journals (.+) INSERT INTO ledger_postings").
+			WithArgs("inv1", EntryReversal).
+			WillReturnResult(sqlmock.NewResult(0, 4))
+
+		assert.NoError(t, PostStatusChange(ctx, tx, "inv1", models.InvestmentStatusUnitsAllocated, models.InvestmentStatusFailed))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestService_Check(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	service := NewService(NewRepository(db))
+
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM ledger_journals").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery("SELECT (.+) FROM ledger_accounts a LEFT JOIN ledger_postings").
+		WillReturnRows(sqlmock.NewRows([]string{"account_type", "amount", "units"}).
+			AddRow(AccountClientMoneyBank, 0.0, 0.0).
+			AddRow(AccountCustomerUnits, -100.0, -80.0).
+			AddRow(AccountFundUnits, 100.0, 80.0))
+	mock.ExpectQuery("SELECT journal_id FROM ledger_postings").
+		WillReturnRows(sqlmock.NewRows([]string{"journal_id"}))
+	mock.ExpectQuery("WITH expected AS (.+) FULL OUTER JOIN ledger_customer_fund_totals").
+		WillReturnRows(sqlmock.NewRows([]string{"customer_id", "fund_id", "lt", "et", "ls", "es"}))
+
+	check, err := service.check(context.Background())
+	require.NoError(t, err)
+	assert.True(t, check.Balanced)
+	assert.Equal(t, 3, check.Journals)
+	assert.Len(t, check.TrialBalance, 3)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostedSteps(t *testing.T) {
+	assert.Equal(t, []string{"cash_received", "units_allocated"}, postedSteps(models.InvestmentTypeDeposit, models.InvestmentStatusUnitsAllocated))
+	assert.Equal(t, []string{"units_allocated", "settled"}, postedSteps(models.InvestmentTypeWithdrawal, models.InvestmentStatusSettled))
+	assert.Nil(t, postedSteps(models.InvestmentTypeDeposit, models.InvestmentStatusPending))
+}
@@ -0,0 +1,98 @@
+package ledger
+
+import (
+	"context"
+	"log"
+	"math"
+	"time"
+
+	"github.com/stcol316/cushon-isa/internal/models"
+)
+
+type Service struct {
+	repo *Repository
+	now  func() time.Time
+}
+
+func NewService(repo *Repository) *Service {
+	return &Service{repo: repo, now: time.Now}
+}
+
+func (s *Service) check(ctx context.Context) (*models.LedgerCheck, error) {
+	journals, err := s.repo.countJournals(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	trialBalance, err := s.repo.getTrialBalance(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	unbalanced, err := s.repo.listUnbalancedJournals(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	mismatches, err := s.repo.listMismatches(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var amount, units float64
+	for _, balance := range trialBalance {
+		amount += balance.Amount
+		units += balance.Units
+	}
+
+	return &models.LedgerCheck{
+		Balanced:           math.Abs(amount) < 0.005 && math.Abs(units) < 0.0000005 && len(unbalanced) == 0 && len(mismatches) == 0,
+		Journals:           journals,
+		TrialBalance:       trialBalance,
+		UnbalancedJournals: unbalanced,
+		Mismatches:         mismatches,
+		CheckedAt:          s.now().UTC(),
+	}, nil
+}
+
+// Note: Posts investments that moved money before the ledger existed. Safe to rerun as
+// investments that already have journals are skipped
+func (s *Service) backfill(ctx context.Context) (*models.LedgerBackfill, error) {
+	investments, err := s.repo.listUnpostedInvestments(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.LedgerBackfill{}
+	for _, investment := range investments {
+		steps := postedSteps(investment.investmentType, investment.status)
+		if err := s.repo.postSteps(ctx, investment.id, steps); err != nil {
+			return nil, err
+		}
+		result.Investments++
+		result.Journals += len(steps)
+	}
+
+	log.Printf("Ledger backfill: %d investments, %d journals", result.Investments, result.Journals)
+	return result, nil
+}
+
+// postedSteps returns the statuses an investment has passed through that move money, up to its current status
+func postedSteps(investmentType, status string) []string {
+	var path []string
+	switch investmentType {
+	case models.InvestmentTypeDeposit:
+		path = []string{models.InvestmentStatusCashReceived, models.InvestmentStatusUnitsAllocated, models.InvestmentStatusSettled}
+	case models.InvestmentTypeWithdrawal:
+		path = []string{models.InvestmentStatusUnitsAllocated, models.InvestmentStatusSettled}
+	case models.InvestmentTypeCharge, models.InvestmentTypeTransferOut:
+		path = []string{models.InvestmentStatusSettled}
+	}
+
+	for i, step := range path {
+		if step == status {
+			return path[:i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package lifetimeisa
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/models"
+	helper "github.com/stcol316/cushon-isa/pkg/helpers"
+)
+
+type Handler struct {
+	service   *Service
+	scheduler *BonusScheduler
+}
+
+func NewHandler(service *Service, scheduler *BonusScheduler) *Handler {
+	return &Handler{service: service, scheduler: scheduler}
+}
+
+func (h *Handler) OpenLifetimeISAHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "invalid customer ID format")
+		return
+	}
+
+	lisa, err := h.service.openLifetimeISA(r.Context(), id, time.Now())
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusCreated, lisa)
+}
+
+func (h *Handler) GetLifetimeISAHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "invalid customer ID format")
+		return
+	}
+
+	lisa, err := h.service.getLifetimeISA(r.Context(), id)
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, lisa)
+}
+
+// Note: Admin only. Claims the bonus on contributions from months that have ended
+func (h *Handler) ClaimBonusesHandler(w http.ResponseWriter, r *http.Request) {
+	run, err := h.scheduler.ClaimNow(r.Context())
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, run)
+}
+
+// Note: Admin only. Credits the bonus once HMRC has paid a claim, e.g. {"month": "2025-01"}
+func (h *Handler) PayBonusesHandler(w http.ResponseWriter, r *http.Request) {
+	req := new(models.PayLifetimeISABonusesRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		helper.RespondWithProblem(w, r, isaerrors.ErrInvalidRequestBody.Wrap(err))
+		return
+	}
+
+	run, err := h.scheduler.Pay(r.Context(), req.Month)
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, run)
+}
@@ -0,0 +1,264 @@
+package lifetimeisa
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/ledger"
+	"github.com/stcol316/cushon-isa/internal/models"
+)
+
+// TODO: Use interfaces at service level instead of "repo *Repository"
+type LifetimeISARepository interface {
+	GetHolder(ctx context.Context, customerID string) (*holder, error)
+	OpenLifetimeISA(ctx context.Context, customerID string) (*models.LifetimeISA, error)
+	GetLifetimeISA(ctx context.Context, customerID string) (*models.LifetimeISA, error)
+	ListBonuses(ctx context.Context, customerID string) ([]models.LifetimeISABonus, error)
+	ClaimBonuses(ctx context.Context, before time.Time, rate float64) (int, float64, error)
+	ListUnpaidBonuses(ctx context.Context, month time.Time) ([]bonusPayment, error)
+	PayBonus(ctx context.Context, payment bonusPayment, month time.Time, dealingDate string) error
+}
+
+type Repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// holder is what we need to know about a customer to open a Lifetime ISA
+type holder struct {
+	product     string
+	dateOfBirth string
+}
+
+// bonusPayment is the bonus received for one customer's contributions in a claim month
+type bonusPayment struct {
+	customerID string
+	fundID     string
+	bonus      float64
+}
+
+func (r *Repository) getHolder(ctx context.Context, customerID string) (*holder, error) {
+	var h holder
+	err := r.db.QueryRowContext(ctx, `
+        SELECT isa_product, COALESCE(TO_CHAR(date_of_birth, 'YYYY-MM-DD'), '')
+        FROM retail_customers
+        WHERE id = $1
+    `, customerID).Scan(&h.product, &h.dateOfBirth)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, isaerrors.ErrCustomerNotFound
+		}
+		return nil, fmt.Errorf("failed to get customer: %w", err)
+	}
+	return &h, nil
+}
+
+// Note: The Lifetime ISA's account is opened in the same statement
+func (r *Repository) openLifetimeISA(ctx context.Context, customerID string) (*models.LifetimeISA, error) {
+	lisa := models.LifetimeISA{CustomerID: customerID}
+	err := r.db.QueryRowContext(ctx, `
+	WITH lisa AS (
+		INSERT INTO lifetime_isas (customer_id)
+		VALUES ($1)
+		RETURNING customer_id, opened_at
+	)
+	INSERT INTO accounts (customer_id, product, opened_at)
+	SELECT customer_id, 'lifetime_isa', opened_at FROM lisa
+	RETURNING id, opened_at
+`, customerID).Scan(&lisa.AccountID, &lisa.OpenedAt)
+	if err != nil {
+		if isaerrors.IsUniqueViolation(err) {
+			return nil, isaerrors.ErrLifetimeISAAlreadyOpen
+		}
+		if isaerrors.IsForeignKeyViolation(err) {
+			return nil, isaerrors.ErrCustomerNotFound
+		}
+		return nil, fmt.Errorf("failed to open lifetime isa: %w", err)
+	}
+	return &lisa, nil
+}
+
+func (r *Repository) getLifetimeISA(ctx context.Context, customerID string) (*models.LifetimeISA, error) {
+	lisa := models.LifetimeISA{CustomerID: customerID}
+	err := r.db.QueryRowContext(ctx, `
+        SELECT l.opened_at, COALESCE(a.id::text, '')
+        FROM lifetime_isas l
+        LEFT JOIN LATERAL (
+            SELECT id FROM accounts
+            WHERE customer_id = l.customer_id AND product = 'lifetime_isa'
+            ORDER BY status = 'closed', opened_at DESC
+            LIMIT 1
+        ) a ON TRUE
+        WHERE l.customer_id = $1
+    `, customerID).Scan(&lisa.OpenedAt, &lisa.AccountID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, isaerrors.ErrLifetimeISANotOpen
+		}
+		return nil, fmt.Errorf("failed to get lifetime isa: %w", err)
+	}
+	return &lisa, nil
+}
+
+func (r *Repository) listBonuses(ctx context.Context, customerID string) ([]models.LifetimeISABonus, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT id, customer_id, fund_id, deposit_investment_id, TO_CHAR(claim_month, 'YYYY-MM'),
+            contribution, bonus, status, COALESCE(paid_investment_id::text, ''), created_at, paid_at
+        FROM lifetime_isa_bonuses
+        WHERE customer_id = $1
+        ORDER BY claim_month DESC, created_at DESC
+    `, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list lifetime isa bonuses: %w", err)
+	}
+	defer rows.Close()
+
+	bonuses := []models.LifetimeISABonus{}
+	for rows.Next() {
+		var bonus models.LifetimeISABonus
+		var paid sql.NullTime
+		if err := rows.Scan(
+			&bonus.ID,
+			&bonus.CustomerID,
+			&bonus.FundID,
+			&bonus.DepositInvestmentID,
+			&bonus.ClaimMonth,
+			&bonus.Contribution,
+			&bonus.Bonus,
+			&bonus.Status,
+			&bonus.PaidInvestmentID,
+			&bonus.CreatedAt,
+			&paid,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan lifetime isa bonus: %w", err)
+		}
+		if paid.Valid {
+			bonus.PaidAt = &paid.Time
+		}
+		bonuses = append(bonuses, bonus)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list lifetime isa bonuses: %w", err)
+	}
+
+	return bonuses, nil
+}
+
+// Note: Claims the bonus on every Lifetime ISA contribution received before the given date that has
+// not been claimed yet, so missed runs catch up. Bonus deposits are not contributions.
+// Contributions are claimed in the month they were made
+func (r *Repository) claimBonuses(ctx context.Context, before time.Time, rate float64) (int, float64, error) {
+	var claimed int
+	var total float64
+	err := r.db.QueryRowContext(ctx, `
+        WITH claimed AS (
+            INSERT INTO lifetime_isa_bonuses (customer_id, fund_id, deposit_investment_id, claim_month, contribution, bonus)
+            SELECT i.customer_id, i.fund_id, i.id, DATE_TRUNC('month', i.created_at)::date, i.amount, ROUND(i.amount * $2, 2)
+            FROM investments i
+            WHERE i.product = 'lifetime_isa' AND i.type = 'deposit'
+                AND i.status IN ('cash_received', 'units_allocated', 'settled')
+                AND i.created_at < $1
+                AND NOT EXISTS (SELECT 1 FROM lifetime_isa_bonuses b WHERE b.paid_investment_id = i.id)
+            ON CONFLICT (deposit_investment_id) DO NOTHING
+            RETURNING bonus
+        )
+        SELECT COUNT(*), COALESCE(SUM(bonus), 0) FROM claimed
+    `, before, rate).Scan(&claimed, &total)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to claim lifetime isa bonuses: %w", err)
+	}
+	return claimed, total, nil
+}
+
+func (r *Repository) listUnpaidBonuses(ctx context.Context, month time.Time) ([]bonusPayment, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT customer_id, fund_id, SUM(bonus)
+        FROM lifetime_isa_bonuses
+        WHERE claim_month = $1 AND status = 'claimed'
+        GROUP BY customer_id, fund_id
+        ORDER BY customer_id
+    `, month.Format(dateLayout))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list unpaid lifetime isa bonuses: %w", err)
+	}
+	defer rows.Close()
+
+	var payments []bonusPayment
+	for rows.Next() {
+		var payment bonusPayment
+		if err := rows.Scan(&payment.customerID, &payment.fundID, &payment.bonus); err != nil {
+			return nil, fmt.Errorf("failed to scan lifetime isa bonus: %w", err)
+		}
+		payments = append(payments, payment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list unpaid lifetime isa bonuses: %w", err)
+	}
+
+	return payments, nil
+}
+
+// Note: The bonus is credited as a Lifetime ISA deposit with the cash already received from HMRC,
+// so it is invested by settlement like any other deposit
+func (r *Repository) payBonus(ctx context.Context, payment bonusPayment, month time.Time, dealingDate string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var investmentID string
+	err = tx.QueryRowContext(ctx, `
+	INSERT INTO investments (customer_id, fund_id, amount, type, status, dealing_date, product, account_id)
+	VALUES ($1, $2, $3, 'deposit', 'cash_received', $4::date, 'lifetime_isa',
+		(SELECT id FROM accounts WHERE customer_id = $1 AND product = 'lifetime_isa' ORDER BY status = 'closed', opened_at DESC LIMIT 1))
+	RETURNING id
+`, payment.customerID, payment.fundID, payment.bonus, dealingDate).Scan(&investmentID)
+	if err != nil {
+		return fmt.Errorf("failed to record lifetime isa bonus: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	INSERT INTO investment_status_history (investment_id, from_status, to_status, reason)
+	VALUES ($1, NULL, 'cash_received', $2)
+`, investmentID, fmt.Sprintf("lifetime isa bonus for %s", month.Format(monthLayout)))
+	if err != nil {
+		return fmt.Errorf("failed to record status history: %w", err)
+	}
+
+	if err := ledger.PostStatusChange(ctx, tx, investmentID, "", models.InvestmentStatusCashReceived); err != nil {
+		return err
+	}
+
+	res, err := tx.ExecContext(ctx, `
+	UPDATE lifetime_isa_bonuses
+	SET status = 'paid', paid_investment_id = $4, paid_at = CURRENT_TIMESTAMP
+	WHERE customer_id = $1 AND fund_id = $2 AND claim_month = $3 AND status = 'claimed'
+`, payment.customerID, payment.fundID, month.Format(dateLayout), investmentID)
+	if err != nil {
+		return fmt.Errorf("failed to mark lifetime isa bonus paid: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return isaerrors.ErrNoBonusToPay
+	}
+
+	log.Printf("Attempting to refresh materialized view")
+	_, err = tx.ExecContext(ctx, "REFRESH MATERIALIZED VIEW customer_fund_totals")
+	if err != nil {
+		return fmt.Errorf("failed to refresh materialized view: %w", err)
+	}
+	log.Printf("Successfully refreshed materialized view")
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
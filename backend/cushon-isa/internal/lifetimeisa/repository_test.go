@@ -0,0 +1,135 @@
+package lifetimeisa
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository_OpenLifetimeISA(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	openedAt := time.Date(2025, 6, 1, 9, 0, 0, 0, time.UTC)
+
+	t.Run("opened", func(t *testing.T) {
+		mock.ExpectQuery("INSERT INTO lifetime_isas (.+) INSERT INTO accounts (.+) 'lifetime_isa'").
+			WithArgs("customer1").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "opened_at"}).AddRow("account1", openedAt))
+
+		lisa, err := repo.openLifetimeISA(context.Background(), "customer1")
+		require.NoError(t, err)
+		assert.Equal(t, &models.LifetimeISA{CustomerID: "customer1", AccountID: "account1", OpenedAt: openedAt}, lisa)
+	})
+
+	t.Run("already open", func(t *testing.T) {
+		mock.ExpectQuery("INSERT INTO lifetime_isas").
+			WithArgs("customer1").
+			WillReturnError(&pq.Error{Code: "23505"})
+
+		_, err := repo.openLifetimeISA(context.Background(), "customer1")
+		assert.ErrorIs(t, err, isaerrors.ErrLifetimeISAAlreadyOpen)
+	})
+
+	t.Run("customer not found", func(t *testing.T) {
+		mock.ExpectQuery("INSERT INTO lifetime_isas").
+			WithArgs("missing").
+			WillReturnError(&pq.Error{Code: "23503"})
+
+		_, err := repo.openLifetimeISA(context.Background(), "missing")
+		assert.ErrorIs(t, err, isaerrors.ErrCustomerNotFound)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCheckOpeningAge(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	assert.NoError(t, checkOpeningAge("2007-06-01", now))
+	assert.NoError(t, checkOpeningAge("1985-06-02", now))
+	assert.ErrorIs(t, checkOpeningAge("2007-06-02", now), isaerrors.ErrLifetimeISAAge)
+	assert.ErrorIs(t, checkOpeningAge("1985-06-01", now), isaerrors.ErrLifetimeISAAge)
+	assert.ErrorIs(t, checkOpeningAge("", now), isaerrors.ErrEligibilityIncomplete)
+}
+
+func TestRepository_ClaimBonuses(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	before := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery("WITH claimed AS \\( INSERT INTO lifetime_isa_bonuses (.+) ON CONFLICT \\(deposit_investment_id\\) DO NOTHING").
+		WithArgs(before, bonusRate).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "sum"}).AddRow(3, 250.0))
+
+	claimed, bonus, err := repo.claimBonuses(context.Background(), before, bonusRate)
+	require.NoError(t, err)
+	assert.Equal(t, 3, claimed)
+	assert.Equal(t, 250.0, bonus)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_PayBonus(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	month := time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+	payment := bonusPayment{customerID: "customer1", fundID: "fund1", bonus: 250}
+
+	expectBonusDeposit := func() {
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO investments (.+) 'deposit', 'cash_received', \\$4::date, 'lifetime_isa'").
+			WithArgs("customer1", "fund1", 250.0, "2025-06-03").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("inv1"))
+		mock.ExpectExec("INSERT INTO investment_status_history").
+			WithArgs("inv1", "lifetime isa bonus for 2025-05").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("SELECT type, customer_id, fund_id, amount, units FROM investments WHERE id = \\$1").
+			WithArgs("inv1").
+			WillReturnRows(sqlmock.NewRows([]string{"type", "customer_id", "fund_id", "amount", "units"}).
+				AddRow(models.InvestmentTypeDeposit, "customer1", "fund1", 250.0, nil))
+		mock.ExpectExec("INSERT INTO ledger_accounts").
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec("INSERT INTO ledger_journals (.+) INSERT INTO ledger_postings").
+			WithArgs("inv1", models.InvestmentStatusCashReceived, "deposit cash_received", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), false).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+	}
+
+	t.Run("paid", func(t *testing.T) {
+		expectBonusDeposit()
+		mock.ExpectExec("UPDATE lifetime_isa_bonuses SET status = 'paid'").
+			WithArgs("customer1", "fund1", "2025-05-01", "inv1").
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec("REFRESH MATERIALIZED VIEW customer_fund_totals").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		assert.NoError(t, repo.payBonus(context.Background(), payment, month, "2025-06-03"))
+	})
+
+	t.Run("already paid by another run", func(t *testing.T) {
+		expectBonusDeposit()
+		mock.ExpectExec("UPDATE lifetime_isa_bonuses SET status = 'paid'").
+			WithArgs("customer1", "fund1", "2025-05-01", "inv1").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		assert.ErrorIs(t, repo.payBonus(context.Background(), payment, month, "2025-06-03"), isaerrors.ErrNoBonusToPay)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
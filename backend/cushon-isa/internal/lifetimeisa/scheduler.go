@@ -0,0 +1,85 @@
+package lifetimeisa
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/stcol316/cushon-isa/internal/models"
+)
+
+// Note: Claims the Lifetime ISA bonus once a day. Only contributions from months that have ended
+// are claimed, so the daily run makes the claim early in each month and catches up after failures
+type BonusScheduler struct {
+	service  *Service
+	hour     int
+	minute   int
+	location *time.Location
+}
+
+// NewBonusScheduler takes the run time as HH:MM in the given IANA time zone
+func NewBonusScheduler(service *Service, runAt, timezone string) (*BonusScheduler, error) {
+	parsed, err := time.Parse("15:04", runAt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid lifetime isa bonus time %q: %w", runAt, err)
+	}
+
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid lifetime isa bonus time zone %q: %w", timezone, err)
+	}
+
+	return &BonusScheduler{
+		service:  service,
+		hour:     parsed.Hour(),
+		minute:   parsed.Minute(),
+		location: location,
+	}, nil
+}
+
+func (s *BonusScheduler) today(now time.Time) time.Time {
+	now = now.In(s.location)
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, s.location)
+}
+
+func (s *BonusScheduler) nextRun(now time.Time) time.Time {
+	now = now.In(s.location)
+	next := time.Date(now.Year(), now.Month(), now.Day(), s.hour, s.minute, 0, 0, s.location)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// Note: Lifetime ISA bonus go routine. Stops when the context is cancelled
+func (s *BonusScheduler) Start(ctx context.Context) {
+	go func() {
+		for {
+			next := s.nextRun(time.Now())
+			log.Printf("Next lifetime ISA bonus claim at %s", next.Format(time.RFC3339))
+
+			timer := time.NewTimer(time.Until(next))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			if _, err := s.service.claimBonuses(ctx, s.today(next)); err != nil {
+				log.Printf("Lifetime ISA bonus claim failed: %v", err)
+			}
+		}
+	}()
+}
+
+// ClaimNow claims the bonus on every unclaimed contribution from months that have ended
+func (s *BonusScheduler) ClaimNow(ctx context.Context) (*models.LifetimeISABonusClaimRun, error) {
+	return s.service.claimBonuses(ctx, s.today(time.Now()))
+}
+
+// Pay credits the bonus received for a claim month
+func (s *BonusScheduler) Pay(ctx context.Context, month string) (*models.LifetimeISABonusPaymentRun, error) {
+	return s.service.payBonuses(ctx, month, time.Now())
+}
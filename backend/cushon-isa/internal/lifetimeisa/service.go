@@ -0,0 +1,140 @@
+package lifetimeisa
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/fund"
+	"github.com/stcol316/cushon-isa/internal/investment"
+	"github.com/stcol316/cushon-isa/internal/models"
+)
+
+const (
+	dateLayout  = "2006-01-02"
+	monthLayout = "2006-01"
+	// Note: The government adds 25% to every contribution
+	bonusRate      = 0.25
+	minimumOpenAge = 18
+	maximumOpenAge = 39
+)
+
+type Service struct {
+	repo        *Repository
+	investments *investment.Service
+	funds       *fund.Service
+}
+
+func NewService(repo *Repository, investments *investment.Service, funds *fund.Service) *Service {
+	return &Service{repo: repo, investments: investments, funds: funds}
+}
+
+// Note: Lifetime ISAs are opened alongside an existing Stocks & Shares ISA, so the customer must
+// already be able to invest. Junior ISA holders cannot hold one until they convert
+func (s *Service) openLifetimeISA(ctx context.Context, customerID string, now time.Time) (*models.LifetimeISA, error) {
+	if err := s.investments.CheckDepositEligibility(ctx, customerID); err != nil {
+		return nil, err
+	}
+
+	holder, err := s.repo.getHolder(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	if holder.product == models.ISAProductJunior {
+		return nil, isaerrors.ErrLifetimeISAAge
+	}
+	if err := checkOpeningAge(holder.dateOfBirth, now); err != nil {
+		return nil, err
+	}
+
+	return s.repo.openLifetimeISA(ctx, customerID)
+}
+
+func checkOpeningAge(dob string, now time.Time) error {
+	parsed, err := time.Parse(dateLayout, dob)
+	if err != nil {
+		return isaerrors.ErrEligibilityIncomplete
+	}
+	age := ageOn(parsed, now)
+	if age < minimumOpenAge || age > maximumOpenAge {
+		return isaerrors.ErrLifetimeISAAge
+	}
+	return nil
+}
+
+// ageOn returns the age in whole years on the given date
+func ageOn(dob, on time.Time) int {
+	age := on.Year() - dob.Year()
+	if on.Month() < dob.Month() || (on.Month() == dob.Month() && on.Day() < dob.Day()) {
+		age--
+	}
+	return age
+}
+
+func (s *Service) getLifetimeISA(ctx context.Context, customerID string) (*models.LifetimeISA, error) {
+	lisa, err := s.repo.getLifetimeISA(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	lisa.Bonuses, err = s.repo.listBonuses(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	return lisa, nil
+}
+
+// Note: Claims the bonus on contributions made before the given month. Safe to rerun,
+// contributions that have already been claimed are skipped
+func (s *Service) claimBonuses(ctx context.Context, month time.Time) (*models.LifetimeISABonusClaimRun, error) {
+	before := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, month.Location())
+
+	claimed, bonus, err := s.repo.claimBonuses(ctx, before, bonusRate)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Claimed lifetime ISA bonus of %.2f on %d contributions before %s", bonus, claimed, before.Format(dateLayout))
+	return &models.LifetimeISABonusClaimRun{
+		Before:  before.Format(dateLayout),
+		Claimed: claimed,
+		Bonus:   bonus,
+	}, nil
+}
+
+// Note: Credits the bonus received from HMRC for a claim month to each customer's Lifetime ISA,
+// in the fund the contributions were made to
+func (s *Service) payBonuses(ctx context.Context, month string, now time.Time) (*models.LifetimeISABonusPaymentRun, error) {
+	start, err := time.Parse(monthLayout, month)
+	if err != nil {
+		return nil, isaerrors.ErrInvalidBonusMonth
+	}
+
+	payments, err := s.repo.listUnpaidBonuses(ctx, start)
+	if err != nil {
+		return nil, err
+	}
+	if len(payments) == 0 {
+		return nil, isaerrors.ErrNoBonusToPay
+	}
+
+	run := &models.LifetimeISABonusPaymentRun{ClaimMonth: month}
+	customers := make(map[string]bool)
+	for _, payment := range payments {
+		dealingDate, err := s.funds.DealingDate(ctx, payment.fundID, now)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.repo.payBonus(ctx, payment, start, dealingDate); err != nil {
+			return nil, fmt.Errorf("failed to pay lifetime isa bonus for customer %s: %w", payment.customerID, err)
+		}
+		customers[payment.customerID] = true
+		run.Bonus += payment.bonus
+	}
+	run.Customers = len(customers)
+
+	log.Printf("Paid lifetime ISA bonus of %.2f to %d customers for %s", run.Bonus, run.Customers, month)
+	return run, nil
+}
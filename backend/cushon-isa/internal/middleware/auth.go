@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/jwtauth/v5"
+	helper "github.com/stcol316/cushon-isa/pkg/helpers"
+)
+
+// Note: Role based access for admin and operations routes.
+// Must be used after the jwtauth Verifier and Authenticator middleware
+func RequireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, claims, err := jwtauth.FromContext(r.Context())
+			if err != nil || claims["role"] != role {
+				helper.RespondWithError(w, http.StatusForbidden, "insufficient permissions")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// GetUserID returns the user_id claim of the authenticated user, used to attribute actions
+func GetUserID(r *http.Request) string {
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		return ""
+	}
+	if id, ok := claims["user_id"]; ok {
+		return fmt.Sprint(id)
+	}
+	return ""
+}
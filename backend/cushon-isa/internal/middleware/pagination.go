@@ -2,25 +2,81 @@ package middleware
 
 import (
 	"context"
+	"encoding/base64"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	helper "github.com/stcol316/cushon-isa/pkg/helpers"
+)
+
+// Note: Requests for larger pages are capped rather than rejected
+const (
+	DefaultPageSize = 10
+	MaxPageSize     = 100
 )
 
 type PaginatedResult struct {
 	Data       interface{} `json:"data"`
 	Pagination struct {
-		CurrentPage int  `json:"current_page"`
-		PageSize    int  `json:"page_size"`
-		TotalItems  int  `json:"total_items"`
-		TotalPages  int  `json:"total_pages"`
+		CurrentPage int `json:"current_page"`
+		PageSize    int `json:"page_size"`
+		// Note: Omitted on cursor pages after the first, counting on every page defeats the keyset
+		TotalItems  *int `json:"total_items,omitempty"`
+		TotalPages  *int `json:"total_pages,omitempty"`
 		HasNext     bool `json:"has_next"`
 		HasPrevious bool `json:"has_previous"`
+		// Note: Only set in cursor mode. Pass back as ?cursor= to fetch the next page
+		NextCursor string `json:"next_cursor,omitempty"`
 	} `json:"pagination"`
 }
 
+// Note: Offset mode uses Page. Cursor mode is enabled by sending a cursor query parameter,
+// which is empty for the first page. Endpoints that do not support cursors ignore it
 type PaginationParams struct {
-	Page     int
-	PageSize int
+	Page       int
+	PageSize   int
+	CursorMode bool
+	Cursor     *Cursor
+}
+
+// Cursor is the position of the last item on the previous page.
+// Items are ordered by created_at then id so the pair is unique
+type Cursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+// EncodeCursor returns an opaque cursor for the item. Clients must not rely on its format
+func EncodeCursor(createdAt time.Time, id string) string {
+	raw := createdAt.UTC().Format(time.RFC3339Nano) + "," + id
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// Note: The ID is checked here so a tampered cursor is a 400 rather than a uuid cast error in the query
+func DecodeCursor(cursor string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, isaerrors.ErrInvalidCursor
+	}
+
+	createdAt, id, ok := strings.Cut(string(raw), ",")
+	if !ok {
+		return nil, isaerrors.ErrInvalidCursor
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, isaerrors.ErrInvalidCursor
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return nil, isaerrors.ErrInvalidCursor
+	}
+
+	return &Cursor{CreatedAt: t, ID: id}, nil
 }
 
 // Note: Best practice to define our own key to avoid collisions
@@ -32,7 +88,7 @@ const PaginationParamsKey contextKey = "pagination"
 func Paginate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		page := 1
-		pageSize := 10
+		pageSize := DefaultPageSize
 
 		if p := r.URL.Query().Get("page"); p != "" {
 			if pageInt, err := strconv.Atoi(p); err == nil && pageInt > 0 {
@@ -46,11 +102,30 @@ func Paginate(next http.Handler) http.Handler {
 			}
 		}
 
-		// Store pagination in context with previously defined key
-		ctx := context.WithValue(r.Context(), PaginationParamsKey, PaginationParams{
+		if pageSize > MaxPageSize {
+			pageSize = MaxPageSize
+		}
+
+		params := PaginationParams{
 			Page:     page,
 			PageSize: pageSize,
-		})
+		}
+
+		query := r.URL.Query()
+		if query.Has("cursor") {
+			params.CursorMode = true
+			if c := query.Get("cursor"); c != "" {
+				cursor, err := DecodeCursor(c)
+				if err != nil {
+					helper.RespondWithProblem(w, r, err)
+					return
+				}
+				params.Cursor = cursor
+			}
+		}
+
+		// Store pagination in context with previously defined key
+		ctx := context.WithValue(r.Context(), PaginationParamsKey, params)
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+const (
+	AccountStatusOpen   = "open"
+	AccountStatusClosed = "closed"
+)
+
+// Account is a wrapper held by a customer. Product is ISAProductStandard, ISAProductJunior or
+// ISAProductLifetime, a Junior ISA account becomes a standard ISA account when its holder turns 18.
+// Workplace pension accounts (PensionProduct) are held by an employee instead of a customer
+type Account struct {
+	ID         string     `json:"id"`
+	CustomerID string     `json:"customerId,omitempty"`
+	EmployeeID string     `json:"employeeId,omitempty"`
+	Product    string     `json:"product"`
+	Status     string     `json:"status"`
+	OpenedAt   time.Time  `json:"openedAt"`
+	ClosedAt   *time.Time `json:"closedAt,omitempty"`
+}
+
+// Note: A Junior ISA account holds standard ISA investments, so its history carries over unchanged
+// when it converts
+func (a *Account) InvestmentProduct() string {
+	if a.Product == ISAProductJunior {
+		return ISAProductStandard
+	}
+	return a.Product
+}
@@ -0,0 +1,42 @@
+package models
+
+const (
+	AMLOutcomeAllow  = "allow"
+	AMLOutcomeHold   = "hold"
+	AMLOutcomeReject = "reject"
+)
+
+const (
+	AMLResolutionReleased  = "released"
+	AMLResolutionCancelled = "cancelled"
+)
+
+// AMLRuleResult is the outcome of a single rule that fired
+type AMLRuleResult struct {
+	Rule    string `json:"rule"`
+	Outcome string `json:"outcome"`
+	Reason  string `json:"reason"`
+}
+
+// AMLDecision is the combined outcome of all rules. The most severe outcome wins
+type AMLDecision struct {
+	Outcome string          `json:"outcome"`
+	Results []AMLRuleResult `json:"results"`
+}
+
+// AMLActivity is the recent history of a customer that rules are evaluated against
+type AMLActivity struct {
+	VelocityDepositCount  int
+	VelocityDepositAmount float64
+	RecentDepositAmount   float64
+}
+
+// AMLReview is a held investment awaiting a decision in the review queue
+type AMLReview struct {
+	Investment Investment      `json:"investment"`
+	Alerts     []AMLRuleResult `json:"alerts"`
+}
+
+type AMLReviewDecisionRequest struct {
+	Note string `json:"note"`
+}
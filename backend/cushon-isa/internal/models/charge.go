@@ -0,0 +1,75 @@
+package models
+
+import "time"
+
+// Charge types. Platform and fixed fees are collected, OCF is taken within the fund price
+const (
+	ChargeTypePlatform = "platform"
+	ChargeTypeFixed    = "fixed"
+	ChargeTypeOCF      = "ocf"
+)
+
+// Charge collection methods
+const (
+	ChargeMethodUnits = "units"
+	ChargeMethodCash  = "cash"
+)
+
+// Charge is a collected charge. Each one is also recorded as an investment of type charge
+type Charge struct {
+	ID           string    `json:"id"`
+	CustomerID   string    `json:"customerId"`
+	AccountID    string    `json:"accountId"`
+	FundID       string    `json:"fundId"`
+	FundName     string    `json:"fundName"`
+	InvestmentID string    `json:"investmentId"`
+	Type         string    `json:"type"`
+	PeriodStart  string    `json:"periodStart"`
+	PeriodEnd    string    `json:"periodEnd"`
+	Amount       float64   `json:"amount"`
+	Method       string    `json:"method"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// PlatformFeeTier is a band of portfolio value. Rates are annual percentages, e.g. 0.30 is 0.30% a year
+type PlatformFeeTier struct {
+	LowerBound float64  `json:"lowerBound"`
+	UpperBound *float64 `json:"upperBound"` // nil for the top band
+	AnnualRate float64  `json:"annualRate"`
+}
+
+type UpdateFeeTiersRequest struct {
+	Tiers []PlatformFeeTier `json:"tiers"`
+}
+
+// ChargeSummary is the costs and charges disclosure for a customer over a date range.
+// Platform and fund charges are accrued amounts, fixed fees are collected amounts
+type ChargeSummary struct {
+	CustomerID      string   `json:"customerId"`
+	From            string   `json:"from"`
+	To              string   `json:"to"`
+	PlatformCharges float64  `json:"platformCharges"`
+	FundCharges     float64  `json:"fundCharges"`
+	FixedCharges    float64  `json:"fixedCharges"`
+	TotalCharges    float64  `json:"totalCharges"`
+	Collected       []Charge `json:"collected"`
+}
+
+// ChargeAccrualRun summarises an accrual job. Dates are inclusive
+type ChargeAccrualRun struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Dates    int    `json:"dates"`
+	Accruals int    `json:"accruals"`
+}
+
+// ChargeCollectionRun summarises a monthly collection
+type ChargeCollectionRun struct {
+	Month   string  `json:"month"`
+	Charges int     `json:"charges"`
+	Amount  float64 `json:"amount"`
+}
+
+type CollectChargesRequest struct {
+	Month string `json:"month"` // YYYY-MM
+}
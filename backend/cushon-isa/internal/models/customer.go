@@ -1,16 +1,110 @@
 package models
 
+import "time"
+
+const (
+	CustomerStatusActive = "active"
+	CustomerStatusClosed = "closed"
+	CustomerStatusErased = "erased"
+)
+
+// ISA products. Junior ISAs convert to the standard product at 18. Lifetime ISAs are held
+// alongside the standard product, see Investment.Product
+const (
+	ISAProductStandard = "isa"
+	ISAProductJunior   = "junior_isa"
+	ISAProductLifetime = "lifetime_isa"
+)
+
 type RetailCustomer struct {
-	ID        string `json:"id"`
-	FirstName string `json:"firstname"`
-	LastName  string `json:"lastname"`
-	Email     string `json:"email"`
+	ID            string  `json:"id"`
+	FirstName     string  `json:"firstname"`
+	LastName      string  `json:"lastname"`
+	Email         string  `json:"email"`
+	EmailVerified bool    `json:"emailVerified"`
+	Address       Address `json:"address"`
+	Status        string  `json:"status"`
+
+	// KYC data required to hold an ISA
+	DateOfBirth string `json:"dateOfBirth,omitempty"` // YYYY-MM-DD
+	UKResident  bool   `json:"ukResident"`
+	// Note: NINumber is only ever populated with a masked value for responses
+	NINumber          string `json:"niNumber,omitempty"`
+	EncryptedNINumber string `json:"-"`
+
+	KYCStatus string `json:"kycStatus"`
+
+	Product string `json:"product"`
+	// Note: The parent or guardian who runs a Junior ISA
+	RegisteredContactID string     `json:"registeredContactId,omitempty"`
+	ConvertedAt         *time.Time `json:"convertedAt,omitempty"`
+}
+
+type Address struct {
+	Line1    string `json:"line1"`
+	Line2    string `json:"line2"`
+	City     string `json:"city"`
+	Postcode string `json:"postcode"`
+	Country  string `json:"country"`
 }
 
 type CreateRetailCustomerRequest struct {
-	FirstName string `json:"firstname"`
-	LastName  string `json:"lastname"`
-	Email     string `json:"email"`
+	FirstName   string `json:"firstname"`
+	LastName    string `json:"lastname"`
+	Email       string `json:"email"`
+	DateOfBirth string `json:"dateOfBirth"`
+	UKResident  bool   `json:"ukResident"`
+	NINumber    string `json:"niNumber"`
+}
+
+// Note: Opened by the registered contact on behalf of the child. Email is optional,
+// children often do not have one
+type CreateJuniorISARequest struct {
+	RegisteredContactID string `json:"registeredContactId"`
+	FirstName           string `json:"firstname"`
+	LastName            string `json:"lastname"`
+	Email               string `json:"email"`
+	DateOfBirth         string `json:"dateOfBirth"`
+	UKResident          bool   `json:"ukResident"`
+	NINumber            string `json:"niNumber"`
+}
+
+// Note: Pointer fields allow us to distinguish between a field being omitted and being set to empty
+type UpdateRetailCustomerRequest struct {
+	FirstName *string  `json:"firstname"`
+	LastName  *string  `json:"lastname"`
+	Email     *string  `json:"email"`
+	Address   *Address `json:"address"`
+
+	DateOfBirth *string `json:"dateOfBirth"`
+	UKResident  *bool   `json:"ukResident"`
+	NINumber    *string `json:"niNumber"`
+}
+
+// Note: Everything the investment service needs to know to decide whether a customer may subscribe to an ISA
+type CustomerEligibility struct {
+	Status  string
+	Product string
+	// Note: Set for Junior ISAs, the parent or guardian who runs the account
+	RegisteredContactID string
+	HasDOB              bool
+	IsAdult             bool
+	UKResident          bool
+	HasNINumber         bool
+	KYCStatus           string
+}
+
+// Note: Junior ISAs are for under 18s and the child is only given an NI number at 16.
+// KYCStatus is the registered contact's for a Junior ISA, see investment getCustomerEligibility
+func (e CustomerEligibility) IsComplete() bool {
+	if e.Product == ISAProductJunior {
+		return e.HasDOB && !e.IsAdult && e.UKResident
+	}
+	return e.HasDOB && e.IsAdult && e.UKResident && e.HasNINumber
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
 }
 
 type GetRetailCustomerByIdRequest struct {
@@ -26,5 +120,14 @@ func NewRetailCustomer(firstName, lastName, email string) RetailCustomer {
 		FirstName: firstName,
 		LastName:  lastName,
 		Email:     email,
+		Status:    CustomerStatusActive,
+		KYCStatus: KYCStatusNotStarted,
+		Product:   ISAProductStandard,
 	}
 }
+
+// JuniorISAConversionRun is the result of converting Junior ISAs whose holders have turned 18
+type JuniorISAConversionRun struct {
+	On        string `json:"on"`
+	Converted int    `json:"converted"`
+}
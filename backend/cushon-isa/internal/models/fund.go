@@ -1,8 +1,101 @@
 package models
 
-type Fund struct {
-	ID          string `json:"id"`
+const (
+	FundStatusActive  = "active"
+	FundStatusRetired = "retired"
+)
+
+const (
+	AssetClassEquity      = "equity"
+	AssetClassFixedIncome = "fixed_income"
+	AssetClassMixed       = "mixed"
+	AssetClassProperty    = "property"
+	AssetClassMoneyMarket = "money_market"
+)
+
+type RiskLevel struct {
+	ID          int    `json:"id"`
 	Name        string `json:"name"`
 	Description string `json:"description"`
-	RiskLevel   string `json:"riskLevel"`
+}
+
+type Fund struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	RiskLevel   *RiskLevel `json:"riskLevel,omitempty"`
+	ISIN        string     `json:"isin,omitempty"`
+	// Note: Ongoing charges figure as an annual percentage
+	OCF        float64 `json:"ocf"`
+	Currency   string  `json:"currency"`
+	AssetClass string  `json:"assetClass"`
+	// Note: Meets the fund's ethical / ESG screening criteria
+	Ethical bool `json:"ethical"`
+	// Note: Retired funds keep their holdings but accept no new deposits
+	Status  string       `json:"status"`
+	Dealing *FundDealing `json:"dealing,omitempty"`
+}
+
+// FundFilter narrows and orders the fund list. Zero values and nil pointers are ignored
+type FundFilter struct {
+	Search        string
+	RiskLevelID   int
+	RiskLevelName string
+	MinOCF        *float64
+	MaxOCF        *float64
+	AssetClass    string
+	Ethical       *bool
+	SortBy        string
+	// Note: "asc", "desc" or empty for the sort's own default
+	SortOrder string
+}
+
+type CreateFundRequest struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	RiskLevelID int     `json:"riskLevelId"`
+	ISIN        string  `json:"isin"`
+	OCF         float64 `json:"ocf"`
+	Currency    string  `json:"currency"`   // Defaults to GBP
+	AssetClass  string  `json:"assetClass"` // Defaults to mixed
+	Ethical     bool    `json:"ethical"`
+}
+
+// Note: Partial update. Only fields present in the request are changed
+type UpdateFundRequest struct {
+	Name        *string  `json:"name"`
+	Description *string  `json:"description"`
+	RiskLevelID *int     `json:"riskLevelId"`
+	ISIN        *string  `json:"isin"`
+	OCF         *float64 `json:"ocf"`
+	Currency    *string  `json:"currency"`
+	AssetClass  *string  `json:"assetClass"`
+	Ethical     *bool    `json:"ethical"`
+}
+
+// Note: Times are HH:MM in the fund's time zone. Dealing days are ISO weekdays, 1 = Monday
+type FundDealing struct {
+	ValuationTime string           `json:"valuationTime"`
+	Cutoff        string           `json:"cutoff"`
+	DealingDays   []int            `json:"dealingDays"`
+	Timezone      string           `json:"timezone"`
+	Holidays      []DealingHoliday `json:"holidays"`
+}
+
+type DealingHoliday struct {
+	Date        string `json:"date"`
+	Description string `json:"description,omitempty"`
+}
+
+type FundPrice struct {
+	FundID    string  `json:"fundId"`
+	PriceDate string  `json:"priceDate"`
+	Price     float64 `json:"price"`
+	// Number of orders priced when this price was loaded
+	PricedInvestments int `json:"pricedInvestments"`
+}
+
+type LoadFundPriceRequest struct {
+	PriceDate string  `json:"priceDate"`
+	Price     float64 `json:"price"`
 }
@@ -4,16 +4,58 @@ import (
 	"time"
 )
 
+const (
+	InvestmentTypeDeposit    = "deposit"
+	InvestmentTypeWithdrawal = "withdrawal"
+	// Note: Charges are created by the charges engine and cannot be requested through the API
+	InvestmentTypeCharge = "charge"
+	// Note: Units re-registered to another ISA manager on an in specie transfer out
+	InvestmentTypeTransferOut = "transfer_out"
+)
+
+// Note: Investment lifecycle. See investment/lifecycle.go for the valid transitions
+const (
+	InvestmentStatusHeld           = "held"
+	InvestmentStatusPending        = "pending"
+	InvestmentStatusCashReceived   = "cash_received"
+	InvestmentStatusUnitsAllocated = "units_allocated"
+	InvestmentStatusSettled        = "settled"
+	InvestmentStatusFailed         = "failed"
+	InvestmentStatusCancelled      = "cancelled"
+)
+
 type Investment struct {
-	ID         string    `json:"id"`
+	ID string `json:"id"`
+	// Note: Empty for workplace pension investments, which are held by an employee through the account
 	CustomerID string    `json:"customerId"`
+	AccountID  string    `json:"accountId"`
 	FundID     string    `json:"fundId"`
 	Amount     float64   `json:"amount"`
+	Type       string    `json:"type"`
 	CreatedAt  time.Time `json:"createdAt"`
-	Status     string    `json:"status"` // TODO: We might want something to confirm status of investments here
+	Status     string    `json:"status"`
+	// Note: Forward pricing. Unit price and units are set once the price for the dealing date is loaded
+	DealingDate string   `json:"dealingDate,omitempty"`
+	UnitPrice   *float64 `json:"unitPrice,omitempty"`
+	Units       *float64 `json:"units,omitempty"`
+	// Note: Set when the customer accepted that the fund is riskier than their risk profile
+	RiskAcknowledged bool `json:"riskAcknowledged"`
+	// Note: Deposits only. Quoted by the customer on their bank transfer so the receipt can be matched
+	PaymentReference string `json:"paymentReference,omitempty"`
+	// Note: The ISA the investment is held in, the standard ISA or a Lifetime ISA
+	Product string `json:"product"`
+	// Note: Lifetime ISA withdrawals that do not qualify pay a government charge out of the amount
+	WithdrawalCharge *float64 `json:"withdrawalCharge,omitempty"`
+	// Suitability warnings raised when the investment was placed. Not stored
+	Warnings []string `json:"warnings,omitempty"`
+	// Only populated when fetching a single investment
+	StatusHistory []InvestmentStatusChange `json:"statusHistory,omitempty"`
 }
 
 type InvestmentSummary struct {
+	// Note: Only set for a single account, customer totals add up all of the customer's accounts
+	AccountID       string  `json:"account_id,omitempty"`
+	Product         string  `json:"product,omitempty"`
 	CustomerID      string  `json:"customer_id"`
 	FirstName       string  `json:"first_name"`
 	LastName        string  `json:"last_name"`
@@ -21,12 +63,62 @@ type InvestmentSummary struct {
 	FundID          string  `json:"fund_id"`
 	FundName        string  `json:"fund_name"`
 	TotalInvestment float64 `json:"total_investment"`
+	// Note: Settled money has completed the lifecycle, pending money is still moving through it
+	SettledInvestment float64 `json:"settled_investment"`
+	PendingInvestment float64 `json:"pending_investment"`
+	// Note: From the latest nightly valuation snapshot. Omitted until the holding has been valued
+	Units         *float64 `json:"units,omitempty"`
+	MarketValue   *float64 `json:"market_value,omitempty"`
+	ValuationDate string   `json:"valuation_date,omitempty"`
+}
+
+// InvestmentFilter narrows a customer's investment history. Zero values and nil pointers are ignored.
+// From and To are dates and both are inclusive
+type InvestmentFilter struct {
+	AccountID string
+	From      *time.Time
+	To        *time.Time
+	FundID    string
+	Type      string
+	Status    string
+}
+
+type InvestmentStatusChange struct {
+	ID           string    `json:"id"`
+	InvestmentID string    `json:"investmentId"`
+	FromStatus   string    `json:"fromStatus,omitempty"`
+	ToStatus     string    `json:"toStatus"`
+	Reason       string    `json:"reason,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+type TransitionInvestmentRequest struct {
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+// SettlementRun summarises a settlement batch
+type SettlementRun struct {
+	Cutoff  time.Time `json:"cutoff"`
+	Settled int       `json:"settled"`
+	Errors  int       `json:"errors"`
 }
 
 type CreateInvestmentRequest struct {
 	CustomerID string  `json:"customerId"`
 	FundID     string  `json:"fundId"`
 	Amount     float64 `json:"amount"`
+	Type       string  `json:"type"` // Defaults to deposit
+	// Note: Required when the fund's risk level is above the customer's risk profile
+	RiskAcknowledged bool `json:"riskAcknowledged"`
+	// Note: Deposits only. Quoted by the customer on their bank transfer so the receipt can be matched
+	PaymentReference string `json:"paymentReference,omitempty"`
+	// Note: The account to invest in. When omitted the customer's account for the product is used
+	AccountID string `json:"accountId,omitempty"`
+	// Note: Defaults to the standard ISA, lifetime_isa for a Lifetime ISA
+	Product string `json:"product"`
+	// Note: Lifetime ISA withdrawals only, see LifetimeISAWithdrawalFirstHome
+	WithdrawalReason string `json:"withdrawalReason,omitempty"`
 }
 
 func NewInvestment(customerId, fundId string, amount float64) Investment {
@@ -34,5 +126,8 @@ func NewInvestment(customerId, fundId string, amount float64) Investment {
 		CustomerID: customerId,
 		FundID:     fundId,
 		Amount:     amount,
+		Type:       InvestmentTypeDeposit,
+		Status:     InvestmentStatusPending,
+		Product:    ISAProductStandard,
 	}
 }
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+const (
+	KYCStatusNotStarted = "not_started"
+	KYCStatusPending    = "pending"
+	KYCStatusVerified   = "verified"
+	KYCStatusReferred   = "referred"
+	KYCStatusRejected   = "rejected"
+)
+
+type KYCVerification struct {
+	ID                string    `json:"id"`
+	CustomerID        string    `json:"customerId"`
+	Provider          string    `json:"provider"`
+	ProviderReference string    `json:"providerReference"`
+	Status            string    `json:"status"`
+	Reason            string    `json:"reason,omitempty"`
+	CreatedAt         time.Time `json:"createdAt"`
+	UpdatedAt         time.Time `json:"updatedAt"`
+}
+
+// Note: Payload sent by a KYC provider when the outcome of a check changes
+type KYCCallbackRequest struct {
+	Provider          string `json:"provider"`
+	ProviderReference string `json:"providerReference"`
+	Status            string `json:"status"`
+	Reason            string `json:"reason"`
+}
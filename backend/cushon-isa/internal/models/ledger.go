@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// LedgerCheck is the result of proving the ledger. It is balanced when every journal nets to zero,
+// the trial balance nets to zero and the ledger agrees with the investments for every holding
+type LedgerCheck struct {
+	Balanced           bool                   `json:"balanced"`
+	Journals           int                    `json:"journals"`
+	TrialBalance       []LedgerAccountBalance `json:"trialBalance"`
+	UnbalancedJournals []string               `json:"unbalancedJournals"`
+	Mismatches         []LedgerMismatch       `json:"mismatches"`
+	CheckedAt          time.Time              `json:"checkedAt"`
+}
+
+// LedgerAccountBalance is the net of all postings to one type of account. Debits are positive
+type LedgerAccountBalance struct {
+	AccountType string  `json:"accountType"`
+	Amount      float64 `json:"amount"`
+	Units       float64 `json:"units"`
+}
+
+// LedgerMismatch is a holding where the ledger does not agree with the investments
+type LedgerMismatch struct {
+	CustomerID      string  `json:"customerId"`
+	FundID          string  `json:"fundId"`
+	LedgerTotal     float64 `json:"ledgerTotal"`
+	ExpectedTotal   float64 `json:"expectedTotal"`
+	LedgerSettled   float64 `json:"ledgerSettled"`
+	ExpectedSettled float64 `json:"expectedSettled"`
+}
+
+// LedgerBackfill summarises posting investments that pre-date the ledger
+type LedgerBackfill struct {
+	Investments int `json:"investments"`
+	Journals    int `json:"journals"`
+}
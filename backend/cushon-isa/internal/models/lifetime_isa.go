@@ -0,0 +1,70 @@
+package models
+
+import "time"
+
+// Note: Lifetime ISA withdrawals for these reasons, or made from age 60, do not pay the withdrawal charge
+const (
+	LifetimeISAWithdrawalFirstHome       = "first_home"
+	LifetimeISAWithdrawalTerminalIllness = "terminal_illness"
+)
+
+const (
+	LifetimeISABonusClaimed = "claimed"
+	LifetimeISABonusPaid    = "paid"
+)
+
+type LifetimeISA struct {
+	CustomerID string    `json:"customerId"`
+	AccountID  string    `json:"accountId"`
+	OpenedAt   time.Time `json:"openedAt"`
+	// Note: Only populated when fetching a single Lifetime ISA
+	Bonuses []LifetimeISABonus `json:"bonuses,omitempty"`
+}
+
+// LifetimeISABonus is the government bonus claimed on a single contribution
+type LifetimeISABonus struct {
+	ID                  string     `json:"id"`
+	CustomerID          string     `json:"customerId"`
+	FundID              string     `json:"fundId"`
+	DepositInvestmentID string     `json:"depositInvestmentId"`
+	ClaimMonth          string     `json:"claimMonth"` // YYYY-MM
+	Contribution        float64    `json:"contribution"`
+	Bonus               float64    `json:"bonus"`
+	Status              string     `json:"status"`
+	PaidInvestmentID    string     `json:"paidInvestmentId,omitempty"`
+	CreatedAt           time.Time  `json:"createdAt"`
+	PaidAt              *time.Time `json:"paidAt,omitempty"`
+}
+
+type LifetimeISAAllowance struct {
+	Allowance  float64 `json:"allowance"`
+	Subscribed float64 `json:"subscribed"`
+	Remaining  float64 `json:"remaining"`
+}
+
+// Note: Everything the investment service needs to know about a customer's Lifetime ISA
+type LifetimeISAEligibility struct {
+	Open bool
+	// Note: Contributions stop at 50
+	CanContribute bool
+	// Note: Withdrawals from 60 never pay the withdrawal charge
+	Over60 bool
+}
+
+// LifetimeISABonusClaimRun summarises the bonus claimed for contributions made before a month
+type LifetimeISABonusClaimRun struct {
+	Before  string  `json:"before"` // YYYY-MM-DD
+	Claimed int     `json:"claimed"`
+	Bonus   float64 `json:"bonus"`
+}
+
+// LifetimeISABonusPaymentRun summarises crediting the bonus received for a claim month
+type LifetimeISABonusPaymentRun struct {
+	ClaimMonth string  `json:"claimMonth"`
+	Customers  int     `json:"customers"`
+	Bonus      float64 `json:"bonus"`
+}
+
+type PayLifetimeISABonusesRequest struct {
+	Month string `json:"month"` // YYYY-MM
+}
@@ -0,0 +1,108 @@
+package models
+
+import "time"
+
+// Note: Workplace pension accounts and investments use this product. They are held by an
+// employee rather than a retail customer
+const PensionProduct = "pension"
+
+const (
+	EmployeeStatusActive = "active"
+	EmployeeStatusLeft   = "left"
+)
+
+type Employer struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	PAYEReference string `json:"payeReference"`
+	// Note: The scheme's default fund, every contribution is invested in it
+	DefaultFundID string    `json:"defaultFundId"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+type CreateEmployerRequest struct {
+	Name          string `json:"name"`
+	PAYEReference string `json:"payeReference"`
+	DefaultFundID string `json:"defaultFundId"`
+}
+
+type Employee struct {
+	ID               string     `json:"id"`
+	EmployerID       string     `json:"employerId"`
+	PayrollReference string     `json:"payrollReference"`
+	FirstName        string     `json:"firstname"`
+	LastName         string     `json:"lastname"`
+	Email            string     `json:"email"`
+	DateOfBirth      string     `json:"dateOfBirth"` // YYYY-MM-DD
+	Status           string     `json:"status"`
+	AccountID        string     `json:"accountId"`
+	CreatedAt        time.Time  `json:"createdAt"`
+	LeftAt           *time.Time `json:"leftAt,omitempty"`
+}
+
+type CreateEmployeeRequest struct {
+	EmployerID       string `json:"employerId"`
+	PayrollReference string `json:"payrollReference"`
+	FirstName        string `json:"firstname"`
+	LastName         string `json:"lastname"`
+	Email            string `json:"email"`
+	DateOfBirth      string `json:"dateOfBirth"`
+}
+
+// PensionContribution is what was paid into an employee's pension for one payroll period
+type PensionContribution struct {
+	ID             string    `json:"id"`
+	EmployerID     string    `json:"employerId"`
+	EmployeeID     string    `json:"employeeId"`
+	AccountID      string    `json:"accountId"`
+	InvestmentID   string    `json:"investmentId"`
+	FundID         string    `json:"fundId"`
+	PeriodStart    string    `json:"periodStart"` // YYYY-MM-DD
+	PeriodEnd      string    `json:"periodEnd"`   // YYYY-MM-DD
+	EmployeeAmount float64   `json:"employeeAmount"`
+	EmployerAmount float64   `json:"employerAmount"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+// ContributionLine is one employee's row in an employer's payroll submission
+type ContributionLine struct {
+	PayrollReference string  `json:"payrollReference"`
+	EmployeeAmount   float64 `json:"employeeAmount"`
+	EmployerAmount   float64 `json:"employerAmount"`
+}
+
+type SubmitContributionsRequest struct {
+	PeriodStart   string             `json:"periodStart"` // YYYY-MM-DD
+	PeriodEnd     string             `json:"periodEnd"`   // YYYY-MM-DD
+	Contributions []ContributionLine `json:"contributions"`
+}
+
+// ContributionRun summarises a payroll submission
+type ContributionRun struct {
+	EmployerID    string                `json:"employerId"`
+	PeriodStart   string                `json:"periodStart"`
+	PeriodEnd     string                `json:"periodEnd"`
+	EmployeeTotal float64               `json:"employeeTotal"`
+	EmployerTotal float64               `json:"employerTotal"`
+	Contributions []PensionContribution `json:"contributions"`
+}
+
+// Pension is an employee's pension account with what it holds in each fund
+type Pension struct {
+	AccountID  string           `json:"accountId"`
+	EmployeeID string           `json:"employeeId"`
+	EmployerID string           `json:"employerId"`
+	Status     string           `json:"status"`
+	OpenedAt   time.Time        `json:"openedAt"`
+	Holdings   []PensionHolding `json:"holdings"`
+}
+
+// Note: Totals at cost, the same as customer fund totals. Settled money has completed the
+// lifecycle, pending money is still moving through it
+type PensionHolding struct {
+	FundID            string  `json:"fundId"`
+	FundName          string  `json:"fundName"`
+	TotalInvestment   float64 `json:"totalInvestment"`
+	SettledInvestment float64 `json:"settledInvestment"`
+	PendingInvestment float64 `json:"pendingInvestment"`
+}
@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// Performance periods
+const (
+	PerformancePeriod1M        = "1M"
+	PerformancePeriod3M        = "3M"
+	PerformancePeriod1Y        = "1Y"
+	PerformancePeriodInception = "inception"
+)
+
+// Performance of a customer's portfolio or a single fund holding over a period.
+// Returns are fractions, e.g. 0.05 is 5%, and are nil when there is nothing to measure
+type Performance struct {
+	CustomerID       string  `json:"customerId"`
+	FundID           string  `json:"fundId,omitempty"`
+	FundName         string  `json:"fundName,omitempty"`
+	Period           string  `json:"period"`
+	StartDate        string  `json:"startDate"`
+	EndDate          string  `json:"endDate"`
+	StartValue       float64 `json:"startValue"`
+	EndValue         float64 `json:"endValue"`
+	NetContributions float64 `json:"netContributions"`
+	GainLoss         float64 `json:"gainLoss"`
+	// Note: Time weighted return is cumulative over the period. Money weighted return is annualised (XIRR)
+	TimeWeightedReturn  *float64         `json:"timeWeightedReturn"`
+	MoneyWeightedReturn *float64         `json:"moneyWeightedReturn"`
+	Series              []ValuationPoint `json:"series,omitempty"`
+	// Per fund breakdown, only set for the whole portfolio
+	Funds []Performance `json:"funds,omitempty"`
+}
+
+// ValuationPoint is the value at the end of a day and the net cash paid in that day
+type ValuationPoint struct {
+	Date    time.Time `json:"date"`
+	Value   float64   `json:"value"`
+	NetFlow float64   `json:"netFlow"`
+}
+
+// ValuationRun summarises a valuation job. Dates are inclusive
+type ValuationRun struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Dates    int    `json:"dates"`
+	Holdings int    `json:"holdings"`
+}
+
+type RunValuationRequest struct {
+	From string `json:"from"` // YYYY-MM-DD
+	To   string `json:"to"`   // Defaults to from
+}
@@ -0,0 +1,50 @@
+package models
+
+// Bank statement formats
+const (
+	StatementFormatCSV     = "csv"
+	StatementFormatCAMT053 = "camt053"
+)
+
+// Bank statement line match outcomes
+const (
+	// Reference and amount agree and the deposit moved to cash received
+	MatchStatusMatched = "matched"
+	// Reference found but the amount differs from the order
+	MatchStatusPartial = "partial"
+	// No pending deposit with the reference
+	MatchStatusUnmatched = "unmatched"
+	// The deposit or bank transaction has already been reconciled
+	MatchStatusDuplicate = "duplicate"
+	// Payments out and entries not yet booked are not reconciled against deposits
+	MatchStatusIgnored = "ignored"
+)
+
+type BankStatementLine struct {
+	ID            string  `json:"id"`
+	StatementID   string  `json:"statementId"`
+	LineNumber    int     `json:"lineNumber"`
+	BookingDate   string  `json:"bookingDate"`
+	Amount        float64 `json:"amount"`
+	Currency      string  `json:"currency"`
+	Reference     string  `json:"reference"`
+	Counterparty  string  `json:"counterparty,omitempty"`
+	TransactionID string  `json:"transactionId,omitempty"`
+	MatchStatus   string  `json:"matchStatus"`
+	InvestmentID  string  `json:"investmentId,omitempty"`
+	Note          string  `json:"note,omitempty"`
+}
+
+// ReconciliationResult summarises an imported bank statement
+type ReconciliationResult struct {
+	StatementID  string              `json:"statementId"`
+	Format       string              `json:"format"`
+	StatementRef string              `json:"statementRef,omitempty"`
+	Lines        int                 `json:"lines"`
+	Matched      int                 `json:"matched"`
+	Partial      int                 `json:"partial"`
+	Unmatched    int                 `json:"unmatched"`
+	Duplicate    int                 `json:"duplicate"`
+	Ignored      int                 `json:"ignored"`
+	Items        []BankStatementLine `json:"items"`
+}
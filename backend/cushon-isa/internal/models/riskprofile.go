@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+type RiskQuestion struct {
+	ID       int                  `json:"id"`
+	Position int                  `json:"position"`
+	Text     string               `json:"text"`
+	Options  []RiskQuestionOption `json:"options"`
+}
+
+// Note: Scores are kept server side so customers cannot game the questionnaire
+type RiskQuestionOption struct {
+	ID       int    `json:"id"`
+	Position int    `json:"position"`
+	Text     string `json:"text"`
+	Score    int    `json:"-"`
+}
+
+type RiskAnswer struct {
+	QuestionID int `json:"questionId"`
+	OptionID   int `json:"optionId"`
+}
+
+type SubmitRiskProfileRequest struct {
+	Answers []RiskAnswer `json:"answers"`
+}
+
+type RiskProfile struct {
+	ID          string       `json:"id"`
+	CustomerID  string       `json:"customerId"`
+	RiskLevel   RiskLevel    `json:"riskLevel"`
+	Score       int          `json:"score"`
+	Answers     []RiskAnswer `json:"answers,omitempty"`
+	CompletedAt time.Time    `json:"completedAt"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// Statement summarises a customer's account over a statement period.
+// Values are market values where the fund has been priced, otherwise the settled cash amount
+type Statement struct {
+	CustomerID    string             `json:"customerId"`
+	CustomerName  string             `json:"customerName"`
+	Period        string             `json:"period"`
+	PeriodStart   string             `json:"periodStart"`
+	PeriodEnd     string             `json:"periodEnd"`
+	OpeningValue  float64            `json:"openingValue"`
+	Contributions float64            `json:"contributions"`
+	Withdrawals   float64            `json:"withdrawals"`
+	Charges       float64            `json:"charges"`
+	Growth        float64            `json:"growth"`
+	ClosingValue  float64            `json:"closingValue"`
+	Holdings      []StatementHolding `json:"holdings"`
+	GeneratedAt   time.Time          `json:"generatedAt"`
+}
+
+type StatementHolding struct {
+	FundID   string  `json:"fundId"`
+	FundName string  `json:"fundName"`
+	Units    float64 `json:"units"`
+	// Note: Price is the latest price on or before the statement date. Zero if the fund has never been priced
+	Price     float64 `json:"price"`
+	PriceDate string  `json:"priceDate,omitempty"`
+	// Money invested before unit pricing existed, held at cost
+	UnpricedCash float64 `json:"unpricedCash"`
+	Value        float64 `json:"value"`
+}
+
+// StatementRun summarises a statement batch
+type StatementRun struct {
+	Period    string `json:"period"`
+	Generated int    `json:"generated"`
+	Errors    int    `json:"errors"`
+}
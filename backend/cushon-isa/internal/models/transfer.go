@@ -0,0 +1,136 @@
+package models
+
+import "time"
+
+// Transfer directions
+const (
+	TransferDirectionIn  = "in"
+	TransferDirectionOut = "out"
+)
+
+// Transfer out methods. Cash sells the holding, in specie re-registers the units with the receiving manager
+const (
+	TransferMethodCash     = "cash"
+	TransferMethodInSpecie = "in_specie"
+)
+
+// Transfer scopes. Current tax year subscriptions can only be moved in a full transfer
+const (
+	TransferScopeFull    = "full"
+	TransferScopePartial = "partial"
+)
+
+// Note: ISA transfer lifecycle. See transfer/lifecycle.go for the valid transitions
+const (
+	TransferStatusRequested = "requested"
+	TransferStatusSubmitted = "submitted"
+	TransferStatusAccepted  = "accepted"
+	TransferStatusCompleted = "completed"
+	TransferStatusRejected  = "rejected"
+	TransferStatusCancelled = "cancelled"
+)
+
+type ISATransfer struct {
+	ID         string `json:"id"`
+	CustomerID string `json:"customerId"`
+	FundID     string `json:"fundId"`
+	Direction  string `json:"direction"`
+	// Note: The ceding manager for transfers in and the receiving manager for transfers out
+	ProviderName      string  `json:"providerName"`
+	ProviderReference string  `json:"providerReference"`
+	ProviderFRN       string  `json:"providerFrn,omitempty"`
+	Scope             string  `json:"scope"`
+	Method            string  `json:"method"`
+	CurrentYearAmount float64 `json:"currentYearAmount"`
+	// Note: Subscriptions from earlier tax years do not count against this year's allowance
+	PreviousYearsAmount float64 `json:"previousYearsAmount"`
+	// Note: Transfers out only. First subscription in the current tax year, for the transfer history
+	FirstSubscriptionDate string   `json:"firstSubscriptionDate,omitempty"`
+	ReceivedAmount        *float64 `json:"receivedAmount,omitempty"`
+	TransferredAmount     *float64 `json:"transferredAmount,omitempty"`
+	TransferredUnits      *float64 `json:"transferredUnits,omitempty"`
+	Status                string   `json:"status"`
+	// Note: The deposit that credited a transfer in, or the withdrawal or transfer_out that emptied the holding
+	InvestmentID    string     `json:"investmentId,omitempty"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	StatusUpdatedAt time.Time  `json:"statusUpdatedAt"`
+	CompletedAt     *time.Time `json:"completedAt,omitempty"`
+	// Only populated when fetching a single transfer
+	StatusHistory []TransferStatusChange `json:"statusHistory,omitempty"`
+}
+
+type TransferStatusChange struct {
+	ID         string    `json:"id"`
+	TransferID string    `json:"transferId"`
+	FromStatus string    `json:"fromStatus,omitempty"`
+	ToStatus   string    `json:"toStatus"`
+	Reason     string    `json:"reason,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+type CreateTransferInRequest struct {
+	CustomerID          string  `json:"customerId"`
+	FundID              string  `json:"fundId"`
+	ProviderName        string  `json:"providerName"`
+	ProviderReference   string  `json:"providerReference"`
+	Scope               string  `json:"scope"`
+	CurrentYearAmount   float64 `json:"currentYearAmount"`
+	PreviousYearsAmount float64 `json:"previousYearsAmount"`
+}
+
+type CreateTransferOutRequest struct {
+	CustomerID        string `json:"customerId"`
+	ProviderName      string `json:"providerName"`
+	ProviderReference string `json:"providerReference"`
+	ProviderFRN       string `json:"providerFrn"`
+	Method            string `json:"method"`
+}
+
+// TransferHistory is the information the receiving manager needs to take on a transferred ISA
+type TransferHistory struct {
+	TransferID          string `json:"transferId"`
+	CustomerID          string `json:"customerId"`
+	FirstName           string `json:"firstName"`
+	LastName            string `json:"lastName"`
+	DateOfBirth         string `json:"dateOfBirth,omitempty"`
+	ReceivingManager    string `json:"receivingManager"`
+	ReceivingManagerFRN string `json:"receivingManagerFrn"`
+	ReceivingReference  string `json:"receivingReference"`
+	Scope               string `json:"scope"`
+	Method              string `json:"method"`
+	TaxYear             string `json:"taxYear"`
+	// Note: Current year subscriptions use allowance with the receiving manager too
+	CurrentYearSubscriptions   float64    `json:"currentYearSubscriptions"`
+	FirstSubscriptionDate      string     `json:"firstSubscriptionDate,omitempty"`
+	PreviousYearsSubscriptions float64    `json:"previousYearsSubscriptions"`
+	FundName                   string     `json:"fundName"`
+	FundISIN                   string     `json:"fundIsin,omitempty"`
+	TransferredAmount          *float64   `json:"transferredAmount,omitempty"`
+	TransferredUnits           *float64   `json:"transferredUnits,omitempty"`
+	Status                     string     `json:"status"`
+	CompletedAt                *time.Time `json:"completedAt,omitempty"`
+}
+
+// Note: Received amount is required to complete a transfer, it is the cash credited to the holding
+type TransitionTransferRequest struct {
+	Status         string   `json:"status"`
+	Reason         string   `json:"reason"`
+	ReceivedAmount *float64 `json:"receivedAmount,omitempty"`
+}
+
+// ISAAllowance is a customer's subscriptions against the annual allowance for a tax year
+type ISAAllowance struct {
+	CustomerID string  `json:"customerId"`
+	TaxYear    string  `json:"taxYear"`
+	Product    string  `json:"product"`
+	Allowance  float64 `json:"allowance"`
+	// Note: Deposits plus current year subscriptions transferred in
+	Subscribed               float64 `json:"subscribed"`
+	Deposits                 float64 `json:"deposits"`
+	TransferredInCurrentYear float64 `json:"transferredInCurrentYear"`
+	// Note: Shown for information only, never counted against the allowance
+	TransferredInPreviousYears float64 `json:"transferredInPreviousYears"`
+	Remaining                  float64 `json:"remaining"`
+	// Note: Lifetime ISA contributions have their own limit and also count in Subscribed
+	LifetimeISA *LifetimeISAAllowance `json:"lifetimeIsa,omitempty"`
+}
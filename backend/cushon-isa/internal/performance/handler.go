@@ -0,0 +1,63 @@
+package performance
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stcol316/cushon-isa/internal/models"
+	helper "github.com/stcol316/cushon-isa/pkg/helpers"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+// Note: ?period= is one of 1M, 3M, 1Y or inception and defaults to 1Y
+func periodParam(r *http.Request) string {
+	if period := r.URL.Query().Get("period"); period != "" {
+		return period
+	}
+	return models.PerformancePeriod1Y
+}
+
+func (h *Handler) GetPortfolioPerformanceHandler(w http.ResponseWriter, r *http.Request) {
+	customerID := chi.URLParam(r, "customerId")
+	if _, err := uuid.Parse(customerID); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "invalid customer ID format")
+		return
+	}
+
+	performance, err := h.service.getPortfolioPerformance(r.Context(), customerID, periodParam(r))
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, performance)
+}
+
+func (h *Handler) GetFundPerformanceHandler(w http.ResponseWriter, r *http.Request) {
+	customerID := chi.URLParam(r, "customerId")
+	fundID := chi.URLParam(r, "fundId")
+	if _, err := uuid.Parse(customerID); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "invalid customer ID format")
+		return
+	}
+	if _, err := uuid.Parse(fundID); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "invalid fund ID format")
+		return
+	}
+
+	performance, err := h.service.getFundPerformance(r.Context(), customerID, fundID, periodParam(r))
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, performance)
+}
@@ -0,0 +1,64 @@
+package performance
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// TODO: Use interfaces at service level instead of "repo *Repository"
+type PerformanceRepository interface {
+	ListValuations(ctx context.Context, customerID, fundID string, until time.Time) ([]holdingValuation, error)
+}
+
+type Repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// holdingValuation is one day's snapshot of a holding, see the valuation package
+type holdingValuation struct {
+	fundID   string
+	fundName string
+	date     time.Time
+	value    float64
+	netFlow  float64
+}
+
+// Note: Reads the nightly snapshots rather than replaying the investment history.
+// An empty fundID returns every fund
+func (r *Repository) listValuations(ctx context.Context, customerID, fundID string, until time.Time) ([]holdingValuation, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT v.fund_id, f.name, v.valuation_date, v.market_value, v.net_flow
+        FROM holding_valuations v
+        JOIN funds f ON f.id = v.fund_id
+        WHERE v.customer_id = $1 AND ($2::text = '' OR v.fund_id::text = $2)
+            AND v.valuation_date <= $3::date
+        ORDER BY v.valuation_date, f.name
+    `, customerID, fundID, until)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query valuations: %w", err)
+	}
+	defer rows.Close()
+
+	var valuations []holdingValuation
+	for rows.Next() {
+		var valuation holdingValuation
+		if err := rows.Scan(
+			&valuation.fundID,
+			&valuation.fundName,
+			&valuation.date,
+			&valuation.value,
+			&valuation.netFlow,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan valuation: %w", err)
+		}
+		valuations = append(valuations, valuation)
+	}
+
+	return valuations, nil
+}
@@ -0,0 +1,109 @@
+package performance
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var valuationRowColumns = []string{"fund_id", "name", "valuation_date", "market_value", "net_flow"}
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestRepository_ListValuations(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	until := date(2025, 1, 31)
+
+	mock.ExpectQuery("SELECT (.+) FROM holding_valuations v JOIN funds f").
+		WithArgs("customer1", "", until).
+		WillReturnRows(sqlmock.NewRows(valuationRowColumns).
+			AddRow("fund1", "Cushon Equities Fund", date(2025, 1, 2), 100.0, 100.0).
+			AddRow("fund1", "Cushon Equities Fund", date(2025, 1, 3), 105.0, 0.0))
+
+	valuations, err := repo.listValuations(context.Background(), "customer1", "", until)
+	assert.NoError(t, err)
+	require.Len(t, valuations, 2)
+	assert.Equal(t, 100.0, valuations[0].netFlow)
+	assert.Equal(t, 105.0, valuations[1].value)
+}
+
+func TestXIRR(t *testing.T) {
+	// Note: 1,000 invested returning 1,100 a year later is 10%
+	rate := xirr([]cashFlow{
+		{date: date(2024, 1, 1), amount: -1000},
+		{date: date(2024, 12, 31), amount: 1100},
+	})
+	require.NotNil(t, rate)
+	assert.InDelta(t, 0.10, *rate, 1e-6)
+
+	// No money was ever paid in so there is no rate
+	assert.Nil(t, xirr([]cashFlow{{date: date(2024, 1, 1), amount: 100}}))
+}
+
+func TestTimeWeightedReturn(t *testing.T) {
+	// Note: 10% growth, then a large contribution, then another 10%. The contribution does not affect TWR
+	series := []models.ValuationPoint{
+		{Date: date(2025, 1, 1), Value: 100},
+		{Date: date(2025, 1, 2), Value: 110},
+		{Date: date(2025, 1, 3), Value: 1110, NetFlow: 1000},
+		{Date: date(2025, 1, 4), Value: 1221},
+	}
+
+	twr := timeWeightedReturn(series)
+	require.NotNil(t, twr)
+	assert.InDelta(t, 0.21, *twr, 1e-9)
+
+	assert.Nil(t, timeWeightedReturn([]models.ValuationPoint{{Date: date(2025, 1, 1)}, {Date: date(2025, 1, 2)}}))
+}
+
+func TestService_GetFundPerformance(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	service := NewService(NewRepository(db))
+	service.now = func() time.Time { return time.Date(2025, 1, 5, 15, 0, 0, 0, time.UTC) }
+
+	// Note: 100 units bought at 1.00, 200 more at 1.10, then the price rises to 1.20
+	mock.ExpectQuery("SELECT (.+) FROM holding_valuations v JOIN funds f").
+		WithArgs("customer1", "fund1", date(2025, 1, 5)).
+		WillReturnRows(sqlmock.NewRows(valuationRowColumns).
+			AddRow("fund1", "Cushon Equities Fund", date(2025, 1, 2), 100.0, 100.0).
+			AddRow("fund1", "Cushon Equities Fund", date(2025, 1, 3), 100.0, 0.0).
+			AddRow("fund1", "Cushon Equities Fund", date(2025, 1, 4), 330.0, 220.0).
+			AddRow("fund1", "Cushon Equities Fund", date(2025, 1, 5), 360.0, 0.0))
+
+	performance, err := service.getFundPerformance(context.Background(), "customer1", "fund1", models.PerformancePeriodInception)
+	require.NoError(t, err)
+
+	// Note: 300 units at 1.20 after paying in 320
+	assert.Equal(t, "2025-01-01", performance.StartDate)
+	assert.Equal(t, 0.0, performance.StartValue)
+	assert.Equal(t, 360.0, performance.EndValue)
+	assert.Equal(t, 320.0, performance.NetContributions)
+	assert.Equal(t, 40.0, performance.GainLoss)
+	require.NotNil(t, performance.TimeWeightedReturn)
+	assert.InDelta(t, 0.2, *performance.TimeWeightedReturn, 1e-6)
+	require.NotNil(t, performance.MoneyWeightedReturn)
+	assert.Greater(t, *performance.MoneyWeightedReturn, 0.0)
+	assert.Len(t, performance.Series, 5)
+}
+
+func TestService_InvalidPeriod(t *testing.T) {
+	service := NewService(nil)
+
+	_, err := service.getPortfolioPerformance(context.Background(), "customer1", "5Y")
+	assert.ErrorIs(t, err, isaerrors.ErrInvalidPerformancePeriod)
+}
@@ -0,0 +1,134 @@
+package performance
+
+import (
+	"math"
+	"time"
+
+	"github.com/stcol316/cushon-isa/internal/models"
+)
+
+// Note: Return calculations over a daily valuation series. Each point is the value at the end
+// of the day with NetFlow being the money paid in (positive) or taken out (negative) that day.
+// Flows are treated as arriving at the end of the day because forward pricing buys and sells units
+// at that day's price, so new money has not yet had any growth
+
+// timeWeightedReturn chain links daily returns so the result is independent of the timing
+// and size of contributions. The first point is the starting value and its flow is ignored.
+// Returns nil when there was never anything invested to measure
+func timeWeightedReturn(series []models.ValuationPoint) *float64 {
+	growth := 1.0
+	measured := false
+	for i := 1; i < len(series); i++ {
+		base := series[i-1].Value
+		if base <= 0 {
+			continue
+		}
+		growth *= (series[i].Value - series[i].NetFlow) / base
+		measured = true
+	}
+	if !measured {
+		return nil
+	}
+
+	r := growth - 1
+	return &r
+}
+
+type cashFlow struct {
+	date   time.Time
+	amount float64
+}
+
+// Note: Money weighted return is the annualised internal rate of return (XIRR) from the customer's
+// point of view. The starting value is treated as paid in on the first day and the closing value
+// as received on the last, so contributions made at a good or bad time affect the result
+func moneyWeightedReturn(series []models.ValuationPoint) *float64 {
+	if len(series) < 2 {
+		return nil
+	}
+
+	first, last := series[0], series[len(series)-1]
+	flows := []cashFlow{{date: first.Date, amount: -first.Value}}
+	for _, point := range series[1:] {
+		if point.NetFlow != 0 {
+			flows = append(flows, cashFlow{date: point.Date, amount: -point.NetFlow})
+		}
+	}
+	flows = append(flows, cashFlow{date: last.Date, amount: last.Value})
+
+	return xirr(flows)
+}
+
+const (
+	xirrTolerance     = 1e-9
+	xirrMaxIterations = 100
+)
+
+// xirr solves for the annual rate where the net present value of the flows is zero.
+// Newton's method is tried first and bisection is used if it fails to converge.
+// Returns nil when there is no sign change in the flows, so no rate exists
+func xirr(flows []cashFlow) *float64 {
+	var hasIn, hasOut bool
+	for _, flow := range flows {
+		hasIn = hasIn || flow.amount < 0
+		hasOut = hasOut || flow.amount > 0
+	}
+	if !hasIn || !hasOut {
+		return nil
+	}
+
+	start := flows[0].date
+	years := make([]float64, len(flows))
+	for i, flow := range flows {
+		years[i] = flow.date.Sub(start).Hours() / 24 / 365
+	}
+
+	npv := func(rate float64) (float64, float64) {
+		var value, derivative float64
+		for i, flow := range flows {
+			discount := math.Pow(1+rate, years[i])
+			value += flow.amount / discount
+			derivative -= years[i] * flow.amount / (discount * (1 + rate))
+		}
+		return value, derivative
+	}
+
+	rate := 0.1
+	for i := 0; i < xirrMaxIterations; i++ {
+		value, derivative := npv(rate)
+		if math.Abs(value) < xirrTolerance {
+			return &rate
+		}
+		if derivative == 0 {
+			break
+		}
+		next := rate - value/derivative
+		if next <= -1 || math.IsNaN(next) || math.IsInf(next, 0) {
+			break
+		}
+		rate = next
+	}
+
+	// Note: Bisection between -99.99% and +10,000%. NPV falls as the rate rises for a normal investment
+	low, high := -0.9999, 100.0
+	lowValue, _ := npv(low)
+	highValue, _ := npv(high)
+	if lowValue*highValue > 0 {
+		return nil
+	}
+	for i := 0; i < 200; i++ {
+		mid := (low + high) / 2
+		midValue, _ := npv(mid)
+		if math.Abs(midValue) < xirrTolerance || (high-low)/2 < xirrTolerance {
+			return &mid
+		}
+		if (midValue > 0) == (lowValue > 0) {
+			low, lowValue = mid, midValue
+		} else {
+			high = mid
+		}
+	}
+
+	mid := (low + high) / 2
+	return &mid
+}
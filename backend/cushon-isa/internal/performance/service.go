@@ -0,0 +1,228 @@
+package performance
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/models"
+)
+
+const dateLayout = "2006-01-02"
+
+type Service struct {
+	repo *Repository
+	now  func() time.Time
+}
+
+func NewService(repo *Repository) *Service {
+	return &Service{repo: repo, now: time.Now}
+}
+
+// fundSeries is the daily valuation of one holding
+type fundSeries struct {
+	fundID   string
+	fundName string
+	points   []models.ValuationPoint
+}
+
+// Note: Whole portfolio performance with a per fund breakdown. The series is only returned for the portfolio
+func (s *Service) getPortfolioPerformance(ctx context.Context, customerID, period string) (*models.Performance, error) {
+	end := today(s.now())
+	start, err := periodStart(period, end)
+	if err != nil {
+		return nil, err
+	}
+
+	holdings, err := s.valuationSeries(ctx, customerID, "", end)
+	if err != nil {
+		return nil, err
+	}
+
+	performance := measure(sumSeries(holdings), start, end)
+	performance.CustomerID = customerID
+	performance.Period = period
+
+	for _, holding := range holdings {
+		fund := measure(holding.points, start, end)
+		fund.CustomerID = customerID
+		fund.FundID = holding.fundID
+		fund.FundName = holding.fundName
+		fund.Period = period
+		fund.Series = nil
+		performance.Funds = append(performance.Funds, *fund)
+	}
+
+	return performance, nil
+}
+
+func (s *Service) getFundPerformance(ctx context.Context, customerID, fundID, period string) (*models.Performance, error) {
+	end := today(s.now())
+	start, err := periodStart(period, end)
+	if err != nil {
+		return nil, err
+	}
+
+	holdings, err := s.valuationSeries(ctx, customerID, fundID, end)
+	if err != nil {
+		return nil, err
+	}
+	if len(holdings) == 0 {
+		return nil, isaerrors.ErrCustomerFundTotalNotFound
+	}
+
+	performance := measure(holdings[0].points, start, end)
+	performance.CustomerID = customerID
+	performance.FundID = holdings[0].fundID
+	performance.FundName = holdings[0].fundName
+	performance.Period = period
+
+	return performance, nil
+}
+
+// Note: Builds the daily series for each holding from the valuation snapshots. Every series covers
+// the same days, starting the day before the first snapshot with a value of zero so that the first
+// contribution is a flow rather than a starting value. A holding with no snapshot on a day is worth zero
+func (s *Service) valuationSeries(ctx context.Context, customerID, fundID string, end time.Time) ([]fundSeries, error) {
+	valuations, err := s.repo.listValuations(ctx, customerID, fundID, end)
+	if err != nil {
+		return nil, err
+	}
+	if len(valuations) == 0 {
+		return nil, nil
+	}
+
+	// Note: Valuations are ordered by date so the first is the earliest
+	dates := []time.Time{dateOnly(valuations[0].date).AddDate(0, 0, -1)}
+	index := make(map[time.Time]int)
+	byFund := make(map[string]*fundSeries)
+	var fundIDs []string
+
+	for _, valuation := range valuations {
+		day := dateOnly(valuation.date)
+		if _, ok := index[day]; !ok {
+			index[day] = len(dates)
+			dates = append(dates, day)
+		}
+		if _, ok := byFund[valuation.fundID]; !ok {
+			byFund[valuation.fundID] = &fundSeries{fundID: valuation.fundID, fundName: valuation.fundName}
+			fundIDs = append(fundIDs, valuation.fundID)
+		}
+	}
+
+	for _, id := range fundIDs {
+		points := make([]models.ValuationPoint, len(dates))
+		for i, day := range dates {
+			points[i].Date = day
+		}
+		byFund[id].points = points
+	}
+	for _, valuation := range valuations {
+		point := &byFund[valuation.fundID].points[index[dateOnly(valuation.date)]]
+		point.Value = valuation.value
+		point.NetFlow = valuation.netFlow
+	}
+
+	holdings := make([]fundSeries, 0, len(fundIDs))
+	for _, id := range fundIDs {
+		holdings = append(holdings, *byFund[id])
+	}
+	sort.Slice(holdings, func(i, j int) bool { return holdings[i].fundName < holdings[j].fundName })
+	return holdings, nil
+}
+
+// sumSeries adds the holdings together day by day. All holdings cover the same days
+func sumSeries(holdings []fundSeries) []models.ValuationPoint {
+	if len(holdings) == 0 {
+		return nil
+	}
+
+	total := make([]models.ValuationPoint, len(holdings[0].points))
+	for i := range total {
+		total[i].Date = holdings[0].points[i].Date
+		for _, holding := range holdings {
+			total[i].Value += holding.points[i].Value
+			total[i].NetFlow += holding.points[i].NetFlow
+		}
+		total[i].Value = roundPence(total[i].Value)
+		total[i].NetFlow = roundPence(total[i].NetFlow)
+	}
+
+	return total
+}
+
+// measure calculates performance over the part of the series from start to end.
+// If the series begins after start, e.g. the customer joined recently, it is measured from the beginning
+func measure(series []models.ValuationPoint, start, end time.Time) *models.Performance {
+	var window []models.ValuationPoint
+	for _, point := range series {
+		if !point.Date.Before(start) && !point.Date.After(end) {
+			window = append(window, point)
+		}
+	}
+
+	performance := &models.Performance{
+		StartDate: start.Format(dateLayout),
+		EndDate:   end.Format(dateLayout),
+		Series:    window,
+	}
+	if len(window) == 0 {
+		return performance
+	}
+
+	first, last := window[0], window[len(window)-1]
+	performance.StartDate = first.Date.Format(dateLayout)
+	// Note: Today is only valued once the nightly job has run
+	performance.EndDate = last.Date.Format(dateLayout)
+	performance.StartValue = first.Value
+	performance.EndValue = last.Value
+	for _, point := range window[1:] {
+		performance.NetContributions += point.NetFlow
+	}
+	performance.NetContributions = roundPence(performance.NetContributions)
+	performance.GainLoss = roundPence(performance.EndValue - performance.StartValue - performance.NetContributions)
+	performance.TimeWeightedReturn = roundReturn(timeWeightedReturn(window))
+	performance.MoneyWeightedReturn = roundReturn(moneyWeightedReturn(window))
+
+	return performance
+}
+
+// periodStart returns the valuation date the period is measured from. Since inception
+// starts at the zero time so the whole series is used
+func periodStart(period string, end time.Time) (time.Time, error) {
+	switch period {
+	case models.PerformancePeriod1M:
+		return end.AddDate(0, -1, 0), nil
+	case models.PerformancePeriod3M:
+		return end.AddDate(0, -3, 0), nil
+	case models.PerformancePeriod1Y:
+		return end.AddDate(-1, 0, 0), nil
+	case models.PerformancePeriodInception:
+		return time.Time{}, nil
+	default:
+		return time.Time{}, isaerrors.ErrInvalidPerformancePeriod
+	}
+}
+
+func today(now time.Time) time.Time {
+	return dateOnly(now.UTC())
+}
+
+func dateOnly(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func roundPence(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+func roundReturn(value *float64) *float64 {
+	if value == nil {
+		return nil
+	}
+	r := math.Round(*value*1e6) / 1e6
+	return &r
+}
@@ -0,0 +1,71 @@
+package reconciliation
+
+import (
+	"io"
+	"log"
+	"mime"
+	"net/http"
+
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	mw "github.com/stcol316/cushon-isa/internal/middleware"
+	helper "github.com/stcol316/cushon-isa/pkg/helpers"
+)
+
+// Note: Daily client money statements are well under this
+const maxStatementSize = 10 << 20
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+// Note: Admin only. The statement file is the raw request body. The format is taken from
+// ?format=csv|camt053, then the Content-Type, and is otherwise detected from the content
+func (h *Handler) ImportStatementHandler(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		if ct := r.Header.Get("Content-Type"); ct != "" {
+			if mediaType, _, err := mime.ParseMediaType(ct); err == nil && mediaType != "application/octet-stream" {
+				format = mediaType
+			}
+		}
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxStatementSize))
+	if err != nil {
+		helper.RespondWithProblem(w, r, isaerrors.ErrInvalidBankStatement.Wrap(err))
+		return
+	}
+	if len(data) == 0 {
+		helper.RespondWithProblem(w, r, isaerrors.ErrInvalidBankStatement)
+		return
+	}
+
+	result, err := h.service.Import(r.Context(), format, data)
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusCreated, result)
+}
+
+// Note: Admin only. Partial and unmatched statement lines awaiting investigation
+func (h *Handler) ListExceptionsHandler(w http.ResponseWriter, r *http.Request) {
+	params, ok := mw.GetPaginationParams(r.Context())
+	if !ok {
+		log.Printf("Failed to get pagination params from context")
+		helper.RespondWithError(w, http.StatusInternalServerError, "pagination error")
+		return
+	}
+
+	result, err := h.service.listExceptions(r.Context(), params.Page, params.PageSize)
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+	helper.RespondWithJSON(w, http.StatusOK, result)
+}
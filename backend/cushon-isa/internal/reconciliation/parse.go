@@ -0,0 +1,357 @@
+package reconciliation
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/models"
+)
+
+const dateLayout = "2006-01-02"
+
+// Note: Payment references are ISA followed by ten hex characters, see the reconciliation migration.
+// Banks often add spaces or hyphens and truncate long remittance text, so we search rather than compare
+var referencePattern = regexp.MustCompile(`ISA[0-9A-F]{10}`)
+
+// statementLine is a bank statement entry as read from the file, before matching
+type statementLine struct {
+	lineNumber    int
+	bookingDate   time.Time
+	amount        float64
+	currency      string
+	credit        bool
+	booked        bool
+	reference     string
+	counterparty  string
+	transactionID string
+}
+
+// parsedStatement is a bank statement in either format
+type parsedStatement struct {
+	format       string
+	statementRef string
+	lines        []statementLine
+}
+
+// detectFormat picks the format from the requested one, or sniffs the content when none was given
+func detectFormat(format string, data []byte) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case models.StatementFormatCSV, "text/csv":
+		return models.StatementFormatCSV, nil
+	case models.StatementFormatCAMT053, "camt.053", "xml", "application/xml", "text/xml":
+		return models.StatementFormatCAMT053, nil
+	case "":
+		if bytes.HasPrefix(bytes.TrimSpace(data), []byte("<")) {
+			return models.StatementFormatCAMT053, nil
+		}
+		return models.StatementFormatCSV, nil
+	default:
+		return "", isaerrors.ErrInvalidStatementFormat
+	}
+}
+
+func parseStatement(format string, data []byte) (*parsedStatement, error) {
+	switch format {
+	case models.StatementFormatCSV:
+		return parseCSV(data)
+	case models.StatementFormatCAMT053:
+		return parseCAMT053(data)
+	default:
+		return nil, isaerrors.ErrInvalidStatementFormat
+	}
+}
+
+// Note: Bank CSV exports differ, so columns are found by header name. Amounts are either a single
+// signed amount column or separate credit and debit columns. CSV exports only contain booked entries
+func parseCSV(data []byte) (*parsedStatement, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, isaerrors.ErrInvalidBankStatement.Wrap(err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		switch name {
+		case "date", "booking date", "transaction date":
+			columns["date"] = i
+		case "amount", "value":
+			columns["amount"] = i
+		case "credit", "paid in", "money in":
+			columns["credit"] = i
+		case "debit", "paid out", "money out":
+			columns["debit"] = i
+		case "reference", "description", "payment reference", "narrative":
+			if _, ok := columns["reference"]; !ok {
+				columns["reference"] = i
+			}
+		case "counterparty", "payer", "name":
+			columns["counterparty"] = i
+		case "transaction id", "bank reference", "transaction reference":
+			columns["transaction id"] = i
+		case "currency":
+			columns["currency"] = i
+		}
+	}
+	_, hasAmount := columns["amount"]
+	_, hasCredit := columns["credit"]
+	if _, ok := columns["date"]; !ok || (!hasAmount && !hasCredit) {
+		return nil, isaerrors.Validation("invalid_bank_statement", "bank statement CSV must have date and amount or credit columns")
+	}
+
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	statement := &parsedStatement{format: models.StatementFormatCSV}
+	for n := 1; ; n++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, isaerrors.ErrInvalidBankStatement.Wrap(err)
+		}
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+
+		date, err := parseDate(field(record, "date"))
+		if err != nil {
+			return nil, isaerrors.Validation("invalid_bank_statement", fmt.Sprintf("line %d: invalid date", n))
+		}
+
+		var amount float64
+		if hasAmount {
+			amount, err = parseAmount(field(record, "amount"))
+		} else {
+			var credit, debit float64
+			credit, err = parseAmount(field(record, "credit"))
+			if err == nil {
+				debit, err = parseAmount(field(record, "debit"))
+			}
+			amount = credit - debit
+		}
+		if err != nil {
+			return nil, isaerrors.Validation("invalid_bank_statement", fmt.Sprintf("line %d: invalid amount", n))
+		}
+
+		currency := strings.ToUpper(field(record, "currency"))
+		if currency == "" {
+			currency = "GBP"
+		}
+
+		statement.lines = append(statement.lines, statementLine{
+			lineNumber:    n,
+			bookingDate:   date,
+			amount:        abs(amount),
+			currency:      currency,
+			credit:        amount > 0,
+			booked:        true,
+			reference:     field(record, "reference"),
+			counterparty:  field(record, "counterparty"),
+			transactionID: field(record, "transaction id"),
+		})
+	}
+
+	return statement, nil
+}
+
+// camtDocument is the part of an ISO 20022 camt.053 bank to customer statement we read
+type camtDocument struct {
+	Statements []struct {
+		ID      string      `xml:"Id"`
+		Entries []camtEntry `xml:"Ntry"`
+	} `xml:"BkToCstmrStmt>Stmt"`
+}
+
+type camtEntry struct {
+	Amount struct {
+		Value    string `xml:",chardata"`
+		Currency string `xml:"Ccy,attr"`
+	} `xml:"Amt"`
+	CreditDebit string `xml:"CdtDbtInd"`
+	// Note: camt.053.001.02 has a plain status, later versions wrap it in a code
+	Status struct {
+		Value string `xml:",chardata"`
+		Code  string `xml:"Cd"`
+	} `xml:"Sts"`
+	BookingDate struct {
+		Date     string `xml:"Dt"`
+		DateTime string `xml:"DtTm"`
+	} `xml:"BookgDt"`
+	ServicerRef string `xml:"AcctSvcrRef"`
+	Details     []struct {
+		Transactions []camtTransaction `xml:"TxDtls"`
+	} `xml:"NtryDtls"`
+}
+
+type camtTransaction struct {
+	Amount string `xml:"Amt"`
+	Refs   struct {
+		EndToEndID  string `xml:"EndToEndId"`
+		ServicerRef string `xml:"AcctSvcrRef"`
+	} `xml:"Refs"`
+	Remittance struct {
+		Unstructured []string `xml:"Ustrd"`
+		Structured   []struct {
+			CreditorRef string `xml:"CdtrRefInf>Ref"`
+		} `xml:"Strd"`
+	} `xml:"RmtInf"`
+	DebtorName string `xml:"RltdPties>Dbtr>Nm"`
+}
+
+// references returns the places a payer's reference can appear, most likely first
+func (tx camtTransaction) references() []string {
+	references := append([]string{}, tx.Remittance.Unstructured...)
+	for _, s := range tx.Remittance.Structured {
+		references = append(references, s.CreditorRef)
+	}
+	return append(references, tx.Refs.EndToEndID)
+}
+
+// Note: Only booked credits are reconciled, but every entry is kept so the statement can be audited
+func parseCAMT053(data []byte) (*parsedStatement, error) {
+	var doc camtDocument
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, isaerrors.ErrInvalidBankStatement.Wrap(err)
+	}
+	if len(doc.Statements) == 0 {
+		return nil, isaerrors.Validation("invalid_bank_statement", "bank statement XML has no camt.053 statements")
+	}
+
+	statement := &parsedStatement{format: models.StatementFormatCAMT053, statementRef: doc.Statements[0].ID}
+	n := 0
+	for _, stmt := range doc.Statements {
+		for _, entry := range stmt.Entries {
+			amount, err := parseAmount(entry.Amount.Value)
+			if err != nil {
+				return nil, isaerrors.Validation("invalid_bank_statement", fmt.Sprintf("entry %d: invalid amount", n+1))
+			}
+			dateValue := entry.BookingDate.Date
+			if dateValue == "" && len(entry.BookingDate.DateTime) >= len(dateLayout) {
+				dateValue = entry.BookingDate.DateTime[:len(dateLayout)]
+			}
+			date, err := parseDate(dateValue)
+			if err != nil {
+				return nil, isaerrors.Validation("invalid_bank_statement", fmt.Sprintf("entry %d: invalid booking date", n+1))
+			}
+			status := strings.TrimSpace(entry.Status.Code)
+			if status == "" {
+				status = strings.TrimSpace(entry.Status.Value)
+			}
+
+			base := statementLine{
+				bookingDate:   date,
+				amount:        abs(amount),
+				currency:      strings.ToUpper(entry.Amount.Currency),
+				credit:        entry.CreditDebit == "CRDT",
+				booked:        status != "PDNG",
+				transactionID: entry.ServicerRef,
+			}
+
+			// Note: Batched receipts are split per transaction when each carries its own amount,
+			// otherwise the entry is one line and its references are searched together
+			var transactions []statementLine
+			var references []string
+			split := true
+			for _, details := range entry.Details {
+				for _, tx := range details.Transactions {
+					line := base
+					line.reference = strings.Join(nonEmpty(tx.references()), " ")
+					line.counterparty = strings.TrimSpace(tx.DebtorName)
+					if tx.Refs.ServicerRef != "" {
+						line.transactionID = tx.Refs.ServicerRef
+					}
+					if tx.Amount != "" {
+						line.amount, err = parseAmount(tx.Amount)
+						if err != nil {
+							return nil, isaerrors.Validation("invalid_bank_statement", fmt.Sprintf("entry %d: invalid transaction amount", n+1))
+						}
+					} else {
+						split = false
+					}
+					transactions = append(transactions, line)
+					references = append(references, line.reference)
+				}
+			}
+			if len(transactions) == 1 {
+				transactions[0].amount = base.amount
+			} else if len(transactions) == 0 || !split {
+				line := base
+				line.reference = strings.Join(nonEmpty(references), " ")
+				if len(transactions) > 0 {
+					line.counterparty = transactions[0].counterparty
+				}
+				transactions = []statementLine{line}
+			}
+
+			for _, line := range transactions {
+				n++
+				line.lineNumber = n
+				if line.currency == "" {
+					line.currency = "GBP"
+				}
+				statement.lines = append(statement.lines, line)
+			}
+		}
+	}
+
+	return statement, nil
+}
+
+// extractReference finds the payment reference in free text remittance information
+func extractReference(text string) string {
+	normalised := strings.NewReplacer(" ", "", "-", "").Replace(strings.ToUpper(text))
+	return referencePattern.FindString(normalised)
+}
+
+func parseDate(value string) (time.Time, error) {
+	for _, layout := range []string{dateLayout, "02/01/2006"} {
+		if date, err := time.Parse(layout, strings.TrimSpace(value)); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
+
+func parseAmount(value string) (float64, error) {
+	value = strings.NewReplacer("£", "", ",", "", " ", "").Replace(strings.TrimSpace(value))
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+func nonEmpty(values []string) []string {
+	var out []string
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func abs(v float64) float64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,274 @@
+package reconciliation
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/ledger"
+	"github.com/stcol316/cushon-isa/internal/models"
+)
+
+// TODO: Use interfaces at service level instead of "repo *Repository"
+type ReconciliationRepository interface {
+	CreateStatement(ctx context.Context, format, statementRef, checksum string) (string, error)
+	ListStatementLines(ctx context.Context, statementID string) ([]models.BankStatementLine, error)
+	FindDepositByReference(ctx context.Context, reference string) (*deposit, error)
+	TransactionSeen(ctx context.Context, transactionID string) (bool, error)
+	MatchLine(ctx context.Context, line *models.BankStatementLine) error
+	InsertLine(ctx context.Context, line *models.BankStatementLine) error
+	CompleteStatement(ctx context.Context, statementID string, lines, matched int) error
+	ListExceptions(ctx context.Context, page, pageSize int) ([]models.BankStatementLine, int, error)
+}
+
+type Repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// deposit is the part of an investment a statement line is matched against
+type deposit struct {
+	id     string
+	amount float64
+	status string
+}
+
+// Note: A statement whose earlier import did not complete is returned again so the import can be
+// retried. Only a completed statement counts as already imported
+func (r *Repository) createStatement(ctx context.Context, format, statementRef, checksum string) (string, error) {
+	var id string
+	err := r.db.QueryRowContext(ctx, `
+	INSERT INTO bank_statements (format, statement_ref, checksum)
+	VALUES ($1, NULLIF($2, ''), $3)
+	ON CONFLICT (checksum) DO UPDATE SET imported_at = CURRENT_TIMESTAMP
+	WHERE bank_statements.completed_at IS NULL
+	RETURNING id
+`, format, statementRef, checksum).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", isaerrors.ErrStatementAlreadyImported
+		}
+		return "", fmt.Errorf("failed to create bank statement: %w", err)
+	}
+	return id, nil
+}
+
+// Note: The lines recorded by an earlier attempt at importing the statement
+func (r *Repository) listStatementLines(ctx context.Context, statementID string) ([]models.BankStatementLine, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT `+lineColumns+`
+        FROM bank_statement_lines
+        WHERE statement_id = $1
+        ORDER BY line_number
+    `, statementID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query bank statement lines: %w", err)
+	}
+	defer rows.Close()
+
+	var lines []models.BankStatementLine
+	for rows.Next() {
+		line, err := scanLine(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan bank statement line: %w", err)
+		}
+		lines = append(lines, *line)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating bank statement lines: %w", err)
+	}
+
+	return lines, nil
+}
+
+// Note: Returns nil when no deposit has the reference
+func (r *Repository) findDepositByReference(ctx context.Context, reference string) (*deposit, error) {
+	var d deposit
+	err := r.db.QueryRowContext(ctx, `
+        SELECT id, amount, status
+        FROM investments
+        WHERE payment_reference = $1
+    `, reference).Scan(&d.id, &d.amount, &d.status)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find deposit by reference: %w", err)
+	}
+	return &d, nil
+}
+
+// Note: Banks repeat entries across intraday and end of day statements, so a transaction that has
+// already been matched on an earlier statement must not be counted twice
+func (r *Repository) transactionSeen(ctx context.Context, transactionID string) (bool, error) {
+	if transactionID == "" {
+		return false, nil
+	}
+	var seen bool
+	err := r.db.QueryRowContext(ctx, `
+        SELECT EXISTS(
+            SELECT 1 FROM bank_statement_lines
+            WHERE bank_transaction_id = $1 AND match_status IN ('matched', 'partial')
+        )
+    `, transactionID).Scan(&seen)
+	if err != nil {
+		return false, fmt.Errorf("failed to check bank transaction: %w", err)
+	}
+	return seen, nil
+}
+
+// Note: Moves the deposit to cash received and records the line in one transaction, so a
+// deposit is never marked as received without the statement line that evidences it.
+// Returns ErrInvestmentStatusChanged if the deposit is no longer pending
+func (r *Repository) matchLine(ctx context.Context, line *models.BankStatementLine) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+	UPDATE investments
+	SET status = 'cash_received', status_updated_at = CURRENT_TIMESTAMP
+	WHERE id = $1 AND type = 'deposit' AND status = 'pending'
+`, line.InvestmentID)
+	if err != nil {
+		return fmt.Errorf("failed to update investment status: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return isaerrors.ErrInvestmentStatusChanged
+	}
+
+	if err := ledger.RecordStatusChange(ctx, tx, line.InvestmentID, models.InvestmentStatusPending, models.InvestmentStatusCashReceived, "bank reconciliation"); err != nil {
+		return err
+	}
+
+	if err := insertLine(ctx, tx, line); err != nil {
+		return err
+	}
+
+	log.Printf("Attempting to refresh materialized view")
+	_, err = tx.ExecContext(ctx, "REFRESH MATERIALIZED VIEW customer_fund_totals")
+	if err != nil {
+		return fmt.Errorf("failed to refresh materialized view: %w", err)
+	}
+	log.Printf("Successfully refreshed materialized view")
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) insertLine(ctx context.Context, line *models.BankStatementLine) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := insertLine(ctx, tx, line); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func insertLine(ctx context.Context, tx *sql.Tx, line *models.BankStatementLine) error {
+	err := tx.QueryRowContext(ctx, `
+	INSERT INTO bank_statement_lines (statement_id, line_number, booking_date, amount, currency, reference,
+		counterparty, bank_transaction_id, match_status, investment_id, note)
+	VALUES ($1, $2, $3::date, $4, $5, NULLIF($6, ''), NULLIF($7, ''), NULLIF($8, ''), $9, NULLIF($10, '')::uuid, NULLIF($11, ''))
+	RETURNING id
+`, line.StatementID, line.LineNumber, line.BookingDate, line.Amount, line.Currency, line.Reference,
+		line.Counterparty, line.TransactionID, line.MatchStatus, line.InvestmentID, line.Note).Scan(&line.ID)
+	if err != nil {
+		return fmt.Errorf("failed to record bank statement line: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) completeStatement(ctx context.Context, statementID string, lines, matched int) error {
+	_, err := r.db.ExecContext(ctx, `
+	UPDATE bank_statements SET lines = $2, matched = $3, completed_at = CURRENT_TIMESTAMP WHERE id = $1
+`, statementID, lines, matched)
+	if err != nil {
+		return fmt.Errorf("failed to update bank statement: %w", err)
+	}
+	return nil
+}
+
+// Note: Exceptions are partial and unmatched lines, oldest first, for operations to investigate
+func (r *Repository) listExceptions(ctx context.Context, page, pageSize int) ([]models.BankStatementLine, int, error) {
+	offset := (page - 1) * pageSize
+
+	var total int
+	err := r.db.QueryRowContext(ctx, `
+        SELECT COUNT(*) FROM bank_statement_lines WHERE match_status IN ('partial', 'unmatched')
+    `).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get total count: %w", err)
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT `+lineColumns+`
+        FROM bank_statement_lines
+        WHERE match_status IN ('partial', 'unmatched')
+        ORDER BY created_at, line_number
+        LIMIT $1 OFFSET $2
+    `, pageSize, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query reconciliation exceptions: %w", err)
+	}
+	defer rows.Close()
+
+	var lines []models.BankStatementLine
+	for rows.Next() {
+		line, err := scanLine(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan reconciliation exception: %w", err)
+		}
+		lines = append(lines, *line)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating reconciliation exceptions: %w", err)
+	}
+
+	return lines, total, nil
+}
+
+const lineColumns = `id, statement_id, line_number, TO_CHAR(booking_date, 'YYYY-MM-DD'), amount, currency,
+            COALESCE(reference, ''), COALESCE(counterparty, ''), COALESCE(bank_transaction_id, ''),
+            match_status, COALESCE(investment_id::text, ''), COALESCE(note, '')`
+
+func scanLine(rows *sql.Rows) (*models.BankStatementLine, error) {
+	var line models.BankStatementLine
+	err := rows.Scan(
+		&line.ID,
+		&line.StatementID,
+		&line.LineNumber,
+		&line.BookingDate,
+		&line.Amount,
+		&line.Currency,
+		&line.Reference,
+		&line.Counterparty,
+		&line.TransactionID,
+		&line.MatchStatus,
+		&line.InvestmentID,
+		&line.Note,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &line, nil
+}
@@ -0,0 +1,326 @@
+package reconciliation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const camtStatement = `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.08">
+  <BkToCstmrStmt>
+    <Stmt>
+      <Id>STMT-2025-01-02</Id>
+      <Ntry>
+        <Amt Ccy="GBP">100.00</Amt>
+        <CdtDbtInd>CRDT</CdtDbtInd>
+        <Sts><Cd>BOOK</Cd></Sts>
+        <BookgDt><Dt>2025-01-02</Dt></BookgDt>
+        <AcctSvcrRef>TX1</AcctSvcrRef>
+        <NtryDtls><TxDtls>
+          <RmtInf><Ustrd>isa-0123 456789</Ustrd></RmtInf>
+          <RltdPties><Dbtr><Nm>J Doe</Nm></Dbtr></RltdPties>
+        </TxDtls></NtryDtls>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="GBP">350.00</Amt>
+        <CdtDbtInd>CRDT</CdtDbtInd>
+        <Sts><Cd>BOOK</Cd></Sts>
+        <BookgDt><DtTm>2025-01-02T10:00:00</DtTm></BookgDt>
+        <NtryDtls>
+          <TxDtls><Amt Ccy="GBP">150.00</Amt><Refs><EndToEndId>ISAAAAAAAAAAA</EndToEndId></Refs></TxDtls>
+          <TxDtls><Amt Ccy="GBP">200.00</Amt><RmtInf><Strd><CdtrRefInf><Ref>ISABBBBBBBBBB</Ref></CdtrRefInf></Strd></RmtInf></TxDtls>
+        </NtryDtls>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="GBP">20.00</Amt>
+        <CdtDbtInd>CRDT</CdtDbtInd>
+        <Sts>PDNG</Sts>
+        <BookgDt><Dt>2025-01-02</Dt></BookgDt>
+      </Ntry>
+    </Stmt>
+  </BkToCstmrStmt>
+</Document>`
+
+var lineColumnNames = []string{
+	"id", "statement_id", "line_number", "booking_date", "amount", "currency", "reference",
+	"counterparty", "bank_transaction_id", "match_status", "investment_id", "note",
+}
+
+func TestParseCAMT053(t *testing.T) {
+	statement, err := parseCAMT053([]byte(camtStatement))
+	require.NoError(t, err)
+	assert.Equal(t, "STMT-2025-01-02", statement.statementRef)
+	require.Len(t, statement.lines, 4)
+
+	first := statement.lines[0]
+	assert.Equal(t, 100.0, first.amount)
+	assert.True(t, first.credit)
+	assert.True(t, first.booked)
+	assert.Equal(t, "TX1", first.transactionID)
+	assert.Equal(t, "J Doe", first.counterparty)
+	assert.Equal(t, "ISA0123456789", extractReference(first.reference))
+
+	// Batched receipts are split per transaction
+	assert.Equal(t, 150.0, statement.lines[1].amount)
+	assert.Equal(t, "ISAAAAAAAAAAA", extractReference(statement.lines[1].reference))
+	assert.Equal(t, 200.0, statement.lines[2].amount)
+	assert.Equal(t, "ISABBBBBBBBBB", extractReference(statement.lines[2].reference))
+	assert.Equal(t, "2025-01-02", statement.lines[2].bookingDate.Format(dateLayout))
+
+	assert.False(t, statement.lines[3].booked)
+
+	_, err = parseCAMT053([]byte("<Document></Document>"))
+	assert.ErrorIs(t, err, isaerrors.ErrValidation)
+}
+
+func TestParseCSV(t *testing.T) {
+	t.Run("signed amount", func(t *testing.T) {
+		data := "Date,Amount,Reference,Counterparty,Transaction ID\n" +
+			"2025-01-02,\"£1,000.00\",ISA0123456789,J Doe,TX1\n" +
+			"02/01/2025,-25.00,Bank charge,,TX2\n"
+		statement, err := parseCSV([]byte(data))
+		require.NoError(t, err)
+		require.Len(t, statement.lines, 2)
+		assert.Equal(t, 1000.0, statement.lines[0].amount)
+		assert.True(t, statement.lines[0].credit)
+		assert.Equal(t, "GBP", statement.lines[0].currency)
+		assert.Equal(t, "TX1", statement.lines[0].transactionID)
+		assert.False(t, statement.lines[1].credit)
+		assert.Equal(t, "2025-01-02", statement.lines[1].bookingDate.Format(dateLayout))
+	})
+
+	t.Run("credit and debit columns", func(t *testing.T) {
+		data := "Booking Date,Description,Paid In,Paid Out\n2025-01-02,ISA0123456789,100.00,\n"
+		statement, err := parseCSV([]byte(data))
+		require.NoError(t, err)
+		require.Len(t, statement.lines, 1)
+		assert.Equal(t, 100.0, statement.lines[0].amount)
+		assert.True(t, statement.lines[0].credit)
+	})
+
+	t.Run("missing columns", func(t *testing.T) {
+		_, err := parseCSV([]byte("Reference,Counterparty\nISA0123456789,J Doe\n"))
+		assert.ErrorIs(t, err, isaerrors.ErrValidation)
+	})
+
+	t.Run("invalid amount", func(t *testing.T) {
+		_, err := parseCSV([]byte("Date,Amount\n2025-01-02,abc\n"))
+		assert.ErrorIs(t, err, isaerrors.ErrValidation)
+	})
+}
+
+func TestDetectFormat(t *testing.T) {
+	format, err := detectFormat("", []byte(camtStatement))
+	require.NoError(t, err)
+	assert.Equal(t, models.StatementFormatCAMT053, format)
+
+	format, err = detectFormat("text/csv", nil)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatementFormatCSV, format)
+
+	_, err = detectFormat("pdf", nil)
+	assert.ErrorIs(t, err, isaerrors.ErrInvalidStatementFormat)
+}
+
+func TestService_Import(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	service := NewService(NewRepository(db))
+	data := "Date,Amount,Reference,Transaction ID\n" +
+		"2025-01-02,100.00,ISA0123456789,TX1\n" +
+		"2025-01-02,90.00,ISA9876543210,TX2\n" +
+		"2025-01-02,50.00,Gift from Gran,TX3\n" +
+		"2025-01-02,100.00,ISA0123456789,TX4\n" +
+		"2025-01-02,-10.00,Refund,TX5\n"
+
+	depositRows := func(id string, amount float64, status string) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "amount", "status"}).AddRow(id, amount, status)
+	}
+	notSeen := func(transactionID string) {
+		mock.ExpectQuery("SELECT EXISTS\\((.+) FROM bank_statement_lines").
+			WithArgs(transactionID).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	}
+	expectLine := func() {
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO bank_statement_lines").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("line"))
+		mock.ExpectCommit()
+	}
+
+	mock.ExpectQuery("INSERT INTO bank_statements (.+) ON CONFLICT \\(checksum\\) (.+) WHERE bank_statements.completed_at IS NULL RETURNING id").
+		WithArgs(models.StatementFormatCSV, "", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("statement1"))
+	mock.ExpectQuery("SELECT (.+) FROM bank_statement_lines WHERE statement_id = \\$1").
+		WithArgs("statement1").
+		WillReturnRows(sqlmock.NewRows(lineColumnNames))
+
+	// Line 1 matches and the deposit moves to cash received
+	notSeen("TX1")
+	mock.ExpectQuery("SELECT id, amount, status FROM investments WHERE payment_reference = \\$1").
+		WithArgs("ISA0123456789").
+		WillReturnRows(depositRows("inv1", 100.0, models.InvestmentStatusPending))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE investments SET status = 'cash_received'").
+		WithArgs("inv1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO investment_status_history").
+		WithArgs("inv1", models.InvestmentStatusPending, models.InvestmentStatusCashReceived, "bank reconciliation").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT type, customer_id, fund_id, amount, units FROM investments WHERE id = \\$1").
+		WithArgs("inv1").
+		WillReturnRows(sqlmock.NewRows([]string{"type", "customer_id", "fund_id", "amount", "units"}).
+			AddRow(models.InvestmentTypeDeposit, "customer1", "fund1", 100.0, nil))
+	mock.ExpectExec("INSERT INTO ledger_accounts").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("INSERT INTO ledger_journals (.+) INSERT INTO ledger_postings").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery("INSERT INTO bank_statement_lines").
+		WithArgs("statement1", 1, "2025-01-02", 100.0, "GBP", "ISA0123456789", "", "TX1",
+			models.MatchStatusMatched, "inv1", "").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("line1"))
+	mock.ExpectExec("REFRESH MATERIALIZED VIEW customer_fund_totals").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	// Line 2 has the wrong amount
+	notSeen("TX2")
+	mock.ExpectQuery("SELECT id, amount, status FROM investments").
+		WithArgs("ISA9876543210").
+		WillReturnRows(depositRows("inv2", 100.0, models.InvestmentStatusPending))
+	expectLine()
+
+	// Line 3 has no reference
+	notSeen("TX3")
+	expectLine()
+
+	// Line 4 pays for a deposit that has already been received
+	notSeen("TX4")
+	mock.ExpectQuery("SELECT id, amount, status FROM investments").
+		WithArgs("ISA0123456789").
+		WillReturnRows(depositRows("inv1", 100.0, models.InvestmentStatusCashReceived))
+	expectLine()
+
+	// Line 5 is a payment out
+	expectLine()
+
+	mock.ExpectExec("UPDATE bank_statements SET lines = \\$2, matched = \\$3, completed_at = CURRENT_TIMESTAMP WHERE id = \\$1").
+		WithArgs("statement1", 5, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	result, err := service.Import(context.Background(), "", []byte(data))
+	require.NoError(t, err)
+	assert.Equal(t, 5, result.Lines)
+	assert.Equal(t, []int{1, 1, 1, 1, 1}, []int{result.Matched, result.Partial, result.Unmatched, result.Duplicate, result.Ignored})
+	assert.Equal(t, "received 90.00, expected 100.00", result.Items[1].Note)
+	assert.Equal(t, "inv2", result.Items[1].InvestmentID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Note: The deposit was pending when looked up but moved on before it could be matched
+func TestService_Import_StatusChanged(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	service := NewService(NewRepository(db))
+	data := "Date,Amount,Reference,Transaction ID\n" +
+		"2025-01-02,100.00,ISA0123456789,TX1\n"
+
+	mock.ExpectQuery("INSERT INTO bank_statements").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("statement1"))
+	mock.ExpectQuery("SELECT (.+) FROM bank_statement_lines WHERE statement_id = \\$1").
+		WithArgs("statement1").
+		WillReturnRows(sqlmock.NewRows(lineColumnNames))
+	mock.ExpectQuery("SELECT EXISTS\\((.+) FROM bank_statement_lines").
+		WithArgs("TX1").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery("SELECT id, amount, status FROM investments WHERE payment_reference = \\$1").
+		WithArgs("ISA0123456789").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "amount", "status"}).AddRow("inv1", 100.0, models.InvestmentStatusPending))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE investments SET status = 'cash_received'").
+		WithArgs("inv1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO bank_statement_lines").
+		WithArgs("statement1", 1, "2025-01-02", 100.0, "GBP", "ISA0123456789", "", "TX1",
+			models.MatchStatusDuplicate, "inv1", "deposit changed status during reconciliation").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("line1"))
+	mock.ExpectCommit()
+	mock.ExpectExec("UPDATE bank_statements").
+		WithArgs("statement1", 1, 0).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	result, err := service.Import(context.Background(), "", []byte(data))
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Duplicate)
+	assert.Equal(t, "deposit changed status during reconciliation", result.Items[0].Note)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Note: Retrying an import that failed part way keeps the lines already recorded and only
+// reconciles the rest
+func TestService_Import_Resume(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	service := NewService(NewRepository(db))
+	data := "Date,Amount,Reference,Transaction ID\n" +
+		"2025-01-02,100.00,ISA0123456789,TX1\n" +
+		"2025-01-02,50.00,Gift from Gran,TX2\n"
+
+	mock.ExpectQuery("INSERT INTO bank_statements (.+) ON CONFLICT").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("statement1"))
+	mock.ExpectQuery("SELECT (.+) FROM bank_statement_lines WHERE statement_id = \\$1").
+		WithArgs("statement1").
+		WillReturnRows(sqlmock.NewRows(lineColumnNames).
+			AddRow("line1", "statement1", 1, "2025-01-02", 100.0, "GBP", "ISA0123456789", "", "TX1",
+				models.MatchStatusMatched, "inv1", ""))
+
+	// Line 2 failed last time and is reconciled now
+	mock.ExpectQuery("SELECT EXISTS\\((.+) FROM bank_statement_lines").
+		WithArgs("TX2").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO bank_statement_lines").
+		WithArgs("statement1", 2, "2025-01-02", 50.0, "GBP", "Gift from Gran", "", "TX2",
+			models.MatchStatusUnmatched, "", "no payment reference").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("line2"))
+	mock.ExpectCommit()
+
+	mock.ExpectExec("UPDATE bank_statements SET (.+) completed_at = CURRENT_TIMESTAMP").
+		WithArgs("statement1", 2, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	result, err := service.Import(context.Background(), "", []byte(data))
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Lines)
+	assert.Equal(t, []int{1, 0, 1}, []int{result.Matched, result.Partial, result.Unmatched})
+	assert.Equal(t, "line1", result.Items[0].ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_CreateStatement_AlreadyImported(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	mock.ExpectQuery("INSERT INTO bank_statements").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	_, err = repo.createStatement(context.Background(), models.StatementFormatCSV, "", "checksum")
+	assert.ErrorIs(t, err, isaerrors.ErrStatementAlreadyImported)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
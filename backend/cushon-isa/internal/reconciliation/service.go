@@ -0,0 +1,212 @@
+package reconciliation
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	mw "github.com/stcol316/cushon-isa/internal/middleware"
+	"github.com/stcol316/cushon-isa/internal/models"
+)
+
+type Service struct {
+	repo *Repository
+}
+
+func NewService(repo *Repository) *Service {
+	return &Service{repo: repo}
+}
+
+// Note: Imports a client money bank statement and matches each receipt to a pending deposit by its
+// payment reference. Matched deposits move to cash received and become eligible for settlement.
+// Anything that cannot be matched exactly is recorded as an exception for operations to review.
+// Each line is recorded in its own transaction, so an import that fails part way can be run again
+// and carries on from the lines already recorded
+func (s *Service) Import(ctx context.Context, format string, data []byte) (*models.ReconciliationResult, error) {
+	format, err := detectFormat(format, data)
+	if err != nil {
+		return nil, err
+	}
+
+	statement, err := parseStatement(format, data)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	statementID, err := s.repo.createStatement(ctx, format, statement.statementRef, hex.EncodeToString(sum[:]))
+	if err != nil {
+		return nil, err
+	}
+
+	recorded, err := s.repo.listStatementLines(ctx, statementID)
+	if err != nil {
+		return nil, err
+	}
+	done := make(map[int]*models.BankStatementLine, len(recorded))
+	for i := range recorded {
+		done[recorded[i].LineNumber] = &recorded[i]
+	}
+
+	result := &models.ReconciliationResult{
+		StatementID:  statementID,
+		Format:       format,
+		StatementRef: statement.statementRef,
+		Items:        []models.BankStatementLine{},
+	}
+	for _, parsed := range statement.lines {
+		line, ok := done[parsed.lineNumber]
+		if !ok {
+			line, err = s.reconcileLine(ctx, statementID, parsed)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		result.Lines++
+		switch line.MatchStatus {
+		case models.MatchStatusMatched:
+			result.Matched++
+		case models.MatchStatusPartial:
+			result.Partial++
+		case models.MatchStatusUnmatched:
+			result.Unmatched++
+		case models.MatchStatusDuplicate:
+			result.Duplicate++
+		case models.MatchStatusIgnored:
+			result.Ignored++
+		}
+		result.Items = append(result.Items, *line)
+	}
+
+	if err := s.repo.completeStatement(ctx, statementID, result.Lines, result.Matched); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Bank reconciliation %s: %d lines, %d matched, %d partial, %d unmatched, %d duplicate",
+		statementID, result.Lines, result.Matched, result.Partial, result.Unmatched, result.Duplicate)
+	return result, nil
+}
+
+func (s *Service) reconcileLine(ctx context.Context, statementID string, parsed statementLine) (*models.BankStatementLine, error) {
+	line := &models.BankStatementLine{
+		StatementID:   statementID,
+		LineNumber:    parsed.lineNumber,
+		BookingDate:   parsed.bookingDate.Format(dateLayout),
+		Amount:        parsed.amount,
+		Currency:      parsed.currency,
+		Reference:     parsed.reference,
+		Counterparty:  parsed.counterparty,
+		TransactionID: parsed.transactionID,
+	}
+
+	if err := s.classify(ctx, parsed, line); err != nil {
+		return nil, err
+	}
+
+	if line.MatchStatus == models.MatchStatusMatched {
+		err := s.repo.matchLine(ctx, line)
+		if err == nil {
+			return line, nil
+		}
+		if !errors.Is(err, isaerrors.ErrInvestmentStatusChanged) {
+			return nil, err
+		}
+		// Note: Another request moved the deposit on between the lookup and the update
+		line.MatchStatus = models.MatchStatusDuplicate
+		line.Note = "deposit changed status during reconciliation"
+	}
+
+	if err := s.repo.insertLine(ctx, line); err != nil {
+		return nil, err
+	}
+	return line, nil
+}
+
+// classify decides how a statement line reconciles and links it to the deposit it refers to, if any
+func (s *Service) classify(ctx context.Context, parsed statementLine, line *models.BankStatementLine) error {
+	if !parsed.credit || !parsed.booked {
+		line.MatchStatus = models.MatchStatusIgnored
+		if !parsed.booked {
+			line.Note = "entry is not yet booked"
+		} else {
+			line.Note = "payment out"
+		}
+		return nil
+	}
+
+	if parsed.currency != "GBP" {
+		line.MatchStatus = models.MatchStatusUnmatched
+		line.Note = fmt.Sprintf("unexpected currency %s", parsed.currency)
+		return nil
+	}
+
+	seen, err := s.repo.transactionSeen(ctx, parsed.transactionID)
+	if err != nil {
+		return err
+	}
+	if seen {
+		line.MatchStatus = models.MatchStatusDuplicate
+		line.Note = "bank transaction already reconciled"
+		return nil
+	}
+
+	reference := extractReference(parsed.reference)
+	if reference == "" {
+		line.MatchStatus = models.MatchStatusUnmatched
+		line.Note = "no payment reference"
+		return nil
+	}
+
+	deposit, err := s.repo.findDepositByReference(ctx, reference)
+	if err != nil {
+		return err
+	}
+	if deposit == nil {
+		line.MatchStatus = models.MatchStatusUnmatched
+		line.Note = fmt.Sprintf("unknown payment reference %s", reference)
+		return nil
+	}
+	line.InvestmentID = deposit.id
+
+	switch {
+	case deposit.status != models.InvestmentStatusPending:
+		line.MatchStatus = models.MatchStatusDuplicate
+		line.Note = fmt.Sprintf("deposit is no longer pending (was %s)", deposit.status)
+	case math.Abs(deposit.amount-parsed.amount) >= 0.005:
+		// Note: Over and under payments are never applied automatically, the customer has to be contacted
+		line.MatchStatus = models.MatchStatusPartial
+		line.Note = fmt.Sprintf("received %.2f, expected %.2f", parsed.amount, deposit.amount)
+	default:
+		line.MatchStatus = models.MatchStatusMatched
+	}
+
+	return nil
+}
+
+func (s *Service) listExceptions(ctx context.Context, page, pageSize int) (*mw.PaginatedResult, error) {
+	lines, total, err := s.repo.listExceptions(ctx, page, pageSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reconciliation exceptions: %w", err)
+	}
+
+	// Calculate pagination metadata
+	totalPages := (total + pageSize - 1) / pageSize
+
+	result := &mw.PaginatedResult{
+		Data: lines,
+	}
+	result.Pagination.CurrentPage = page
+	result.Pagination.PageSize = pageSize
+	result.Pagination.TotalItems = &total
+	result.Pagination.TotalPages = &totalPages
+	result.Pagination.HasNext = page < totalPages
+	result.Pagination.HasPrevious = page > 1
+
+	return result, nil
+}
@@ -0,0 +1,169 @@
+package riskprofile
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/models"
+)
+
+type RiskProfileRepository interface {
+	ListQuestions(ctx context.Context) ([]models.RiskQuestion, error)
+	RiskLevelForScore(ctx context.Context, score int) (*models.RiskLevel, error)
+	CreateProfile(ctx context.Context, profile *models.RiskProfile) error
+	GetLatestProfile(ctx context.Context, customerID string) (*models.RiskProfile, error)
+}
+
+type Repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Note: Questions and options are fetched in one query and grouped here
+func (r *Repository) listQuestions(ctx context.Context) ([]models.RiskQuestion, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT q.id, q.position, q.text, o.id, o.position, o.text, o.score
+        FROM risk_questions q
+        JOIN risk_question_options o ON o.question_id = q.id
+        WHERE q.active
+        ORDER BY q.position, o.position
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query risk questions: %w", err)
+	}
+	defer rows.Close()
+
+	questions := []models.RiskQuestion{}
+	for rows.Next() {
+		var question models.RiskQuestion
+		var option models.RiskQuestionOption
+		if err := rows.Scan(
+			&question.ID,
+			&question.Position,
+			&question.Text,
+			&option.ID,
+			&option.Position,
+			&option.Text,
+			&option.Score,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan risk question: %w", err)
+		}
+
+		if n := len(questions); n == 0 || questions[n-1].ID != question.ID {
+			questions = append(questions, question)
+		}
+		last := &questions[len(questions)-1]
+		last.Options = append(last.Options, option)
+	}
+
+	return questions, nil
+}
+
+// riskLevelForScore returns the highest risk level whose minimum score the total reaches
+func (r *Repository) riskLevelForScore(ctx context.Context, score int) (*models.RiskLevel, error) {
+	var riskLevel models.RiskLevel
+	err := r.db.QueryRowContext(ctx, `
+	SELECT id, name, COALESCE(description, '')
+	FROM risk_levels
+	WHERE min_score <= $1
+	ORDER BY min_score DESC
+	LIMIT 1
+`, score).Scan(&riskLevel.ID, &riskLevel.Name, &riskLevel.Description)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, isaerrors.ErrRiskLevelNotFound
+		}
+		return nil, fmt.Errorf("failed to get risk level for score: %w", err)
+	}
+
+	return &riskLevel, nil
+}
+
+// Note: The profile and its answers are written in a single transaction
+func (r *Repository) createProfile(ctx context.Context, profile *models.RiskProfile) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+	INSERT INTO customer_risk_profiles (customer_id, risk_level_id, score)
+	VALUES ($1, $2, $3)
+	RETURNING id, completed_at
+`, profile.CustomerID, profile.RiskLevel.ID, profile.Score).Scan(&profile.ID, &profile.CompletedAt)
+	if err != nil {
+		if isaerrors.IsForeignKeyViolation(err) {
+			return isaerrors.ErrCustomerNotFound.Wrap(err)
+		}
+		return fmt.Errorf("failed to create risk profile: %w", err)
+	}
+
+	for _, answer := range profile.Answers {
+		_, err := tx.ExecContext(ctx, `
+		INSERT INTO customer_risk_profile_answers (profile_id, question_id, option_id)
+		VALUES ($1, $2, $3)
+	`, profile.ID, answer.QuestionID, answer.OptionID)
+		if err != nil {
+			return fmt.Errorf("failed to record risk answer: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) getLatestProfile(ctx context.Context, customerID string) (*models.RiskProfile, error) {
+	var profile models.RiskProfile
+	err := r.db.QueryRowContext(ctx, `
+	SELECT p.id, p.customer_id, p.score, p.completed_at, rl.id, rl.name, COALESCE(rl.description, '')
+	FROM customer_risk_profiles p
+	JOIN risk_levels rl ON rl.id = p.risk_level_id
+	WHERE p.customer_id = $1
+	ORDER BY p.completed_at DESC
+	LIMIT 1
+`, customerID).Scan(
+		&profile.ID,
+		&profile.CustomerID,
+		&profile.Score,
+		&profile.CompletedAt,
+		&profile.RiskLevel.ID,
+		&profile.RiskLevel.Name,
+		&profile.RiskLevel.Description,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, isaerrors.ErrRiskProfileNotFound
+		}
+		return nil, fmt.Errorf("failed to get risk profile: %w", err)
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT question_id, option_id
+        FROM customer_risk_profile_answers
+        WHERE profile_id = $1
+        ORDER BY question_id
+    `, profile.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query risk answers: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var answer models.RiskAnswer
+		if err := rows.Scan(&answer.QuestionID, &answer.OptionID); err != nil {
+			return nil, fmt.Errorf("failed to scan risk answer: %w", err)
+		}
+		profile.Answers = append(profile.Answers, answer)
+	}
+
+	return &profile, nil
+}
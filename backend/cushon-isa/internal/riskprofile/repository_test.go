@@ -0,0 +1,104 @@
+package riskprofile
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository_ListQuestions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	rows := sqlmock.NewRows([]string{"q_id", "q_position", "q_text", "o_id", "o_position", "o_text", "score"}).
+		AddRow(1, 1, "How long?", 1, 1, "Less than 5 years", 1).
+		AddRow(1, 1, "How long?", 2, 2, "More than 5 years", 3).
+		AddRow(2, 2, "Experience?", 3, 1, "None", 1)
+	mock.ExpectQuery("SELECT (.+) FROM risk_questions q JOIN risk_question_options o").
+		WillReturnRows(rows)
+
+	questions, err := repo.listQuestions(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, questions, 2)
+	assert.Len(t, questions[0].Options, 2)
+	assert.Equal(t, 3, questions[0].Options[1].Score)
+	assert.Len(t, questions[1].Options, 1)
+}
+
+func TestRepository_CreateProfile(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	profile := &models.RiskProfile{
+		CustomerID: "customer1",
+		RiskLevel:  models.RiskLevel{ID: 2, Name: "Medium"},
+		Score:      10,
+		Answers:    []models.RiskAnswer{{QuestionID: 1, OptionID: 2}, {QuestionID: 2, OptionID: 3}},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO customer_risk_profiles").
+		WithArgs("customer1", 2, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "completed_at"}).AddRow("profile1", time.Now()))
+	mock.ExpectExec("INSERT INTO customer_risk_profile_answers").
+		WithArgs("profile1", 1, 2).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO customer_risk_profile_answers").
+		WithArgs("profile1", 2, 3).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err = repo.createProfile(ctx, profile)
+	assert.NoError(t, err)
+	assert.Equal(t, "profile1", profile.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_GetLatestProfile(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	t.Run("latest profile with answers", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM customer_risk_profiles p JOIN risk_levels rl").
+			WithArgs("customer1").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "customer_id", "score", "completed_at", "rl_id", "rl_name", "rl_description"}).
+				AddRow("profile1", "customer1", 10, time.Now(), 2, "Medium", "Balanced"))
+		mock.ExpectQuery("SELECT question_id, option_id FROM customer_risk_profile_answers").
+			WithArgs("profile1").
+			WillReturnRows(sqlmock.NewRows([]string{"question_id", "option_id"}).AddRow(1, 2))
+
+		profile, err := repo.getLatestProfile(ctx, "customer1")
+		assert.NoError(t, err)
+		assert.Equal(t, "Medium", profile.RiskLevel.Name)
+		assert.Len(t, profile.Answers, 1)
+	})
+
+	t.Run("no profile", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM customer_risk_profiles p JOIN risk_levels rl").
+			WithArgs("customer2").
+			WillReturnError(sql.ErrNoRows)
+
+		profile, err := repo.getLatestProfile(ctx, "customer2")
+		assert.Nil(t, profile)
+		assert.ErrorIs(t, err, isaerrors.ErrRiskProfileNotFound)
+	})
+}
@@ -0,0 +1,99 @@
+package riskprofile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/models"
+)
+
+type Service struct {
+	repo *Repository
+}
+
+func NewService(repo *Repository) *Service {
+	return &Service{repo: repo}
+}
+
+func (s *Service) getQuestionnaire(ctx context.Context) ([]models.RiskQuestion, error) {
+	return s.repo.listQuestions(ctx)
+}
+
+func (s *Service) submitProfile(ctx context.Context, customerID string, req *models.SubmitRiskProfileRequest) (*models.RiskProfile, error) {
+	questions, err := s.repo.listQuestions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	score, err := scoreAnswers(questions, req.Answers)
+	if err != nil {
+		return nil, err
+	}
+
+	riskLevel, err := s.repo.riskLevelForScore(ctx, score)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := &models.RiskProfile{
+		CustomerID: customerID,
+		RiskLevel:  *riskLevel,
+		Score:      score,
+		Answers:    req.Answers,
+	}
+	if err := s.repo.createProfile(ctx, profile); err != nil {
+		return nil, fmt.Errorf("failed to save risk profile: %w", err)
+	}
+
+	return profile, nil
+}
+
+func (s *Service) getLatestProfile(ctx context.Context, customerID string) (*models.RiskProfile, error) {
+	return s.repo.getLatestProfile(ctx, customerID)
+}
+
+// LatestProfile returns the customer's current risk profile or nil if they have not completed the questionnaire.
+// Exported for the investment service suitability check
+func (s *Service) LatestProfile(ctx context.Context, customerID string) (*models.RiskProfile, error) {
+	profile, err := s.repo.getLatestProfile(ctx, customerID)
+	if errors.Is(err, isaerrors.ErrRiskProfileNotFound) {
+		return nil, nil
+	}
+	return profile, err
+}
+
+// scoreAnswers checks every active question is answered exactly once with one of its own options
+// and returns the total score
+func scoreAnswers(questions []models.RiskQuestion, answers []models.RiskAnswer) (int, error) {
+	if len(answers) != len(questions) {
+		return 0, isaerrors.ErrInvalidRiskAnswers
+	}
+
+	scores := make(map[int]map[int]int, len(questions))
+	for _, question := range questions {
+		options := make(map[int]int, len(question.Options))
+		for _, option := range question.Options {
+			options[option.ID] = option.Score
+		}
+		scores[question.ID] = options
+	}
+
+	total := 0
+	answered := make(map[int]bool, len(answers))
+	for _, answer := range answers {
+		options, ok := scores[answer.QuestionID]
+		if !ok || answered[answer.QuestionID] {
+			return 0, isaerrors.ErrInvalidRiskAnswers
+		}
+		score, ok := options[answer.OptionID]
+		if !ok {
+			return 0, isaerrors.ErrInvalidRiskAnswers
+		}
+		answered[answer.QuestionID] = true
+		total += score
+	}
+
+	return total, nil
+}
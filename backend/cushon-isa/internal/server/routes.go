@@ -1,7 +1,6 @@
 package server
 
 import (
-	"fmt"
 	"net/http"
 	"time"
 
@@ -12,17 +11,6 @@ import (
 	mw "github.com/stcol316/cushon-isa/internal/middleware"
 )
 
-// TODO: This is just an example auth for testing purposes.
-// For a full implementation we would generate this at login and pass back to the user to use in request headers
-var tokenAuth *jwtauth.JWTAuth
-
-func init() {
-	tokenAuth = jwtauth.New("HS256", []byte("secret"), nil)
-
-	_, tokenString, _ := tokenAuth.Encode(map[string]interface{}{"user_id": 123})
-	fmt.Printf("DEBUG: a sample jwt is %s\n\n", tokenString)
-}
-
 func (s *Server) RegisterRoutes() http.Handler {
 	r := chi.NewRouter()
 	r.Use(middleware.Logger)                    // Log HTTP requests
@@ -48,21 +36,156 @@ func (s *Server) RegisterRoutes() http.Handler {
 	r.Route("/v1", func(r chi.Router) {
 		r.Group(func(r chi.Router) {
 			// Note: Simple auth example usage for protected routes
-			r.Use(jwtauth.Verifier(tokenAuth))
-			r.Use(jwtauth.Authenticator(tokenAuth))
+			r.Use(jwtauth.Verifier(s.tokenAuth))
+			r.Use(jwtauth.Authenticator(s.tokenAuth))
 			// Investment routes
 			r.Route("/investments", func(r chi.Router) {
 				r.Post("/", s.investmentHandler.CreateInvestmentHandler)
 				r.Get("/id/{id}", s.investmentHandler.GetInvestmentByIDHandler)
 				r.With(mw.Paginate).Get("/customer/{customerId}", s.investmentHandler.ListCustomerInvestmentsHandler)
 				r.Get("/customer/{customerId}/fund/{fundId}", s.investmentHandler.GetCustomerFundTotalHandler)
+				r.Get("/customer/{customerId}/allowance", s.investmentHandler.GetAllowanceHandler)
+				r.Get("/account/{accountId}/fund/{fundId}", s.investmentHandler.GetAccountFundTotalHandler)
+
+				// Performance, e.g. ?period=1Y
+				r.Get("/customer/{customerId}/performance", s.perfHandler.GetPortfolioPerformanceHandler)
+				r.Get("/customer/{customerId}/fund/{fundId}/performance", s.perfHandler.GetFundPerformanceHandler)
+			})
+
+			// Accounts (ISA and Lifetime ISA wrappers) held by a customer
+			r.Route("/accounts", func(r chi.Router) {
+				r.Get("/id/{id}", s.accountHandler.GetAccountHandler)
+				r.Get("/customer/{customerId}", s.accountHandler.ListCustomerAccountsHandler)
+			})
+
+			// ISA transfers to and from other providers
+			r.Route("/transfers", func(r chi.Router) {
+				r.Post("/in", s.transferHandler.CreateTransferInHandler)
+				r.Post("/out", s.transferHandler.CreateTransferOutHandler)
+				r.Get("/id/{id}", s.transferHandler.GetTransferHandler)
+				r.Get("/id/{id}/history", s.transferHandler.GetTransferHistoryHandler)
+				r.Get("/customer/{customerId}", s.transferHandler.ListCustomerTransfersHandler)
 			})
 		})
 
 		r.Route("/customers/retail", func(r chi.Router) {
 			r.Post("/", s.customerHandler.CreateRetailCustomerHandler)
-			r.Get("/id/{id}", s.customerHandler.GetRetailCustomerByIdHandler)
-			r.Get("/email/{email}", s.customerHandler.GetRetailCustomerByEmailHandler)
+			r.Post("/id/{id}/verify-email", s.customerHandler.VerifyEmailHandler)
+
+			// Note: Everything that reads or changes a customer's personal data is protected
+			r.Group(func(r chi.Router) {
+				r.Use(jwtauth.Verifier(s.tokenAuth))
+				r.Use(jwtauth.Authenticator(s.tokenAuth))
+				r.Get("/id/{id}", s.customerHandler.GetRetailCustomerByIdHandler)
+				r.Get("/email/{email}", s.customerHandler.GetRetailCustomerByEmailHandler)
+				r.Patch("/id/{id}", s.customerHandler.UpdateRetailCustomerHandler)
+				r.Post("/id/{id}/email-verification", s.customerHandler.ResendEmailVerificationHandler)
+				r.Post("/id/{id}/close", s.customerHandler.CloseRetailCustomerHandler)
+				r.Post("/id/{id}/erasure", s.customerHandler.EraseRetailCustomerHandler)
+
+				// Junior ISAs opened and run by the customer as registered contact
+				r.Post("/junior", s.customerHandler.CreateJuniorISAHandler)
+				r.Get("/id/{id}/juniors", s.customerHandler.ListJuniorISAsHandler)
+
+				// Lifetime ISA held alongside the Stocks & Shares ISA, with its bonus history
+				r.Post("/id/{id}/lifetime-isa", s.lifetimeISAHandler.OpenLifetimeISAHandler)
+				r.Get("/id/{id}/lifetime-isa", s.lifetimeISAHandler.GetLifetimeISAHandler)
+
+				// Identity verification
+				r.Post("/id/{id}/verification", s.kycHandler.SubmitVerificationHandler)
+				r.Get("/id/{id}/verification", s.kycHandler.GetVerificationHandler)
+
+				// Attitude to risk profile
+				r.Post("/id/{id}/risk-profile", s.riskHandler.SubmitRiskProfileHandler)
+				r.Get("/id/{id}/risk-profile", s.riskHandler.GetRiskProfileHandler)
+
+				// Periodic statements, e.g. /statements/2025 or /statements/2025-Q1
+				r.Get("/id/{id}/statements/{period}", s.statementHandler.GetStatementHandler)
+
+				// Costs and charges, e.g. ?from=2025-01-01&to=2025-12-31
+				r.Get("/id/{id}/charges", s.chargesHandler.GetCustomerChargesHandler)
+			})
+		})
+
+		// Workplace pension members, enrolled by their employer
+		r.Route("/customers/employee", func(r chi.Router) {
+			r.Use(jwtauth.Verifier(s.tokenAuth))
+			r.Use(jwtauth.Authenticator(s.tokenAuth))
+			r.Post("/", s.employeeHandler.CreateEmployeeHandler)
+			r.Get("/id/{id}", s.employeeHandler.GetEmployeeHandler)
+			r.Get("/id/{id}/pension", s.employeeHandler.GetPensionHandler)
+			r.Get("/id/{id}/contributions", s.employeeHandler.ListContributionsHandler)
+		})
+
+		// KYC provider callbacks
+		// Note: Authenticated by the provider's signature rather than our JWT, see the handler
+		r.Post("/kyc/callbacks", s.kycHandler.ProviderCallbackHandler)
+
+		// Admin routes
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(jwtauth.Verifier(s.tokenAuth))
+			r.Use(jwtauth.Authenticator(s.tokenAuth))
+			r.Use(mw.RequireRole("admin"))
+
+			// AML review queue
+			r.Route("/aml/reviews", func(r chi.Router) {
+				r.With(mw.Paginate).Get("/", s.amlHandler.ListReviewsHandler)
+				r.Post("/{investmentId}/release", s.amlHandler.ReleaseInvestmentHandler)
+				r.Post("/{investmentId}/cancel", s.amlHandler.CancelInvestmentHandler)
+			})
+
+			// Junior ISA conversion at 18, catch up after a failed scheduled run
+			r.Post("/customers/junior-isa/convert", s.customerHandler.RunJuniorISAConversionHandler)
+
+			// Lifetime ISA government bonus, claim catch up and crediting once HMRC has paid
+			r.Route("/lifetime-isa/bonuses", func(r chi.Router) {
+				r.Post("/claim", s.lifetimeISAHandler.ClaimBonusesHandler)
+				r.Post("/pay", s.lifetimeISAHandler.PayBonusesHandler)
+			})
+
+			// Workplace pension employers and their payroll contribution submissions
+			r.Route("/employers", func(r chi.Router) {
+				r.Post("/", s.employerHandler.CreateEmployerHandler)
+				r.Get("/id/{id}", s.employerHandler.GetEmployerHandler)
+				r.Post("/id/{id}/contributions", s.employerHandler.SubmitContributionsHandler)
+			})
+
+			// Investment lifecycle
+			r.Post("/investments/{id}/status", s.investmentHandler.TransitionInvestmentHandler)
+			r.Post("/settlement/run", s.investmentHandler.RunSettlementHandler)
+
+			// Valuation snapshots, catch up or backfill a date range
+			r.Post("/valuations/run", s.valuationHandler.RunValuationHandler)
+
+			// Charges, accrual catch up, monthly collection and platform fee bands
+			r.Route("/charges", func(r chi.Router) {
+				r.Post("/accrue", s.chargesHandler.AccrueChargesHandler)
+				r.Post("/collect", s.chargesHandler.CollectChargesHandler)
+				r.Get("/tiers", s.chargesHandler.GetFeeTiersHandler)
+				r.Put("/tiers", s.chargesHandler.UpdateFeeTiersHandler)
+			})
+
+			// Double-entry ledger, balance proof and posting of investments that pre-date it
+			r.Get("/ledger/check", s.ledgerHandler.CheckLedgerHandler)
+			r.Post("/ledger/backfill", s.ledgerHandler.BackfillLedgerHandler)
+
+			// ISA transfer progress, completion credits the holding or records what left it
+			r.Post("/transfers/{id}/status", s.transferHandler.TransitionTransferHandler)
+
+			// Client money bank statement import and the exceptions it raises
+			r.Route("/reconciliation", func(r chi.Router) {
+				r.Post("/statements", s.reconciliationHandler.ImportStatementHandler)
+				r.With(mw.Paginate).Get("/exceptions", s.reconciliationHandler.ListExceptionsHandler)
+			})
+
+			// Fund administration, dealing and pricing
+			r.Route("/funds", func(r chi.Router) {
+				r.Post("/", s.fundHandler.CreateFundHandler)
+				r.Patch("/{id}", s.fundHandler.UpdateFundHandler)
+				r.Post("/{id}/retire", s.fundHandler.RetireFundHandler)
+				r.Put("/{id}/dealing", s.fundHandler.UpdateFundDealingHandler)
+				r.Post("/{id}/prices", s.fundHandler.LoadFundPriceHandler)
+			})
 		})
 
 		// Fund routes
@@ -71,6 +194,15 @@ func (s *Server) RegisterRoutes() http.Handler {
 			r.With(mw.Paginate).Get("/", s.fundHandler.ListFundsHandler)
 			r.Get("/id/{id}", s.fundHandler.GetFundByIdHandler)
 		})
+
+		// Risk questionnaire
+		r.Get("/risk-questionnaire", s.riskHandler.GetQuestionnaireHandler)
+
+		// Risk level routes
+		r.Route("/risk-levels", func(r chi.Router) {
+			r.Get("/", s.fundHandler.ListRiskLevelsHandler)
+			r.Get("/{id}", s.fundHandler.GetRiskLevelByIDHandler)
+		})
 	})
 
 	return r
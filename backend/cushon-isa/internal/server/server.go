@@ -1,29 +1,83 @@
 package server
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
+	"github.com/go-chi/jwtauth/v5"
+
+	"github.com/stcol316/cushon-isa/internal/account"
+	"github.com/stcol316/cushon-isa/internal/aml"
+	"github.com/stcol316/cushon-isa/internal/charges"
 	"github.com/stcol316/cushon-isa/internal/config"
 	"github.com/stcol316/cushon-isa/internal/customer"
+	"github.com/stcol316/cushon-isa/internal/employee"
+	"github.com/stcol316/cushon-isa/internal/employer"
 	"github.com/stcol316/cushon-isa/internal/fund"
 	"github.com/stcol316/cushon-isa/internal/investment"
+	"github.com/stcol316/cushon-isa/internal/kyc"
+	"github.com/stcol316/cushon-isa/internal/ledger"
+	"github.com/stcol316/cushon-isa/internal/lifetimeisa"
+	"github.com/stcol316/cushon-isa/internal/performance"
+	"github.com/stcol316/cushon-isa/internal/reconciliation"
+	"github.com/stcol316/cushon-isa/internal/riskprofile"
+	"github.com/stcol316/cushon-isa/internal/statement"
+	"github.com/stcol316/cushon-isa/internal/transfer"
+	"github.com/stcol316/cushon-isa/internal/valuation"
 )
 
 type Server struct {
-	port              string
-	customerHandler   *customer.Handler
-	fundHandler       *fund.Handler
-	investmentHandler *investment.Handler
+	port                  string
+	tokenAuth             *jwtauth.JWTAuth
+	customerHandler       *customer.Handler
+	fundHandler           *fund.Handler
+	investmentHandler     *investment.Handler
+	kycHandler            *kyc.Handler
+	amlHandler            *aml.Handler
+	riskHandler           *riskprofile.Handler
+	statementHandler      *statement.Handler
+	perfHandler           *performance.Handler
+	valuationHandler      *valuation.Handler
+	chargesHandler        *charges.Handler
+	ledgerHandler         *ledger.Handler
+	reconciliationHandler *reconciliation.Handler
+	transferHandler       *transfer.Handler
+	lifetimeISAHandler    *lifetimeisa.Handler
+	accountHandler        *account.Handler
+	employerHandler       *employer.Handler
+	employeeHandler       *employee.Handler
 }
 
-func NewServer(cfg *config.Config, ch *customer.Handler, fh *fund.Handler, ih *investment.Handler) *http.Server {
+func NewServer(cfg *config.Config, ch *customer.Handler, fh *fund.Handler, ih *investment.Handler, kh *kyc.Handler, ah *aml.Handler, rh *riskprofile.Handler, sh *statement.Handler, ph *performance.Handler, vh *valuation.Handler, chh *charges.Handler, lh *ledger.Handler, rch *reconciliation.Handler, th *transfer.Handler, lih *lifetimeisa.Handler, ach *account.Handler, erh *employer.Handler, eeh *employee.Handler) (*http.Server, error) {
+	// Note: Config validation already requires it, checked again so a server can never verify
+	// tokens against an empty key
+	// TODO: Tokens are only verified here. For a full implementation we would issue them at login
+	if cfg.JWTSecret == "" {
+		return nil, errors.New("jwt secret is not set")
+	}
+
 	NewServer := &Server{
-		port:              cfg.Port,
-		customerHandler:   ch,
-		fundHandler:       fh,
-		investmentHandler: ih,
+		port:                  cfg.Port,
+		tokenAuth:             jwtauth.New("HS256", []byte(cfg.JWTSecret), nil),
+		customerHandler:       ch,
+		fundHandler:           fh,
+		investmentHandler:     ih,
+		kycHandler:            kh,
+		amlHandler:            ah,
+		riskHandler:           rh,
+		statementHandler:      sh,
+		perfHandler:           ph,
+		valuationHandler:      vh,
+		chargesHandler:        chh,
+		ledgerHandler:         lh,
+		reconciliationHandler: rch,
+		transferHandler:       th,
+		lifetimeISAHandler:    lih,
+		accountHandler:        ach,
+		employerHandler:       erh,
+		employeeHandler:       eeh,
 	}
 
 	server := &http.Server{
@@ -34,5 +88,5 @@ func NewServer(cfg *config.Config, ch *customer.Handler, fh *fund.Handler, ih *i
 		WriteTimeout: 30 * time.Second,
 	}
 
-	return server
+	return server, nil
 }
@@ -0,0 +1,51 @@
+package statement
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	helper "github.com/stcol316/cushon-isa/pkg/helpers"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+// Note: Returns the statement as a PDF by default. Clients can ask for the underlying
+// figures with Accept: application/json
+func (h *Handler) GetStatementHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "invalid customer ID format")
+		return
+	}
+
+	statement, err := h.service.generateStatement(r.Context(), id, chi.URLParam(r, "period"))
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		helper.RespondWithJSON(w, http.StatusOK, statement)
+		return
+	}
+
+	body := renderPDF(statement)
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="statement-%s-%s.pdf"`, statement.Period, id))
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(body); err != nil {
+		log.Printf("Failed to write statement for customer %s: %v", id, err)
+	}
+}
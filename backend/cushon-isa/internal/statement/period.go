@@ -0,0 +1,46 @@
+package statement
+
+import (
+	"regexp"
+	"strconv"
+	"time"
+
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+)
+
+const dateLayout = "2006-01-02"
+
+var periodPattern = regexp.MustCompile(`^(\d{4})(?:-Q([1-4]))?$`)
+
+// Note: Periods are calendar years ("2025") or calendar quarters ("2025-Q1").
+// start is inclusive and end is exclusive, both at midnight UTC
+type period struct {
+	name  string
+	start time.Time
+	end   time.Time
+}
+
+func parsePeriod(name string) (*period, error) {
+	match := periodPattern.FindStringSubmatch(name)
+	if match == nil {
+		return nil, isaerrors.ErrInvalidStatementPeriod
+	}
+
+	year, _ := strconv.Atoi(match[1])
+	p := &period{name: name}
+	if match[2] == "" {
+		p.start = time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+		p.end = p.start.AddDate(1, 0, 0)
+	} else {
+		quarter, _ := strconv.Atoi(match[2])
+		p.start = time.Date(year, time.Month((quarter-1)*3+1), 1, 0, 0, 0, 0, time.UTC)
+		p.end = p.start.AddDate(0, 3, 0)
+	}
+
+	return p, nil
+}
+
+// lastDay is the final day covered by the period, used for display
+func (p *period) lastDay() time.Time {
+	return p.end.AddDate(0, 0, -1)
+}
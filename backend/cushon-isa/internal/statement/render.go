@@ -0,0 +1,143 @@
+package statement
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/stcol316/cushon-isa/internal/models"
+	"github.com/stcol316/cushon-isa/pkg/pdf"
+)
+
+// Page layout in points
+const (
+	marginLeft   = 50.0
+	marginRight  = pdf.PageWidth - 50.0
+	marginTop    = pdf.PageHeight - 60.0
+	marginBottom = 60.0
+	lineHeight   = 16.0
+)
+
+// Holdings table column right edges
+var holdingColumns = []float64{330, 410, 470, marginRight}
+
+// renderPDF lays out the statement as a single column document.
+// Long holdings lists continue onto further pages
+func renderPDF(statement *models.Statement) []byte {
+	doc := pdf.New()
+	y := marginTop
+
+	doc.Text(marginLeft, y, pdf.Bold, 18, "Cushon ISA Statement")
+	y -= lineHeight * 1.5
+	doc.Text(marginLeft, y, pdf.Regular, 10, fmt.Sprintf("Period %s: %s to %s", statement.Period, statement.PeriodStart, statement.PeriodEnd))
+	y -= lineHeight
+	name := statement.CustomerName
+	if name == "" {
+		name = "Customer"
+	}
+	doc.Text(marginLeft, y, pdf.Regular, 10, fmt.Sprintf("%s (%s)", name, statement.CustomerID))
+	y -= lineHeight * 2
+
+	doc.Text(marginLeft, y, pdf.Bold, 12, "Summary")
+	y -= 4
+	doc.Line(marginLeft, marginRight, y)
+	y -= lineHeight
+
+	summary := []struct {
+		label string
+		value float64
+	}{
+		{"Opening value", statement.OpeningValue},
+		{"Contributions", statement.Contributions},
+		{"Withdrawals", -statement.Withdrawals},
+		{"Charges", -statement.Charges},
+		{"Investment growth", statement.Growth},
+	}
+	for _, row := range summary {
+		doc.Text(marginLeft, y, pdf.Regular, 10, row.label)
+		doc.TextRight(marginRight, y, pdf.Regular, 10, formatMoney(row.value))
+		y -= lineHeight
+	}
+	doc.Line(marginLeft, marginRight, y+lineHeight-4)
+	doc.Text(marginLeft, y, pdf.Bold, 10, "Closing value")
+	doc.TextRight(marginRight, y, pdf.Bold, 10, formatMoney(statement.ClosingValue))
+	y -= lineHeight * 2
+
+	holdingsHeader := func() {
+		doc.Text(marginLeft, y, pdf.Bold, 12, "Holdings")
+		y -= 4
+		doc.Line(marginLeft, marginRight, y)
+		y -= lineHeight
+		doc.Text(marginLeft, y, pdf.Bold, 9, "Fund")
+		for i, heading := range []string{"Units", "Price", "Price date", "Value"} {
+			doc.TextRight(holdingColumns[i], y, pdf.Bold, 9, heading)
+		}
+		y -= lineHeight
+	}
+	holdingsHeader()
+
+	if len(statement.Holdings) == 0 {
+		doc.Text(marginLeft, y, pdf.Regular, 9, "No holdings at the end of the period")
+		y -= lineHeight
+	}
+	for _, holding := range statement.Holdings {
+		if y < marginBottom {
+			doc.NewPage()
+			y = marginTop
+			holdingsHeader()
+		}
+		doc.Text(marginLeft, y, pdf.Regular, 9, truncate(holding.FundName, 45))
+		doc.TextRight(holdingColumns[0], y, pdf.Regular, 9, strconv.FormatFloat(holding.Units, 'f', 4, 64))
+		doc.TextRight(holdingColumns[1], y, pdf.Regular, 9, formatPrice(holding))
+		doc.TextRight(holdingColumns[2], y, pdf.Regular, 9, holding.PriceDate)
+		doc.TextRight(holdingColumns[3], y, pdf.Regular, 9, formatMoney(holding.Value))
+		y -= lineHeight
+	}
+
+	y -= lineHeight
+	if y < marginBottom {
+		doc.NewPage()
+		y = marginTop
+	}
+	doc.Text(marginLeft, y, pdf.Regular, 8, "Values use the latest fund price on or before the end of the period. Money invested before unit")
+	y -= lineHeight * 0.75
+	doc.Text(marginLeft, y, pdf.Regular, 8, "pricing was introduced is shown at cost. Investments still being processed are not included.")
+	y -= lineHeight * 0.75
+	doc.Text(marginLeft, y, pdf.Regular, 8, "Generated "+statement.GeneratedAt.Format("2 January 2006 15:04 MST"))
+
+	return doc.Bytes()
+}
+
+func formatPrice(holding models.StatementHolding) string {
+	if holding.PriceDate == "" {
+		return "-"
+	}
+	return strconv.FormatFloat(holding.Price, 'f', 4, 64)
+}
+
+// formatMoney renders pounds with thousands separators, e.g. -£1,234.50
+func formatMoney(value float64) string {
+	sign := ""
+	if value < 0 {
+		sign = "-"
+		value = -value
+	}
+
+	whole, pence, _ := strings.Cut(strconv.FormatFloat(value, 'f', 2, 64), ".")
+	var groups []string
+	for len(whole) > 3 {
+		groups = append([]string{whole[len(whole)-3:]}, groups...)
+		whole = whole[:len(whole)-3]
+	}
+	groups = append([]string{whole}, groups...)
+
+	return sign + "£" + strings.Join(groups, ",") + "." + pence
+}
+
+func truncate(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	return string(runes[:max-3]) + "..."
+}
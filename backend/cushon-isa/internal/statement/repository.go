@@ -0,0 +1,165 @@
+package statement
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/models"
+)
+
+// TODO: Use interfaces at service level instead of "repo *Repository"
+type StatementRepository interface {
+	GetCustomerName(ctx context.Context, customerID string) (string, error)
+	GetHoldings(ctx context.Context, customerID string, at time.Time) ([]models.StatementHolding, error)
+	GetFlows(ctx context.Context, customerID string, start, end time.Time) (*statementFlows, error)
+	ListCustomersWithInvestments(ctx context.Context, before time.Time) ([]string, error)
+}
+
+type Repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// statusAt joins each investment i to the status alias.status it had at the time bound to param,
+// taken from its history, so a statement reissued later reports what was invested then rather than
+// now. The status is NULL for investments placed after that time
+func statusAt(alias, param string) string {
+	return `
+        LEFT JOIN LATERAL (
+            SELECT h.to_status AS status
+            FROM investment_status_history h
+            WHERE h.investment_id = i.id AND h.created_at < ` + param + `
+            ORDER BY h.created_at DESC
+            LIMIT 1
+        ) ` + alias + ` ON TRUE`
+}
+
+// Note: Erased customers keep their investments but no longer have a name on record
+func (r *Repository) getCustomerName(ctx context.Context, customerID string) (string, error) {
+	var name string
+	err := r.db.QueryRowContext(ctx, `
+        SELECT TRIM(COALESCE(first_name, '') || ' ' || COALESCE(last_name, ''))
+        FROM retail_customers
+        WHERE id = $1
+    `, customerID).Scan(&name)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", isaerrors.ErrCustomerNotFound
+		}
+		return "", fmt.Errorf("failed to get customer: %w", err)
+	}
+
+	return name, nil
+}
+
+// Note: Holdings as at the start of the given time, i.e. everything placed before it.
+// Only investments allocated or settled by then count, money still in flight is not yet invested.
+// Units are valued at the latest fund price before the date. Investments without units
+// (placed before forward pricing existed) are held at cost
+func (r *Repository) getHoldings(ctx context.Context, customerID string, at time.Time) ([]models.StatementHolding, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT h.fund_id, h.fund_name, h.units, h.unpriced_cash,
+            COALESCE(p.price, 0), COALESCE(TO_CHAR(p.price_date, 'YYYY-MM-DD'), '')
+        FROM (
+            SELECT f.id AS fund_id, f.name AS fund_name,
+                COALESCE(SUM(CASE WHEN i.type = 'deposit' THEN i.units ELSE -i.units END), 0) AS units,
+                COALESCE(SUM(CASE WHEN i.units IS NOT NULL THEN 0 WHEN i.type = 'deposit' THEN i.amount ELSE -i.amount END), 0) AS unpriced_cash
+            FROM investments i
+            JOIN funds f ON f.id = i.fund_id`+statusAt("s", "$2")+`
+            WHERE i.customer_id = $1 AND i.created_at < $2
+                AND s.status IN ('units_allocated', 'settled')
+            GROUP BY f.id, f.name
+        ) h
+        LEFT JOIN LATERAL (
+            SELECT price, price_date
+            FROM fund_prices
+            WHERE fund_id = h.fund_id AND price_date < $2::date
+            ORDER BY price_date DESC
+            LIMIT 1
+        ) p ON TRUE
+        ORDER BY h.fund_name
+    `, customerID, at)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query holdings: %w", err)
+	}
+	defer rows.Close()
+
+	var holdings []models.StatementHolding
+	for rows.Next() {
+		var holding models.StatementHolding
+		if err := rows.Scan(
+			&holding.FundID,
+			&holding.FundName,
+			&holding.Units,
+			&holding.UnpricedCash,
+			&holding.Price,
+			&holding.PriceDate,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan holding: %w", err)
+		}
+		holdings = append(holdings, holding)
+	}
+
+	return holdings, nil
+}
+
+// Note: Contributions, withdrawals and charges that became allocated or settled in [start, end), by
+// their status at each end of the period as getHoldings uses, so that opening value plus flows and
+// growth reconciles to the closing value.
+// Units transferred out in specie leave the ISA so are shown as withdrawals
+func (r *Repository) getFlows(ctx context.Context, customerID string, start, end time.Time) (*statementFlows, error) {
+	var flows statementFlows
+	err := r.db.QueryRowContext(ctx, `
+        SELECT
+            COALESCE(SUM(i.amount) FILTER (WHERE i.type = 'deposit'), 0),
+            COALESCE(SUM(i.amount) FILTER (WHERE i.type IN ('withdrawal', 'transfer_out')), 0),
+            COALESCE(SUM(i.amount) FILTER (WHERE i.type = 'charge'), 0)
+        FROM investments i`+statusAt("s", "$3")+statusAt("o", "$2")+`
+        WHERE i.customer_id = $1 AND i.created_at < $3
+            AND s.status IN ('units_allocated', 'settled')
+            AND (o.status IS NULL OR o.status NOT IN ('units_allocated', 'settled'))
+    `, customerID, start, end).Scan(&flows.contributions, &flows.withdrawals, &flows.charges)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get statement flows: %w", err)
+	}
+
+	return &flows, nil
+}
+
+type statementFlows struct {
+	contributions float64
+	withdrawals   float64
+	charges       float64
+}
+
+// Note: Every customer who had invested by the end of the period gets a statement,
+// including closed accounts that still held money during the period. Employees are not sent retail statements
+func (r *Repository) listCustomersWithInvestments(ctx context.Context, before time.Time) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT DISTINCT i.customer_id
+        FROM investments i`+statusAt("s", "$1")+`
+        WHERE i.created_at < $1 AND s.status IN ('units_allocated', 'settled') AND i.customer_id IS NOT NULL
+        ORDER BY i.customer_id
+    `, before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query statement customers: %w", err)
+	}
+	defer rows.Close()
+
+	var customerIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan customer: %w", err)
+		}
+		customerIDs = append(customerIDs, id)
+	}
+
+	return customerIDs, nil
+}
@@ -0,0 +1,157 @@
+package statement
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var holdingRowColumns = []string{"fund_id", "fund_name", "units", "unpriced_cash", "price", "price_date"}
+
+func TestRepository_GetCustomerName(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	t.Run("found", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM retail_customers").
+			WithArgs("customer1").
+			WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("Jane Smith"))
+
+		name, err := repo.getCustomerName(ctx, "customer1")
+		assert.NoError(t, err)
+		assert.Equal(t, "Jane Smith", name)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM retail_customers").
+			WithArgs("missing").
+			WillReturnRows(sqlmock.NewRows([]string{"name"}))
+
+		_, err := repo.getCustomerName(ctx, "missing")
+		assert.ErrorIs(t, err, isaerrors.ErrCustomerNotFound)
+	})
+}
+
+func TestRepository_GetHoldings(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	at := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery("SELECT (.+) FROM investments i JOIN funds f ON f.id = i.fund_id LEFT JOIN LATERAL \\( SELECT h.to_status AS status FROM investment_status_history h WHERE h.investment_id = i.id AND h.created_at < \\$2 (.+) LEFT JOIN LATERAL").
+		WithArgs("customer1", at).
+		WillReturnRows(sqlmock.NewRows(holdingRowColumns).
+			AddRow("fund1", "Cushon Equities Fund", 100.0, 50.0, 1.25, "2025-03-31").
+			AddRow("fund2", "Cushon Bond Fund", 0.0, 200.0, 0.0, ""))
+
+	holdings, err := repo.getHoldings(context.Background(), "customer1", at)
+	assert.NoError(t, err)
+	require.Len(t, holdings, 2)
+	assert.Equal(t, 100.0, holdings[0].Units)
+	assert.Equal(t, "2025-03-31", holdings[0].PriceDate)
+
+	// Note: Priced units at market value plus anything held at cost
+	assert.Equal(t, 375.0, valueHoldings(holdings))
+	assert.Equal(t, 175.0, holdings[0].Value)
+	assert.Equal(t, 200.0, holdings[1].Value)
+}
+
+func TestRepository_GetFlows(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	p, err := parsePeriod("2025-Q1")
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT (.+) FROM investments i LEFT JOIN LATERAL (.+) h.created_at < \\$3 (.+) s ON TRUE LEFT JOIN LATERAL (.+) h.created_at < \\$2 (.+) o ON TRUE WHERE i.customer_id = \\$1 AND i.created_at < \\$3 AND s.status IN (.+) AND \\(o.status IS NULL OR").
+		WithArgs("customer1", p.start, p.end).
+		WillReturnRows(sqlmock.NewRows([]string{"deposits", "withdrawals", "charges"}).AddRow(1000.0, 250.0, 1.5))
+
+	flows, err := repo.getFlows(context.Background(), "customer1", p.start, p.end)
+	assert.NoError(t, err)
+	assert.Equal(t, 1000.0, flows.contributions)
+	assert.Equal(t, 250.0, flows.withdrawals)
+	assert.Equal(t, 1.5, flows.charges)
+}
+
+func TestParsePeriod(t *testing.T) {
+	p, err := parsePeriod("2025-Q4")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC), p.start)
+	assert.Equal(t, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), p.end)
+	assert.Equal(t, "2025-12-31", p.lastDay().Format(dateLayout))
+
+	p, err = parsePeriod("2025")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), p.end)
+
+	for _, invalid := range []string{"", "25", "2025-Q5", "2025-q1", "2025-01"} {
+		_, err := parsePeriod(invalid)
+		assert.ErrorIs(t, err, isaerrors.ErrInvalidStatementPeriod, invalid)
+	}
+}
+
+func TestFormatMoney(t *testing.T) {
+	assert.Equal(t, "£0.00", formatMoney(0))
+	assert.Equal(t, "£1,234,567.89", formatMoney(1234567.891))
+	assert.Equal(t, "-£250.50", formatMoney(-250.5))
+}
+
+func TestRenderPDF(t *testing.T) {
+	p, err := parsePeriod("2025-Q1")
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	service := NewService(NewRepository(db))
+	service.now = func() time.Time { return time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC) }
+
+	mock.ExpectQuery("SELECT (.+) FROM retail_customers").
+		WithArgs("customer1").
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("Jane Smith"))
+	mock.ExpectQuery("SELECT (.+) FROM investments i").
+		WithArgs("customer1", p.start).
+		WillReturnRows(sqlmock.NewRows(holdingRowColumns).AddRow("fund1", "Cushon Equities Fund", 100.0, 0.0, 1.00, "2024-12-31"))
+	mock.ExpectQuery("SELECT (.+) FROM investments i").
+		WithArgs("customer1", p.end).
+		WillReturnRows(sqlmock.NewRows(holdingRowColumns).AddRow("fund1", "Cushon Equities Fund", 150.0, 0.0, 1.10, "2025-03-31"))
+	mock.ExpectQuery("SELECT (.+) FROM investments i LEFT JOIN LATERAL (.+) WHERE i.customer_id").
+		WithArgs("customer1", p.start, p.end).
+		WillReturnRows(sqlmock.NewRows([]string{"deposits", "withdrawals", "charges"}).AddRow(50.0, 0.0, 0.0))
+
+	statement, err := service.generateStatement(context.Background(), "customer1", "2025-Q1")
+	require.NoError(t, err)
+	assert.Equal(t, 100.0, statement.OpeningValue)
+	assert.Equal(t, 165.0, statement.ClosingValue)
+	// Note: 165 closing - 100 opening - 50 contributed
+	assert.Equal(t, 15.0, statement.Growth)
+
+	body := renderPDF(statement)
+	assert.True(t, bytes.HasPrefix(body, []byte("%PDF-1.4")))
+	assert.True(t, bytes.HasSuffix(body, []byte("%%EOF\n")))
+	assert.Contains(t, string(body), "Cushon Equities Fund")
+}
+
+func TestGenerateStatementPeriodNotEnded(t *testing.T) {
+	service := NewService(nil)
+	service.now = func() time.Time { return time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC) }
+
+	_, err := service.generateStatement(context.Background(), "customer1", "2025-Q1")
+	assert.ErrorIs(t, err, isaerrors.ErrStatementPeriodNotEnded)
+}
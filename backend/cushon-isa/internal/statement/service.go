@@ -0,0 +1,125 @@
+package statement
+
+import (
+	"context"
+	"log"
+	"math"
+	"time"
+
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/models"
+)
+
+type Service struct {
+	repo *Repository
+	now  func() time.Time
+}
+
+func NewService(repo *Repository) *Service {
+	return &Service{repo: repo, now: time.Now}
+}
+
+func (s *Service) generateStatement(ctx context.Context, customerID, name string) (*models.Statement, error) {
+	p, err := parsePeriod(name)
+	if err != nil {
+		return nil, err
+	}
+	// Note: Statements are only produced for complete periods so they never change once issued
+	if p.end.After(s.now()) {
+		return nil, isaerrors.ErrStatementPeriodNotEnded
+	}
+
+	return s.buildStatement(ctx, customerID, p)
+}
+
+func (s *Service) buildStatement(ctx context.Context, customerID string, p *period) (*models.Statement, error) {
+	customerName, err := s.repo.getCustomerName(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	opening, err := s.repo.getHoldings(ctx, customerID, p.start)
+	if err != nil {
+		return nil, err
+	}
+	closing, err := s.repo.getHoldings(ctx, customerID, p.end)
+	if err != nil {
+		return nil, err
+	}
+	flows, err := s.repo.getFlows(ctx, customerID, p.start, p.end)
+	if err != nil {
+		return nil, err
+	}
+
+	statement := &models.Statement{
+		CustomerID:    customerID,
+		CustomerName:  customerName,
+		Period:        p.name,
+		PeriodStart:   p.start.Format(dateLayout),
+		PeriodEnd:     p.lastDay().Format(dateLayout),
+		OpeningValue:  valueHoldings(opening),
+		Contributions: flows.contributions,
+		Withdrawals:   flows.withdrawals,
+		Charges:       flows.charges,
+		Holdings:      closing,
+		GeneratedAt:   s.now().UTC(),
+	}
+	statement.ClosingValue = valueHoldings(statement.Holdings)
+
+	// Note: Growth is whatever is left after cash flows, so the statement always reconciles
+	statement.Growth = roundPence(statement.ClosingValue - statement.OpeningValue -
+		statement.Contributions + statement.Withdrawals + statement.Charges)
+
+	return statement, nil
+}
+
+// valueHoldings sets the value of each holding and returns the total
+func valueHoldings(holdings []models.StatementHolding) float64 {
+	var total float64
+	for i := range holdings {
+		holdings[i].Value = roundPence(holdings[i].Units*holdings[i].Price + holdings[i].UnpricedCash)
+		total += holdings[i].Value
+	}
+	return roundPence(total)
+}
+
+func roundPence(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+// GenerateBatch builds and renders statements for every customer with investments in the period.
+// write is called with each rendered PDF. A failure for one customer is logged and does not stop the batch
+func (s *Service) GenerateBatch(ctx context.Context, name string, write func(statement *models.Statement, pdf []byte) error) (*models.StatementRun, error) {
+	p, err := parsePeriod(name)
+	if err != nil {
+		return nil, err
+	}
+	if p.end.After(s.now()) {
+		return nil, isaerrors.ErrStatementPeriodNotEnded
+	}
+
+	customerIDs, err := s.repo.listCustomersWithInvestments(ctx, p.end)
+	if err != nil {
+		return nil, err
+	}
+
+	run := &models.StatementRun{Period: p.name}
+	for _, customerID := range customerIDs {
+		if err := ctx.Err(); err != nil {
+			return run, err
+		}
+		statement, err := s.buildStatement(ctx, customerID, p)
+		if err == nil {
+			err = write(statement, renderPDF(statement))
+		}
+		if err != nil {
+			log.Printf("Failed to generate %s statement for customer %s: %v", p.name, customerID, err)
+			run.Errors++
+			continue
+		}
+		run.Generated++
+	}
+
+	log.Printf("Statement run for %s: %d generated, %d errors", p.name, run.Generated, run.Errors)
+	return run, nil
+}
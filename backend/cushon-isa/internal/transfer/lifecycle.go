@@ -0,0 +1,53 @@
+package transfer
+
+import (
+	"github.com/stcol316/cushon-isa/internal/models"
+)
+
+// Note: ISA transfer in state machine
+//
+//	requested -> submitted | cancelled
+//	submitted -> accepted | rejected | cancelled
+//	accepted -> completed | rejected
+//
+// Submitted means the transfer request has been sent to the ceding provider, accepted that they
+// have agreed to it and the cash is on its way. completed, rejected and cancelled are terminal
+var transitions = map[string]map[string]bool{
+	models.TransferStatusRequested: {
+		models.TransferStatusSubmitted: true,
+		models.TransferStatusCancelled: true,
+	},
+	models.TransferStatusSubmitted: {
+		models.TransferStatusAccepted:  true,
+		models.TransferStatusRejected:  true,
+		models.TransferStatusCancelled: true,
+	},
+	models.TransferStatusAccepted: {
+		models.TransferStatusCompleted: true,
+		models.TransferStatusRejected:  true,
+	},
+}
+
+// Note: ISA transfer out state machine
+//
+//	requested -> accepted | rejected | cancelled
+//	accepted -> completed
+//
+// Accepting a cash transfer places the sale of the holding, so it can no longer be backed out of
+var outTransitions = map[string]map[string]bool{
+	models.TransferStatusRequested: {
+		models.TransferStatusAccepted:  true,
+		models.TransferStatusRejected:  true,
+		models.TransferStatusCancelled: true,
+	},
+	models.TransferStatusAccepted: {
+		models.TransferStatusCompleted: true,
+	},
+}
+
+func canTransition(direction, from, to string) bool {
+	if direction == models.TransferDirectionOut {
+		return outTransitions[from][to]
+	}
+	return transitions[from][to]
+}
@@ -0,0 +1,648 @@
+package transfer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/ledger"
+	"github.com/stcol316/cushon-isa/internal/models"
+)
+
+// TODO: Use interfaces at service level instead of "repo *Repository"
+type TransferRepository interface {
+	CreateTransfer(ctx context.Context, transfer *models.ISATransfer) error
+	GetTransfer(ctx context.Context, id string) (*models.ISATransfer, error)
+	GetStatusHistory(ctx context.Context, id string) ([]models.TransferStatusChange, error)
+	ListCustomerTransfers(ctx context.Context, customerID string) ([]models.ISATransfer, error)
+	UpdateStatus(ctx context.Context, id, from, to, reason string) error
+	CompleteTransferIn(ctx context.Context, transfer *models.ISATransfer, receivedAmount float64, dealingDate, reason string) error
+	GetHolding(ctx context.Context, customerID string) (*holding, error)
+	CloseISAAccount(ctx context.Context, customerID string) (int, error)
+	GetSubscriptionSplit(ctx context.Context, customerID string, start, end time.Time) (float64, float64, string, error)
+	AcceptCashTransferOut(ctx context.Context, transfer *models.ISATransfer, dealingDate, reason string) error
+	CompleteTransferOut(ctx context.Context, transfer *models.ISATransfer, reason string) error
+	GetTransferHistory(ctx context.Context, id string) (*models.TransferHistory, time.Time, error)
+}
+
+type Repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// holding is what a customer has left to transfer out
+type holding struct {
+	accountID string
+	fundID    string
+	// Note: At cost, the same measure withdrawals are checked against
+	balance float64
+	units   float64
+	// Investments and transfers in that have not finished moving
+	inFlight int
+}
+
+const transferColumns = `id, customer_id, fund_id, direction, provider_name, provider_reference, COALESCE(provider_frn, ''),
+	scope, method, current_year_amount, previous_years_amount, COALESCE(TO_CHAR(first_subscription_date, 'YYYY-MM-DD'), ''),
+	received_amount, transferred_amount, transferred_units, status, COALESCE(investment_id::text, ''),
+	created_at, status_updated_at, completed_at`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func scanTransfer(row rowScanner) (*models.ISATransfer, error) {
+	var transfer models.ISATransfer
+	var received, transferred, transferredUnits sql.NullFloat64
+	var completed sql.NullTime
+	err := row.Scan(
+		&transfer.ID,
+		&transfer.CustomerID,
+		&transfer.FundID,
+		&transfer.Direction,
+		&transfer.ProviderName,
+		&transfer.ProviderReference,
+		&transfer.ProviderFRN,
+		&transfer.Scope,
+		&transfer.Method,
+		&transfer.CurrentYearAmount,
+		&transfer.PreviousYearsAmount,
+		&transfer.FirstSubscriptionDate,
+		&received,
+		&transferred,
+		&transferredUnits,
+		&transfer.Status,
+		&transfer.InvestmentID,
+		&transfer.CreatedAt,
+		&transfer.StatusUpdatedAt,
+		&completed,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if received.Valid {
+		transfer.ReceivedAmount = &received.Float64
+	}
+	if transferred.Valid {
+		transfer.TransferredAmount = &transferred.Float64
+	}
+	if transferredUnits.Valid {
+		transfer.TransferredUnits = &transferredUnits.Float64
+	}
+	if completed.Valid {
+		transfer.CompletedAt = &completed.Time
+	}
+	return &transfer, nil
+}
+
+func (r *Repository) createTransfer(ctx context.Context, transfer *models.ISATransfer) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Note: Customers are limited to one fund, see investment createInvestment
+	if transfer.Direction == models.TransferDirectionIn {
+		var existingFundID string
+		err = tx.QueryRowContext(ctx, `
+        SELECT fund_id FROM investments WHERE customer_id = $1 LIMIT 1
+    `, transfer.CustomerID).Scan(&existingFundID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("failed to check existing fund: %w", err)
+		}
+		if existingFundID != "" && existingFundID != transfer.FundID {
+			return isaerrors.ErrDifferentFundNotAllowed
+		}
+	}
+
+	err = tx.QueryRowContext(ctx, `
+	INSERT INTO isa_transfers (customer_id, fund_id, direction, provider_name, provider_reference, provider_frn, scope,
+		method, current_year_amount, previous_years_amount, first_subscription_date, status)
+	VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, $9, $10, NULLIF($11, '')::date, $12)
+	RETURNING id, created_at, status_updated_at
+`, transfer.CustomerID, transfer.FundID, transfer.Direction, transfer.ProviderName, transfer.ProviderReference,
+		transfer.ProviderFRN, transfer.Scope, transfer.Method, transfer.CurrentYearAmount, transfer.PreviousYearsAmount,
+		transfer.FirstSubscriptionDate, transfer.Status,
+	).Scan(&transfer.ID, &transfer.CreatedAt, &transfer.StatusUpdatedAt)
+	if err != nil {
+		if isaerrors.IsForeignKeyViolation(err) {
+			return isaerrors.ErrInvalidInvestmentRef
+		}
+		if isaerrors.IsUniqueViolation(err) {
+			return isaerrors.ErrTransferOutInProgress
+		}
+		return fmt.Errorf("failed to create isa transfer: %w", err)
+	}
+
+	if err := insertStatusChange(ctx, tx, transfer.ID, "", transfer.Status, "requested by customer"); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) getTransfer(ctx context.Context, id string) (*models.ISATransfer, error) {
+	transfer, err := scanTransfer(r.db.QueryRowContext(ctx, `
+        SELECT `+transferColumns+`
+        FROM isa_transfers
+        WHERE id = $1
+    `, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, isaerrors.ErrTransferNotFound
+		}
+		return nil, fmt.Errorf("failed to get isa transfer: %w", err)
+	}
+	return transfer, nil
+}
+
+func (r *Repository) getStatusHistory(ctx context.Context, id string) ([]models.TransferStatusChange, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT id, transfer_id, COALESCE(from_status, ''), to_status, COALESCE(reason, ''), created_at
+        FROM isa_transfer_status_history
+        WHERE transfer_id = $1
+        ORDER BY created_at, id
+    `, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transfer status history: %w", err)
+	}
+	defer rows.Close()
+
+	var history []models.TransferStatusChange
+	for rows.Next() {
+		var change models.TransferStatusChange
+		if err := rows.Scan(&change.ID, &change.TransferID, &change.FromStatus, &change.ToStatus, &change.Reason, &change.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan transfer status change: %w", err)
+		}
+		history = append(history, change)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating transfer status history: %w", err)
+	}
+
+	return history, nil
+}
+
+// Note: Customers only have a handful of transfers so the list is not paginated
+func (r *Repository) listCustomerTransfers(ctx context.Context, customerID string) ([]models.ISATransfer, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT `+transferColumns+`
+        FROM isa_transfers
+        WHERE customer_id = $1
+        ORDER BY created_at DESC
+    `, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query isa transfers: %w", err)
+	}
+	defer rows.Close()
+
+	transfers := []models.ISATransfer{}
+	for rows.Next() {
+		transfer, err := scanTransfer(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan isa transfer: %w", err)
+		}
+		transfers = append(transfers, *transfer)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating isa transfers: %w", err)
+	}
+
+	return transfers, nil
+}
+
+// Note: Only applied if the transfer is still in the status it was read in
+func (r *Repository) updateStatus(ctx context.Context, id, from, to, reason string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+	UPDATE isa_transfers
+	SET status = $3, status_updated_at = CURRENT_TIMESTAMP
+	WHERE id = $1 AND status = $2
+`, id, from, to)
+	if err != nil {
+		return fmt.Errorf("failed to update isa transfer status: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return isaerrors.ErrTransferStatusChanged
+	}
+
+	if err := insertStatusChange(ctx, tx, id, from, to, reason); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// Note: The cash from the ceding provider is credited as a deposit that has already been received,
+// so it is priced at its dealing date and settled like any other deposit. It is linked to the
+// transfer so the allowance counts the transfer's current year amount rather than the deposit
+func (r *Repository) completeTransferIn(ctx context.Context, transfer *models.ISATransfer, receivedAmount float64, dealingDate, reason string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var investmentID string
+	err = tx.QueryRowContext(ctx, `
+	INSERT INTO investments (customer_id, fund_id, amount, type, status, dealing_date, account_id)
+	VALUES ($1, $2, $3, 'deposit', 'cash_received', $4::date, (SELECT id FROM accounts WHERE customer_id = $1 AND product IN ('isa', 'junior_isa') ORDER BY status = 'closed', opened_at DESC LIMIT 1))
+	RETURNING id
+`, transfer.CustomerID, transfer.FundID, receivedAmount, dealingDate).Scan(&investmentID)
+	if err != nil {
+		return fmt.Errorf("failed to record transfer investment: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	INSERT INTO investment_status_history (investment_id, from_status, to_status, reason)
+	VALUES ($1, NULL, 'cash_received', $2)
+`, investmentID, fmt.Sprintf("isa transfer in from %s", transfer.ProviderName))
+	if err != nil {
+		return fmt.Errorf("failed to record status history: %w", err)
+	}
+
+	if err := ledger.PostStatusChange(ctx, tx, investmentID, "", models.InvestmentStatusCashReceived); err != nil {
+		return err
+	}
+
+	res, err := tx.ExecContext(ctx, `
+	UPDATE isa_transfers
+	SET status = 'completed', status_updated_at = CURRENT_TIMESTAMP, completed_at = CURRENT_TIMESTAMP,
+		received_amount = $3, investment_id = $4
+	WHERE id = $1 AND status = $2
+`, transfer.ID, transfer.Status, receivedAmount, investmentID)
+	if err != nil {
+		return fmt.Errorf("failed to complete isa transfer: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return isaerrors.ErrTransferStatusChanged
+	}
+
+	if err := insertStatusChange(ctx, tx, transfer.ID, transfer.Status, models.TransferStatusCompleted, reason); err != nil {
+		return err
+	}
+
+	log.Printf("Attempting to refresh materialized view")
+	_, err = tx.ExecContext(ctx, "REFRESH MATERIALIZED VIEW customer_fund_totals")
+	if err != nil {
+		return fmt.Errorf("failed to refresh materialized view: %w", err)
+	}
+	log.Printf("Successfully refreshed materialized view")
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// Note: Returns nil when the customer has never held anything. Only the customer's ISA account is
+// transferred, a Lifetime ISA is a separate account. Customers are limited to one fund so the
+// largest holding is the only one
+func (r *Repository) getHolding(ctx context.Context, customerID string) (*holding, error) {
+	return queryHolding(ctx, r.db, customerID)
+}
+
+func queryHolding(ctx context.Context, q queryer, customerID string) (*holding, error) {
+	var h holding
+	err := q.QueryRowContext(ctx, `
+        SELECT account_id, fund_id,
+            COALESCE(SUM(
+                CASE
+                    WHEN type IN ('withdrawal', 'charge', 'transfer_out') AND status NOT IN ('cancelled', 'failed') THEN -amount
+                    WHEN type = 'deposit' AND status = 'settled' THEN amount
+                    ELSE 0
+                END), 0),
+            COALESCE(SUM(CASE WHEN type = 'deposit' THEN units ELSE -units END)
+                FILTER (WHERE status IN ('units_allocated', 'settled')), 0),
+            COUNT(*) FILTER (WHERE status IN ('held', 'pending', 'cash_received', 'units_allocated'))
+                + (SELECT COUNT(*) FROM isa_transfers
+                   WHERE customer_id = $1 AND direction = 'in' AND status NOT IN ('completed', 'rejected', 'cancelled'))
+        FROM investments
+        WHERE account_id = (
+            SELECT id FROM accounts
+            WHERE customer_id = $1 AND product IN ('isa', 'junior_isa')
+            ORDER BY status = 'closed', opened_at DESC
+            LIMIT 1
+        )
+        GROUP BY account_id, fund_id
+        ORDER BY 3 DESC
+        LIMIT 1
+    `, customerID).Scan(&h.accountID, &h.fundID, &h.balance, &h.units, &h.inFlight)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get holding: %w", err)
+	}
+	return &h, nil
+}
+
+// Note: Locks the customer's accounts, the same lock investments take before checking the balance,
+// and reads the holding again so a withdrawal placed since the service checked it cannot be sold twice
+func lockHolding(ctx context.Context, tx *sql.Tx, customerID string) (*holding, error) {
+	_, err := tx.ExecContext(ctx, `
+        SELECT id FROM accounts WHERE customer_id = $1 ORDER BY id FOR UPDATE
+    `, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock customer accounts: %w", err)
+	}
+
+	h, err := queryHolding(ctx, tx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	if h == nil || h.balance <= 0 {
+		return nil, isaerrors.ErrNothingToTransfer
+	}
+	if h.inFlight > 0 {
+		return nil, isaerrors.ErrInvestmentsInFlight
+	}
+	return h, nil
+}
+
+// Note: Closes the ISA account once its holding has been transferred away and returns how many
+// accounts the customer still has open. A Lifetime ISA is a separate account and stays open
+func (r *Repository) closeISAAccount(ctx context.Context, customerID string) (int, error) {
+	var open int
+	err := r.db.QueryRowContext(ctx, `
+        WITH closed AS (
+            UPDATE accounts
+            SET status = 'closed', closed_at = CURRENT_TIMESTAMP
+            WHERE customer_id = $1 AND product IN ('isa', 'junior_isa') AND status = 'open'
+            RETURNING id
+        )
+        SELECT COUNT(*) FROM accounts
+        WHERE customer_id = $1 AND status = 'open' AND id NOT IN (SELECT id FROM closed)
+    `, customerID).Scan(&open)
+	if err != nil {
+		return 0, fmt.Errorf("failed to close isa account: %w", err)
+	}
+	return open, nil
+}
+
+// Note: Subscriptions made in the tax year [start, end) and in earlier years, and the date of the
+// first subscription this year. Transfers in count as the subscriptions they carried rather than
+// the cash received, so the split follows the customer from manager to manager
+func (r *Repository) getSubscriptionSplit(ctx context.Context, customerID string, start, end time.Time) (float64, float64, string, error) {
+	var current, previous float64
+	var first string
+	err := r.db.QueryRowContext(ctx, `
+        WITH subscriptions AS (
+            SELECT i.created_at, i.amount, 0::numeric AS carried
+            FROM investments i
+            WHERE i.customer_id = $1 AND i.product = 'isa' AND i.type = 'deposit'
+                AND i.status NOT IN ('held', 'failed', 'cancelled')
+                AND NOT EXISTS (SELECT 1 FROM isa_transfers t WHERE t.investment_id = i.id)
+            UNION ALL
+            SELECT created_at, current_year_amount, previous_years_amount
+            FROM isa_transfers
+            WHERE customer_id = $1 AND direction = 'in' AND status = 'completed'
+        )
+        SELECT
+            COALESCE(SUM(amount) FILTER (WHERE created_at >= $2 AND created_at < $3), 0),
+            COALESCE(SUM(amount) FILTER (WHERE created_at < $2), 0) + COALESCE(SUM(carried), 0),
+            COALESCE(TO_CHAR(MIN(created_at) FILTER (WHERE created_at >= $2 AND created_at < $3 AND amount > 0), 'YYYY-MM-DD'), '')
+        FROM subscriptions
+    `, customerID, start, end).Scan(&current, &previous, &first)
+	if err != nil {
+		return 0, 0, "", fmt.Errorf("failed to get subscription split: %w", err)
+	}
+	return current, previous, first, nil
+}
+
+// Note: Places the sale of the whole holding as a withdrawal, which settlement takes through to
+// settled like any other. The proceeds are paid to the receiving manager rather than the customer
+func (r *Repository) acceptCashTransferOut(ctx context.Context, transfer *models.ISATransfer, dealingDate, reason string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	h, err := lockHolding(ctx, tx, transfer.CustomerID)
+	if err != nil {
+		return err
+	}
+
+	var investmentID string
+	err = tx.QueryRowContext(ctx, `
+	INSERT INTO investments (customer_id, fund_id, amount, type, status, dealing_date, account_id)
+	VALUES ($1, $2, $3, 'withdrawal', 'pending', $4::date, $5)
+	RETURNING id
+`, transfer.CustomerID, transfer.FundID, h.balance, dealingDate, h.accountID).Scan(&investmentID)
+	if err != nil {
+		return fmt.Errorf("failed to record transfer sale: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	INSERT INTO investment_status_history (investment_id, from_status, to_status, reason)
+	VALUES ($1, NULL, 'pending', $2)
+`, investmentID, fmt.Sprintf("isa transfer out to %s", transfer.ProviderName))
+	if err != nil {
+		return fmt.Errorf("failed to record status history: %w", err)
+	}
+
+	if err := ledger.PostStatusChange(ctx, tx, investmentID, "", models.InvestmentStatusPending); err != nil {
+		return err
+	}
+
+	res, err := tx.ExecContext(ctx, `
+	UPDATE isa_transfers
+	SET status = 'accepted', status_updated_at = CURRENT_TIMESTAMP, investment_id = $3
+	WHERE id = $1 AND status = $2
+`, transfer.ID, transfer.Status, investmentID)
+	if err != nil {
+		return fmt.Errorf("failed to accept isa transfer: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return isaerrors.ErrTransferStatusChanged
+	}
+
+	if err := insertStatusChange(ctx, tx, transfer.ID, transfer.Status, models.TransferStatusAccepted, reason); err != nil {
+		return err
+	}
+
+	log.Printf("Attempting to refresh materialized view")
+	_, err = tx.ExecContext(ctx, "REFRESH MATERIALIZED VIEW customer_fund_totals")
+	if err != nil {
+		return fmt.Errorf("failed to refresh materialized view: %w", err)
+	}
+	log.Printf("Successfully refreshed materialized view")
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// Note: Cash transfers complete once the sale has settled and the proceeds have been sent.
+// In specie transfers re-register every unit held, recorded as a settled transfer_out investment
+func (r *Repository) completeTransferOut(ctx context.Context, transfer *models.ISATransfer, reason string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	investmentID := transfer.InvestmentID
+	var amount float64
+	var units sql.NullFloat64
+	if transfer.Method == models.TransferMethodCash {
+		var status string
+		err = tx.QueryRowContext(ctx, `
+        SELECT status, amount, units FROM investments WHERE id = $1
+    `, investmentID).Scan(&status, &amount, &units)
+		if err != nil {
+			return fmt.Errorf("failed to get transfer sale: %w", err)
+		}
+		if status != models.InvestmentStatusSettled {
+			return isaerrors.ErrTransferNotSettled
+		}
+	} else {
+		h, err := lockHolding(ctx, tx, transfer.CustomerID)
+		if err != nil {
+			return err
+		}
+		amount, units = h.balance, sql.NullFloat64{Float64: h.units, Valid: true}
+		err = tx.QueryRowContext(ctx, `
+	INSERT INTO investments (customer_id, fund_id, amount, type, status, units, account_id)
+	VALUES ($1, $2, $3, 'transfer_out', 'settled', $4, $5)
+	RETURNING id
+`, transfer.CustomerID, transfer.FundID, amount, h.units, h.accountID).Scan(&investmentID)
+		if err != nil {
+			return fmt.Errorf("failed to record transfer out investment: %w", err)
+		}
+
+		_, err = tx.ExecContext(ctx, `
+	INSERT INTO investment_status_history (investment_id, from_status, to_status, reason)
+	VALUES ($1, NULL, 'settled', $2)
+`, investmentID, fmt.Sprintf("isa transfer out to %s", transfer.ProviderName))
+		if err != nil {
+			return fmt.Errorf("failed to record status history: %w", err)
+		}
+
+		if err := ledger.PostStatusChange(ctx, tx, investmentID, "", models.InvestmentStatusSettled); err != nil {
+			return err
+		}
+	}
+
+	res, err := tx.ExecContext(ctx, `
+	UPDATE isa_transfers
+	SET status = 'completed', status_updated_at = CURRENT_TIMESTAMP, completed_at = CURRENT_TIMESTAMP,
+		transferred_amount = $3, transferred_units = $4, investment_id = $5
+	WHERE id = $1 AND status = $2
+`, transfer.ID, transfer.Status, amount, units, investmentID)
+	if err != nil {
+		return fmt.Errorf("failed to complete isa transfer: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return isaerrors.ErrTransferStatusChanged
+	}
+
+	if err := insertStatusChange(ctx, tx, transfer.ID, transfer.Status, models.TransferStatusCompleted, reason); err != nil {
+		return err
+	}
+
+	log.Printf("Attempting to refresh materialized view")
+	_, err = tx.ExecContext(ctx, "REFRESH MATERIALIZED VIEW customer_fund_totals")
+	if err != nil {
+		return fmt.Errorf("failed to refresh materialized view: %w", err)
+	}
+	log.Printf("Successfully refreshed materialized view")
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// Note: Also returns when the transfer was requested, which decides the tax year it reports on
+func (r *Repository) getTransferHistory(ctx context.Context, id string) (*models.TransferHistory, time.Time, error) {
+	var history models.TransferHistory
+	var requestedAt time.Time
+	var transferred, transferredUnits sql.NullFloat64
+	var completed sql.NullTime
+	err := r.db.QueryRowContext(ctx, `
+        SELECT t.id, t.customer_id, COALESCE(c.first_name, ''), COALESCE(c.last_name, ''),
+            COALESCE(TO_CHAR(c.date_of_birth, 'YYYY-MM-DD'), ''),
+            t.provider_name, COALESCE(t.provider_frn, ''), t.provider_reference, t.scope, t.method,
+            t.current_year_amount, COALESCE(TO_CHAR(t.first_subscription_date, 'YYYY-MM-DD'), ''), t.previous_years_amount,
+            f.name, COALESCE(f.isin, ''), t.transferred_amount, t.transferred_units, t.status, t.completed_at, t.created_at
+        FROM isa_transfers t
+        JOIN retail_customers c ON c.id = t.customer_id
+        JOIN funds f ON f.id = t.fund_id
+        WHERE t.id = $1 AND t.direction = 'out'
+    `, id).Scan(
+		&history.TransferID,
+		&history.CustomerID,
+		&history.FirstName,
+		&history.LastName,
+		&history.DateOfBirth,
+		&history.ReceivingManager,
+		&history.ReceivingManagerFRN,
+		&history.ReceivingReference,
+		&history.Scope,
+		&history.Method,
+		&history.CurrentYearSubscriptions,
+		&history.FirstSubscriptionDate,
+		&history.PreviousYearsSubscriptions,
+		&history.FundName,
+		&history.FundISIN,
+		&transferred,
+		&transferredUnits,
+		&history.Status,
+		&completed,
+		&requestedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, time.Time{}, isaerrors.ErrTransferNotFound
+		}
+		return nil, time.Time{}, fmt.Errorf("failed to get transfer history: %w", err)
+	}
+	if transferred.Valid {
+		history.TransferredAmount = &transferred.Float64
+	}
+	if transferredUnits.Valid {
+		history.TransferredUnits = &transferredUnits.Float64
+	}
+	if completed.Valid {
+		history.CompletedAt = &completed.Time
+	}
+	return &history, requestedAt, nil
+}
+
+func insertStatusChange(ctx context.Context, tx *sql.Tx, transferID, from, to, reason string) error {
+	_, err := tx.ExecContext(ctx, `
+	INSERT INTO isa_transfer_status_history (transfer_id, from_status, to_status, reason)
+	VALUES ($1, NULLIF($2, ''), $3, NULLIF($4, ''))
+`, transferID, from, to, reason)
+	if err != nil {
+		return fmt.Errorf("failed to record transfer status change: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,363 @@
+package transfer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var transferRowColumns = []string{"id", "customer_id", "fund_id", "direction", "provider_name", "provider_reference", "provider_frn",
+	"scope", "method", "current_year_amount", "previous_years_amount", "first_subscription_date",
+	"received_amount", "transferred_amount", "transferred_units", "status", "investment_id",
+	"created_at", "status_updated_at", "completed_at"}
+
+func TestValidateTransferIn(t *testing.T) {
+	valid := models.CreateTransferInRequest{
+		ProviderName:        "Other Provider",
+		ProviderReference:   "ACC123",
+		Scope:               models.TransferScopeFull,
+		CurrentYearAmount:   2000,
+		PreviousYearsAmount: 15000,
+	}
+	assert.NoError(t, validateTransferIn(&valid))
+
+	tests := []struct {
+		name   string
+		modify func(req *models.CreateTransferInRequest)
+		err    error
+	}{
+		{"missing provider", func(req *models.CreateTransferInRequest) { req.ProviderName = " " }, isaerrors.ErrTransferProviderRequired},
+		{"invalid scope", func(req *models.CreateTransferInRequest) { req.Scope = "some" }, isaerrors.ErrInvalidTransferScope},
+		{"negative amount", func(req *models.CreateTransferInRequest) { req.PreviousYearsAmount = -1 }, isaerrors.ErrInvalidTransferAmounts},
+		{"nothing to transfer", func(req *models.CreateTransferInRequest) {
+			req.CurrentYearAmount, req.PreviousYearsAmount = 0, 0
+		}, isaerrors.ErrInvalidTransferAmounts},
+		{"partial current year", func(req *models.CreateTransferInRequest) { req.Scope = models.TransferScopePartial }, isaerrors.ErrCurrentYearTransferPartial},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := valid
+			tt.modify(&req)
+			assert.ErrorIs(t, validateTransferIn(&req), tt.err)
+		})
+	}
+
+	partial := valid
+	partial.Scope, partial.CurrentYearAmount = models.TransferScopePartial, 0
+	assert.NoError(t, validateTransferIn(&partial))
+}
+
+func TestValidateTransferOut(t *testing.T) {
+	valid := models.CreateTransferOutRequest{
+		ProviderName:      "Other Provider",
+		ProviderReference: "ACC123",
+		ProviderFRN:       "123456",
+		Method:            models.TransferMethodInSpecie,
+	}
+	assert.NoError(t, validateTransferOut(&valid))
+
+	tests := []struct {
+		name   string
+		modify func(req *models.CreateTransferOutRequest)
+		err    error
+	}{
+		{"missing reference", func(req *models.CreateTransferOutRequest) { req.ProviderReference = "" }, isaerrors.ErrTransferProviderRequired},
+		{"short frn", func(req *models.CreateTransferOutRequest) { req.ProviderFRN = "12345" }, isaerrors.ErrInvalidProviderFRN},
+		{"non numeric frn", func(req *models.CreateTransferOutRequest) { req.ProviderFRN = "12345A" }, isaerrors.ErrInvalidProviderFRN},
+		{"invalid method", func(req *models.CreateTransferOutRequest) { req.Method = "cheque" }, isaerrors.ErrInvalidTransferMethod},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := valid
+			tt.modify(&req)
+			assert.ErrorIs(t, validateTransferOut(&req), tt.err)
+		})
+	}
+}
+
+func TestCanTransition(t *testing.T) {
+	assert.True(t, canTransition(models.TransferDirectionIn, models.TransferStatusRequested, models.TransferStatusSubmitted))
+	assert.True(t, canTransition(models.TransferDirectionIn, models.TransferStatusAccepted, models.TransferStatusCompleted))
+	assert.False(t, canTransition(models.TransferDirectionIn, models.TransferStatusRequested, models.TransferStatusCompleted))
+	assert.False(t, canTransition(models.TransferDirectionIn, models.TransferStatusAccepted, models.TransferStatusCancelled))
+	assert.False(t, canTransition(models.TransferDirectionIn, models.TransferStatusCompleted, models.TransferStatusRejected))
+
+	assert.True(t, canTransition(models.TransferDirectionOut, models.TransferStatusRequested, models.TransferStatusAccepted))
+	assert.True(t, canTransition(models.TransferDirectionOut, models.TransferStatusAccepted, models.TransferStatusCompleted))
+	assert.False(t, canTransition(models.TransferDirectionOut, models.TransferStatusRequested, models.TransferStatusSubmitted))
+	assert.False(t, canTransition(models.TransferDirectionOut, models.TransferStatusAccepted, models.TransferStatusCancelled))
+}
+
+func TestRepository_CreateTransfer(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+	newTransfer := func() *models.ISATransfer {
+		return &models.ISATransfer{
+			CustomerID:          "customer1",
+			FundID:              "fund1",
+			Direction:           models.TransferDirectionIn,
+			ProviderName:        "Other Provider",
+			ProviderReference:   "ACC123",
+			Scope:               models.TransferScopeFull,
+			Method:              models.TransferMethodCash,
+			CurrentYearAmount:   2000,
+			PreviousYearsAmount: 15000,
+			Status:              models.TransferStatusRequested,
+		}
+	}
+
+	t.Run("created", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT fund_id FROM investments WHERE customer_id = \\$1").
+			WithArgs("customer1").
+			WillReturnRows(sqlmock.NewRows([]string{"fund_id"}))
+		mock.ExpectQuery("INSERT INTO isa_transfers (.+) RETURNING id, created_at, status_updated_at").
+			WithArgs("customer1", "fund1", "in", "Other Provider", "ACC123", "", "full", "cash", 2000.0, 15000.0, "", "requested").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "status_updated_at"}).AddRow("transfer1", time.Now(), time.Now()))
+		mock.ExpectExec("INSERT INTO isa_transfer_status_history").
+			WithArgs("transfer1", "", "requested", "requested by customer").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		transfer := newTransfer()
+		require.NoError(t, repo.createTransfer(ctx, transfer))
+		assert.Equal(t, "transfer1", transfer.ID)
+	})
+
+	t.Run("different fund", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT fund_id FROM investments").
+			WithArgs("customer1").
+			WillReturnRows(sqlmock.NewRows([]string{"fund_id"}).AddRow("fund2"))
+		mock.ExpectRollback()
+
+		assert.ErrorIs(t, repo.createTransfer(ctx, newTransfer()), isaerrors.ErrDifferentFundNotAllowed)
+	})
+
+	t.Run("transfer out already in progress", func(t *testing.T) {
+		transfer := newTransfer()
+		transfer.Direction, transfer.ProviderFRN, transfer.Method = models.TransferDirectionOut, "123456", models.TransferMethodInSpecie
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO isa_transfers").
+			WillReturnError(&pq.Error{Code: "23505"})
+		mock.ExpectRollback()
+
+		assert.ErrorIs(t, repo.createTransfer(ctx, transfer), isaerrors.ErrTransferOutInProgress)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_CompleteTransferIn(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	transfer := &models.ISATransfer{ID: "transfer1", CustomerID: "customer1", FundID: "fund1", ProviderName: "Other Provider", Status: models.TransferStatusAccepted}
+
+	t.Run("credits the holding", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO investments (.+) 'deposit', 'cash_received'").
+			WithArgs("customer1", "fund1", 17250.0, "2025-01-03").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("inv1"))
+		mock.ExpectExec("INSERT INTO investment_status_history").
+			WithArgs("inv1", "isa transfer in from Other Provider").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("SELECT type, customer_id, fund_id, amount, units FROM investments WHERE id = \\$1").
+			WithArgs("inv1").
+			WillReturnRows(sqlmock.NewRows([]string{"type", "customer_id", "fund_id", "amount", "units"}).
+				AddRow(models.InvestmentTypeDeposit, "customer1", "fund1", 17250.0, nil))
+		mock.ExpectExec("INSERT INTO ledger_accounts").
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec("INSERT INTO ledger_journals (.+) INSERT INTO ledger_postings").
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec("UPDATE isa_transfers SET status = 'completed'").
+			WithArgs("transfer1", "accepted", 17250.0, "inv1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO isa_transfer_status_history").
+			WithArgs("transfer1", "accepted", "completed", "cash received").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("REFRESH MATERIALIZED VIEW customer_fund_totals").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		assert.NoError(t, repo.completeTransferIn(context.Background(), transfer, 17250, "2025-01-03", "cash received"))
+	})
+
+	t.Run("status changed", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO investments").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("inv2"))
+		mock.ExpectExec("INSERT INTO investment_status_history").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("SELECT type, customer_id, fund_id, amount, units FROM investments").
+			WillReturnRows(sqlmock.NewRows([]string{"type", "customer_id", "fund_id", "amount", "units"}).
+				AddRow(models.InvestmentTypeDeposit, "customer1", "fund1", 17250.0, nil))
+		mock.ExpectExec("INSERT INTO ledger_accounts").
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec("INSERT INTO ledger_journals").
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec("UPDATE isa_transfers").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		err := repo.completeTransferIn(context.Background(), transfer, 17250, "2025-01-03", "")
+		assert.ErrorIs(t, err, isaerrors.ErrTransferStatusChanged)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_GetHolding(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	holdingColumns := []string{"account_id", "fund_id", "balance", "units", "in_flight"}
+
+	mock.ExpectQuery("SELECT account_id, fund_id, (.+) FROM investments WHERE account_id = \\( SELECT id FROM accounts WHERE customer_id = \\$1 AND product IN \\('isa', 'junior_isa'\\)").
+		WithArgs("customer1").
+		WillReturnRows(sqlmock.NewRows(holdingColumns).AddRow("account1", "fund1", 17250.0, 1500.5, 0))
+
+	h, err := repo.getHolding(context.Background(), "customer1")
+	require.NoError(t, err)
+	require.NotNil(t, h)
+	assert.Equal(t, "account1", h.accountID)
+	assert.Equal(t, "fund1", h.fundID)
+	assert.Equal(t, 17250.0, h.balance)
+	assert.Equal(t, 1500.5, h.units)
+
+	mock.ExpectQuery("SELECT account_id, fund_id").
+		WithArgs("customer2").
+		WillReturnRows(sqlmock.NewRows(holdingColumns))
+
+	h, err = repo.getHolding(context.Background(), "customer2")
+	require.NoError(t, err)
+	assert.Nil(t, h)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_CompleteTransferOut(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+
+	t.Run("in specie re-registers the units", func(t *testing.T) {
+		transfer := &models.ISATransfer{ID: "transfer1", CustomerID: "customer1", FundID: "fund1", ProviderName: "Other Provider",
+			Method: models.TransferMethodInSpecie, Status: models.TransferStatusAccepted}
+		mock.ExpectBegin()
+		mock.ExpectExec("SELECT id FROM accounts WHERE customer_id = \\$1 ORDER BY id FOR UPDATE").
+			WithArgs("customer1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("SELECT account_id, fund_id").
+			WithArgs("customer1").
+			WillReturnRows(sqlmock.NewRows([]string{"account_id", "fund_id", "balance", "units", "in_flight"}).
+				AddRow("account1", "fund1", 17250.0, 1500.5, 0))
+		mock.ExpectQuery("INSERT INTO investments (.+) 'transfer_out', 'settled'").
+			WithArgs("customer1", "fund1", 17250.0, 1500.5, "account1").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("inv1"))
+		mock.ExpectExec("INSERT INTO investment_status_history").
+			WithArgs("inv1", "isa transfer out to Other Provider").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("SELECT type, customer_id, fund_id, amount, units FROM investments WHERE id = \\$1").
+			WithArgs("inv1").
+			WillReturnRows(sqlmock.NewRows([]string{"type", "customer_id", "fund_id", "amount", "units"}).
+				AddRow(models.InvestmentTypeTransferOut, "customer1", "fund1", 17250.0, 1500.5))
+		mock.ExpectExec("INSERT INTO ledger_accounts").
+			WillReturnResult(sqlmock.NewResult(0, 4))
+		mock.ExpectExec("INSERT INTO ledger_journals (.+) INSERT INTO ledger_postings").
+			WillReturnResult(sqlmock.NewResult(0, 4))
+		mock.ExpectExec("UPDATE isa_transfers SET status = 'completed'").
+			WithArgs("transfer1", "accepted", 17250.0, 1500.5, "inv1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO isa_transfer_status_history").
+			WithArgs("transfer1", "accepted", "completed", "re-registered").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("REFRESH MATERIALIZED VIEW customer_fund_totals").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		assert.NoError(t, repo.completeTransferOut(context.Background(), transfer, "re-registered"))
+	})
+
+	t.Run("cash sale not settled", func(t *testing.T) {
+		transfer := &models.ISATransfer{ID: "transfer2", CustomerID: "customer1", FundID: "fund1", InvestmentID: "inv2",
+			Method: models.TransferMethodCash, Status: models.TransferStatusAccepted}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT status, amount, units FROM investments WHERE id = \\$1").
+			WithArgs("inv2").
+			WillReturnRows(sqlmock.NewRows([]string{"status", "amount", "units"}).
+				AddRow(models.InvestmentStatusUnitsAllocated, 17250.0, 1500.5))
+		mock.ExpectRollback()
+
+		err := repo.completeTransferOut(context.Background(), transfer, "")
+		assert.ErrorIs(t, err, isaerrors.ErrTransferNotSettled)
+	})
+
+	t.Run("in specie with a withdrawal placed since the check", func(t *testing.T) {
+		transfer := &models.ISATransfer{ID: "transfer3", CustomerID: "customer1", FundID: "fund1",
+			Method: models.TransferMethodInSpecie, Status: models.TransferStatusAccepted}
+
+		mock.ExpectBegin()
+		mock.ExpectExec("SELECT id FROM accounts WHERE customer_id = \\$1 ORDER BY id FOR UPDATE").
+			WithArgs("customer1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("SELECT account_id, fund_id").
+			WithArgs("customer1").
+			WillReturnRows(sqlmock.NewRows([]string{"account_id", "fund_id", "balance", "units", "in_flight"}).
+				AddRow("account1", "fund1", 17250.0, 1500.5, 1))
+		mock.ExpectRollback()
+
+		err := repo.completeTransferOut(context.Background(), transfer, "")
+		assert.ErrorIs(t, err, isaerrors.ErrInvestmentsInFlight)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_GetTransfer(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	now := time.Now()
+
+	mock.ExpectQuery("SELECT (.+) FROM isa_transfers WHERE id = \\$1").
+		WithArgs("transfer1").
+		WillReturnRows(sqlmock.NewRows(transferRowColumns).
+			AddRow("transfer1", "customer1", "fund1", "in", "Other Provider", "ACC123", "", "full", "cash",
+				2000.0, 15000.0, "", 17250.0, nil, nil, "completed", "inv1", now, now, now))
+
+	transfer, err := repo.getTransfer(context.Background(), "transfer1")
+	require.NoError(t, err)
+	require.NotNil(t, transfer.ReceivedAmount)
+	assert.Equal(t, 17250.0, *transfer.ReceivedAmount)
+	assert.Equal(t, "inv1", transfer.InvestmentID)
+	assert.NotNil(t, transfer.CompletedAt)
+
+	mock.ExpectQuery("SELECT (.+) FROM isa_transfers").
+		WithArgs("missing").
+		WillReturnRows(sqlmock.NewRows(transferRowColumns))
+
+	_, err = repo.getTransfer(context.Background(), "missing")
+	assert.ErrorIs(t, err, isaerrors.ErrTransferNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
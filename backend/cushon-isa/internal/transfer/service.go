@@ -0,0 +1,269 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/stcol316/cushon-isa/internal/customer"
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/fund"
+	"github.com/stcol316/cushon-isa/internal/investment"
+	"github.com/stcol316/cushon-isa/internal/models"
+)
+
+type Service struct {
+	repo        *Repository
+	investments *investment.Service
+	funds       *fund.Service
+	customers   *customer.Service
+	now         func() time.Time
+}
+
+func NewService(repo *Repository, investments *investment.Service, funds *fund.Service, customers *customer.Service) *Service {
+	return &Service{repo: repo, investments: investments, funds: funds, customers: customers, now: time.Now}
+}
+
+// FCA firm reference numbers are 6 digits, newer firms are given 7
+var frnPattern = regexp.MustCompile(`^\d{6,7}$`)
+
+// Note: Transfers in are only accepted for customers who could otherwise deposit, into a fund
+// that is open to new money. The allowance is not checked, current year subscriptions have
+// already been made with the ceding provider, but they do count against it from now on
+func (s *Service) createTransferIn(ctx context.Context, req *models.CreateTransferInRequest) (*models.ISATransfer, error) {
+	if err := validateTransferIn(req); err != nil {
+		return nil, err
+	}
+
+	if err := s.investments.CheckDepositEligibility(ctx, req.CustomerID); err != nil {
+		return nil, err
+	}
+	if _, err := s.funds.GetOpenFund(ctx, req.FundID); err != nil {
+		if errors.Is(err, isaerrors.ErrFundNotFound) {
+			return nil, isaerrors.ErrInvalidInvestmentRef
+		}
+		return nil, err
+	}
+
+	transfer := &models.ISATransfer{
+		CustomerID:          req.CustomerID,
+		FundID:              req.FundID,
+		Direction:           models.TransferDirectionIn,
+		ProviderName:        strings.TrimSpace(req.ProviderName),
+		ProviderReference:   strings.TrimSpace(req.ProviderReference),
+		Scope:               req.Scope,
+		Method:              models.TransferMethodCash,
+		CurrentYearAmount:   req.CurrentYearAmount,
+		PreviousYearsAmount: req.PreviousYearsAmount,
+		Status:              models.TransferStatusRequested,
+	}
+	if err := s.repo.createTransfer(ctx, transfer); err != nil {
+		return nil, err
+	}
+
+	return transfer, nil
+}
+
+func validateTransferIn(req *models.CreateTransferInRequest) error {
+	if strings.TrimSpace(req.ProviderName) == "" || strings.TrimSpace(req.ProviderReference) == "" {
+		return isaerrors.ErrTransferProviderRequired
+	}
+	if req.Scope != models.TransferScopeFull && req.Scope != models.TransferScopePartial {
+		return isaerrors.ErrInvalidTransferScope
+	}
+	if req.CurrentYearAmount < 0 || req.PreviousYearsAmount < 0 || req.CurrentYearAmount+req.PreviousYearsAmount <= 0 {
+		return isaerrors.ErrInvalidTransferAmounts
+	}
+	// Note: HMRC rules. Subscriptions made this tax year can only be transferred in full,
+	// a partial transfer may only move money subscribed in earlier years
+	if req.Scope == models.TransferScopePartial && req.CurrentYearAmount > 0 {
+		return isaerrors.ErrCurrentYearTransferPartial
+	}
+	return nil
+}
+
+// Note: Transfers out are always of the whole ISA, customers are limited to one fund so there is
+// nothing to split. The subscription split is fixed when the request is made, the tax year it
+// was made in is the one the receiving manager reports against
+func (s *Service) createTransferOut(ctx context.Context, req *models.CreateTransferOutRequest) (*models.ISATransfer, error) {
+	if err := validateTransferOut(req); err != nil {
+		return nil, err
+	}
+
+	h, err := s.repo.getHolding(ctx, req.CustomerID)
+	if err != nil {
+		return nil, err
+	}
+	if h == nil || h.balance <= 0 {
+		return nil, isaerrors.ErrNothingToTransfer
+	}
+	if h.inFlight > 0 {
+		return nil, isaerrors.ErrInvestmentsInFlight
+	}
+
+	start, end := investment.TaxYear(s.now())
+	current, previous, first, err := s.repo.getSubscriptionSplit(ctx, req.CustomerID, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	transfer := &models.ISATransfer{
+		CustomerID:            req.CustomerID,
+		FundID:                h.fundID,
+		Direction:             models.TransferDirectionOut,
+		ProviderName:          strings.TrimSpace(req.ProviderName),
+		ProviderReference:     strings.TrimSpace(req.ProviderReference),
+		ProviderFRN:           req.ProviderFRN,
+		Scope:                 models.TransferScopeFull,
+		Method:                req.Method,
+		CurrentYearAmount:     current,
+		PreviousYearsAmount:   previous,
+		FirstSubscriptionDate: first,
+		Status:                models.TransferStatusRequested,
+	}
+	if err := s.repo.createTransfer(ctx, transfer); err != nil {
+		return nil, err
+	}
+
+	return transfer, nil
+}
+
+func validateTransferOut(req *models.CreateTransferOutRequest) error {
+	if strings.TrimSpace(req.ProviderName) == "" || strings.TrimSpace(req.ProviderReference) == "" {
+		return isaerrors.ErrTransferProviderRequired
+	}
+	if !frnPattern.MatchString(req.ProviderFRN) {
+		return isaerrors.ErrInvalidProviderFRN
+	}
+	if req.Method != models.TransferMethodCash && req.Method != models.TransferMethodInSpecie {
+		return isaerrors.ErrInvalidTransferMethod
+	}
+	return nil
+}
+
+func (s *Service) getTransfer(ctx context.Context, id string) (*models.ISATransfer, error) {
+	transfer, err := s.repo.getTransfer(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	transfer.StatusHistory, err = s.repo.getStatusHistory(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return transfer, nil
+}
+
+func (s *Service) listCustomerTransfers(ctx context.Context, customerID string) ([]models.ISATransfer, error) {
+	return s.repo.listCustomerTransfers(ctx, customerID)
+}
+
+// Note: Admin only. Moves a transfer through its stages as the other provider responds.
+// Completing a transfer in credits the cash received to the customer's holding, transfers
+// out are handled by transitionTransferOut
+func (s *Service) transitionTransfer(ctx context.Context, id string, req *models.TransitionTransferRequest) (*models.ISATransfer, error) {
+	transfer, err := s.repo.getTransfer(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !canTransition(transfer.Direction, transfer.Status, req.Status) {
+		return nil, isaerrors.ErrInvalidTransferTransition
+	}
+
+	if transfer.Direction == models.TransferDirectionOut {
+		return s.transitionTransferOut(ctx, transfer, req)
+	}
+
+	if req.Status != models.TransferStatusCompleted {
+		if err := s.repo.updateStatus(ctx, id, transfer.Status, req.Status, req.Reason); err != nil {
+			return nil, err
+		}
+		return s.getTransfer(ctx, id)
+	}
+
+	if req.ReceivedAmount == nil || *req.ReceivedAmount <= 0 {
+		return nil, isaerrors.ErrReceivedAmountRequired
+	}
+
+	dealingDate, err := s.funds.DealingDate(ctx, transfer.FundID, s.now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dealing date: %w", err)
+	}
+
+	if err := s.repo.completeTransferIn(ctx, transfer, *req.ReceivedAmount, dealingDate, req.Reason); err != nil {
+		return nil, err
+	}
+
+	return s.getTransfer(ctx, id)
+}
+
+// Note: Accepting a cash transfer sells the holding. Completing it records what left, after
+// which an account with nothing left in it is closed
+func (s *Service) transitionTransferOut(ctx context.Context, transfer *models.ISATransfer, req *models.TransitionTransferRequest) (*models.ISATransfer, error) {
+	switch {
+	case req.Status == models.TransferStatusAccepted && transfer.Method == models.TransferMethodCash:
+		h, err := s.repo.getHolding(ctx, transfer.CustomerID)
+		if err != nil {
+			return nil, err
+		}
+		if h == nil || h.balance <= 0 {
+			return nil, isaerrors.ErrNothingToTransfer
+		}
+		if h.inFlight > 0 {
+			return nil, isaerrors.ErrInvestmentsInFlight
+		}
+
+		dealingDate, err := s.funds.DealingDate(ctx, transfer.FundID, s.now())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get dealing date: %w", err)
+		}
+
+		if err := s.repo.acceptCashTransferOut(ctx, transfer, dealingDate, req.Reason); err != nil {
+			return nil, err
+		}
+
+	case req.Status == models.TransferStatusCompleted:
+		if err := s.repo.completeTransferOut(ctx, transfer, req.Reason); err != nil {
+			return nil, err
+		}
+
+		remaining, err := s.repo.getHolding(ctx, transfer.CustomerID)
+		if err != nil {
+			return nil, err
+		}
+		if remaining == nil || remaining.balance <= 0 {
+			open, err := s.repo.closeISAAccount(ctx, transfer.CustomerID)
+			if err != nil {
+				return nil, err
+			}
+			if open == 0 {
+				if err := s.customers.CloseAccount(ctx, transfer.CustomerID); err != nil {
+					return nil, fmt.Errorf("failed to close account: %w", err)
+				}
+			}
+		}
+
+	default:
+		if err := s.repo.updateStatus(ctx, transfer.ID, transfer.Status, req.Status, req.Reason); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.getTransfer(ctx, transfer.ID)
+}
+
+func (s *Service) getTransferHistory(ctx context.Context, id string) (*models.TransferHistory, error) {
+	history, requestedAt, err := s.repo.getTransferHistory(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	start, _ := investment.TaxYear(requestedAt)
+	history.TaxYear = investment.TaxYearName(start)
+	return history, nil
+}
@@ -0,0 +1,44 @@
+package valuation
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/models"
+	helper "github.com/stcol316/cushon-isa/pkg/helpers"
+)
+
+type Handler struct {
+	scheduler *Scheduler
+}
+
+func NewHandler(scheduler *Scheduler) *Handler {
+	return &Handler{scheduler: scheduler}
+}
+
+// Note: Admin only. With no body the job catches up to today as the nightly run would.
+// A body of {"from": "2025-01-01", "to": "2025-01-31"} revalues that range
+func (h *Handler) RunValuationHandler(w http.ResponseWriter, r *http.Request) {
+	req := new(models.RunValuationRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil && !errors.Is(err, io.EOF) {
+		helper.RespondWithProblem(w, r, isaerrors.ErrInvalidRequestBody.Wrap(err))
+		return
+	}
+
+	var run *models.ValuationRun
+	var err error
+	if req.From == "" {
+		run, err = h.scheduler.RunNow(r.Context())
+	} else {
+		run, err = h.scheduler.Backfill(r.Context(), req)
+	}
+	if err != nil {
+		helper.RespondWithProblem(w, r, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, run)
+}
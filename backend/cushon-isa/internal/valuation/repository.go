@@ -0,0 +1,119 @@
+package valuation
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// TODO: Use interfaces at service level instead of "repo *Repository"
+type ValuationRepository interface {
+	ValueHoldings(ctx context.Context, date time.Time) (int, error)
+	GetLastValuationDate(ctx context.Context) (*time.Time, error)
+	GetFirstFlowDate(ctx context.Context) (*time.Time, error)
+}
+
+type Repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Note: Writes the snapshot for one date. Existing rows for the date are replaced in the same
+// transaction so reruns are idempotent and pick up investments allocated after the first run.
+// Only allocated and settled investments are held, dated by their dealing date where known.
+// Units are valued at the latest price on or before the date and unpriced money at cost.
+// Holdings that have been fully withdrawn are not written once the withdrawal day has passed.
+// Charges reduce units but are not cash flows, so performance is measured net of charges.
+// TODO: Workplace pensions are held by employees and are not valued yet
+func (r *Repository) valueHoldings(ctx context.Context, date time.Time) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM holding_valuations WHERE valuation_date = $1::date`, date); err != nil {
+		return 0, fmt.Errorf("failed to clear valuations: %w", err)
+	}
+
+	res, err := tx.ExecContext(ctx, `
+	INSERT INTO holding_valuations (customer_id, fund_id, valuation_date, units, price, market_value, cost_basis, net_flow)
+	SELECT h.customer_id, h.fund_id, $1::date, h.units, p.price,
+		ROUND(h.units * COALESCE(p.price, 0) + h.unpriced_cash, 2), h.cost_basis, h.net_flow
+	FROM (
+		SELECT customer_id, fund_id,
+			COALESCE(SUM(CASE WHEN type = 'deposit' THEN units ELSE -units END), 0) AS units,
+			COALESCE(SUM(CASE WHEN units IS NOT NULL THEN 0 WHEN type = 'deposit' THEN amount ELSE -amount END), 0) AS unpriced_cash,
+			COALESCE(SUM(CASE WHEN type = 'deposit' THEN amount ELSE -amount END)
+				FILTER (WHERE type <> 'charge'), 0) AS cost_basis,
+			COALESCE(SUM(CASE WHEN type = 'deposit' THEN amount ELSE -amount END)
+				FILTER (WHERE type <> 'charge' AND COALESCE(dealing_date, created_at::date) = $1::date), 0) AS net_flow
+		FROM investments
+		WHERE status IN ('units_allocated', 'settled')
+			AND COALESCE(dealing_date, created_at::date) <= $1::date
+			AND customer_id IS NOT NULL
+		GROUP BY customer_id, fund_id
+	) h
+	LEFT JOIN LATERAL (
+		SELECT price
+		FROM fund_prices
+		WHERE fund_id = h.fund_id AND price_date <= $1::date
+		ORDER BY price_date DESC
+		LIMIT 1
+	) p ON TRUE
+	WHERE NOT (h.units = 0 AND h.unpriced_cash = 0 AND h.net_flow = 0)
+`, date)
+	if err != nil {
+		return 0, fmt.Errorf("failed to write valuations: %w", err)
+	}
+	holdings, _ := res.RowsAffected()
+
+	_, err = tx.ExecContext(ctx, `
+	INSERT INTO valuation_runs (valuation_date, holdings)
+	VALUES ($1::date, $2)
+	ON CONFLICT (valuation_date) DO UPDATE
+	SET holdings = EXCLUDED.holdings, completed_at = CURRENT_TIMESTAMP
+`, date, holdings)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record valuation run: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return int(holdings), nil
+}
+
+// getLastValuationDate returns the latest valued date, or nil if the job has never run
+func (r *Repository) getLastValuationDate(ctx context.Context) (*time.Time, error) {
+	var last sql.NullTime
+	if err := r.db.QueryRowContext(ctx, `SELECT MAX(valuation_date) FROM valuation_runs`).Scan(&last); err != nil {
+		return nil, fmt.Errorf("failed to get last valuation date: %w", err)
+	}
+	if !last.Valid {
+		return nil, nil
+	}
+	return &last.Time, nil
+}
+
+// getFirstFlowDate returns the date of the earliest held investment, or nil if there are none
+func (r *Repository) getFirstFlowDate(ctx context.Context) (*time.Time, error) {
+	var first sql.NullTime
+	err := r.db.QueryRowContext(ctx, `
+        SELECT MIN(COALESCE(dealing_date, created_at::date))
+        FROM investments
+        WHERE status IN ('units_allocated', 'settled')
+    `).Scan(&first)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get first investment date: %w", err)
+	}
+	if !first.Valid {
+		return nil, nil
+	}
+	return &first.Time, nil
+}
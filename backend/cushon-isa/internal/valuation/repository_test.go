@@ -0,0 +1,129 @@
+package valuation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func expectValuation(mock sqlmock.Sqlmock, day time.Time, holdings int64) {
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM holding_valuations WHERE valuation_date = \\$1::date").
+		WithArgs(day).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO holding_valuations (.+) SELECT (.+) FROM investments").
+		WithArgs(day).
+		WillReturnResult(sqlmock.NewResult(0, holdings))
+	mock.ExpectExec("INSERT INTO valuation_runs (.+) ON CONFLICT").
+		WithArgs(day, holdings).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+func TestRepository_ValueHoldings(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	day := date(2025, 1, 2)
+
+	expectValuation(mock, day, 3)
+
+	holdings, err := repo.valueHoldings(context.Background(), day)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, holdings)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_GetLastValuationDate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+
+	t.Run("never run", func(t *testing.T) {
+		mock.ExpectQuery("SELECT MAX\\(valuation_date\\) FROM valuation_runs").
+			WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(nil))
+
+		last, err := repo.getLastValuationDate(context.Background())
+		assert.NoError(t, err)
+		assert.Nil(t, last)
+	})
+
+	t.Run("previous run", func(t *testing.T) {
+		mock.ExpectQuery("SELECT MAX\\(valuation_date\\) FROM valuation_runs").
+			WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(date(2025, 1, 2)))
+
+		last, err := repo.getLastValuationDate(context.Background())
+		assert.NoError(t, err)
+		require.NotNil(t, last)
+		assert.Equal(t, date(2025, 1, 2), *last)
+	})
+}
+
+func TestService_CatchUp(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	service := NewService(NewRepository(db))
+	today := date(2025, 1, 20)
+
+	// Note: Last run was long ago so the job resumes from the day after it
+	mock.ExpectQuery("SELECT MAX\\(valuation_date\\) FROM valuation_runs").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(date(2025, 1, 10)))
+	for day := date(2025, 1, 11); !day.After(today); day = day.AddDate(0, 0, 1) {
+		expectValuation(mock, day, 1)
+	}
+
+	run, err := service.catchUp(context.Background(), today)
+	require.NoError(t, err)
+	assert.Equal(t, "2025-01-11", run.From)
+	assert.Equal(t, 10, run.Dates)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// Note: Up to date, so only the trailing revaluation window is rerun
+	mock.ExpectQuery("SELECT MAX\\(valuation_date\\) FROM valuation_runs").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(today))
+	for day := today.AddDate(0, 0, -revalueDays); !day.After(today); day = day.AddDate(0, 0, 1) {
+		expectValuation(mock, day, 1)
+	}
+
+	run, err = service.catchUp(context.Background(), today)
+	require.NoError(t, err)
+	assert.Equal(t, revalueDays+1, run.Dates)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestService_Backfill(t *testing.T) {
+	service := NewService(nil)
+	today := date(2025, 1, 20)
+
+	tests := []struct {
+		name string
+		req  models.RunValuationRequest
+	}{
+		{"invalid date", models.RunValuationRequest{From: "20/01/2025"}},
+		{"reversed range", models.RunValuationRequest{From: "2025-01-10", To: "2025-01-01"}},
+		{"future date", models.RunValuationRequest{From: "2025-01-21"}},
+		{"range too large", models.RunValuationRequest{From: "2023-01-01", To: "2025-01-01"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.backfill(context.Background(), &tt.req, today)
+			assert.ErrorIs(t, err, isaerrors.ErrValidation)
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package valuation
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/stcol316/cushon-isa/internal/models"
+)
+
+// Note: Runs the valuation job once a night after fund prices have been loaded
+type Scheduler struct {
+	service  *Service
+	hour     int
+	minute   int
+	location *time.Location
+}
+
+// NewScheduler takes the run time as HH:MM in the given IANA time zone.
+// Valuation dates are calendar days in that time zone
+func NewScheduler(service *Service, runAt, timezone string) (*Scheduler, error) {
+	parsed, err := time.Parse("15:04", runAt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid valuation time %q: %w", runAt, err)
+	}
+
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid valuation time zone %q: %w", timezone, err)
+	}
+
+	return &Scheduler{
+		service:  service,
+		hour:     parsed.Hour(),
+		minute:   parsed.Minute(),
+		location: location,
+	}, nil
+}
+
+// Today returns the current valuation date
+func (s *Scheduler) Today(now time.Time) time.Time {
+	return dateOnly(now.In(s.location))
+}
+
+func (s *Scheduler) nextRun(now time.Time) time.Time {
+	now = now.In(s.location)
+	next := time.Date(now.Year(), now.Month(), now.Day(), s.hour, s.minute, 0, 0, s.location)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// Note: Valuation go routine. Stops when the context is cancelled
+func (s *Scheduler) Start(ctx context.Context) {
+	go func() {
+		for {
+			next := s.nextRun(time.Now())
+			log.Printf("Next valuation run at %s", next.Format(time.RFC3339))
+
+			timer := time.NewTimer(time.Until(next))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			if _, err := s.service.catchUp(ctx, s.Today(next)); err != nil {
+				log.Printf("Valuation run failed: %v", err)
+			}
+		}
+	}()
+}
+
+// RunNow catches up to today immediately
+func (s *Scheduler) RunNow(ctx context.Context) (*models.ValuationRun, error) {
+	return s.service.catchUp(ctx, s.Today(time.Now()))
+}
+
+// Backfill revalues a specific date range
+func (s *Scheduler) Backfill(ctx context.Context, req *models.RunValuationRequest) (*models.ValuationRun, error) {
+	return s.service.backfill(ctx, req, s.Today(time.Now()))
+}
@@ -0,0 +1,101 @@
+package valuation
+
+import (
+	"context"
+	"log"
+	"time"
+
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+	"github.com/stcol316/cushon-isa/internal/models"
+)
+
+const (
+	dateLayout = "2006-01-02"
+	// Note: Keeps a single backfill request well inside the request timeout
+	maxBackfillDays = 366
+	// Note: Units are allocated after the dealing date, once the price is loaded and settlement runs,
+	// so each nightly run also revalues the last few days to pick them up. Covers a long weekend
+	revalueDays = 5
+)
+
+type Service struct {
+	repo *Repository
+}
+
+func NewService(repo *Repository) *Service {
+	return &Service{repo: repo}
+}
+
+// runValuations snapshots every date from from to to inclusive, oldest first
+func (s *Service) runValuations(ctx context.Context, from, to time.Time) (*models.ValuationRun, error) {
+	run := &models.ValuationRun{From: from.Format(dateLayout), To: to.Format(dateLayout)}
+	for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+		holdings, err := s.repo.valueHoldings(ctx, date)
+		if err != nil {
+			return nil, err
+		}
+		run.Dates++
+		run.Holdings += holdings
+	}
+
+	log.Printf("Valuation run %s to %s: %d dates, %d holdings", run.From, run.To, run.Dates, run.Holdings)
+	return run, nil
+}
+
+// Note: Backfill requested by an admin, e.g. after a price correction
+func (s *Service) backfill(ctx context.Context, req *models.RunValuationRequest, today time.Time) (*models.ValuationRun, error) {
+	from, err := time.Parse(dateLayout, req.From)
+	if err != nil {
+		return nil, isaerrors.ErrInvalidDealingDate
+	}
+	to := from
+	if req.To != "" {
+		if to, err = time.Parse(dateLayout, req.To); err != nil {
+			return nil, isaerrors.ErrInvalidDealingDate
+		}
+	}
+
+	if from.After(to) {
+		return nil, isaerrors.Validation("invalid_date_range", "from cannot be after to")
+	}
+	if to.After(today) {
+		return nil, isaerrors.Validation("invalid_valuation_date", "valuations cannot be run for future dates")
+	}
+	if to.Sub(from) >= maxBackfillDays*24*time.Hour {
+		return nil, isaerrors.Validation("valuation_range_too_large", "valuations can be backfilled at most 366 days at a time")
+	}
+
+	return s.runValuations(ctx, from, to)
+}
+
+// catchUp values every date since the last run up to and including today, plus the trailing
+// revaluation window. The first ever run starts from the earliest investment
+func (s *Service) catchUp(ctx context.Context, today time.Time) (*models.ValuationRun, error) {
+	from := today.AddDate(0, 0, -revalueDays)
+
+	last, err := s.repo.getLastValuationDate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if last != nil {
+		if next := last.AddDate(0, 0, 1); next.Before(from) {
+			from = next
+		}
+	} else {
+		first, err := s.repo.getFirstFlowDate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if first == nil {
+			return &models.ValuationRun{From: today.Format(dateLayout), To: today.Format(dateLayout)}, nil
+		}
+		from = *first
+	}
+
+	return s.runValuations(ctx, dateOnly(from), today)
+}
+
+func dateOnly(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
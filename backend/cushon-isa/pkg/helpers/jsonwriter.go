@@ -3,6 +3,7 @@ package helpers
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
 )
 
 func writeJSON(w http.ResponseWriter, status int, v interface{}) error {
@@ -13,8 +14,17 @@ func writeJSON(w http.ResponseWriter, status int, v interface{}) error {
 	return json.NewEncoder(w).Encode(v)
 }
 
+// Note: Simple errors raised directly in handlers (e.g. malformed path params) also use the problem format
+// The code is derived from the status text so it remains stable, e.g. "bad_request"
 func RespondWithError(w http.ResponseWriter, code int, message string) {
-	writeJSON(w, code, map[string]string{"error": message})
+	statusCode := strings.ReplaceAll(strings.ToLower(http.StatusText(code)), " ", "_")
+	writeProblem(w, Problem{
+		Type:   problemTypeBase + statusCode,
+		Title:  http.StatusText(code),
+		Status: code,
+		Detail: message,
+		Code:   statusCode,
+	})
 }
 
 func RespondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
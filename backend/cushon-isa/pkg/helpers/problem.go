@@ -0,0 +1,72 @@
+package helpers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	isaerrors "github.com/stcol316/cushon-isa/internal/errors"
+)
+
+// Note: RFC 7807 problem details. Code is an extension member holding our stable error code
+// See https://datatracker.ietf.org/doc/html/rfc7807
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+}
+
+const problemContentType = "application/problem+json"
+
+// TODO: Host documentation for each error code at this location
+const problemTypeBase = "https://api.cushon.co.uk/problems/"
+
+var kindStatus = map[isaerrors.Kind]int{
+	isaerrors.KindNotFound:     http.StatusNotFound,
+	isaerrors.KindConflict:     http.StatusConflict,
+	isaerrors.KindValidation:   http.StatusBadRequest,
+	isaerrors.KindForbidden:    http.StatusForbidden,
+	isaerrors.KindBusinessRule: http.StatusUnprocessableEntity,
+}
+
+func writeProblem(w http.ResponseWriter, p Problem) {
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// Note: Single error responder used by all handlers.
+// Domain errors are mapped to their status and code, anything else is treated as an internal error
+// and the details are logged rather than leaked to the client
+func RespondWithProblem(w http.ResponseWriter, r *http.Request, err error) {
+	domainErr, ok := isaerrors.As(err)
+	if !ok {
+		log.Printf("internal error on %s %s: %v", r.Method, r.URL.Path, err)
+		writeProblem(w, Problem{
+			Type:     problemTypeBase + "internal_error",
+			Title:    http.StatusText(http.StatusInternalServerError),
+			Status:   http.StatusInternalServerError,
+			Detail:   "an unexpected error occurred",
+			Instance: r.URL.Path,
+			Code:     "internal_error",
+		})
+		return
+	}
+
+	status, ok := kindStatus[domainErr.Kind]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+
+	writeProblem(w, Problem{
+		Type:     problemTypeBase + domainErr.Code,
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   domainErr.Message,
+		Instance: r.URL.Path,
+		Code:     domainErr.Code,
+	})
+}
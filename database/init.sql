@@ -3,6 +3,36 @@ CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
 \i /docker-entrypoint-initdb.d/migrations/001_create_tables.sql
 \i /docker-entrypoint-initdb.d/views/001_create_materialized_views.sql
 \i /docker-entrypoint-initdb.d/migrations/002_create_indexes.sql
+\i /docker-entrypoint-initdb.d/migrations/003_customer_profile.sql
+\i /docker-entrypoint-initdb.d/migrations/004_customer_kyc.sql
+\i /docker-entrypoint-initdb.d/migrations/005_kyc_verification.sql
+\i /docker-entrypoint-initdb.d/migrations/006_aml_monitoring.sql
+\i /docker-entrypoint-initdb.d/views/002_customer_fund_totals_by_status.sql
+\i /docker-entrypoint-initdb.d/migrations/007_investment_lifecycle.sql
+\i /docker-entrypoint-initdb.d/views/003_customer_fund_totals_settlement.sql
+\i /docker-entrypoint-initdb.d/migrations/008_fund_dealing.sql
+\i /docker-entrypoint-initdb.d/migrations/009_fund_admin.sql
+\i /docker-entrypoint-initdb.d/migrations/010_fund_search.sql
+\i /docker-entrypoint-initdb.d/migrations/011_risk_profile.sql
+\i /docker-entrypoint-initdb.d/migrations/012_investment_keyset_index.sql
+\i /docker-entrypoint-initdb.d/migrations/013_holding_valuations.sql
+\i /docker-entrypoint-initdb.d/migrations/014_charges.sql
+\i /docker-entrypoint-initdb.d/views/004_customer_fund_totals_charges.sql
+\i /docker-entrypoint-initdb.d/migrations/015_ledger.sql
+\i /docker-entrypoint-initdb.d/views/005_ledger_customer_fund_totals.sql
+\i /docker-entrypoint-initdb.d/migrations/016_bank_reconciliation.sql
+\i /docker-entrypoint-initdb.d/migrations/017_isa_transfers.sql
+\i /docker-entrypoint-initdb.d/migrations/018_isa_transfers_out.sql
+\i /docker-entrypoint-initdb.d/migrations/019_junior_isa.sql
+\i /docker-entrypoint-initdb.d/migrations/020_lifetime_isa.sql
+\i /docker-entrypoint-initdb.d/migrations/021_accounts.sql
+\i /docker-entrypoint-initdb.d/views/006_customer_fund_totals_accounts.sql
+\i /docker-entrypoint-initdb.d/migrations/022_workplace_pensions.sql
+\i /docker-entrypoint-initdb.d/views/007_ledger_customer_fund_totals_pensions.sql
+\i /docker-entrypoint-initdb.d/migrations/023_ledger_hmrc_payable.sql
+\i /docker-entrypoint-initdb.d/migrations/024_bank_statement_completion.sql
+\i /docker-entrypoint-initdb.d/migrations/025_charges_per_account.sql
+\i /docker-entrypoint-initdb.d/migrations/026_junior_isa_accounts.sql
 
 \set ENVIRONMENT `echo "$PGENVIRONMENT"`
 \echo 'Running in environment: ' :ENVIRONMENT
@@ -13,4 +43,6 @@ SELECT (:'ENVIRONMENT' = 'development') AS is_dev \gset
     \i /docker-entrypoint-initdb.d/seeds/001_seed_test_data.sql
 \endif
 \i /docker-entrypoint-initdb.d/seeds/002_seed_funds.sql
+\i /docker-entrypoint-initdb.d/seeds/003_seed_risk_questionnaire.sql
+\i /docker-entrypoint-initdb.d/seeds/004_seed_charges.sql
 
@@ -0,0 +1,28 @@
+-- Note: Profile management for retail customers
+-- Address fields are optional as they were not collected at sign up originally
+ALTER TABLE retail_customers
+    ADD COLUMN address_line1 VARCHAR(255),
+    ADD COLUMN address_line2 VARCHAR(255),
+    ADD COLUMN city VARCHAR(100),
+    ADD COLUMN postcode VARCHAR(20),
+    ADD COLUMN country VARCHAR(100),
+    ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN email_verification_token VARCHAR(64),
+    ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'active',
+    ADD COLUMN closed_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN erased_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    ADD CONSTRAINT valid_customer_status CHECK (status IN ('active', 'closed', 'erased'));
+
+-- Note: Audit trail of changes to customer records
+-- We only record which fields changed and never the values themselves so that
+-- the trail can be retained after a GDPR erasure without holding personal data
+CREATE TABLE customer_audit_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    customer_id UUID NOT NULL REFERENCES retail_customers(id),
+    event_type VARCHAR(50) NOT NULL,
+    changed_fields TEXT[],
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_customer_audit_events_customer ON customer_audit_events(customer_id, created_at);
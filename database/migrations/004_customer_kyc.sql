@@ -0,0 +1,7 @@
+-- Note: Data required to open a Stocks & Shares ISA
+-- These are nullable as existing customers will need to supply them before investing
+-- National Insurance numbers are encrypted by the application before being stored
+ALTER TABLE retail_customers
+    ADD COLUMN date_of_birth DATE,
+    ADD COLUMN uk_resident BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN ni_number_encrypted TEXT;
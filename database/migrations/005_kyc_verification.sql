@@ -0,0 +1,21 @@
+-- Note: Identity verification state for retail customers
+-- The latest status is denormalised onto the customer so eligibility checks need a single lookup
+ALTER TABLE retail_customers
+    ADD COLUMN kyc_status VARCHAR(20) NOT NULL DEFAULT 'not_started',
+    ADD CONSTRAINT valid_kyc_status CHECK (kyc_status IN ('not_started', 'pending', 'verified', 'referred', 'rejected'));
+
+-- Note: Every submission to a KYC provider is kept for audit purposes
+CREATE TABLE kyc_verifications (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    customer_id UUID NOT NULL REFERENCES retail_customers(id),
+    provider VARCHAR(50) NOT NULL,
+    provider_reference VARCHAR(255) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    reason TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT valid_verification_status CHECK (status IN ('pending', 'verified', 'referred', 'rejected')),
+    CONSTRAINT unique_provider_reference UNIQUE (provider, provider_reference)
+);
+
+CREATE INDEX idx_kyc_verifications_customer ON kyc_verifications(customer_id, created_at);
@@ -0,0 +1,31 @@
+-- Note: Investments now record whether money is going in or out and a status.
+-- Held investments are awaiting AML review and are excluded from customer totals
+ALTER TABLE investments
+    ADD COLUMN type VARCHAR(20) NOT NULL DEFAULT 'deposit',
+    ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    ADD CONSTRAINT valid_investment_type CHECK (type IN ('deposit', 'withdrawal')),
+    ADD CONSTRAINT valid_investment_status CHECK (status IN ('pending', 'held', 'cancelled'));
+
+CREATE INDEX idx_investments_customer_created ON investments(customer_id, created_at);
+CREATE INDEX idx_investments_status ON investments(status);
+
+-- Note: Every rule that fires is recorded, including for rejected transactions which have no investment
+CREATE TABLE aml_alerts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    investment_id UUID REFERENCES investments(id),
+    customer_id UUID NOT NULL REFERENCES retail_customers(id),
+    rule VARCHAR(50) NOT NULL,
+    outcome VARCHAR(20) NOT NULL,
+    reason TEXT NOT NULL,
+    amount DECIMAL(10,2) NOT NULL,
+    resolution VARCHAR(20),
+    reviewed_by VARCHAR(255),
+    review_note TEXT,
+    reviewed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT valid_aml_outcome CHECK (outcome IN ('hold', 'reject')),
+    CONSTRAINT valid_aml_resolution CHECK (resolution IN ('released', 'cancelled'))
+);
+
+CREATE INDEX idx_aml_alerts_investment ON aml_alerts(investment_id);
+CREATE INDEX idx_aml_alerts_customer ON aml_alerts(customer_id, created_at);
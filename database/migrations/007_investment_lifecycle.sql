@@ -0,0 +1,21 @@
+-- Note: Full investment lifecycle
+-- held -> pending -> cash_received -> units_allocated -> settled
+-- with failed and cancelled as terminal exits
+ALTER TABLE investments
+    DROP CONSTRAINT valid_investment_status,
+    ADD CONSTRAINT valid_investment_status CHECK (
+        status IN ('held', 'pending', 'cash_received', 'units_allocated', 'settled', 'failed', 'cancelled')
+    ),
+    ADD COLUMN status_updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP;
+
+-- Note: Every status change is recorded with its own timestamp
+CREATE TABLE investment_status_history (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    investment_id UUID NOT NULL REFERENCES investments(id),
+    from_status VARCHAR(20),
+    to_status VARCHAR(20) NOT NULL,
+    reason TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_investment_status_history_investment ON investment_status_history(investment_id, created_at);
@@ -0,0 +1,36 @@
+-- Note: Forward pricing. Each fund deals once a day at its valuation point.
+-- Orders placed before the cut-off deal at that day's price, later orders at the next dealing day's price
+ALTER TABLE funds
+    ADD COLUMN valuation_time TIME NOT NULL DEFAULT '12:00',
+    ADD COLUMN dealing_cutoff TIME NOT NULL DEFAULT '12:00',
+    -- ISO weekdays, 1 = Monday
+    ADD COLUMN dealing_days SMALLINT[] NOT NULL DEFAULT '{1,2,3,4,5}',
+    ADD COLUMN dealing_timezone VARCHAR(64) NOT NULL DEFAULT 'Europe/London',
+    ADD CONSTRAINT cutoff_before_valuation CHECK (dealing_cutoff <= valuation_time);
+
+-- Note: Non-dealing days such as bank holidays
+CREATE TABLE fund_dealing_holidays (
+    fund_id UUID NOT NULL REFERENCES funds(id),
+    holiday_date DATE NOT NULL,
+    description VARCHAR(100),
+    PRIMARY KEY (fund_id, holiday_date)
+);
+
+-- Note: One price per fund per dealing date. Prices are never overwritten once loaded
+CREATE TABLE fund_prices (
+    fund_id UUID NOT NULL REFERENCES funds(id),
+    price_date DATE NOT NULL,
+    price DECIMAL(18,6) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (fund_id, price_date),
+    CONSTRAINT positive_price CHECK (price > 0)
+);
+
+-- Note: Unit price and units stay empty until the price for the dealing date is loaded
+ALTER TABLE investments
+    ADD COLUMN dealing_date DATE,
+    ADD COLUMN unit_price DECIMAL(18,6),
+    ADD COLUMN units DECIMAL(18,6),
+    ADD COLUMN priced_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX idx_investments_unpriced ON investments(fund_id, dealing_date) WHERE unit_price IS NULL;
@@ -0,0 +1,11 @@
+-- Note: Fund administration. Funds are retired rather than deleted so existing holdings stay intact
+ALTER TABLE funds
+    ADD COLUMN isin CHAR(12) UNIQUE,
+    -- Ongoing charges figure as an annual percentage, e.g. 0.22
+    ADD COLUMN ocf DECIMAL(6,4) NOT NULL DEFAULT 0,
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'GBP',
+    ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'active',
+    ADD COLUMN retired_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    ADD CONSTRAINT valid_fund_status CHECK (status IN ('active', 'retired')),
+    ADD CONSTRAINT valid_ocf CHECK (ocf >= 0 AND ocf < 100);
@@ -0,0 +1,15 @@
+-- Note: Fund search and filtering
+ALTER TABLE funds
+    ADD COLUMN asset_class VARCHAR(20) NOT NULL DEFAULT 'mixed',
+    ADD COLUMN ethical BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD CONSTRAINT valid_asset_class CHECK (
+        asset_class IN ('equity', 'fixed_income', 'mixed', 'property', 'money_market')
+    ),
+    -- Note: Kept up to date by Postgres so search never drifts from the name and description
+    ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', COALESCE(name, '')), 'A') ||
+        setweight(to_tsvector('english', COALESCE(description, '')), 'B')
+    ) STORED;
+
+CREATE INDEX idx_funds_search ON funds USING GIN (search_vector);
+CREATE INDEX idx_funds_status_risk ON funds(status, risk_level_id);
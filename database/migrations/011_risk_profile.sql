@@ -0,0 +1,41 @@
+-- Note: Attitude to risk questionnaire. Each answer carries a score and the total
+-- maps to the highest risk level whose min_score it reaches
+ALTER TABLE risk_levels
+    ADD COLUMN min_score SMALLINT NOT NULL DEFAULT 0;
+
+CREATE TABLE risk_questions (
+    id SERIAL PRIMARY KEY,
+    position SMALLINT NOT NULL,
+    text TEXT NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+CREATE TABLE risk_question_options (
+    id SERIAL PRIMARY KEY,
+    question_id INTEGER NOT NULL REFERENCES risk_questions(id),
+    position SMALLINT NOT NULL,
+    text TEXT NOT NULL,
+    score SMALLINT NOT NULL
+);
+
+-- Note: Profiles are never overwritten. The most recent one is the customer's current profile
+CREATE TABLE customer_risk_profiles (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    customer_id UUID NOT NULL REFERENCES retail_customers(id),
+    risk_level_id SMALLINT NOT NULL REFERENCES risk_levels(id),
+    score SMALLINT NOT NULL,
+    completed_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE customer_risk_profile_answers (
+    profile_id UUID NOT NULL REFERENCES customer_risk_profiles(id),
+    question_id INTEGER NOT NULL REFERENCES risk_questions(id),
+    option_id INTEGER NOT NULL REFERENCES risk_question_options(id),
+    PRIMARY KEY (profile_id, question_id)
+);
+
+CREATE INDEX idx_customer_risk_profiles_customer ON customer_risk_profiles(customer_id, completed_at DESC);
+
+-- Note: Records that the customer accepted a warning that the fund is riskier than their profile
+ALTER TABLE investments
+    ADD COLUMN risk_acknowledged BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,4 @@
+-- Note: Supports cursor pagination of a customer's investment history ordered by (created_at, id).
+-- Replaces the (customer_id, created_at) index from 006 which this one covers
+DROP INDEX IF EXISTS idx_investments_customer_created;
+CREATE INDEX idx_investments_customer_created ON investments(customer_id, created_at, id);
@@ -0,0 +1,26 @@
+-- Note: Daily snapshot of every customer holding, written by the nightly valuation job.
+-- Customer facing value and performance figures read from here rather than replaying all history
+CREATE TABLE holding_valuations (
+    customer_id UUID NOT NULL REFERENCES retail_customers(id),
+    fund_id UUID NOT NULL REFERENCES funds(id),
+    valuation_date DATE NOT NULL,
+    units DECIMAL(18,6) NOT NULL,
+    -- Note: NULL until the fund has been priced
+    price DECIMAL(12,6),
+    market_value DECIMAL(14,2) NOT NULL,
+    -- Note: Net cash paid in, deposits less withdrawals
+    cost_basis DECIMAL(14,2) NOT NULL,
+    -- Note: Cash paid in (positive) or taken out (negative) on the valuation date
+    net_flow DECIMAL(14,2) NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (customer_id, fund_id, valuation_date)
+);
+
+CREATE INDEX idx_holding_valuations_date ON holding_valuations(valuation_date);
+
+-- Note: One row per valued date. Lets the job catch up on any days it missed
+CREATE TABLE valuation_runs (
+    valuation_date DATE PRIMARY KEY,
+    holdings INTEGER NOT NULL,
+    completed_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
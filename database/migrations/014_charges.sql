@@ -0,0 +1,70 @@
+-- Note: Charges. Collected charges are recorded as investments of type charge so they
+-- appear in the customer's history and reduce their holding like a withdrawal
+ALTER TABLE investments
+    DROP CONSTRAINT valid_investment_type,
+    ADD CONSTRAINT valid_investment_type CHECK (type IN ('deposit', 'withdrawal', 'charge'));
+
+-- Note: Platform fee bands. The rate for each band applies only to the part of the portfolio
+-- value that falls within it. Rates are annual percentages, e.g. 0.30 is 0.30% a year
+CREATE TABLE platform_fee_tiers (
+    id SERIAL PRIMARY KEY,
+    lower_bound DECIMAL(14,2) NOT NULL UNIQUE,
+    upper_bound DECIMAL(14,2),
+    annual_rate DECIMAL(6,4) NOT NULL,
+    CONSTRAINT valid_fee_tier_bounds CHECK (lower_bound >= 0 AND (upper_bound IS NULL OR upper_bound > lower_bound)),
+    CONSTRAINT valid_fee_tier_rate CHECK (annual_rate >= 0 AND annual_rate < 100)
+);
+
+-- Note: Flat fees charged to every customer with a holding, once a month
+CREATE TABLE fixed_fees (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    monthly_amount DECIMAL(10,2) NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    CONSTRAINT valid_fixed_fee CHECK (monthly_amount > 0)
+);
+
+-- Note: Daily accruals from the valuation snapshots. OCF is already taken within the fund price
+-- so it is accrued for disclosure only and never collected
+CREATE TABLE charge_accruals (
+    customer_id UUID NOT NULL REFERENCES retail_customers(id),
+    fund_id UUID NOT NULL REFERENCES funds(id),
+    accrual_date DATE NOT NULL,
+    charge_type VARCHAR(20) NOT NULL,
+    amount DECIMAL(14,6) NOT NULL,
+    PRIMARY KEY (customer_id, fund_id, accrual_date, charge_type),
+    CONSTRAINT valid_accrual_type CHECK (charge_type IN ('platform', 'ocf'))
+);
+
+CREATE INDEX idx_charge_accruals_date ON charge_accruals(accrual_date);
+
+CREATE TABLE charge_accrual_runs (
+    accrual_date DATE PRIMARY KEY,
+    accruals INTEGER NOT NULL,
+    completed_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Note: Collected charges. The unique constraint stops a month being collected twice
+CREATE TABLE charges (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    customer_id UUID NOT NULL REFERENCES retail_customers(id),
+    fund_id UUID NOT NULL REFERENCES funds(id),
+    investment_id UUID REFERENCES investments(id),
+    charge_type VARCHAR(20) NOT NULL,
+    period_start DATE NOT NULL,
+    period_end DATE NOT NULL,
+    amount DECIMAL(10,2) NOT NULL,
+    method VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT valid_charge_type CHECK (charge_type IN ('platform', 'fixed')),
+    CONSTRAINT valid_charge_method CHECK (method IN ('units', 'cash')),
+    UNIQUE (customer_id, fund_id, charge_type, period_start)
+);
+
+CREATE INDEX idx_charges_customer ON charges(customer_id, period_start);
+
+CREATE TABLE charge_collection_runs (
+    period_start DATE PRIMARY KEY,
+    charges INTEGER NOT NULL,
+    completed_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,61 @@
+-- Note: Double-entry ledger for client money and units. Debits are positive and credits negative.
+-- Every journal must balance in both money and units. Postings are never updated or deleted,
+-- a failed investment is unwound with a reversing journal
+CREATE TABLE ledger_accounts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    account_type VARCHAR(30) NOT NULL,
+    customer_id UUID REFERENCES retail_customers(id),
+    fund_id UUID REFERENCES funds(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT valid_account_type CHECK (account_type IN (
+        'client_money_bank',       -- Cash in the client money bank account
+        'customer_cash',           -- Deposits received and not yet invested, per customer and fund
+        'customer_payable',        -- Withdrawal proceeds owed to the customer, per customer and fund
+        'customer_units',          -- Units held for the customer at cost, per customer and fund
+        'fund_manager_settlement', -- Cash owed to or by the fund manager, per customer and fund
+        'fund_units',              -- Units registered with the fund manager at cost, per fund
+        'platform_fee_income'      -- Charges taken, firm money awaiting transfer out of client money
+    )),
+    UNIQUE NULLS NOT DISTINCT (account_type, customer_id, fund_id)
+);
+
+CREATE TABLE ledger_journals (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    investment_id UUID REFERENCES investments(id),
+    entry_type VARCHAR(30) NOT NULL,
+    description TEXT,
+    posted_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT valid_entry_type CHECK (entry_type IN ('cash_received', 'units_allocated', 'settled', 'charge', 'reversal'))
+);
+
+CREATE INDEX idx_ledger_journals_investment ON ledger_journals(investment_id);
+
+CREATE TABLE ledger_postings (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    journal_id UUID NOT NULL REFERENCES ledger_journals(id),
+    account_id UUID NOT NULL REFERENCES ledger_accounts(id),
+    amount DECIMAL(14,2) NOT NULL,
+    units DECIMAL(18,6) NOT NULL DEFAULT 0
+);
+
+CREATE INDEX idx_ledger_postings_journal ON ledger_postings(journal_id);
+CREATE INDEX idx_ledger_postings_account ON ledger_postings(account_id);
+
+-- Note: Checked when the transaction commits so a journal's postings can be written one at a time
+CREATE FUNCTION check_journal_balanced() RETURNS TRIGGER AS $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM ledger_postings
+        WHERE journal_id = NEW.journal_id
+        HAVING SUM(amount) <> 0 OR SUM(units) <> 0
+    ) THEN
+        RAISE EXCEPTION 'ledger journal % does not balance', NEW.journal_id USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER ledger_postings_balanced
+    AFTER INSERT ON ledger_postings
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION check_journal_balanced();
@@ -0,0 +1,41 @@
+-- Note: Customers quote the payment reference when paying a deposit by bank transfer so the
+-- receipt can be matched to the order. Derived from the investment ID so it never changes
+ALTER TABLE investments
+    ADD COLUMN payment_reference VARCHAR(13) GENERATED ALWAYS AS (
+        CASE WHEN type = 'deposit' THEN 'ISA' || UPPER(SUBSTRING(REPLACE(id::text, '-', ''), 1, 10)) END
+    ) STORED;
+
+CREATE UNIQUE INDEX idx_investments_payment_reference ON investments(payment_reference);
+
+-- Note: Imported client money bank statements. The checksum stops the same file being imported twice
+CREATE TABLE bank_statements (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    format VARCHAR(10) NOT NULL,
+    statement_ref VARCHAR(100),
+    checksum CHAR(64) NOT NULL UNIQUE,
+    lines INTEGER NOT NULL DEFAULT 0,
+    matched INTEGER NOT NULL DEFAULT 0,
+    imported_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT valid_statement_format CHECK (format IN ('csv', 'camt053'))
+);
+
+CREATE TABLE bank_statement_lines (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    statement_id UUID NOT NULL REFERENCES bank_statements(id),
+    line_number INTEGER NOT NULL,
+    booking_date DATE NOT NULL,
+    amount DECIMAL(14,2) NOT NULL,
+    currency CHAR(3) NOT NULL DEFAULT 'GBP',
+    reference TEXT,
+    counterparty VARCHAR(200),
+    bank_transaction_id VARCHAR(100),
+    match_status VARCHAR(20) NOT NULL,
+    investment_id UUID REFERENCES investments(id),
+    note TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT valid_match_status CHECK (match_status IN ('matched', 'partial', 'unmatched', 'duplicate', 'ignored')),
+    UNIQUE (statement_id, line_number)
+);
+
+CREATE INDEX idx_bank_statement_lines_exceptions ON bank_statement_lines(created_at) WHERE match_status IN ('partial', 'unmatched');
+CREATE INDEX idx_bank_statement_lines_transaction ON bank_statement_lines(bank_transaction_id);
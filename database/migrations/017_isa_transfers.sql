@@ -0,0 +1,43 @@
+-- Note: ISA transfers from other providers. Subscriptions made in the current tax year count
+-- against the customer's allowance here. Previous years' subscriptions do not, they were already
+-- counted when they were made. Current year subscriptions can only be transferred in full
+CREATE TABLE isa_transfers (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    customer_id UUID NOT NULL REFERENCES retail_customers(id),
+    fund_id UUID NOT NULL REFERENCES funds(id),
+    direction VARCHAR(3) NOT NULL DEFAULT 'in',
+    provider_name VARCHAR(200) NOT NULL,
+    provider_reference VARCHAR(100) NOT NULL,
+    scope VARCHAR(10) NOT NULL,
+    current_year_amount DECIMAL(12,2) NOT NULL DEFAULT 0,
+    previous_years_amount DECIMAL(12,2) NOT NULL DEFAULT 0,
+    -- Note: Cash actually received from the ceding provider, which can differ from the subscriptions
+    received_amount DECIMAL(12,2),
+    status VARCHAR(20) NOT NULL DEFAULT 'requested',
+    -- Note: The deposit that credited the holding when the transfer completed
+    investment_id UUID REFERENCES investments(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    status_updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT valid_transfer_direction CHECK (direction IN ('in')),
+    CONSTRAINT valid_transfer_scope CHECK (scope IN ('full', 'partial')),
+    CONSTRAINT valid_transfer_status CHECK (status IN ('requested', 'submitted', 'accepted', 'completed', 'rejected', 'cancelled')),
+    CONSTRAINT valid_transfer_amounts CHECK (
+        current_year_amount >= 0 AND previous_years_amount >= 0 AND current_year_amount + previous_years_amount > 0
+    ),
+    CONSTRAINT current_year_transferred_whole CHECK (scope = 'full' OR current_year_amount = 0)
+);
+
+CREATE INDEX idx_isa_transfers_customer ON isa_transfers(customer_id, created_at);
+CREATE UNIQUE INDEX idx_isa_transfers_investment ON isa_transfers(investment_id);
+
+CREATE TABLE isa_transfer_status_history (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    transfer_id UUID NOT NULL REFERENCES isa_transfers(id),
+    from_status VARCHAR(20),
+    to_status VARCHAR(20) NOT NULL,
+    reason TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_isa_transfer_status_history_transfer ON isa_transfer_status_history(transfer_id, created_at);
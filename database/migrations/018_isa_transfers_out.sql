@@ -0,0 +1,32 @@
+-- Note: ISA transfers to other providers. Cash transfers sell the holding with a withdrawal and pay
+-- the proceeds to the receiving manager. In specie transfers re-register the units, recorded as an
+-- investment of type transfer_out which reduces the holding like a withdrawal but moves no cash
+ALTER TABLE investments
+    DROP CONSTRAINT valid_investment_type,
+    ADD CONSTRAINT valid_investment_type CHECK (type IN ('deposit', 'withdrawal', 'charge', 'transfer_out'));
+
+ALTER TABLE ledger_journals
+    DROP CONSTRAINT valid_entry_type,
+    ADD CONSTRAINT valid_entry_type CHECK (entry_type IN ('cash_received', 'units_allocated', 'settled', 'charge', 'transfer_out', 'reversal'));
+
+-- Note: For transfers out the provider is the receiving manager, identified by its FCA firm reference
+-- number. The subscription split and first subscription date are taken from our records when the
+-- transfer is requested and passed to the receiving manager as the transfer history
+ALTER TABLE isa_transfers
+    DROP CONSTRAINT valid_transfer_direction,
+    ADD CONSTRAINT valid_transfer_direction CHECK (direction IN ('in', 'out')),
+    DROP CONSTRAINT valid_transfer_amounts,
+    ADD CONSTRAINT valid_transfer_amounts CHECK (
+        current_year_amount >= 0 AND previous_years_amount >= 0
+        AND (direction = 'out' OR current_year_amount + previous_years_amount > 0)
+    ),
+    ADD COLUMN method VARCHAR(10) NOT NULL DEFAULT 'cash',
+    ADD COLUMN provider_frn VARCHAR(7),
+    ADD COLUMN first_subscription_date DATE,
+    ADD COLUMN transferred_amount DECIMAL(12,2),
+    ADD COLUMN transferred_units DECIMAL(18,6),
+    ADD CONSTRAINT valid_transfer_method CHECK (method IN ('cash', 'in_specie'));
+
+-- Note: A customer can only be leaving once
+CREATE UNIQUE INDEX idx_isa_transfers_active_out ON isa_transfers(customer_id)
+    WHERE direction = 'out' AND status NOT IN ('completed', 'rejected', 'cancelled');
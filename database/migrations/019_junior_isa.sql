@@ -0,0 +1,20 @@
+-- Note: Junior ISAs are held by children under 18 and run by a parent or guardian, the registered
+-- contact, who is an existing retail customer. Children often have no email address of their own.
+-- At 18 the account converts to an adult ISA, the child takes over and the registered contact is removed
+ALTER TABLE retail_customers
+    ADD COLUMN isa_product VARCHAR(20) NOT NULL DEFAULT 'isa',
+    ADD COLUMN registered_contact_id UUID REFERENCES retail_customers(id),
+    ADD COLUMN converted_at TIMESTAMP WITH TIME ZONE,
+    ALTER COLUMN email DROP NOT NULL,
+    ADD CONSTRAINT valid_isa_product CHECK (isa_product IN ('isa', 'junior_isa')),
+    ADD CONSTRAINT junior_isa_registered_contact CHECK (
+        (isa_product = 'junior_isa') = (registered_contact_id IS NOT NULL)
+    ),
+    ADD CONSTRAINT email_required CHECK (email IS NOT NULL OR isa_product = 'junior_isa');
+
+CREATE INDEX idx_retail_customers_registered_contact ON retail_customers(registered_contact_id)
+    WHERE registered_contact_id IS NOT NULL;
+
+-- Note: Found by the nightly conversion job
+CREATE INDEX idx_retail_customers_junior_dob ON retail_customers(date_of_birth)
+    WHERE isa_product = 'junior_isa';
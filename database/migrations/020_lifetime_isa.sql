@@ -0,0 +1,44 @@
+-- Note: Lifetime ISAs are held alongside the Stocks & Shares ISA. They can only be opened between
+-- 18 and 39 and take contributions until 50. Investments record which product they belong to,
+-- existing investments are all in the Stocks & Shares ISA
+CREATE TABLE lifetime_isas (
+    customer_id UUID PRIMARY KEY REFERENCES retail_customers(id),
+    opened_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Note: Withdrawals that are not for a first home, terminal illness or after 60 pay a government
+-- withdrawal charge out of the amount withdrawn. The charge is recorded against the withdrawal
+ALTER TABLE investments
+    ADD COLUMN product VARCHAR(20) NOT NULL DEFAULT 'isa',
+    ADD COLUMN withdrawal_charge DECIMAL(10,2),
+    ADD CONSTRAINT valid_investment_product CHECK (product IN ('isa', 'lifetime_isa')),
+    ADD CONSTRAINT lifetime_isa_withdrawal_charge CHECK (
+        withdrawal_charge IS NULL OR (product = 'lifetime_isa' AND type = 'withdrawal' AND withdrawal_charge >= 0)
+    );
+
+CREATE INDEX idx_investments_customer_product ON investments(customer_id, product);
+
+-- Note: The government bonus is claimed monthly, one line per contribution made in the month.
+-- When the bonus is received it is credited to the Lifetime ISA as a deposit which is not a
+-- contribution and does not use allowance
+CREATE TABLE lifetime_isa_bonuses (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    customer_id UUID NOT NULL REFERENCES retail_customers(id),
+    fund_id UUID NOT NULL REFERENCES funds(id),
+    deposit_investment_id UUID NOT NULL UNIQUE REFERENCES investments(id),
+    claim_month DATE NOT NULL,
+    contribution DECIMAL(10,2) NOT NULL,
+    bonus DECIMAL(10,2) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'claimed',
+    paid_investment_id UUID REFERENCES investments(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    paid_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT valid_bonus_status CHECK (status IN ('claimed', 'paid')),
+    CONSTRAINT positive_bonus CHECK (bonus > 0),
+    CONSTRAINT claim_month_first_day CHECK (EXTRACT(DAY FROM claim_month) = 1)
+);
+
+CREATE INDEX idx_lifetime_isa_bonuses_month ON lifetime_isa_bonuses(claim_month, status);
+CREATE INDEX idx_lifetime_isa_bonuses_customer ON lifetime_isa_bonuses(customer_id, claim_month);
+CREATE INDEX idx_lifetime_isa_bonuses_paid_investment ON lifetime_isa_bonuses(paid_investment_id)
+    WHERE paid_investment_id IS NOT NULL;
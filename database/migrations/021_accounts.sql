@@ -0,0 +1,40 @@
+-- Note: Accounts (wrappers) sit between customers and their investments. A customer can hold one
+-- open account of each product. Junior ISAs are the same account as the adult ISA they convert to
+CREATE TABLE accounts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    customer_id UUID NOT NULL REFERENCES retail_customers(id),
+    product VARCHAR(20) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'open',
+    opened_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    closed_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT valid_account_product CHECK (product IN ('isa', 'lifetime_isa')),
+    CONSTRAINT valid_account_status CHECK (status IN ('open', 'closed')),
+    CONSTRAINT account_closed_at CHECK ((status = 'closed') = (closed_at IS NOT NULL))
+);
+
+CREATE UNIQUE INDEX idx_accounts_open_product ON accounts(customer_id, product) WHERE status = 'open';
+CREATE INDEX idx_accounts_customer ON accounts(customer_id, opened_at);
+
+-- Note: Every existing customer had one implicit ISA, closed along with the customer.
+-- Lifetime ISAs opened before accounts existed become accounts of their own
+INSERT INTO accounts (customer_id, product, status, opened_at, closed_at)
+SELECT id, 'isa',
+    CASE WHEN status = 'active' THEN 'open' ELSE 'closed' END,
+    COALESCE(created_at, CURRENT_TIMESTAMP),
+    CASE WHEN status = 'active' THEN NULL ELSE COALESCE(closed_at, CURRENT_TIMESTAMP) END
+FROM retail_customers;
+
+INSERT INTO accounts (customer_id, product, opened_at)
+SELECT customer_id, 'lifetime_isa', opened_at
+FROM lifetime_isas;
+
+ALTER TABLE investments ADD COLUMN account_id UUID REFERENCES accounts(id);
+
+UPDATE investments i
+SET account_id = a.id
+FROM accounts a
+WHERE a.customer_id = i.customer_id AND a.product = i.product;
+
+ALTER TABLE investments ALTER COLUMN account_id SET NOT NULL;
+
+CREATE INDEX idx_investments_account_fund ON investments(account_id, fund_id);
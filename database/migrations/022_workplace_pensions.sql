@@ -0,0 +1,79 @@
+-- Note: Workplace pensions. Employees are kept separate from retail customers (see 001_create_tables.sql).
+-- Each employee belongs to an employer and holds a pension account that the employer's payroll
+-- contributes to. Contributions are invested in the scheme's default fund
+CREATE TABLE employers (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(255) NOT NULL,
+    paye_reference VARCHAR(20) UNIQUE NOT NULL,
+    default_fund_id UUID NOT NULL REFERENCES funds(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE employees (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    employer_id UUID NOT NULL REFERENCES employers(id),
+    payroll_reference VARCHAR(50) NOT NULL,
+    first_name VARCHAR(100) NOT NULL,
+    last_name VARCHAR(100) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    date_of_birth DATE NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'active',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    left_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT valid_employee_status CHECK (status IN ('active', 'left')),
+    CONSTRAINT employee_left_at CHECK ((status = 'left') = (left_at IS NOT NULL)),
+    UNIQUE (employer_id, payroll_reference)
+);
+
+CREATE INDEX idx_employees_employer ON employees(employer_id, created_at);
+
+-- Note: Pension accounts belong to an employee rather than a retail customer
+ALTER TABLE accounts
+    ALTER COLUMN customer_id DROP NOT NULL,
+    ADD COLUMN employee_id UUID REFERENCES employees(id),
+    DROP CONSTRAINT valid_account_product,
+    ADD CONSTRAINT valid_account_product CHECK (product IN ('isa', 'lifetime_isa', 'pension')),
+    ADD CONSTRAINT account_holder CHECK (
+        (product = 'pension') = (employee_id IS NOT NULL) AND num_nonnulls(customer_id, employee_id) = 1
+    );
+
+CREATE UNIQUE INDEX idx_accounts_open_pension ON accounts(employee_id) WHERE status = 'open' AND employee_id IS NOT NULL;
+
+-- Note: Pension investments are held by the employee, customer_id is left empty
+ALTER TABLE investments
+    ADD COLUMN employee_id UUID REFERENCES employees(id),
+    DROP CONSTRAINT valid_investment_product,
+    ADD CONSTRAINT valid_investment_product CHECK (product IN ('isa', 'lifetime_isa', 'pension')),
+    ADD CONSTRAINT investment_holder CHECK (
+        (product = 'pension') = (employee_id IS NOT NULL) AND (employee_id IS NULL OR customer_id IS NULL)
+    );
+
+CREATE INDEX idx_investments_employee ON investments(employee_id) WHERE employee_id IS NOT NULL;
+
+-- Note: Ledger accounts per holder and fund are kept for employees in the same way as customers
+ALTER TABLE ledger_accounts
+    ADD COLUMN employee_id UUID REFERENCES employees(id),
+    DROP CONSTRAINT ledger_accounts_account_type_customer_id_fund_id_key,
+    ADD CONSTRAINT ledger_account_holder CHECK (customer_id IS NULL OR employee_id IS NULL),
+    ADD CONSTRAINT ledger_accounts_unique UNIQUE NULLS NOT DISTINCT (account_type, customer_id, employee_id, fund_id);
+
+-- Note: One line per employee per payroll period. The employee and employer amounts are invested
+-- together as a single deposit and the split is kept here
+CREATE TABLE pension_contributions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    employer_id UUID NOT NULL REFERENCES employers(id),
+    employee_id UUID NOT NULL REFERENCES employees(id),
+    account_id UUID NOT NULL REFERENCES accounts(id),
+    investment_id UUID NOT NULL UNIQUE REFERENCES investments(id),
+    period_start DATE NOT NULL,
+    period_end DATE NOT NULL,
+    employee_amount DECIMAL(10,2) NOT NULL,
+    employer_amount DECIMAL(10,2) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT valid_pay_period CHECK (period_end >= period_start),
+    CONSTRAINT non_negative_contributions CHECK (employee_amount >= 0 AND employer_amount >= 0),
+    CONSTRAINT positive_contribution CHECK (employee_amount + employer_amount > 0),
+    UNIQUE (employee_id, period_start)
+);
+
+CREATE INDEX idx_pension_contributions_employer ON pension_contributions(employer_id, period_start);
@@ -0,0 +1,14 @@
+-- Note: Lifetime ISA withdrawal charges are kept back from the customer's payout and owed to HMRC.
+-- They stay in client money until paid over, in the same way as platform fee income
+ALTER TABLE ledger_accounts
+    DROP CONSTRAINT valid_account_type,
+    ADD CONSTRAINT valid_account_type CHECK (account_type IN (
+        'client_money_bank',
+        'customer_cash',
+        'customer_payable',
+        'customer_units',
+        'fund_manager_settlement',
+        'fund_units',
+        'hmrc_payable',            -- Lifetime ISA withdrawal charges owed to HMRC
+        'platform_fee_income'
+    ));
@@ -0,0 +1,9 @@
+-- Note: A statement is only complete once every line has been recorded. An import that fails part way
+-- leaves it incomplete so the same file can be imported again, picking up from the lines already recorded
+ALTER TABLE bank_statements
+    ADD COLUMN completed_at TIMESTAMP WITH TIME ZONE;
+
+-- Note: Earlier imports are complete when they recorded the number of lines they counted
+UPDATE bank_statements s
+SET completed_at = s.imported_at
+WHERE s.lines = (SELECT COUNT(*) FROM bank_statement_lines l WHERE l.statement_id = s.id);
@@ -0,0 +1,15 @@
+-- Note: The platform fee is tiered on the customer's whole portfolio, so each month's charge is
+-- spread across their accounts by value rather than taken from the ISA alone. Each account's share
+-- is collected separately, so a month is collected once per account rather than per customer
+ALTER TABLE charges ADD COLUMN account_id UUID REFERENCES accounts(id);
+
+UPDATE charges c
+SET account_id = i.account_id
+FROM investments i
+WHERE i.id = c.investment_id;
+
+ALTER TABLE charges ALTER COLUMN account_id SET NOT NULL;
+
+ALTER TABLE charges
+    DROP CONSTRAINT charges_customer_id_fund_id_charge_type_period_start_key,
+    ADD CONSTRAINT charges_account_period_key UNIQUE (account_id, fund_id, charge_type, period_start);
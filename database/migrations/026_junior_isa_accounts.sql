@@ -0,0 +1,11 @@
+-- Note: Junior ISAs get an account product of their own so they can be told apart from adult ISAs
+-- without joining to the customer. Investments in them are still standard ISA investments and the
+-- account becomes the adult ISA on conversion, so its history carries over
+ALTER TABLE accounts
+    DROP CONSTRAINT valid_account_product,
+    ADD CONSTRAINT valid_account_product CHECK (product IN ('isa', 'junior_isa', 'lifetime_isa', 'pension'));
+
+UPDATE accounts a
+SET product = 'junior_isa'
+FROM retail_customers c
+WHERE c.id = a.customer_id AND c.isa_product = 'junior_isa' AND a.product = 'isa';
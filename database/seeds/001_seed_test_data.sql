@@ -1,4 +1,9 @@
-INSERT INTO retail_customers (first_name, last_name, email) VALUES 
-    ('Stephen', 'Collins', 'user1@email.com'),
-    ('John', 'Doe', 'user2@email.com');
+-- Note: NI numbers are encrypted with the development NI_ENCRYPTION_KEY in backend/cushon-isa/.env
+-- They decrypt to AB123456C and CE654321A respectively
+INSERT INTO retail_customers (first_name, last_name, email, date_of_birth, uk_resident, ni_number_encrypted, kyc_status) VALUES 
+    ('Stephen', 'Collins', 'user1@email.com', '1988-04-12', TRUE, 'hyqDb4DHQQ0squARxlDkRzxKMv21xYBeO294v/tKNk8ift+Vng==', 'verified'),
+    ('John', 'Doe', 'user2@email.com', '1975-11-03', TRUE, '6v+4lfgKYfVKhuE8IhoHgC8YpxFaDP/v5R2FI6MKkWFUDFmbmQ==', 'verified');
 
+-- Note: Customers open with an ISA account, see the accounts migration
+INSERT INTO accounts (customer_id, product)
+SELECT id, 'isa' FROM retail_customers;
@@ -3,7 +3,30 @@ INSERT INTO risk_levels (id, name, description) VALUES
     (2, 'Medium', 'Balanced approach combining stability and growth. Mix of bonds and equities aiming for moderate long-term returns while managing volatility.'),
     (3, 'High', 'Growth-focused investments accepting larger short-term fluctuations for potentially higher long-term returns. Primarily equities and higher-risk assets.');
 
-INSERT INTO funds (name, description, risk_level_id) VALUES 
-    ('Ethical Bond Fund', 'Fixed income investments meeting strict ethical criteria', 1),
-    ('Balanced Growth Fund', 'Balanced portfolio of 60% stocks and 40% bonds', 2),
-    ('Emerging Markets Fund', 'Focus on high-growth potential markets in developing economies', 3);
\ No newline at end of file
+INSERT INTO funds (name, description, risk_level_id, isin, ocf, currency, asset_class, ethical) VALUES 
+    ('Ethical Bond Fund', 'Fixed income investments meeting strict ethical criteria', 1, 'GB00CUSH0016', 0.25, 'GBP', 'fixed_income', TRUE),
+    ('Balanced Growth Fund', 'Balanced portfolio of 60% stocks and 40% bonds', 2, 'GB00CUSH0024', 0.22, 'GBP', 'mixed', FALSE),
+    ('Emerging Markets Fund', 'Focus on high-growth potential markets in developing economies', 3, 'GB00CUSH0032', 0.45, 'GBP', 'equity', FALSE);
+
+-- Note: England and Wales bank holidays are non-dealing days for every fund
+INSERT INTO fund_dealing_holidays (fund_id, holiday_date, description)
+SELECT f.id, h.holiday_date, h.description
+FROM funds f
+CROSS JOIN (VALUES
+    (DATE '2026-01-01', 'New Year''s Day'),
+    (DATE '2026-04-03', 'Good Friday'),
+    (DATE '2026-04-06', 'Easter Monday'),
+    (DATE '2026-05-04', 'Early May bank holiday'),
+    (DATE '2026-05-25', 'Spring bank holiday'),
+    (DATE '2026-08-31', 'Summer bank holiday'),
+    (DATE '2026-12-25', 'Christmas Day'),
+    (DATE '2026-12-28', 'Boxing Day (substitute day)'),
+    (DATE '2027-01-01', 'New Year''s Day'),
+    (DATE '2027-03-26', 'Good Friday'),
+    (DATE '2027-03-29', 'Easter Monday'),
+    (DATE '2027-05-03', 'Early May bank holiday'),
+    (DATE '2027-05-31', 'Spring bank holiday'),
+    (DATE '2027-08-30', 'Summer bank holiday'),
+    (DATE '2027-12-27', 'Christmas Day (substitute day)'),
+    (DATE '2027-12-28', 'Boxing Day (substitute day)')
+) AS h(holiday_date, description);
@@ -0,0 +1,30 @@
+-- Note: Five questions scored 1 to 3 gives a total between 5 and 15
+UPDATE risk_levels SET min_score = 0 WHERE id = 1;
+UPDATE risk_levels SET min_score = 9 WHERE id = 2;
+UPDATE risk_levels SET min_score = 13 WHERE id = 3;
+
+INSERT INTO risk_questions (id, position, text) VALUES
+    (1, 1, 'How long do you plan to keep this money invested?'),
+    (2, 2, 'If your investments fell by 20% in a month, what would you do?'),
+    (3, 3, 'Which statement best describes your investment experience?'),
+    (4, 4, 'What is most important to you when investing?'),
+    (5, 5, 'How much of your savings does this investment represent?');
+
+INSERT INTO risk_question_options (question_id, position, text, score) VALUES
+    (1, 1, 'Less than 5 years', 1),
+    (1, 2, '5 to 10 years', 2),
+    (1, 3, 'More than 10 years', 3),
+    (2, 1, 'Sell everything to avoid further losses', 1),
+    (2, 2, 'Wait and see before deciding', 2),
+    (2, 3, 'Hold or invest more while prices are lower', 3),
+    (3, 1, 'I have never invested before', 1),
+    (3, 2, 'I have some experience with funds or pensions', 2),
+    (3, 3, 'I invest regularly and understand market risk', 3),
+    (4, 1, 'Protecting the money I have', 1),
+    (4, 2, 'A balance of protection and growth', 2),
+    (4, 3, 'Maximising long term growth', 3),
+    (5, 1, 'Most of my savings', 1),
+    (5, 2, 'Around half of my savings', 2),
+    (5, 3, 'A small part of my savings', 3);
+
+SELECT setval('risk_questions_id_seq', (SELECT MAX(id) FROM risk_questions));
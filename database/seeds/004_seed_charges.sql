@@ -0,0 +1,5 @@
+-- Note: Default platform fee bands. No fixed fees are charged by default
+INSERT INTO platform_fee_tiers (lower_bound, upper_bound, annual_rate) VALUES
+    (0, 250000, 0.30),
+    (250000, 1000000, 0.15),
+    (1000000, NULL, 0.00);
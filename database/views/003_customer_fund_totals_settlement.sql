@@ -0,0 +1,29 @@
+-- Note: Customer totals distinguish settled money from money still moving through the lifecycle
+DROP MATERIALIZED VIEW IF EXISTS customer_fund_totals;
+
+CREATE MATERIALIZED VIEW customer_fund_totals AS
+SELECT 
+    rc.id as customer_id,
+    rc.first_name,
+    rc.last_name,
+    rc.email,
+    f.id as fund_id,
+    f.name as fund_name,
+    SUM(CASE WHEN i.type = 'withdrawal' THEN -i.amount ELSE i.amount END) as total_investment,
+    COALESCE(SUM(CASE WHEN i.type = 'withdrawal' THEN -i.amount ELSE i.amount END)
+        FILTER (WHERE i.status = 'settled'), 0) as settled_investment,
+    COALESCE(SUM(CASE WHEN i.type = 'withdrawal' THEN -i.amount ELSE i.amount END)
+        FILTER (WHERE i.status IN ('pending', 'cash_received', 'units_allocated')), 0) as pending_investment
+FROM retail_customers rc
+JOIN investments i ON rc.id = i.customer_id
+JOIN funds f ON f.id = i.fund_id
+WHERE i.status NOT IN ('held', 'cancelled', 'failed')
+GROUP BY 
+    rc.id, 
+    rc.first_name, 
+    rc.last_name, 
+    rc.email,
+    f.id,
+    f.name;
+
+CREATE UNIQUE INDEX idx_customer_fund_totals ON customer_fund_totals(customer_id, fund_id);
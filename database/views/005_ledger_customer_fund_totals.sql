@@ -0,0 +1,17 @@
+-- Note: Customer fund totals derived from ledger balances. Orders still pending have not moved any
+-- money so they are not in the ledger. Otherwise this matches customer_fund_totals:
+--   settled = units at cost - amounts still to settle with the fund manager
+--   pending = deposits received but not invested + amounts still to settle with the fund manager
+CREATE VIEW ledger_customer_fund_totals AS
+SELECT
+    a.customer_id,
+    a.fund_id,
+    -SUM(p.amount) FILTER (WHERE a.account_type IN ('customer_units', 'customer_cash')) AS total_investment,
+    COALESCE(-SUM(p.amount) FILTER (WHERE a.account_type = 'customer_units'), 0)
+        + COALESCE(SUM(p.amount) FILTER (WHERE a.account_type = 'fund_manager_settlement'), 0) AS settled_investment,
+    COALESCE(-SUM(p.amount) FILTER (WHERE a.account_type IN ('customer_cash', 'fund_manager_settlement')), 0) AS pending_investment,
+    COALESCE(-SUM(p.units) FILTER (WHERE a.account_type = 'customer_units'), 0) AS units
+FROM ledger_accounts a
+JOIN ledger_postings p ON p.account_id = a.id
+WHERE a.customer_id IS NOT NULL AND a.fund_id IS NOT NULL
+GROUP BY a.customer_id, a.fund_id;
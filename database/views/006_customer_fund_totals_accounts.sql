@@ -0,0 +1,36 @@
+-- Note: Totals are kept per account so a customer's ISA and Lifetime ISA in the same fund are
+-- reported separately. Customer level totals add up the customer's accounts
+DROP MATERIALIZED VIEW IF EXISTS customer_fund_totals;
+
+CREATE MATERIALIZED VIEW customer_fund_totals AS
+SELECT 
+    a.id as account_id,
+    a.product,
+    rc.id as customer_id,
+    rc.first_name,
+    rc.last_name,
+    rc.email,
+    f.id as fund_id,
+    f.name as fund_name,
+    SUM(CASE WHEN i.type = 'deposit' THEN i.amount ELSE -i.amount END) as total_investment,
+    COALESCE(SUM(CASE WHEN i.type = 'deposit' THEN i.amount ELSE -i.amount END)
+        FILTER (WHERE i.status = 'settled'), 0) as settled_investment,
+    COALESCE(SUM(CASE WHEN i.type = 'deposit' THEN i.amount ELSE -i.amount END)
+        FILTER (WHERE i.status IN ('pending', 'cash_received', 'units_allocated')), 0) as pending_investment
+FROM accounts a
+JOIN retail_customers rc ON rc.id = a.customer_id
+JOIN investments i ON a.id = i.account_id
+JOIN funds f ON f.id = i.fund_id
+WHERE i.status NOT IN ('held', 'cancelled', 'failed')
+GROUP BY 
+    a.id,
+    a.product,
+    rc.id, 
+    rc.first_name, 
+    rc.last_name, 
+    rc.email,
+    f.id,
+    f.name;
+
+CREATE UNIQUE INDEX idx_customer_fund_totals ON customer_fund_totals(account_id, fund_id);
+CREATE INDEX idx_customer_fund_totals_customer ON customer_fund_totals(customer_id, fund_id);
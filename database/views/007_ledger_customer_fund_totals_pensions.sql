@@ -0,0 +1,15 @@
+-- Note: Ledger totals now include workplace pensions. customer_id is the holder, the retail
+-- customer or for a pension the employee, so the totals still line up with the investments
+CREATE OR REPLACE VIEW ledger_customer_fund_totals AS
+SELECT
+    COALESCE(a.customer_id, a.employee_id) AS customer_id,
+    a.fund_id,
+    -SUM(p.amount) FILTER (WHERE a.account_type IN ('customer_units', 'customer_cash')) AS total_investment,
+    COALESCE(-SUM(p.amount) FILTER (WHERE a.account_type = 'customer_units'), 0)
+        + COALESCE(SUM(p.amount) FILTER (WHERE a.account_type = 'fund_manager_settlement'), 0) AS settled_investment,
+    COALESCE(-SUM(p.amount) FILTER (WHERE a.account_type IN ('customer_cash', 'fund_manager_settlement')), 0) AS pending_investment,
+    COALESCE(-SUM(p.units) FILTER (WHERE a.account_type = 'customer_units'), 0) AS units
+FROM ledger_accounts a
+JOIN ledger_postings p ON p.account_id = a.id
+WHERE (a.customer_id IS NOT NULL OR a.employee_id IS NOT NULL) AND a.fund_id IS NOT NULL
+GROUP BY COALESCE(a.customer_id, a.employee_id), a.fund_id;